@@ -74,6 +74,8 @@ type Join struct {
 	Using []*ColumnName
 	// NaturalJoin represents join is natural join
 	NaturalJoin bool
+	// ExplicitCross represents join is written as CROSS JOIN without join condition, the cartesian product is intended.
+	ExplicitCross bool
 }
 
 // Accept implements Node Accept interface.
@@ -117,6 +119,8 @@ type TableName struct {
 	TableInfo *model.TableInfo
 
 	IndexHints []*IndexHint
+	// AsOf is the AS OF TIMESTAMP clause for stale read, it is nil if the table is read at the current time.
+	AsOf *AsOfClause
 }
 
 // IndexHintType is the type for index hint use, ignore or force.
@@ -154,6 +158,36 @@ func (n *TableName) Accept(v Visitor) (Node, bool) {
 		return v.Leave(newNode)
 	}
 	n = newNode.(*TableName)
+	// The TableName of a ReferenceDef may be nil.
+	if n != nil && n.AsOf != nil {
+		node, ok := n.AsOf.Accept(v)
+		if !ok {
+			return n, false
+		}
+		n.AsOf = node.(*AsOfClause)
+	}
+	return v.Leave(n)
+}
+
+// AsOfClause is the AS OF TIMESTAMP clause of a table name, it reads the table as of the timestamp.
+type AsOfClause struct {
+	node
+
+	TsExpr ExprNode
+}
+
+// Accept implements Node Accept interface.
+func (n *AsOfClause) Accept(v Visitor) (Node, bool) {
+	newNode, skipChildren := v.Enter(n)
+	if skipChildren {
+		return v.Leave(newNode)
+	}
+	n = newNode.(*AsOfClause)
+	node, ok := n.TsExpr.Accept(v)
+	if !ok {
+		return n, false
+	}
+	n.TsExpr = node.(ExprNode)
 	return v.Leave(n)
 }
 
@@ -484,6 +518,8 @@ type SelectStmt struct {
 	LockTp SelectLockType
 	// TableHints represents the level Optimizer Hint
 	TableHints []*TableOptimizerHint
+	// IsTable means the statement is written as `TABLE t`, which selects all the columns of t.
+	IsTable bool
 }
 
 // Accept implements Node Accept interface.
@@ -909,6 +945,9 @@ type Limit struct {
 
 	Count  ExprNode
 	Offset ExprNode
+	// WithTies is true for FETCH FIRST ... WITH TIES, the rows with the same ORDER BY values
+	// as the last row of the limit are returned too.
+	WithTies bool
 }
 
 // Accept implements Node Accept interface.
@@ -508,7 +508,7 @@ type PatternLikeExpr struct {
 	Pattern ExprNode
 	// Not is true, the expression is "not like".
 	Not bool
-
+	// Escape is the escape character of the pattern, 0 means there is no escape character.
 	Escape byte
 
 	PatChars []byte
@@ -583,6 +583,8 @@ func (n *ParenthesesExpr) Accept(v Visitor) (Node, bool) {
 // PositionExpr is the expression for order by and group by position.
 // MySQL use position expression started from 1, it looks a little confused inner.
 // maybe later we will use 0 at first.
+// Only a literal integer is a position, a placeholder of a prepared statement is a constant expression even if an
+// integer is bound to it, e.g. `ORDER BY ?` doesn't change the order.
 type PositionExpr struct {
 	exprNode
 	// N is the position, started from 1 now.
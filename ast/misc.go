@@ -706,7 +706,15 @@ type TableOptimizerHint struct {
 	// Table hints has no schema info
 	// It allows only table name or alias (if table has an alias)
 	HintName model.CIStr
-	Tables   []model.CIStr
+	// QBName is the query block the hint is specified for, e.g. qb1 of `tidb_inlj(@qb1 t)`, empty means the query block
+	// the hint is in. For the QB_NAME hint, it is the name given to the query block the hint is in.
+	QBName model.CIStr
+	Tables []model.CIStr
+	// Column is the column of the table whose NDV is given by the TIDB_CARD hint, e.g. c of `tidb_card(t.c 100)`, empty
+	// means the hint gives the row count of the table.
+	Column model.CIStr
+	// Cardinality is the row count or the column NDV given by the TIDB_CARD hint.
+	Cardinality uint64
 }
 
 // Accept implements Node Accept interface.
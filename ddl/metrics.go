@@ -48,10 +48,60 @@ var (
 			Help:      "Bucketed histogram of processing time (s) of batch handle data",
 			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 20),
 		}, []string{"handle_data_type"})
+
+	// handle owner reacquire result.
+	ownerRegained           = "regained"
+	ownerLostNotRegained    = "lost_not_regained"
+	ownerReacquireHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "tidb",
+			Subsystem: "ddl",
+			Name:      "owner_reacquire_duration_seconds",
+			Help:      "Bucketed histogram of the time (s) from losing the ownership to regaining it or finding another owner",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 20),
+		}, []string{"key", "result"})
+
+	ownerStepDownCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "tidb",
+			Subsystem: "ddl",
+			Name:      "owner_step_down_total",
+			Help:      "Counter of the owner stepping down.",
+		}, []string{"key", "reason"})
+
+	ownerCampaignPanicCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "tidb",
+			Subsystem: "ddl",
+			Name:      "owner_campaign_panic_total",
+			Help:      "Counter of the owner campaign loop panics.",
+		}, []string{"key"})
+
+	ownerClockSkewCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "tidb",
+			Subsystem: "ddl",
+			Name:      "owner_clock_skew_total",
+			Help:      "Counter of the owner campaign encountering the lease not found error, which is probably caused by the clock skew.",
+		}, []string{"key"})
+
+	ownerWatchProcessLagHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "tidb",
+			Subsystem: "ddl",
+			Name:      "owner_watch_process_lag_seconds",
+			Help:      "Bucketed histogram of the time (s) from receiving the deletion of the owner key from the local watcher to processing it, the delay before it's received isn't included",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 20),
+		}, []string{"key"})
 )
 
 func init() {
 	prometheus.MustRegister(jobsGauge)
 	prometheus.MustRegister(handleJobHistogram)
 	prometheus.MustRegister(batchHandleDataHistogram)
+	prometheus.MustRegister(ownerReacquireHistogram)
+	prometheus.MustRegister(ownerStepDownCounter)
+	prometheus.MustRegister(ownerCampaignPanicCounter)
+	prometheus.MustRegister(ownerClockSkewCounter)
+	prometheus.MustRegister(ownerWatchProcessLagHistogram)
 }
@@ -30,16 +30,20 @@ var _ SchemaSyncer = &mockSchemaSyncer{}
 // It's used for local store and testing.
 // So this worker will always be the ddl owner and background owner.
 type mockOwnerManager struct {
-	ddlOwner int32
-	ddlID    string // id is the ID of DDL.
-	cancel   goctx.CancelFunc
+	ddlOwner  int32
+	ddlID     string // id is the ID of DDL.
+	cancel    goctx.CancelFunc
+	notifier  *ownerNotifier
+	keyOwners *keyOwners
 }
 
 // NewMockOwnerManager creates a new mock OwnerManager.
 func NewMockOwnerManager(id string, cancel goctx.CancelFunc) OwnerManager {
 	return &mockOwnerManager{
-		ddlID:  id,
-		cancel: cancel,
+		ddlID:     id,
+		cancel:    cancel,
+		notifier:  newOwnerNotifier(),
+		keyOwners: newKeyOwners(),
 	}
 }
 
@@ -55,35 +59,116 @@ func (m *mockOwnerManager) IsOwner() bool {
 
 // SetOwner implements mockOwnerManager.SetOwner interface.
 func (m *mockOwnerManager) SetOwner(isOwner bool) {
+	var val int32
 	if isOwner {
-		atomic.StoreInt32(&m.ddlOwner, 1)
-	} else {
-		atomic.StoreInt32(&m.ddlOwner, 0)
+		val = 1
 	}
+	if atomic.SwapInt32(&m.ddlOwner, val) != val {
+		m.notifier.notifyOwnerChange(newOwnerChange(m.ddlID, isOwner))
+	}
+}
+
+// RegisterOwnerChangeCh implements mockOwnerManager.RegisterOwnerChangeCh interface.
+func (m *mockOwnerManager) RegisterOwnerChangeCh(ch chan<- OwnerChange) {
+	m.notifier.register(ch)
+}
+
+// StopCampaign implements mockOwnerManager.StopCampaign interface.
+func (m *mockOwnerManager) StopCampaign() {
+	m.SetOwner(false)
 }
 
 // Cancel implements mockOwnerManager.Cancel interface.
 func (m *mockOwnerManager) Cancel() {
 	m.cancel()
+	m.notifier.notifyCancel()
+}
+
+// WaitUntilOwner implements mockOwnerManager.WaitUntilOwner interface.
+func (m *mockOwnerManager) WaitUntilOwner(ctx goctx.Context) error {
+	return m.notifier.wait(ctx, nil)
+}
+
+// WaitUntilOwnerIfNot implements mockOwnerManager.WaitUntilOwnerIfNot interface.
+func (m *mockOwnerManager) WaitUntilOwnerIfNot(ctx goctx.Context) error {
+	return m.notifier.wait(ctx, m.IsOwner)
 }
 
 // GetOwnerID implements OwnerManager.GetOwnerID interface.
 func (m *mockOwnerManager) GetOwnerID(ctx goctx.Context, key string) (string, error) {
-	if key != DDLOwnerKey {
-		return "", errors.New("invalid owner key")
-	}
-	if m.IsOwner() {
+	if m.IsKeyOwner(key) {
 		return m.ID(), nil
 	}
 	return "", errors.New("no owner")
 }
 
+// GetOwnerVersion implements OwnerManager.GetOwnerVersion interface.
+func (m *mockOwnerManager) GetOwnerVersion(ctx goctx.Context, key string) (string, int64, error) {
+	ownerID, err := m.GetOwnerID(ctx, key)
+	if err != nil {
+		return "", 0, errors.Trace(err)
+	}
+	return ownerID, ownerVersion, nil
+}
+
+// CampaignLoopExited implements mockOwnerManager.CampaignLoopExited interface.
+func (m *mockOwnerManager) CampaignLoopExited(key string) (bool, error) {
+	return false, nil
+}
+
+// SetPriority implements mockOwnerManager.SetPriority interface.
+func (m *mockOwnerManager) SetPriority(_ int64) {}
+
+// HealthStatus implements mockOwnerManager.HealthStatus interface.
+func (m *mockOwnerManager) HealthStatus() (bool, string) {
+	return true, "mock owner manager"
+}
+
+// CampaignOwnerKey implements mockOwnerManager.CampaignOwnerKey interface.
+func (m *mockOwnerManager) CampaignOwnerKey(_ goctx.Context, key string) error {
+	if key == DDLOwnerKey {
+		return errors.Errorf("use CampaignOwner to campaign the DDL owner")
+	}
+	if err := checkOwnerKey(key); err != nil {
+		return errors.Trace(err)
+	}
+	m.keyOwners.setOwner(key, true)
+	return nil
+}
+
+// IsKeyOwner implements mockOwnerManager.IsKeyOwner interface.
+func (m *mockOwnerManager) IsKeyOwner(key string) bool {
+	if key == DDLOwnerKey {
+		return m.IsOwner()
+	}
+	return m.keyOwners.isOwner(key)
+}
+
 // CampaignOwner implements mockOwnerManager.CampaignOwner interface.
 func (m *mockOwnerManager) CampaignOwner(_ goctx.Context) error {
 	m.SetOwner(true)
 	return nil
 }
 
+// DryRunCampaign implements mockOwnerManager.DryRunCampaign interface.
+func (m *mockOwnerManager) DryRunCampaign(_ goctx.Context, key string) (*CampaignPosition, error) {
+	if err := checkOwnerKey(key); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if m.IsKeyOwner(key) {
+		return &CampaignPosition{LeaderID: m.ddlID, Campaigners: []string{m.ddlID}, Position: 0}, nil
+	}
+	return &CampaignPosition{Campaigners: []string{}, Position: 0}, nil
+}
+
+// DoIfOwner implements mockOwnerManager.DoIfOwner interface.
+func (m *mockOwnerManager) DoIfOwner(ctx goctx.Context, fn func(ctx goctx.Context) error) error {
+	if !m.IsOwner() {
+		return errors.Trace(errNotOwner)
+	}
+	return errors.Trace(fn(ctx))
+}
+
 const mockCheckVersInterval = 2 * time.Millisecond
 
 type mockSchemaSyncer struct {
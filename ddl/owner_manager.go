@@ -17,7 +17,11 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -41,10 +45,65 @@ type OwnerManager interface {
 	SetOwner(isOwner bool)
 	// GetOwnerID gets the owner ID.
 	GetOwnerID(ctx goctx.Context, ownerKey string) (string, error)
+	// GetOwnerVersion gets the owner ID and the owner logic version of the owner.
+	GetOwnerVersion(ctx goctx.Context, ownerKey string) (string, int64, error)
 	// CampaignOwner campaigns the DDL owner.
 	CampaignOwner(ctx goctx.Context) error
 	// Cancel cancels this etcd ownerManager campaign.
 	Cancel()
+	// WaitUntilOwner blocks until the ownerManager becomes the DDL owner next time,
+	// or the ctx is done, or the ownerManager is cancelled.
+	WaitUntilOwner(ctx goctx.Context) error
+	// WaitUntilOwnerIfNot is like WaitUntilOwner, but it returns immediately if the ownerManager is already the DDL owner.
+	WaitUntilOwnerIfNot(ctx goctx.Context) error
+	// CampaignOwnerKey campaigns the owner of the key other than DDLOwnerKey.
+	// The election of every key is independent of the others.
+	CampaignOwnerKey(ctx goctx.Context, key string) error
+	// IsKeyOwner returns whether the ownerManager is the owner of the key.
+	IsKeyOwner(key string) bool
+	// CampaignLoopExited returns whether the campaign of the key has exited permanently and the reason.
+	// Once it exits, the ownerManager never becomes the owner of the key again until it campaigns the key again.
+	CampaignLoopExited(key string) (exited bool, reason error)
+	// SetPriority sets the priority of the ownerManager. If a node with a higher priority is campaigning,
+	// the owner with a lower priority yields the ownership, so the owner converges on the preferred node.
+	// Nodes with the same priority use the first-come election. The default priority is 0.
+	SetPriority(priority int64)
+	// HealthStatus returns whether the ownerManager is healthy and the detail of its status.
+	// It doesn't send any request to etcd, so it's cheap to call.
+	HealthStatus() (healthy bool, detail string)
+	// StopCampaign stops campaigning the DDL owner, it resigns the ownership and closes the session.
+	// CampaignOwner can be called again to restart campaigning with a new session.
+	StopCampaign()
+	// RegisterOwnerChangeCh registers the channel that receives the new DDL ownership when it changes.
+	// The change is dropped if the channel is full.
+	RegisterOwnerChangeCh(ch chan<- OwnerChange)
+	// DryRunCampaign reports the current campaigners of the key and the position this ownerManager would be at
+	// if it campaigned now. It's read-only, it neither campaigns nor acquires a lease.
+	DryRunCampaign(ctx goctx.Context, key string) (*CampaignPosition, error)
+	// DoIfOwner runs fn if the ownerManager is the DDL owner, and cancels fn's ctx if the ownership is lost
+	// before fn returns. It returns errNotOwner if the ownerManager isn't the owner or the ownership is lost,
+	// otherwise it returns the error of fn.
+	DoIfOwner(ctx goctx.Context, fn func(ctx goctx.Context) error) error
+}
+
+// CampaignPosition is the election position reported by DryRunCampaign.
+type CampaignPosition struct {
+	// LeaderID is the ID of the current owner, it's empty if there is no owner.
+	LeaderID string
+	// Campaigners are the IDs of the current campaigners in the election order, the first one is the leader.
+	Campaigners []string
+	// Position is the index of the ownerManager in Campaigners if it's campaigning,
+	// otherwise it's len(Campaigners), which is where a new campaigner is queued. Zero means it would be the owner.
+	Position int
+}
+
+// OwnerChange is the notification of the DDL ownership change.
+type OwnerChange struct {
+	// IsOwner is whether the ownerManager is the DDL owner.
+	IsOwner bool
+	// OwnerID is the ID of the new DDL owner, it's the ownerManager's ID if IsOwner is true.
+	// It's empty if the new owner is unknown yet, e.g. the ownerManager just steps down.
+	OwnerID string
 }
 
 const (
@@ -52,23 +111,387 @@ const (
 	DDLOwnerKey               = "/tidb/ddl/fg/owner"
 	newSessionDefaultRetryCnt = 3
 	newSessionRetryUnlimited  = math.MaxInt64
+	// ownerMismatchThreshold is the number of consecutive campaigns whose leader doesn't match this manager,
+	// after which the manager ID is suspected to be duplicated.
+	ownerMismatchThreshold = 3
+	// ownerMismatchBackoffUnit and ownerMismatchMaxBackoff bound the waiting time before campaigning again
+	// when the leader doesn't match this manager.
+	ownerMismatchBackoffUnit = 200 * time.Millisecond
+	ownerMismatchMaxBackoff  = 5 * time.Second
+	// ownerPriorityCheckInterval is the interval of the owner checking whether there is a campaigner with a higher priority.
+	ownerPriorityCheckInterval = 5 * time.Second
+	// ownerPriorityMinHoldTime is the minimum time an owner holds the ownership before yielding it to a higher priority campaigner,
+	// it's used to guard against the ownership thrashing.
+	ownerPriorityMinHoldTime = 30 * time.Second
+	// clockSkewThreshold is the number of consecutive lease-not-found errors,
+	// after which the clocks of the TiDB and etcd servers are suspected to be skewed.
+	clockSkewThreshold = 3
+	// clockSkewBackoffUnit and clockSkewMaxBackoff bound the waiting time before creating a new session
+	// when the lease isn't found.
+	clockSkewBackoffUnit = 500 * time.Millisecond
+	clockSkewMaxBackoff  = 10 * time.Second
+	// ownerVersionBackoff is the waiting time before campaigning again after refusing the ownership,
+	// because there is a campaigner with a higher owner logic version.
+	ownerVersionBackoff = 5 * time.Second
+	// ownerWatchChanSize is the buffer size of the owner key's watch channel with the receiving time.
+	ownerWatchChanSize = 16
+	// ownerWatchProcessLagWarnThreshold is the lag of processing the owner key's deletion after receiving it, above which
+	// a warning is logged.
+	ownerWatchProcessLagWarnThreshold = time.Second
+	// ownerMaxRewatchCnt is the maximum number of consecutive re-establishing the owner key's watch
+	// after it's canceled, the owner steps down if the watch keeps being canceled.
+	ownerMaxRewatchCnt = 3
+	// campaignLoopMaxRestarts is the maximum number of restarting the campaign loop after it panics.
+	campaignLoopMaxRestarts = 3
+	// campaignLoopRestartBackoff is the waiting time before restarting the campaign loop after it panics.
+	campaignLoopRestartBackoff = time.Second
 )
 
+var (
+	errOwnerInfoNotMatch = errors.New("ownerInfoNotMatch")
+	errDuplicateOwnerID  = errors.New("duplicate ownerManager ID")
+	errAlreadyCampaign   = errors.New("the key is already being campaigned")
+)
+
+// ownerVersion is the version of the owner logic. Bump it when the owner logic is incompatible with the old one,
+// then the nodes of the old version refuse the ownership during the rolling upgrade.
+const ownerVersion int64 = 1
+
+// ownerVersionSep separates the manager ID and the owner version in the campaign value.
+const ownerVersionSep = "@"
+
+// encodeOwnerValue encodes the manager ID and the owner version into the campaign value.
+func encodeOwnerValue(id string, version int64) string {
+	return id + ownerVersionSep + strconv.FormatInt(version, 10)
+}
+
+// decodeOwnerValue decodes the manager ID and the owner version from the campaign value.
+// The value without the version is campaigned by the node of the old release, and its version is 0.
+func decodeOwnerValue(val string) (string, int64) {
+	idx := strings.LastIndex(val, ownerVersionSep)
+	if idx < 0 {
+		return val, 0
+	}
+	version, err := strconv.ParseInt(val[idx+len(ownerVersionSep):], 10, 64)
+	if err != nil {
+		return val, 0
+	}
+	return val[:idx], version
+}
+
+// findHigherVersion finds a campaigner whose owner version is higher than the version.
+func findHigherVersion(version int64, kvs []*mvccpb.KeyValue) (string, bool) {
+	for _, kv := range kvs {
+		id, v := decodeOwnerValue(string(kv.Value))
+		if v > version {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// ownerKeyPrefix is the namespace of the owner keys.
+const ownerKeyPrefix = "/tidb/"
+
+// SkipOwnerKeyCheck skips checking whether the owner key is in the ownerKeyPrefix namespace.
+// It's exported for testing with custom keys.
+var SkipOwnerKeyCheck = false
+
+// checkOwnerKey checks whether the owner key is well-formed,
+// so that a typo doesn't make two roles campaign on the same etcd path.
+func checkOwnerKey(key string) error {
+	if len(key) == 0 {
+		return errors.New("owner key is empty")
+	}
+	if SkipOwnerKeyCheck {
+		return nil
+	}
+	if !strings.HasPrefix(key, ownerKeyPrefix) || len(key) == len(ownerKeyPrefix) {
+		return errors.Errorf("owner key %q isn't in the %q namespace", key, ownerKeyPrefix)
+	}
+	if strings.HasSuffix(key, "/") {
+		return errors.Errorf("owner key %q mustn't end with \"/\"", key)
+	}
+	return nil
+}
+
+// ownerNotifier notifies the listeners and the waiters when the ownership changes.
+type ownerNotifier struct {
+	mu sync.Mutex
+	// becomeOwnerCh is closed and replaced every time the manager becomes the owner.
+	becomeOwnerCh chan struct{}
+	listeners     []chan<- OwnerChange
+	// ownerID is the owner ID of the last notification.
+	ownerID string
+	// cancelCh is closed when the manager is cancelled.
+	cancelCh   chan struct{}
+	cancelOnce sync.Once
+}
+
+func newOwnerNotifier() *ownerNotifier {
+	return &ownerNotifier{
+		becomeOwnerCh: make(chan struct{}),
+		cancelCh:      make(chan struct{}),
+	}
+}
+
+// notifyOwnerChange notifies the listeners of the new ownership,
+// and wakes up all the waiters of the current term if the manager becomes the owner.
+func (n *ownerNotifier) notifyOwnerChange(change OwnerChange) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if change.IsOwner {
+		close(n.becomeOwnerCh)
+		n.becomeOwnerCh = make(chan struct{})
+	}
+	n.send(change)
+}
+
+// notifyOtherOwner notifies the listeners that another manager becomes the owner.
+// It's ignored if the owner isn't changed since the last notification.
+func (n *ownerNotifier) notifyOtherOwner(ownerID string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if ownerID == n.ownerID {
+		return
+	}
+	n.send(OwnerChange{IsOwner: false, OwnerID: ownerID})
+}
+
+// send sends the change to the listeners, it must be called with the lock held.
+func (n *ownerNotifier) send(change OwnerChange) {
+	n.ownerID = change.OwnerID
+	for _, ch := range n.listeners {
+		select {
+		case ch <- change:
+		default:
+			log.Warnf("[ddl] [owner] notify the ownership %v failed, the channel is full", change)
+		}
+	}
+}
+
+func (n *ownerNotifier) register(ch chan<- OwnerChange) {
+	n.mu.Lock()
+	n.listeners = append(n.listeners, ch)
+	n.mu.Unlock()
+}
+
+func (n *ownerNotifier) notifyCancel() {
+	n.cancelOnce.Do(func() { close(n.cancelCh) })
+}
+
+func (n *ownerNotifier) getBecomeOwnerCh() <-chan struct{} {
+	n.mu.Lock()
+	ch := n.becomeOwnerCh
+	n.mu.Unlock()
+	return ch
+}
+
+// wait waits until the manager becomes the owner next time. If isOwner isn't nil and it returns true, wait returns immediately.
+func (n *ownerNotifier) wait(ctx goctx.Context, isOwner func() bool) error {
+	// Get the channel before checking isOwner, so we won't miss the notification between them.
+	ch := n.getBecomeOwnerCh()
+	if isOwner != nil && isOwner() {
+		return nil
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return errors.Trace(ctx.Err())
+	case <-n.cancelCh:
+		return errors.Trace(goctx.Canceled)
+	}
+}
+
+// keyOwners records the ownership of the keys other than DDLOwnerKey.
+type keyOwners struct {
+	mu     sync.RWMutex
+	owners map[string]bool
+}
+
+func newKeyOwners() *keyOwners {
+	return &keyOwners{owners: make(map[string]bool)}
+}
+
+func (k *keyOwners) isOwner(key string) bool {
+	k.mu.RLock()
+	isOwner := k.owners[key]
+	k.mu.RUnlock()
+	return isOwner
+}
+
+func (k *keyOwners) setOwner(key string, isOwner bool) {
+	k.mu.Lock()
+	k.owners[key] = isOwner
+	k.mu.Unlock()
+}
+
+// campaignStatus is the status of a campaign loop.
+type campaignStatus struct {
+	running bool
+	session ownerSession
+	// ownerKey is the election key of the session when the manager is the owner, otherwise it's empty.
+	ownerKey string
+	lastErr  error
+	// exited is set when the campaign loop exits or fails to start, and exitReason is the reason.
+	exited     bool
+	exitReason error
+}
+
+// campaignStatuses records the status of the campaign loop of every key, it's used for the health check.
+type campaignStatuses struct {
+	mu       sync.RWMutex
+	statuses map[string]*campaignStatus
+}
+
+func newCampaignStatuses() *campaignStatuses {
+	return &campaignStatuses{statuses: make(map[string]*campaignStatus)}
+}
+
+func (cs *campaignStatuses) update(key string, fn func(s *campaignStatus)) {
+	cs.mu.Lock()
+	s, ok := cs.statuses[key]
+	if !ok {
+		s = &campaignStatus{}
+		cs.statuses[key] = s
+	}
+	fn(s)
+	cs.mu.Unlock()
+}
+
+// get returns the copy of the status of the key, and false if the key has no status.
+func (cs *campaignStatuses) get(key string) (campaignStatus, bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	s, ok := cs.statuses[key]
+	if !ok {
+		return campaignStatus{}, false
+	}
+	return *s, true
+}
+
+// snapshot returns the copies of all the statuses ordered by the key.
+func (cs *campaignStatuses) snapshot() ([]string, []campaignStatus) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	keys := make([]string, 0, len(cs.statuses))
+	for key := range cs.statuses {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	statuses := make([]campaignStatus, 0, len(keys))
+	for _, key := range keys {
+		statuses = append(statuses, *cs.statuses[key])
+	}
+	return keys, statuses
+}
+
 // ownerManager represents the structure which is used for electing owner.
 type ownerManager struct {
-	ddlOwner int32
-	ddlID    string // id is the ID of DDL.
-	etcdCli  *clientv3.Client
-	cancel   goctx.CancelFunc
+	ddlOwner  int32
+	ddlID     string // id is the ID of DDL.
+	version   int64  // version is the owner logic version, it's replaced in tests.
+	etcdCli   *clientv3.Client
+	cancel    goctx.CancelFunc
+	notifier  *ownerNotifier
+	keyOwners *keyOwners
+	statuses  *campaignStatuses
+	priority  int64
+	// clock, sessionFactory, watcher and lease are replaced in tests.
+	clock          ownerClock
+	sessionFactory sessionFactory
+	// watcher and lease are the ones of etcdCli by default, they are used to watch the owner key and check its lease.
+	watcher clientv3.Watcher
+	lease   clientv3.Lease
+	// etcdCtx is the ctx of etcdCli, it's done when etcdCli is closed.
+	etcdCtx goctx.Context
+
+	campaignsMu sync.Mutex
+	// campaigns are the running campaign loops of the keys.
+	campaigns map[string]*campaignHandle
+}
+
+// campaignHandle is used to stop a campaign loop.
+type campaignHandle struct {
+	cancel goctx.CancelFunc
+	// done is closed when the campaign loop exits.
+	done chan struct{}
+}
+
+// ownerClock is the clock used by ownerManager, it's replaced in tests.
+type ownerClock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) ownerTicker
+}
+
+// ownerTicker is the ticker created by ownerClock.
+type ownerTicker interface {
+	Chan() <-chan time.Time
+	Stop()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTicker(d time.Duration) ownerTicker  { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) Chan() <-chan time.Time { return t.C }
+
+// ownerSession is the etcd session that holds the ownership, it's replaced in tests.
+// *concurrency.Session implements it.
+type ownerSession interface {
+	Done() <-chan struct{}
+	Lease() clientv3.LeaseID
+	Close() error
+}
+
+// ownerElection is the election of an owner key, it's replaced in tests.
+// *concurrency.Election implements it.
+type ownerElection interface {
+	Key() string
+	Leader(ctx goctx.Context) (*clientv3.GetResponse, error)
+}
+
+// sessionFactory creates an etcd session, it's replaced in tests.
+type sessionFactory func(ctx goctx.Context, etcdCli *clientv3.Client, ttl int) (*concurrency.Session, error)
+
+func newEtcdSession(ctx goctx.Context, etcdCli *clientv3.Client, ttl int) (*concurrency.Session, error) {
+	return concurrency.NewSession(etcdCli, concurrency.WithTTL(ttl), concurrency.WithContext(ctx))
 }
 
 // NewOwnerManager creates a new OwnerManager.
 func NewOwnerManager(etcdCli *clientv3.Client, id string, cancel goctx.CancelFunc) OwnerManager {
-	return &ownerManager{
-		etcdCli: etcdCli,
-		ddlID:   id,
-		cancel:  cancel,
+	return newOwnerManager(etcdCli, id, cancel, realClock{}, newEtcdSession)
+}
+
+// newOwnerManager creates a new ownerManager with the clock and the session factory.
+func newOwnerManager(etcdCli *clientv3.Client, id string, cancel goctx.CancelFunc, clock ownerClock,
+	factory sessionFactory) *ownerManager {
+	m := &ownerManager{
+		etcdCli:        etcdCli,
+		ddlID:          id,
+		version:        ownerVersion,
+		cancel:         cancel,
+		notifier:       newOwnerNotifier(),
+		keyOwners:      newKeyOwners(),
+		statuses:       newCampaignStatuses(),
+		clock:          clock,
+		sessionFactory: factory,
+		campaigns:      make(map[string]*campaignHandle),
 	}
+	if etcdCli != nil {
+		m.watcher, m.lease, m.etcdCtx = etcdCli.Watcher, etcdCli.Lease, etcdCli.Ctx()
+	}
+	return m
 }
 
 // ID implements OwnerManager.ID interface.
@@ -83,22 +506,79 @@ func (m *ownerManager) IsOwner() bool {
 
 // SetOwner implements OwnerManager.SetOwner interface.
 func (m *ownerManager) SetOwner(isOwner bool) {
+	var val int32
 	if isOwner {
-		atomic.StoreInt32(&m.ddlOwner, 1)
-	} else {
-		atomic.StoreInt32(&m.ddlOwner, 0)
+		val = 1
+	}
+	if atomic.SwapInt32(&m.ddlOwner, val) != val {
+		m.notifier.notifyOwnerChange(newOwnerChange(m.ddlID, isOwner))
 	}
 }
 
+// newOwnerChange creates the notification of the manager's own ownership change.
+func newOwnerChange(id string, isOwner bool) OwnerChange {
+	change := OwnerChange{IsOwner: isOwner}
+	if isOwner {
+		change.OwnerID = id
+	}
+	return change
+}
+
+// RegisterOwnerChangeCh implements OwnerManager.RegisterOwnerChangeCh interface.
+func (m *ownerManager) RegisterOwnerChangeCh(ch chan<- OwnerChange) {
+	m.notifier.register(ch)
+}
+
 // Cancel implements OwnerManager.Cancel interface.
+// It only stops the campaign loops of this manager, the etcd client may be shared with other managers,
+// so it isn't closed here.
 func (m *ownerManager) Cancel() {
 	m.cancel()
+	m.cancelCampaigns()
+	m.notifier.notifyCancel()
 }
 
-// ManagerSessionTTL is the etcd session's TTL in seconds. It's exported for testing.
-var ManagerSessionTTL = 60
+// cancelCampaigns cancels all the campaign loops of this manager without waiting for them to exit.
+// The loops may be started with a ctx that isn't derived from the manager's cancel function,
+// so they are cancelled by their own handles.
+func (m *ownerManager) cancelCampaigns() {
+	m.campaignsMu.Lock()
+	for _, h := range m.campaigns {
+		h.cancel()
+	}
+	m.campaignsMu.Unlock()
+}
+
+// WaitUntilOwner implements OwnerManager.WaitUntilOwner interface.
+func (m *ownerManager) WaitUntilOwner(ctx goctx.Context) error {
+	return m.notifier.wait(ctx, nil)
+}
+
+// WaitUntilOwnerIfNot implements OwnerManager.WaitUntilOwnerIfNot interface.
+func (m *ownerManager) WaitUntilOwnerIfNot(ctx goctx.Context) error {
+	return m.notifier.wait(ctx, m.IsOwner)
+}
+
+// managerSessionTTL is the etcd session's TTL in seconds.
+// It's read atomically every time a session is created, so use SetManagerSessionTTL to change it.
+var managerSessionTTL int64 = 60
+
+// SetManagerSessionTTL sets the etcd session's TTL in seconds.
+// The new TTL takes effect the next time the ownerManager creates a session.
+func SetManagerSessionTTL(ttl int) error {
+	if ttl <= 0 {
+		return errors.Errorf("invalid manager session TTL %d", ttl)
+	}
+	atomic.StoreInt64(&managerSessionTTL, int64(ttl))
+	return nil
+}
+
+// getManagerSessionTTL gets the etcd session's TTL in seconds.
+func getManagerSessionTTL() int {
+	return int(atomic.LoadInt64(&managerSessionTTL))
+}
 
-// setManagerSessionTTL sets the ManagerSessionTTL value, it's used for testing.
+// setManagerSessionTTL sets the managerSessionTTL value from the environment variable, it's used for testing.
 func setManagerSessionTTL() error {
 	ttlStr := os.Getenv("tidb_manager_ttl")
 	if len(ttlStr) == 0 {
@@ -108,11 +588,42 @@ func setManagerSessionTTL() error {
 	if err != nil {
 		return errors.Trace(err)
 	}
-	ManagerSessionTTL = ttl
+	return errors.Trace(SetManagerSessionTTL(ttl))
+}
+
+// ownerMinCampaignInterval is the minimum interval in nanoseconds between two successful acquisitions of the ownership.
+// It's read atomically, so use SetOwnerMinCampaignInterval to change it.
+var ownerMinCampaignInterval int64
+
+// SetOwnerMinCampaignInterval sets the minimum interval between two successful acquisitions of the ownership.
+// A manager that loses the ownership soon after acquiring it waits before campaigning again,
+// so the ownership doesn't flap between the nodes when the owner key churns. Zero means no waiting.
+func SetOwnerMinCampaignInterval(d time.Duration) error {
+	if d < 0 {
+		return errors.Errorf("invalid owner min campaign interval %v", d)
+	}
+	atomic.StoreInt64(&ownerMinCampaignInterval, int64(d))
 	return nil
 }
 
+func getOwnerMinCampaignInterval() time.Duration {
+	return time.Duration(atomic.LoadInt64(&ownerMinCampaignInterval))
+}
+
+// recampaignDelay returns the time to wait before campaigning again after holding the ownership for heldTime.
+func recampaignDelay(interval, heldTime time.Duration) time.Duration {
+	if heldTime >= interval {
+		return 0
+	}
+	return interval - heldTime
+}
+
 func newSession(ctx goctx.Context, flag string, etcdCli *clientv3.Client, retryCnt, ttl int) (*concurrency.Session, error) {
+	return newSessionWithRetry(ctx, flag, etcdCli, retryCnt, ttl, realClock{}, newEtcdSession)
+}
+
+func newSessionWithRetry(ctx goctx.Context, flag string, etcdCli *clientv3.Client, retryCnt, ttl int,
+	clock ownerClock, factory sessionFactory) (*concurrency.Session, error) {
 	var err error
 	var etcdSession *concurrency.Session
 	for i := 0; i < retryCnt; i++ {
@@ -120,48 +631,395 @@ func newSession(ctx goctx.Context, flag string, etcdCli *clientv3.Client, retryC
 			return etcdSession, errors.Trace(ctx.Err())
 		}
 
-		etcdSession, err = concurrency.NewSession(etcdCli,
-			concurrency.WithTTL(ttl), concurrency.WithContext(ctx))
+		etcdSession, err = factory(ctx, etcdCli, ttl)
 		if err == nil {
 			break
 		}
 		log.Warnf("[ddl] %s failed to new session, err %v", flag, err)
-		time.Sleep(200 * time.Millisecond)
+		clock.Sleep(200 * time.Millisecond)
 	}
 	return etcdSession, errors.Trace(err)
 }
 
+func (m *ownerManager) newSession(ctx goctx.Context, flag string, retryCnt int) (*concurrency.Session, error) {
+	return newSessionWithRetry(ctx, flag, m.etcdCli, retryCnt, getManagerSessionTTL(), m.clock, m.sessionFactory)
+}
+
 // CampaignOwner implements OwnerManager.CampaignOwner interface.
 func (m *ownerManager) CampaignOwner(ctx goctx.Context) error {
-	ddlSession, err := newSession(ctx, DDLOwnerKey, m.etcdCli, newSessionDefaultRetryCnt, ManagerSessionTTL)
+	return errors.Trace(m.campaign(ctx, DDLOwnerKey))
+}
+
+// CampaignOwnerKey implements OwnerManager.CampaignOwnerKey interface.
+func (m *ownerManager) CampaignOwnerKey(ctx goctx.Context, key string) error {
+	if key == DDLOwnerKey {
+		return errors.Errorf("use CampaignOwner to campaign the DDL owner")
+	}
+	return errors.Trace(m.campaign(ctx, key))
+}
+
+// IsKeyOwner implements OwnerManager.IsKeyOwner interface.
+func (m *ownerManager) IsKeyOwner(key string) bool {
+	if key == DDLOwnerKey {
+		return m.IsOwner()
+	}
+	return m.keyOwners.isOwner(key)
+}
+
+// HealthStatus implements OwnerManager.HealthStatus interface.
+func (m *ownerManager) HealthStatus() (bool, string) {
+	if m.etcdCtx == nil || m.etcdCtx.Err() != nil {
+		return false, "etcd client is closed"
+	}
+	keys, statuses := m.statuses.snapshot()
+	if len(keys) == 0 {
+		return false, "campaign isn't started"
+	}
+
+	healthy := true
+	details := make([]string, 0, len(keys))
+	for i, key := range keys {
+		st := statuses[i]
+		var state string
+		switch {
+		case st.exited:
+			healthy = false
+			state = fmt.Sprintf("campaign loop exited, reason %v", st.exitReason)
+		case !st.running:
+			healthy = false
+			state = "campaign loop isn't running"
+		case st.session == nil || isSessionDone(st.session):
+			healthy = false
+			state = "session is done"
+		case m.IsKeyOwner(key) && len(st.ownerKey) == 0:
+			// The ownership isn't held by the owner key of the campaign, so DoIfOwner can't run.
+			healthy = false
+			state = "owner without the owner key"
+		default:
+			state = "campaigning"
+		}
+		details = append(details, fmt.Sprintf("key=%s state=%q is_owner=%v last_err=%v",
+			key, state, m.IsKeyOwner(key), st.lastErr))
+	}
+	return healthy, strings.Join(details, "; ")
+}
+
+func isSessionDone(session ownerSession) bool {
+	select {
+	case <-session.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// campaign creates a session for the key and campaigns the owner of the key.
+// Every key has its own session, so losing the ownership of one key doesn't affect the others.
+func (m *ownerManager) campaign(ctx goctx.Context, key string) error {
+	if err := checkOwnerKey(key); err != nil {
+		return errors.Trace(err)
+	}
+	logger := newOwnerLogger(key, m.ddlID)
+	m.campaignsMu.Lock()
+	if h, ok := m.campaigns[key]; ok {
+		select {
+		case <-h.done:
+		default:
+			m.campaignsMu.Unlock()
+			return errors.Trace(errAlreadyCampaign)
+		}
+	}
+	campaignCtx, cancel := goctx.WithCancel(ctx)
+	h := &campaignHandle{cancel: cancel, done: make(chan struct{})}
+	m.campaigns[key] = h
+	m.campaignsMu.Unlock()
+
+	// The key is reserved by the handle, so the session is created without holding the lock,
+	// and the creation can be canceled by stopping the campaign.
+	session, err := m.newSession(campaignCtx, logger.tag, newSessionDefaultRetryCnt)
 	if err != nil {
+		cancel()
+		m.campaignsMu.Lock()
+		if m.campaigns[key] == h {
+			delete(m.campaigns, key)
+		}
+		m.campaignsMu.Unlock()
+		close(h.done)
+		m.statuses.update(key, func(s *campaignStatus) {
+			s.exited = true
+			s.exitReason = errors.Trace(err)
+		})
 		return errors.Trace(err)
 	}
-	ddlCtx, _ := goctx.WithCancel(ctx)
-	go m.campaignLoop(ddlCtx, ddlSession, DDLOwnerKey)
+	go func() {
+		defer close(h.done)
+		m.runCampaignLoop(campaignCtx, session, key)
+	}()
+	return nil
+}
+
+// runCampaignLoop runs the campaign loop, and restarts it with a new session after it panics.
+// If it panics too many times, the campaign of the key exits permanently.
+func (m *ownerManager) runCampaignLoop(ctx goctx.Context, etcdSession *concurrency.Session, key string) {
+	logger := newOwnerLogger(key, m.ddlID)
+	setExited := func(reason error) {
+		m.statuses.update(key, func(s *campaignStatus) {
+			s.running = false
+			s.exited = true
+			s.exitReason = reason
+		})
+	}
+	for i := 0; ; i++ {
+		reason, r := m.campaignLoopWithRecover(ctx, etcdSession, key, logger)
+		if r == nil {
+			setExited(reason)
+			return
+		}
+		ownerCampaignPanicCounter.WithLabelValues(key).Inc()
+		m.setOwnerVal(key, false)
+		m.closeCampaignSession(key, logger)
+		if i >= campaignLoopMaxRestarts {
+			logger.Errorf("campaign loop panics too many times, stop campaigning")
+			setExited(errors.Errorf("campaign loop panics: %v", r))
+			return
+		}
+
+		select {
+		case <-m.clock.After(campaignLoopRestartBackoff):
+		case <-ctx.Done():
+			setExited(errors.Trace(ctx.Err()))
+			return
+		}
+		var err error
+		etcdSession, err = m.newSession(ctx, logger.tag, newSessionRetryUnlimited)
+		if err != nil {
+			setExited(errors.Trace(err))
+			return
+		}
+		logger.Infof("restart campaign loop, restart count %d", i+1)
+	}
+}
+
+// campaignLoopWithRecover runs the campaign loop, and returns the reason of exiting the loop,
+// or the recovered value if it panics.
+func (m *ownerManager) campaignLoopWithRecover(ctx goctx.Context, etcdSession *concurrency.Session, key string,
+	logger ownerLogger) (exitReason error, r interface{}) {
+	defer func() {
+		if r = recover(); r != nil {
+			buf := make([]byte, 4096)
+			buf = buf[:runtime.Stack(buf, false)]
+			logger.Errorf("campaign loop panics, err %v, stack %s", r, buf)
+		}
+	}()
+	return m.campaignLoop(ctx, etcdSession, key), nil
+}
+
+// closeCampaignSession closes the last session of the campaign loop of the key, so the ownership is resigned.
+func (m *ownerManager) closeCampaignSession(key string, logger ownerLogger) {
+	var session ownerSession
+	m.statuses.update(key, func(s *campaignStatus) {
+		session = s.session
+		s.session = nil
+	})
+	if session == nil {
+		return
+	}
+	if err := session.Close(); err != nil {
+		logger.Warnf("close session failed, err %v", err)
+	}
+}
+
+// StopCampaign implements OwnerManager.StopCampaign interface.
+func (m *ownerManager) StopCampaign() {
+	m.stopCampaign(DDLOwnerKey)
+}
+
+// stopCampaign stops the campaign loop of the key and waits for it to exit.
+// The loop revokes the session's lease when it exits, so the ownership is resigned.
+func (m *ownerManager) stopCampaign(key string) {
+	m.campaignsMu.Lock()
+	h, ok := m.campaigns[key]
+	delete(m.campaigns, key)
+	m.campaignsMu.Unlock()
+	if !ok {
+		return
+	}
+	h.cancel()
+	<-h.done
+	m.setOwnerVal(key, false)
+}
+
+// ownerLogger tags ownership-related log lines with the election key and the manager ID
+// in the key=value form, so that they can be filtered across nodes.
+type ownerLogger struct {
+	tag string
+}
+
+func newOwnerLogger(key, id string) ownerLogger {
+	return ownerLogger{tag: fmt.Sprintf("key=%s id=%s", key, id)}
+}
+
+func (l ownerLogger) Debugf(format string, args ...interface{}) {
+	log.Debugf("[ddl] [owner] %s %s", l.tag, fmt.Sprintf(format, args...))
+}
+
+func (l ownerLogger) Infof(format string, args ...interface{}) {
+	log.Infof("[ddl] [owner] %s %s", l.tag, fmt.Sprintf(format, args...))
+}
+
+func (l ownerLogger) Warnf(format string, args ...interface{}) {
+	log.Warnf("[ddl] [owner] %s %s", l.tag, fmt.Sprintf(format, args...))
+}
+
+func (l ownerLogger) Errorf(format string, args ...interface{}) {
+	log.Errorf("[ddl] [owner] %s %s", l.tag, fmt.Sprintf(format, args...))
+}
+
+// ownerMismatchTracker tracks the consecutive campaigns whose leader doesn't match this manager.
+// Two processes started with the same manager ID keep seeing each other as the leader,
+// so it's used to detect this misconfiguration and to back off instead of tight-looping.
+type ownerMismatchTracker struct {
+	cnt int
+}
+
+// observe records the result of checking the leader after a campaign.
+// It returns the time to wait before campaigning again,
+// and whether the manager ID is probably duplicated.
+func (t *ownerMismatchTracker) observe(err error) (time.Duration, bool) {
+	if err == nil {
+		t.cnt = 0
+		return 0, false
+	}
+	isDup := terror.ErrorEqual(err, errDuplicateOwnerID)
+	if !isDup && !terror.ErrorEqual(err, errOwnerInfoNotMatch) {
+		return 0, false
+	}
+
+	t.cnt++
+	backoff := time.Duration(t.cnt) * ownerMismatchBackoffUnit
+	if backoff > ownerMismatchMaxBackoff {
+		backoff = ownerMismatchMaxBackoff
+	}
+	return backoff, isDup || t.cnt >= ownerMismatchThreshold
+}
+
+// clockSkewTracker tracks the consecutive lease-not-found errors.
+// If the etcd server turns clocks forward, it deletes the session's lease before the session finds it,
+// and it keeps happening until the clocks are fixed, so it's used to back off instead of tight-looping.
+type clockSkewTracker struct {
+	cnt int
+}
+
+// observe records a lease-not-found error.
+// It returns the time to wait before creating a new session, and whether the clocks are probably skewed.
+func (t *clockSkewTracker) observe() (time.Duration, bool) {
+	t.cnt++
+	backoff := time.Duration(t.cnt) * clockSkewBackoffUnit
+	if backoff > clockSkewMaxBackoff {
+		backoff = clockSkewMaxBackoff
+	}
+	return backoff, t.cnt >= clockSkewThreshold
+}
+
+func (t *clockSkewTracker) reset() {
+	t.cnt = 0
+}
+
+// ownerLossTracker records the time when the ownership is lost,
+// to measure how long it takes to regain the ownership or to find another owner.
+type ownerLossTracker struct {
+	lostTime time.Time
+}
+
+func (t *ownerLossTracker) isLost() bool {
+	return !t.lostTime.IsZero()
+}
+
+func (t *ownerLossTracker) lose(now time.Time) {
+	t.lostTime = now
+}
+
+// finish returns the time from losing the ownership to now, and resets the tracker.
+// It returns false if the ownership isn't lost.
+func (t *ownerLossTracker) finish(now time.Time) (time.Duration, bool) {
+	if !t.isLost() {
+		return 0, false
+	}
+	d := now.Sub(t.lostTime)
+	t.lostTime = time.Time{}
+	return d, true
+}
+
+// observe records the result of the lost ownership to the metric and the log.
+func (t *ownerLossTracker) observe(now time.Time, key, result string, logger ownerLogger) {
+	d, ok := t.finish(now)
+	if !ok {
+		return
+	}
+	ownerReacquireHistogram.WithLabelValues(key, result).Observe(d.Seconds())
+	logger.Infof("ownership is %s, time since lost %v", result, d)
+}
+
+// checkOwnerCollision checks whether the leader elected by the campaign is this manager.
+// selfKey is the key this manager campaigned with, leaderKey and leaderID are the key and the value of the leader.
+func checkOwnerCollision(selfKey, id, leaderKey, leaderID string) error {
+	if leaderID != id {
+		return errOwnerInfoNotMatch
+	}
+	if leaderKey != selfKey {
+		// The leader has the same ID as this manager, but it's campaigned by another session.
+		return errDuplicateOwnerID
+	}
 	return nil
 }
 
-func (m *ownerManager) campaignLoop(ctx goctx.Context, etcdSession *concurrency.Session, key string) {
-	idInfo := fmt.Sprintf("%s ownerManager %s", key, m.ddlID)
+// campaignLoop campaigns the owner of the key until the ctx is done, and returns the reason of exiting the loop.
+// The loop only exits when the ctx is done, because a new session is created with unlimited retries,
+// so the exit is unrecoverable and the loop isn't restarted.
+func (m *ownerManager) campaignLoop(ctx goctx.Context, etcdSession *concurrency.Session, key string) (exitReason error) {
+	logger := newOwnerLogger(key, m.ddlID)
 	var err error
+	var mismatch ownerMismatchTracker
+	var skew clockSkewTracker
+	var loss ownerLossTracker
+	defer func() { loss.observe(m.clock.Now(), key, ownerLostNotRegained, logger) }()
+	m.statuses.update(key, func(s *campaignStatus) {
+		s.running = true
+		// A nil etcdSession isn't stored as a non-nil ownerSession.
+		s.session = nil
+		if etcdSession != nil {
+			s.session = etcdSession
+		}
+		s.lastErr = nil
+		s.exited = false
+		s.exitReason = nil
+	})
+	// The loop also returns here when it panics and is going to be restarted, so it's marked exited by
+	// runCampaignLoop instead.
+	defer m.statuses.update(key, func(s *campaignStatus) {
+		s.running = false
+		s.lastErr = err
+	})
 	for {
 		select {
 		case <-etcdSession.Done():
-			log.Infof("[ddl] %s etcd session is done, creates a new one", idInfo)
-			etcdSession, err = newSession(ctx, idInfo, m.etcdCli, newSessionRetryUnlimited, ManagerSessionTTL)
+			logger.Infof("etcd session is done, creates a new one")
+			etcdSession, err = m.newSession(ctx, logger.tag, newSessionRetryUnlimited)
 			if err != nil {
-				log.Infof("[ddl] %s break campaign loop, err %v", idInfo, err)
+				logger.Infof("break campaign loop, err %v", err)
+				exitReason = errors.Trace(err)
 				return
 			}
+			m.statuses.update(key, func(s *campaignStatus) { s.session = etcdSession })
 		case <-ctx.Done():
 			// Revoke the session lease.
 			// If revoke takes longer than the ttl, lease is expired anyway.
 			cancelCtx, cancel := goctx.WithTimeout(goctx.Background(),
-				time.Duration(ManagerSessionTTL)*time.Second)
+				time.Duration(getManagerSessionTTL())*time.Second)
 			_, err = m.etcdCli.Revoke(cancelCtx, etcdSession.Lease())
 			cancel()
-			log.Infof("[ddl] %s break campaign loop err %v", idInfo, err)
+			logger.Infof("break campaign loop, err %v", err)
+			exitReason = errors.Trace(ctx.Err())
 			return
 		default:
 		}
@@ -169,56 +1027,345 @@ func (m *ownerManager) campaignLoop(ctx goctx.Context, etcdSession *concurrency.
 		// The etcd server deletes this session's lease ID, but etcd session doesn't find it.
 		// In this time if we do the campaign operation, the etcd server will return ErrLeaseNotFound.
 		if terror.ErrorEqual(err, rpctypes.ErrLeaseNotFound) {
-			if etcdSession != nil {
-				err = etcdSession.Close()
-				log.Infof("[ddl] %s etcd session encounters the error of lease not found, closes it err %s", idInfo, err)
-			}
+			err = m.handleLeaseNotFound(ctx, etcdSession, key, &skew, logger)
 			continue
 		}
 
+		if err = m.publishPriority(ctx, etcdSession, key); err != nil {
+			logger.Warnf("failed to publish priority, err %v", err)
+		}
 		elec := concurrency.NewElection(etcdSession, key)
-		err = elec.Campaign(ctx, m.ddlID)
+		// Campaign blocks until this manager is elected, so the leaders elected meanwhile are observed to find out
+		// whether the lost ownership is regained or taken by another manager.
+		stopObserve := m.observeOwner(ctx, elec, key, &loss, logger)
+		err = elec.Campaign(ctx, encodeOwnerValue(m.ddlID, m.version))
+		stopObserve()
 		if err != nil {
-			log.Infof("[ddl] %s failed to campaign, err %v", idInfo, err)
+			logger.Infof("failed to campaign, err %v", err)
+			m.setLastErr(key, err)
 			continue
 		}
+		skew.reset()
 
-		ownerKey, err := GetOwnerInfo(ctx, elec, key, m.ddlID)
+		ownerKey, err := m.checkCampaignLeader(ctx, elec, key, &mismatch, logger)
 		if err != nil {
 			continue
 		}
+		if higherID, ok := m.higherVersionCampaigner(ctx, key, logger); ok {
+			logger.Warnf("refuse the ownership, campaigner %s has a higher owner version than %d, back off %v",
+				higherID, m.version, ownerVersionBackoff)
+			if err = elec.Resign(ctx); err != nil {
+				logger.Warnf("resign failed, err %v", err)
+			}
+			select {
+			case <-m.clock.After(ownerVersionBackoff):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		m.statuses.update(key, func(s *campaignStatus) { s.ownerKey = ownerKey })
 		m.setOwnerVal(key, true)
+		acquiredTime := m.clock.Now()
+		loss.observe(acquiredTime, key, ownerRegained, logger)
 
-		m.watchOwner(ctx, etcdSession, ownerKey)
+		reason := m.watchOwner(ctx, etcdSession, key, ownerKey, logger)
+		// The ownership is lost when the owner key or the session is gone, the regaining is measured from this point.
+		lostTime := m.clock.Now()
 		m.setOwnerVal(key, false)
+		m.statuses.update(key, func(s *campaignStatus) { s.ownerKey = "" })
+		ownerStepDownCounter.WithLabelValues(key, string(reason)).Inc()
+		logger.Infof("step down, reason=%s", reason)
+		// Stepping down for closing the manager isn't a loss of the ownership.
+		if ctx.Err() == nil {
+			loss.lose(lostTime)
+			delay := recampaignDelay(getOwnerMinCampaignInterval(), m.clock.Now().Sub(acquiredTime))
+			if delay > 0 {
+				logger.Infof("lose the ownership soon after acquiring it, wait %v before campaigning again", delay)
+				select {
+				case <-m.clock.After(delay):
+				case <-ctx.Done():
+				}
+			}
+		}
 	}
 }
 
+// checkCampaignLeader checks whether the leader elected by the campaign of elec is this manager, and returns the owner
+// key if it is. Otherwise it backs off before the manager campaigns again, and reports the duplicated manager ID.
+func (m *ownerManager) checkCampaignLeader(ctx goctx.Context, elec ownerElection, key string,
+	mismatch *ownerMismatchTracker, logger ownerLogger) (string, error) {
+	ownerKey, err := getOwnerInfo(ctx, elec, key, m.ddlID, true)
+	if err == nil && ownerKey != elec.Key() {
+		err = checkOwnerCollision(elec.Key(), m.ddlID, ownerKey, m.ddlID)
+	}
+	m.setLastErr(key, err)
+	backoff, isDup := mismatch.observe(err)
+	if isDup {
+		logger.Errorf("probably another process is started with the same manager ID, please check the deployment, back off %v, err %v",
+			backoff, err)
+	}
+	if err != nil {
+		if backoff > 0 {
+			select {
+			case <-m.clock.After(backoff):
+			case <-ctx.Done():
+			}
+		}
+		return "", err
+	}
+	return ownerKey, nil
+}
+
+// handleLeaseNotFound closes the session whose lease isn't found, and backs off before the session is replaced by a
+// new one. It returns the error of closing the session.
+func (m *ownerManager) handleLeaseNotFound(ctx goctx.Context, etcdSession ownerSession, key string,
+	skew *clockSkewTracker, logger ownerLogger) error {
+	ownerClockSkewCounter.WithLabelValues(key).Inc()
+	backoff, isSkewed := skew.observe()
+	if isSkewed {
+		logger.Warnf("lease not found %d times in a row, probably the clocks of the servers are skewed, please check NTP, back off %v",
+			skew.cnt, backoff)
+	}
+	err := etcdSession.Close()
+	logger.Infof("etcd session encounters the error of lease not found, closes it, err %v", err)
+	select {
+	case <-m.clock.After(backoff):
+	case <-ctx.Done():
+	}
+	return errors.Trace(err)
+}
+
+// observeOwner observes the leader of the election while campaigning.
+// It returns the function that stops observing and waits for it to exit.
+func (m *ownerManager) observeOwner(ctx goctx.Context, elec *concurrency.Election, key string,
+	loss *ownerLossTracker, logger ownerLogger) func() {
+	observeCtx, cancel := goctx.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for resp := range elec.Observe(observeCtx) {
+			m.onObserveOwner(key, resp.Kvs, loss, logger)
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// onObserveOwner handles the leader observed while campaigning. If another manager is the leader, the lost
+// ownership isn't regained, and the listeners are notified for the DDL owner key.
+func (m *ownerManager) onObserveOwner(key string, kvs []*mvccpb.KeyValue, loss *ownerLossTracker, logger ownerLogger) {
+	if len(kvs) == 0 {
+		return
+	}
+	ownerID, _ := decodeOwnerValue(string(kvs[0].Value))
+	// This manager's own ownership is notified by SetOwner.
+	if ownerID == m.ddlID {
+		return
+	}
+	loss.observe(m.clock.Now(), key, ownerLostNotRegained, logger)
+	if key == DDLOwnerKey {
+		m.notifier.notifyOtherOwner(ownerID)
+	}
+}
+
+// SetPriority implements OwnerManager.SetPriority interface.
+func (m *ownerManager) SetPriority(priority int64) {
+	atomic.StoreInt64(&m.priority, priority)
+}
+
+func (m *ownerManager) getPriority() int64 {
+	return atomic.LoadInt64(&m.priority)
+}
+
+// priorityKeyPrefix returns the prefix of the keys that save the campaigners' priorities of the election key.
+// It mustn't have the prefix of the election key, otherwise these keys take part in the election and GetOwnerID.
+func priorityKeyPrefix(key string) string {
+	return "/tidb/owner_priority" + key + "/"
+}
+
+// publishPriority saves this manager's priority to etcd with the session's lease,
+// so the priority disappears when the campaigner is gone.
+func (m *ownerManager) publishPriority(ctx goctx.Context, etcdSession *concurrency.Session, key string) error {
+	childCtx, cancel := goctx.WithTimeout(ctx, keyOpDefaultTimeout)
+	_, err := m.etcdCli.Put(childCtx, priorityKeyPrefix(key)+m.ddlID, strconv.FormatInt(m.getPriority(), 10),
+		clientv3.WithLease(etcdSession.Lease()))
+	cancel()
+	return errors.Trace(err)
+}
+
+// getCampaignerPriorities gets the priorities of all the campaigners of the key.
+func (m *ownerManager) getCampaignerPriorities(ctx goctx.Context, key string) (map[string]int64, error) {
+	childCtx, cancel := goctx.WithTimeout(ctx, keyOpDefaultTimeout)
+	resp, err := m.etcdCli.Get(childCtx, priorityKeyPrefix(key), clientv3.WithPrefix())
+	cancel()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	priorities := make(map[string]int64, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		priority, err := strconv.ParseInt(string(kv.Value), 10, 64)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		priorities[strings.TrimPrefix(string(kv.Key), priorityKeyPrefix(key))] = priority
+	}
+	return priorities, nil
+}
+
+// shouldYieldOwner returns whether the owner should yield the ownership to a campaigner with a higher priority.
+// The owner keeps the ownership for at least ownerPriorityMinHoldTime to avoid thrashing.
+func shouldYieldOwner(id string, priority int64, priorities map[string]int64, heldTime time.Duration) (string, bool) {
+	if heldTime < ownerPriorityMinHoldTime {
+		return "", false
+	}
+	for campaignerID, p := range priorities {
+		if campaignerID != id && p > priority {
+			return campaignerID, true
+		}
+	}
+	return "", false
+}
+
+// checkYieldOwner checks whether the owner should yield the ownership, and yields it by deleting the owner key.
+// It returns true if the owner key is deleted.
+func (m *ownerManager) checkYieldOwner(ctx goctx.Context, key, ownerKey string, heldTime time.Duration, logger ownerLogger) bool {
+	if heldTime < ownerPriorityMinHoldTime {
+		return false
+	}
+	priorities, err := m.getCampaignerPriorities(ctx, key)
+	if err != nil {
+		logger.Warnf("get campaigner priorities failed, err %v", err)
+		return false
+	}
+	higherID, ok := shouldYieldOwner(m.ddlID, m.getPriority(), priorities, heldTime)
+	if !ok {
+		return false
+	}
+	logger.Infof("yield the ownership to the campaigner with a higher priority, campaigner=%s", higherID)
+	childCtx, cancel := goctx.WithTimeout(ctx, keyOpDefaultTimeout)
+	_, err = m.etcdCli.Delete(childCtx, ownerKey)
+	cancel()
+	if err != nil {
+		logger.Warnf("yield the ownership failed, err %v", err)
+		return false
+	}
+	return true
+}
+
+// CampaignLoopExited implements OwnerManager.CampaignLoopExited interface.
+func (m *ownerManager) CampaignLoopExited(key string) (bool, error) {
+	s, ok := m.statuses.get(key)
+	if !ok {
+		return false, nil
+	}
+	return s.exited, s.exitReason
+}
+
+func (m *ownerManager) setLastErr(key string, err error) {
+	m.statuses.update(key, func(s *campaignStatus) { s.lastErr = err })
+}
+
+// DryRunCampaign implements OwnerManager.DryRunCampaign interface.
+func (m *ownerManager) DryRunCampaign(ctx goctx.Context, key string) (*CampaignPosition, error) {
+	if err := checkOwnerKey(key); err != nil {
+		return nil, errors.Trace(err)
+	}
+	kvs, err := m.getCampaigners(ctx, key)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return newCampaignPosition(m.ddlID, kvs), nil
+}
+
+// getCampaigners gets the keys of the campaigners of the key sorted by the create revision.
+func (m *ownerManager) getCampaigners(ctx goctx.Context, key string) ([]*mvccpb.KeyValue, error) {
+	// The campaigners' keys are under the election prefix, and the one with the smallest create revision is the leader.
+	resp, err := m.etcdCli.Get(ctx, key+"/", clientv3.WithPrefix(),
+		clientv3.WithSort(clientv3.SortByCreateRevision, clientv3.SortAscend))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return resp.Kvs, nil
+}
+
+// higherVersionCampaigner returns a campaigner of the key whose owner version is higher than this manager's.
+func (m *ownerManager) higherVersionCampaigner(ctx goctx.Context, key string, logger ownerLogger) (string, bool) {
+	childCtx, cancel := goctx.WithTimeout(ctx, keyOpDefaultTimeout)
+	kvs, err := m.getCampaigners(childCtx, key)
+	cancel()
+	if err != nil {
+		logger.Warnf("get campaigners failed, err %v", err)
+		return "", false
+	}
+	return findHigherVersion(m.version, kvs)
+}
+
+// newCampaignPosition creates the CampaignPosition of the manager from the campaigners' keys sorted by the create revision.
+func newCampaignPosition(id string, kvs []*mvccpb.KeyValue) *CampaignPosition {
+	pos := &CampaignPosition{Campaigners: make([]string, 0, len(kvs)), Position: -1}
+	for i, kv := range kvs {
+		campaignerID, _ := decodeOwnerValue(string(kv.Value))
+		pos.Campaigners = append(pos.Campaigners, campaignerID)
+		if campaignerID == id && pos.Position < 0 {
+			pos.Position = i
+		}
+	}
+	if len(pos.Campaigners) > 0 {
+		pos.LeaderID = pos.Campaigners[0]
+	}
+	if pos.Position < 0 {
+		pos.Position = len(pos.Campaigners)
+	}
+	return pos
+}
+
 // GetOwnerID implements OwnerManager.GetOwnerID interface.
 func (m *ownerManager) GetOwnerID(ctx goctx.Context, key string) (string, error) {
+	ownerID, _, err := m.GetOwnerVersion(ctx, key)
+	return ownerID, errors.Trace(err)
+}
+
+// GetOwnerVersion implements OwnerManager.GetOwnerVersion interface.
+func (m *ownerManager) GetOwnerVersion(ctx goctx.Context, key string) (string, int64, error) {
 	resp, err := m.etcdCli.Get(ctx, key, clientv3.WithFirstCreate()...)
 	if err != nil {
-		return "", errors.Trace(err)
+		return "", 0, errors.Trace(err)
 	}
 	if len(resp.Kvs) == 0 {
-		return "", concurrency.ErrElectionNoLeader
+		return "", 0, concurrency.ErrElectionNoLeader
 	}
-	return string(resp.Kvs[0].Value), nil
+	ownerID, version := decodeOwnerValue(string(resp.Kvs[0].Value))
+	return ownerID, version, nil
 }
 
 // GetOwnerInfo gets the owner information.
 func GetOwnerInfo(ctx goctx.Context, elec *concurrency.Election, key, id string) (string, error) {
+	return getOwnerInfo(ctx, elec, key, id, false)
+}
+
+// GetOwnerInfoQuietly is like GetOwnerInfo, but it doesn't log the error of no leader,
+// which is expected when no owner is elected yet.
+func GetOwnerInfoQuietly(ctx goctx.Context, elec *concurrency.Election, key, id string) (string, error) {
+	return getOwnerInfo(ctx, elec, key, id, true)
+}
+
+func getOwnerInfo(ctx goctx.Context, elec ownerElection, key, id string, ignoreNoLeader bool) (string, error) {
+	logger := newOwnerLogger(key, id)
 	resp, err := elec.Leader(ctx)
 	if err != nil {
 		// If no leader elected currently, it returns ErrElectionNoLeader.
-		log.Infof("[ddl] %s ownerManager %s failed to get leader, err %v", key, id, err)
+		if !ignoreNoLeader || !terror.ErrorEqual(err, concurrency.ErrElectionNoLeader) {
+			logger.Infof("failed to get leader, err %v", err)
+		}
 		return "", errors.Trace(err)
 	}
-	ownerID := string(resp.Kvs[0].Value)
-	log.Infof("[ddl] %s ownerManager is %s, owner is %v", key, id, ownerID)
+	ownerID, version := decodeOwnerValue(string(resp.Kvs[0].Value))
+	logger.Infof("get leader, owner=%s version=%d", ownerID, version)
 	if ownerID != id {
-		log.Warnf("[ddl] %s ownerManager %s isn't the owner", key, id)
-		return "", errors.New("ownerInfoNotMatch")
+		logger.Warnf("isn't the owner, owner=%s", ownerID)
+		return "", errOwnerInfoNotMatch
 	}
 
 	return string(resp.Kvs[0].Key), nil
@@ -227,31 +1374,244 @@ func GetOwnerInfo(ctx goctx.Context, elec *concurrency.Election, key, id string)
 func (m *ownerManager) setOwnerVal(key string, val bool) {
 	if key == DDLOwnerKey {
 		m.SetOwner(val)
+		return
 	}
+	m.keyOwners.setOwner(key, val)
 }
 
-func (m *ownerManager) watchOwner(ctx goctx.Context, etcdSession *concurrency.Session, key string) {
-	log.Debugf("[ddl] ownerManager %s watch owner key %v", m.ddlID, key)
-	watchCh := m.etcdCli.Watch(ctx, key)
-	for {
-		select {
-		case resp := <-watchCh:
-			if resp.Canceled {
-				log.Infof("[ddl] ownerManager %s watch owner key %v failed, no owner",
-					m.ddlID, key)
+// stepDownReason is the reason why the owner steps down.
+type stepDownReason string
+
+const (
+	stepDownLeaseExpired stepDownReason = "lease_expired"
+	stepDownKeyDeleted   stepDownReason = "key_deleted"
+	stepDownYielded      stepDownReason = "yielded"
+	stepDownWatchCancel  stepDownReason = "watch_canceled"
+	stepDownSessionDone  stepDownReason = "session_done"
+	stepDownCtxDone      stepDownReason = "ctx_done"
+	stepDownKeyMismatch  stepDownReason = "key_mismatch"
+)
+
+// deletedReason returns why the owner key is deleted.
+// If the session's lease doesn't exist anymore, the key is deleted because the lease expires,
+// otherwise the key is deleted explicitly.
+func (m *ownerManager) deletedReason(ctx goctx.Context, etcdSession ownerSession, yielded bool) stepDownReason {
+	if yielded {
+		return stepDownYielded
+	}
+	childCtx, cancel := goctx.WithTimeout(ctx, keyOpDefaultTimeout)
+	resp, err := m.lease.TimeToLive(childCtx, etcdSession.Lease())
+	cancel()
+	if terror.ErrorEqual(err, rpctypes.ErrLeaseNotFound) || (err == nil && resp.TTL <= 0) {
+		return stepDownLeaseExpired
+	}
+	return stepDownKeyDeleted
+}
+
+// isOwnerKeyDeleted returns whether the watch response has the event of deleting the owner key.
+func isOwnerKeyDeleted(resp clientv3.WatchResponse) bool {
+	for _, ev := range resp.Events {
+		if ev.Type == mvccpb.DELETE {
+			return true
+		}
+	}
+	return false
+}
+
+// DoIfOwner implements OwnerManager.DoIfOwner interface.
+func (m *ownerManager) DoIfOwner(ctx goctx.Context, fn func(ctx goctx.Context) error) error {
+	if !m.IsOwner() {
+		return errors.Trace(errNotOwner)
+	}
+	status, _ := m.statuses.get(DDLOwnerKey)
+	session, ownerKey := status.session, status.ownerKey
+	if session == nil || len(ownerKey) == 0 {
+		return errors.Trace(errNotOwner)
+	}
+
+	fnCtx, cancel := goctx.WithCancel(ctx)
+	defer cancel()
+	// Check the owner key with the live session, and watch it from the revision of the check,
+	// so the deletion between them isn't missed.
+	childCtx, childCancel := goctx.WithTimeout(fnCtx, keyOpDefaultTimeout)
+	resp, err := m.etcdCli.Get(childCtx, ownerKey)
+	childCancel()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if len(resp.Kvs) == 0 || resp.Kvs[0].Lease != int64(session.Lease()) {
+		return errors.Trace(errNotOwner)
+	}
+	watchCh := m.etcdCli.Watch(fnCtx, ownerKey, clientv3.WithRev(resp.Header.Revision+1))
+
+	var lost int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case resp, ok := <-watchCh:
+				if ok && !resp.Canceled && !isOwnerKeyDeleted(resp) {
+					continue
+				}
+				if fnCtx.Err() != nil {
+					return
+				}
+			case <-session.Done():
+			case <-fnCtx.Done():
 				return
 			}
+			atomic.StoreInt32(&lost, 1)
+			cancel()
+			return
+		}
+	}()
+	err = fn(fnCtx)
+	cancel()
+	<-done
+	if atomic.LoadInt32(&lost) == 1 {
+		newOwnerLogger(DDLOwnerKey, m.ddlID).Warnf("the ownership is lost while running the function, err %v", err)
+		return errors.Trace(errNotOwner)
+	}
+	return errors.Trace(err)
+}
 
-			for _, ev := range resp.Events {
-				if ev.Type == mvccpb.DELETE {
-					log.Infof("[ddl] ownerManager %s watch owner key %v failed, owner is deleted", m.ddlID, key)
-					return
+// timedWatchResponse is the watch response with the time when it's received from the watcher.
+type timedWatchResponse struct {
+	clientv3.WatchResponse
+	recvTime time.Time
+}
+
+// timedWatch watches the key and forwards the responses with the receiving time,
+// so the lag of processing them can be measured. The returned channel is closed when the watch is closed.
+func (m *ownerManager) timedWatch(ctx goctx.Context, key string, opts ...clientv3.OpOption) <-chan timedWatchResponse {
+	watchCh := m.watcher.Watch(ctx, key, opts...)
+	ch := make(chan timedWatchResponse, ownerWatchChanSize)
+	go func() {
+		defer close(ch)
+		for resp := range watchCh {
+			select {
+			case ch <- timedWatchResponse{WatchResponse: resp, recvTime: m.clock.Now()}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// observeWatchProcessLag records the lag between receiving the owner key's deletion from the watcher and processing
+// it, and returns the lag. The etcd events carry no timestamp, so the delay of the etcd server and the network isn't
+// included.
+func (m *ownerManager) observeWatchProcessLag(electionKey string, resp timedWatchResponse, logger ownerLogger) time.Duration {
+	lag := m.clock.Now().Sub(resp.recvTime)
+	ownerWatchProcessLagHistogram.WithLabelValues(electionKey).Observe(lag.Seconds())
+	if lag > ownerWatchProcessLagWarnThreshold {
+		logger.Warnf("process the deletion of the owner key slowly, lag %v, revision %d", lag, resp.Header.Revision)
+	}
+	return lag
+}
+
+// ownerKeyState is the state of the owner key checked before re-establishing its watch.
+type ownerKeyState int
+
+const (
+	ownerKeyHeld ownerKeyState = iota
+	ownerKeyDeleted
+	// ownerKeyMismatch means the owner key exists, but it doesn't hold this manager's value and lease.
+	ownerKeyMismatch
+)
+
+// getOwnerKeyState returns the state of the owner key whose kvs are got from etcd.
+func getOwnerKeyState(kvs []*mvccpb.KeyValue, val string, lease clientv3.LeaseID) ownerKeyState {
+	if len(kvs) == 0 {
+		return ownerKeyDeleted
+	}
+	if string(kvs[0].Value) != val || kvs[0].Lease != int64(lease) {
+		return ownerKeyMismatch
+	}
+	return ownerKeyHeld
+}
+
+// rewatchOwner verifies that the owner key still holds this manager's value,
+// and re-establishes the watch of it from the current revision if it does.
+func (m *ownerManager) rewatchOwner(ctx goctx.Context, etcdSession ownerSession,
+	key string) (<-chan timedWatchResponse, ownerKeyState, error) {
+	childCtx, cancel := goctx.WithTimeout(ctx, keyOpDefaultTimeout)
+	resp, err := m.etcdCli.Get(childCtx, key)
+	cancel()
+	if err != nil {
+		return nil, ownerKeyHeld, errors.Trace(err)
+	}
+	state := getOwnerKeyState(resp.Kvs, encodeOwnerValue(m.ddlID, m.version), etcdSession.Lease())
+	if state != ownerKeyHeld {
+		return nil, state, nil
+	}
+	return m.timedWatch(ctx, key, clientv3.WithRev(resp.Header.Revision+1)), state, nil
+}
+
+// watchOwner watches the owner key until the ownership is lost, and returns the reason.
+func (m *ownerManager) watchOwner(ctx goctx.Context, etcdSession ownerSession, electionKey, key string,
+	logger ownerLogger) stepDownReason {
+	logger.Debugf("watch owner, owner_key=%s", key)
+	startTime := m.clock.Now()
+	ticker := m.clock.NewTicker(ownerPriorityCheckInterval)
+	defer ticker.Stop()
+	yielded := false
+	// The watch is closed when the ownership is lost.
+	watchCtx, cancel := goctx.WithCancel(ctx)
+	defer cancel()
+	watchCh := m.timedWatch(watchCtx, key)
+	rewatchCnt := 0
+	for {
+		select {
+		case resp, ok := <-watchCh:
+			if !ok || resp.Canceled {
+				if ctx.Err() != nil {
+					return stepDownCtxDone
+				}
+				// The watch may be canceled transiently, e.g. the revision to watch is compacted or the etcd leader changes,
+				// so the owner only steps down if the owner key doesn't hold this manager's value anymore.
+				rewatchCnt++
+				if rewatchCnt > ownerMaxRewatchCnt {
+					logger.Infof("watch owner failed, it's canceled too many times, owner_key=%s", key)
+					return stepDownWatchCancel
 				}
+				logger.Warnf("watch owner is canceled, rewatch it, owner_key=%s compact_revision=%d err %v",
+					key, resp.CompactRevision, resp.Err())
+				var state ownerKeyState
+				var err error
+				watchCh, state, err = m.rewatchOwner(watchCtx, etcdSession, key)
+				switch {
+				case err != nil:
+					logger.Infof("watch owner failed, rewatch err %v, owner_key=%s", err, key)
+					return stepDownWatchCancel
+				case state == ownerKeyDeleted:
+					reason := m.deletedReason(ctx, etcdSession, yielded)
+					logger.Infof("watch owner failed, owner is deleted, owner_key=%s reason=%s", key, reason)
+					return reason
+				case state == ownerKeyMismatch:
+					logger.Infof("watch owner failed, owner key doesn't hold this manager's value, owner_key=%s", key)
+					return stepDownKeyMismatch
+				}
+				continue
+			}
+			rewatchCnt = 0
+
+			if isOwnerKeyDeleted(resp.WatchResponse) {
+				m.observeWatchProcessLag(electionKey, resp, logger)
+				reason := m.deletedReason(ctx, etcdSession, yielded)
+				logger.Infof("watch owner failed, owner is deleted, owner_key=%s reason=%s", key, reason)
+				return reason
+			}
+		case <-ticker.Chan():
+			if m.checkYieldOwner(ctx, electionKey, key, m.clock.Now().Sub(startTime), logger) {
+				yielded = true
 			}
 		case <-etcdSession.Done():
-			return
+			return stepDownSessionDone
 		case <-ctx.Done():
-			return
+			return stepDownCtxDone
 		}
 	}
 }
@@ -0,0 +1,918 @@
+// Copyright 2017 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ddl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/clientv3/concurrency"
+	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	"github.com/juju/errors"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb/terror"
+	"github.com/pingcap/tidb/util/testleak"
+	goctx "golang.org/x/net/context"
+)
+
+var _ = Suite(&testOwnerManagerSuite{})
+
+type testOwnerManagerSuite struct{}
+
+func (s *testOwnerManagerSuite) TestDuplicateIDCollision(c *C) {
+	defer testleak.AfterTest(c)()
+	// Two managers are started with the same ID, and manager 1 is the leader.
+	id := "same-id"
+	key1 := DDLOwnerKey + "/1"
+	key2 := DDLOwnerKey + "/2"
+	c.Assert(checkOwnerCollision(key1, id, key1, id), IsNil)
+	err := checkOwnerCollision(key2, id, key1, id)
+	c.Assert(terror.ErrorEqual(err, errDuplicateOwnerID), IsTrue)
+
+	// Manager 2 reports the duplicated ID at once and backs off.
+	var tracker ownerMismatchTracker
+	backoff, isDup := tracker.observe(errors.Trace(err))
+	c.Assert(isDup, IsTrue)
+	c.Assert(backoff, Equals, ownerMismatchBackoffUnit)
+
+	// The back off grows but is bounded.
+	for i := 0; i < 100; i++ {
+		backoff, isDup = tracker.observe(err)
+	}
+	c.Assert(isDup, IsTrue)
+	c.Assert(backoff, Equals, ownerMismatchMaxBackoff)
+
+	// Winning the campaign resets the tracker.
+	backoff, isDup = tracker.observe(nil)
+	c.Assert(isDup, IsFalse)
+	c.Assert(backoff, Equals, time.Duration(0))
+}
+
+func (s *testOwnerManagerSuite) TestDuplicateIDCampaigns(c *C) {
+	defer testleak.AfterTest(c)()
+	// Two managers are started with the same ID, they campaign with their own sessions, and manager 1 is elected.
+	id := "same-id"
+	key1 := DDLOwnerKey + "/1"
+	key2 := DDLOwnerKey + "/2"
+	leader := &clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{
+		{Key: []byte(key1), Value: []byte(encodeOwnerValue(id, ownerVersion))},
+	}}
+	clock1, clock2 := &mockOwnerClock{now: time.Now()}, &mockOwnerClock{now: time.Now()}
+	m1 := newOwnerManager(nil, id, func() {}, clock1, newEtcdSession)
+	m2 := newOwnerManager(nil, id, func() {}, clock2, newEtcdSession)
+	var mismatch1, mismatch2 ownerMismatchTracker
+	ctx := goctx.Background()
+
+	for i := 1; i <= 3; i++ {
+		ownerKey, err := m1.checkCampaignLeader(ctx, &mockElection{key: key1, leader: leader}, DDLOwnerKey,
+			&mismatch1, newOwnerLogger(DDLOwnerKey, id))
+		c.Assert(err, IsNil)
+		c.Assert(ownerKey, Equals, key1)
+		c.Assert(clock1.sleeps, HasLen, 0)
+
+		// Manager 2 finds the leader has its ID but not its key, and backs off longer every time.
+		ownerKey, err = m2.checkCampaignLeader(ctx, &mockElection{key: key2, leader: leader}, DDLOwnerKey,
+			&mismatch2, newOwnerLogger(DDLOwnerKey, id))
+		c.Assert(terror.ErrorEqual(err, errDuplicateOwnerID), IsTrue)
+		c.Assert(ownerKey, Equals, "")
+		c.Assert(clock2.sleeps, HasLen, i)
+		c.Assert(clock2.sleeps[i-1], Equals, time.Duration(i)*ownerMismatchBackoffUnit)
+		st, _ := m2.statuses.get(DDLOwnerKey)
+		c.Assert(st.lastErr, Equals, errDuplicateOwnerID)
+	}
+
+	// Manager 2 is elected after manager 1 exits.
+	leader = &clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{
+		{Key: []byte(key2), Value: []byte(encodeOwnerValue(id, ownerVersion))},
+	}}
+	ownerKey, err := m2.checkCampaignLeader(ctx, &mockElection{key: key2, leader: leader}, DDLOwnerKey,
+		&mismatch2, newOwnerLogger(DDLOwnerKey, id))
+	c.Assert(err, IsNil)
+	c.Assert(ownerKey, Equals, key2)
+	c.Assert(clock2.sleeps, HasLen, 3)
+	c.Assert(mismatch2.cnt, Equals, 0)
+}
+
+func (s *testOwnerManagerSuite) TestRepeatedOwnerInfoNotMatch(c *C) {
+	defer testleak.AfterTest(c)()
+	c.Assert(checkOwnerCollision("k1", "id1", "k2", "id2"), Equals, errOwnerInfoNotMatch)
+
+	var tracker ownerMismatchTracker
+	for i := 1; i < ownerMismatchThreshold; i++ {
+		backoff, isDup := tracker.observe(errOwnerInfoNotMatch)
+		c.Assert(isDup, IsFalse)
+		c.Assert(backoff > 0, IsTrue)
+	}
+	_, isDup := tracker.observe(errOwnerInfoNotMatch)
+	c.Assert(isDup, IsTrue)
+
+	// Other errors don't affect the tracker.
+	backoff, isDup := tracker.observe(errors.New("other"))
+	c.Assert(isDup, IsFalse)
+	c.Assert(backoff, Equals, time.Duration(0))
+}
+
+func (s *testOwnerManagerSuite) TestClockSkewTracker(c *C) {
+	defer testleak.AfterTest(c)()
+	var tracker clockSkewTracker
+	lastBackoff := time.Duration(0)
+	for i := 1; i < clockSkewThreshold; i++ {
+		backoff, isSkewed := tracker.observe()
+		c.Assert(isSkewed, IsFalse)
+		c.Assert(backoff > lastBackoff, IsTrue)
+		lastBackoff = backoff
+	}
+	_, isSkewed := tracker.observe()
+	c.Assert(isSkewed, IsTrue)
+	// The backoff is bounded.
+	for i := 0; i < 100; i++ {
+		backoff, _ := tracker.observe()
+		c.Assert(backoff <= clockSkewMaxBackoff, IsTrue)
+	}
+
+	tracker.reset()
+	backoff, isSkewed := tracker.observe()
+	c.Assert(isSkewed, IsFalse)
+	c.Assert(backoff, Equals, clockSkewBackoffUnit)
+}
+
+func (s *testOwnerManagerSuite) TestWaitUntilOwner(c *C) {
+	defer testleak.AfterTest(c)()
+	ctx, cancel := goctx.WithCancel(goctx.Background())
+	m := NewMockOwnerManager("id", cancel)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.WaitUntilOwnerIfNot(goctx.Background())
+	}()
+	c.Assert(m.CampaignOwner(ctx), IsNil)
+	c.Assert(<-done, IsNil)
+	c.Assert(m.WaitUntilOwnerIfNot(goctx.Background()), IsNil)
+
+	m.SetOwner(false)
+	go func() {
+		done <- m.WaitUntilOwner(goctx.Background())
+	}()
+	time.Sleep(10 * time.Millisecond)
+	m.SetOwner(true)
+	c.Assert(<-done, IsNil)
+
+	// WaitUntilOwner waits for the next term even if the manager is the owner.
+	timeoutCtx, timeoutCancel := goctx.WithTimeout(goctx.Background(), 10*time.Millisecond)
+	err := m.WaitUntilOwner(timeoutCtx)
+	timeoutCancel()
+	c.Assert(terror.ErrorEqual(err, goctx.DeadlineExceeded), IsTrue)
+
+	// Cancel makes the waiters return.
+	go func() {
+		done <- m.WaitUntilOwner(goctx.Background())
+	}()
+	m.Cancel()
+	c.Assert(terror.ErrorEqual(<-done, goctx.Canceled), IsTrue)
+	c.Assert(ctx.Err(), NotNil)
+}
+
+func (s *testOwnerManagerSuite) TestMultipleOwnerKeys(c *C) {
+	defer testleak.AfterTest(c)()
+	statsKey := "/tidb/stats/owner"
+	m := NewMockOwnerManager("id", func() {})
+	c.Assert(m.CampaignOwnerKey(goctx.Background(), DDLOwnerKey), NotNil)
+	c.Assert(m.CampaignOwnerKey(goctx.Background(), statsKey), IsNil)
+	c.Assert(m.IsKeyOwner(statsKey), IsTrue)
+	c.Assert(m.IsOwner(), IsFalse)
+	_, err := m.GetOwnerID(goctx.Background(), DDLOwnerKey)
+	c.Assert(err, NotNil)
+	id, err := m.GetOwnerID(goctx.Background(), statsKey)
+	c.Assert(err, IsNil)
+	c.Assert(id, Equals, "id")
+
+	// Losing one role doesn't affect the other.
+	c.Assert(m.CampaignOwner(goctx.Background()), IsNil)
+	c.Assert(m.IsKeyOwner(DDLOwnerKey), IsTrue)
+	m.SetOwner(false)
+	c.Assert(m.IsKeyOwner(DDLOwnerKey), IsFalse)
+	c.Assert(m.IsKeyOwner(statsKey), IsTrue)
+
+	om := newOwnerManager(nil, "id", func() {}, realClock{}, newEtcdSession)
+	om.setOwnerVal(statsKey, true)
+	om.setOwnerVal(DDLOwnerKey, true)
+	om.setOwnerVal(statsKey, false)
+	c.Assert(om.IsKeyOwner(statsKey), IsFalse)
+	c.Assert(om.IsOwner(), IsTrue)
+}
+
+func (s *testOwnerManagerSuite) TestCampaignStatuses(c *C) {
+	defer testleak.AfterTest(c)()
+	om := newOwnerManager(nil, "id", func() {}, realClock{}, newEtcdSession)
+	healthy, detail := om.HealthStatus()
+	c.Assert(healthy, IsFalse)
+	c.Assert(detail, Equals, "etcd client is closed")
+
+	statsKey := "/tidb/stats/owner"
+	om.statuses.update(statsKey, func(st *campaignStatus) { st.running = true })
+	om.statuses.update(DDLOwnerKey, func(st *campaignStatus) { st.running = true })
+	om.setLastErr(DDLOwnerKey, errOwnerInfoNotMatch)
+	om.statuses.update(statsKey, func(st *campaignStatus) { st.running = false })
+	keys, statuses := om.statuses.snapshot()
+	c.Assert(keys, DeepEquals, []string{DDLOwnerKey, statsKey})
+	c.Assert(statuses[0].running, IsTrue)
+	c.Assert(statuses[0].lastErr, Equals, errOwnerInfoNotMatch)
+	c.Assert(statuses[1].running, IsFalse)
+
+	exited, reason := om.CampaignLoopExited(DDLOwnerKey)
+	c.Assert(exited, IsFalse)
+	c.Assert(reason, IsNil)
+	om.statuses.update(DDLOwnerKey, func(st *campaignStatus) {
+		st.running = false
+		st.exited = true
+		st.exitReason = goctx.Canceled
+	})
+	exited, reason = om.CampaignLoopExited(DDLOwnerKey)
+	c.Assert(exited, IsTrue)
+	c.Assert(reason, Equals, goctx.Canceled)
+	exited, _ = om.CampaignLoopExited("/tidb/not/started")
+	c.Assert(exited, IsFalse)
+}
+
+func (s *testOwnerManagerSuite) TestHealthStatus(c *C) {
+	defer testleak.AfterTest(c)()
+	m := newOwnerManager(nil, "id", func() {}, &mockOwnerClock{now: time.Now()}, newEtcdSession)
+	etcdCtx, cancel := goctx.WithCancel(goctx.Background())
+	m.etcdCtx = etcdCtx
+	healthy, detail := m.HealthStatus()
+	c.Assert(healthy, IsFalse)
+	c.Assert(detail, Equals, "campaign isn't started")
+
+	session := &mockOwnerSession{done: make(chan struct{}), lease: 1}
+	m.statuses.update(DDLOwnerKey, func(st *campaignStatus) {
+		st.running = true
+		st.session = session
+	})
+	healthy, detail = m.HealthStatus()
+	c.Assert(healthy, IsTrue)
+	c.Assert(detail, Matches, `.*state="campaigning" is_owner=false.*`)
+
+	// The owner holds the owner key.
+	m.SetOwner(true)
+	m.statuses.update(DDLOwnerKey, func(st *campaignStatus) { st.ownerKey = DDLOwnerKey + "/1" })
+	healthy, detail = m.HealthStatus()
+	c.Assert(healthy, IsTrue)
+	c.Assert(detail, Matches, `.*state="campaigning" is_owner=true.*`)
+	m.statuses.update(DDLOwnerKey, func(st *campaignStatus) { st.ownerKey = "" })
+	healthy, detail = m.HealthStatus()
+	c.Assert(healthy, IsFalse)
+	c.Assert(detail, Matches, `.*state="owner without the owner key".*`)
+	m.SetOwner(false)
+
+	// Any unhealthy key makes the manager unhealthy.
+	statsKey := "/tidb/stats/owner"
+	m.statuses.update(statsKey, func(st *campaignStatus) {
+		st.running = true
+		st.session = &mockOwnerSession{done: make(chan struct{}), lease: 2}
+	})
+	healthy, _ = m.HealthStatus()
+	c.Assert(healthy, IsTrue)
+	close(session.done)
+	healthy, detail = m.HealthStatus()
+	c.Assert(healthy, IsFalse)
+	c.Assert(detail, Matches, `key=/tidb/ddl/fg/owner state="session is done".*; key=/tidb/stats/owner state="campaigning".*`)
+
+	m.statuses.update(DDLOwnerKey, func(st *campaignStatus) {
+		st.running = false
+		st.exited = true
+		st.exitReason = goctx.Canceled
+	})
+	healthy, detail = m.HealthStatus()
+	c.Assert(healthy, IsFalse)
+	c.Assert(detail, Matches, `.*state="campaign loop exited, reason context canceled".*`)
+
+	cancel()
+	healthy, detail = m.HealthStatus()
+	c.Assert(healthy, IsFalse)
+	c.Assert(detail, Equals, "etcd client is closed")
+}
+
+func (s *testOwnerManagerSuite) TestShouldYieldOwner(c *C) {
+	defer testleak.AfterTest(c)()
+	priorities := map[string]int64{"id1": 1, "id2": 2, "id3": 2}
+	// The owner holds the ownership for a while before yielding.
+	_, ok := shouldYieldOwner("id1", 1, priorities, ownerPriorityMinHoldTime/2)
+	c.Assert(ok, IsFalse)
+	higherID, ok := shouldYieldOwner("id1", 1, priorities, ownerPriorityMinHoldTime)
+	c.Assert(ok, IsTrue)
+	c.Assert(priorities[higherID], Equals, int64(2))
+	// The nodes with the same priority don't yield.
+	_, ok = shouldYieldOwner("id2", 2, priorities, ownerPriorityMinHoldTime)
+	c.Assert(ok, IsFalse)
+	_, ok = shouldYieldOwner("id1", 1, map[string]int64{"id1": 1}, ownerPriorityMinHoldTime)
+	c.Assert(ok, IsFalse)
+	c.Assert(strings.HasPrefix(priorityKeyPrefix(DDLOwnerKey), DDLOwnerKey), IsFalse)
+}
+
+func (s *testOwnerManagerSuite) TestRecampaignDelay(c *C) {
+	defer testleak.AfterTest(c)()
+	c.Assert(SetOwnerMinCampaignInterval(-time.Second), NotNil)
+	c.Assert(SetOwnerMinCampaignInterval(10*time.Second), IsNil)
+	c.Assert(getOwnerMinCampaignInterval(), Equals, 10*time.Second)
+	c.Assert(SetOwnerMinCampaignInterval(0), IsNil)
+	c.Assert(getOwnerMinCampaignInterval(), Equals, time.Duration(0))
+
+	// Zero interval campaigns again immediately.
+	c.Assert(recampaignDelay(0, 0), Equals, time.Duration(0))
+	c.Assert(recampaignDelay(0, time.Second), Equals, time.Duration(0))
+	// The ownership is lost soon after acquiring it.
+	c.Assert(recampaignDelay(10*time.Second, 3*time.Second), Equals, 7*time.Second)
+	// The ownership is held longer than the interval.
+	c.Assert(recampaignDelay(10*time.Second, 10*time.Second), Equals, time.Duration(0))
+	c.Assert(recampaignDelay(10*time.Second, time.Minute), Equals, time.Duration(0))
+}
+
+func (s *testOwnerManagerSuite) TestOwnerLossTracker(c *C) {
+	defer testleak.AfterTest(c)()
+	var loss ownerLossTracker
+	c.Assert(loss.isLost(), IsFalse)
+	_, ok := loss.finish(time.Now())
+	c.Assert(ok, IsFalse)
+
+	now := time.Now()
+	loss.lose(now)
+	c.Assert(loss.isLost(), IsTrue)
+	d, ok := loss.finish(now.Add(time.Second))
+	c.Assert(ok, IsTrue)
+	c.Assert(d, Equals, time.Second)
+	c.Assert(loss.isLost(), IsFalse)
+
+	// It's observed only once.
+	loss.lose(now)
+	loss.observe(now, DDLOwnerKey, ownerLostNotRegained, newOwnerLogger(DDLOwnerKey, "id"))
+	c.Assert(loss.isLost(), IsFalse)
+}
+
+func (s *testOwnerManagerSuite) TestObserveOwnerLoss(c *C) {
+	defer testleak.AfterTest(c)()
+	clock := &mockOwnerClock{now: time.Now()}
+	m := newOwnerManager(nil, "id1", func() {}, clock, newEtcdSession)
+	ownerCh := make(chan OwnerChange, 5)
+	m.RegisterOwnerChangeCh(ownerCh)
+	logger := newOwnerLogger(DDLOwnerKey, "id1")
+	self := []*mvccpb.KeyValue{{Value: []byte(encodeOwnerValue("id1", ownerVersion))}}
+	other := []*mvccpb.KeyValue{{Value: []byte(encodeOwnerValue("id2", ownerVersion))}}
+
+	var loss ownerLossTracker
+	loss.lose(clock.Now())
+	// No leader or the stale key of this manager doesn't finish the loss.
+	m.onObserveOwner(DDLOwnerKey, nil, &loss, logger)
+	m.onObserveOwner(DDLOwnerKey, self, &loss, logger)
+	c.Assert(loss.isLost(), IsTrue)
+	c.Assert(ownerCh, HasLen, 0)
+	// Another manager is elected while this one is campaigning, so the loss isn't regained.
+	clock.Sleep(time.Second)
+	m.onObserveOwner(DDLOwnerKey, other, &loss, logger)
+	c.Assert(loss.isLost(), IsFalse)
+	c.Assert(<-ownerCh, Equals, OwnerChange{IsOwner: false, OwnerID: "id2"})
+
+	// Only the DDL owner key is notified.
+	loss.lose(clock.Now())
+	m.onObserveOwner(DDLOwnerKey+"/other", other, &loss, logger)
+	c.Assert(loss.isLost(), IsFalse)
+	c.Assert(ownerCh, HasLen, 0)
+}
+
+type mockOwnerClock struct {
+	now     time.Time
+	sleeps  []time.Duration
+	tickers []*mockOwnerTicker
+}
+
+func (c *mockOwnerClock) Now() time.Time { return c.now }
+
+func (c *mockOwnerClock) Sleep(d time.Duration) {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+}
+
+func (c *mockOwnerClock) After(d time.Duration) <-chan time.Time {
+	c.Sleep(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func (c *mockOwnerClock) NewTicker(d time.Duration) ownerTicker {
+	t := &mockOwnerTicker{interval: d, ch: make(chan time.Time, 1)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// hookedOwnerClock calls onAfter before waiting for the duration by After.
+type hookedOwnerClock struct {
+	*mockOwnerClock
+	onAfter func(d time.Duration)
+}
+
+func (c *hookedOwnerClock) After(d time.Duration) <-chan time.Time {
+	c.onAfter(d)
+	return c.mockOwnerClock.After(d)
+}
+
+type mockOwnerTicker struct {
+	interval time.Duration
+	ch       chan time.Time
+	stopped  bool
+}
+
+func (t *mockOwnerTicker) Chan() <-chan time.Time { return t.ch }
+func (t *mockOwnerTicker) Stop()                  { t.stopped = true }
+
+type mockOwnerSession struct {
+	done     chan struct{}
+	lease    clientv3.LeaseID
+	closeCnt int
+}
+
+func (s *mockOwnerSession) Done() <-chan struct{}   { return s.done }
+func (s *mockOwnerSession) Lease() clientv3.LeaseID { return s.lease }
+func (s *mockOwnerSession) Close() error {
+	s.closeCnt++
+	return nil
+}
+
+// mockWatcher forwards the responses sent to ch to the watch, until the watch's ctx is done.
+type mockWatcher struct {
+	clientv3.Watcher
+	ch chan clientv3.WatchResponse
+}
+
+func (w *mockWatcher) Watch(ctx goctx.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	watchCh := make(chan clientv3.WatchResponse)
+	go func() {
+		defer close(watchCh)
+		for {
+			select {
+			case resp := <-w.ch:
+				select {
+				case watchCh <- resp:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return watchCh
+}
+
+// mockElection is the election campaigned with key, whose leader is leader.
+type mockElection struct {
+	key    string
+	leader *clientv3.GetResponse
+}
+
+func (e *mockElection) Key() string { return e.key }
+
+func (e *mockElection) Leader(ctx goctx.Context) (*clientv3.GetResponse, error) {
+	return e.leader, nil
+}
+
+// mockLease returns ttl and err for TimeToLive.
+type mockLease struct {
+	clientv3.Lease
+	ttl int64
+	err error
+}
+
+func (l *mockLease) TimeToLive(ctx goctx.Context, id clientv3.LeaseID,
+	opts ...clientv3.LeaseOption) (*clientv3.LeaseTimeToLiveResponse, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	return &clientv3.LeaseTimeToLiveResponse{ID: id, TTL: l.ttl}, nil
+}
+
+func (s *testOwnerManagerSuite) TestObserveWatchProcessLag(c *C) {
+	defer testleak.AfterTest(c)()
+	clock := &mockOwnerClock{now: time.Now()}
+	m := newOwnerManager(nil, "id", func() {}, clock, newEtcdSession)
+	logger := newOwnerLogger(DDLOwnerKey, "id")
+	resp := timedWatchResponse{recvTime: clock.Now()}
+	c.Assert(m.observeWatchProcessLag(DDLOwnerKey, resp, logger), Equals, time.Duration(0))
+	clock.Sleep(2 * ownerWatchProcessLagWarnThreshold)
+	c.Assert(m.observeWatchProcessLag(DDLOwnerKey, resp, logger), Equals, 2*ownerWatchProcessLagWarnThreshold)
+}
+
+func (s *testOwnerManagerSuite) TestWatchOwner(c *C) {
+	defer testleak.AfterTest(c)()
+	clock := &mockOwnerClock{now: time.Now()}
+	m := newOwnerManager(nil, "id", func() {}, clock, newEtcdSession)
+	watcher := &mockWatcher{ch: make(chan clientv3.WatchResponse)}
+	lease := &mockLease{}
+	m.watcher, m.lease = watcher, lease
+	logger := newOwnerLogger(DDLOwnerKey, "id")
+	ownerKey := DDLOwnerKey + "/1"
+	deleted := clientv3.WatchResponse{Events: []*clientv3.Event{{Type: mvccpb.DELETE}}}
+
+	// The session is done.
+	session := &mockOwnerSession{done: make(chan struct{}), lease: 1}
+	close(session.done)
+	reason := m.watchOwner(goctx.Background(), session, DDLOwnerKey, ownerKey, logger)
+	c.Assert(reason, Equals, stepDownSessionDone)
+	c.Assert(clock.tickers, HasLen, 1)
+	c.Assert(clock.tickers[0].interval, Equals, ownerPriorityCheckInterval)
+	c.Assert(clock.tickers[0].stopped, IsTrue)
+
+	// The owner key is deleted, and the lease isn't found.
+	session = &mockOwnerSession{done: make(chan struct{}), lease: 1}
+	lease.err = rpctypes.ErrLeaseNotFound
+	go func() { watcher.ch <- deleted }()
+	reason = m.watchOwner(goctx.Background(), session, DDLOwnerKey, ownerKey, logger)
+	c.Assert(reason, Equals, stepDownLeaseExpired)
+
+	// The owner key is deleted, and the lease is alive.
+	lease.err, lease.ttl = nil, 10
+	go func() { watcher.ch <- deleted }()
+	reason = m.watchOwner(goctx.Background(), session, DDLOwnerKey, ownerKey, logger)
+	c.Assert(reason, Equals, stepDownKeyDeleted)
+
+	// The ctx is done.
+	ctx, cancel := goctx.WithCancel(goctx.Background())
+	cancel()
+	reason = m.watchOwner(ctx, session, DDLOwnerKey, ownerKey, logger)
+	c.Assert(reason, Equals, stepDownCtxDone)
+	for _, t := range clock.tickers {
+		c.Assert(t.stopped, IsTrue)
+	}
+}
+
+func (s *testOwnerManagerSuite) TestHandleLeaseNotFound(c *C) {
+	defer testleak.AfterTest(c)()
+	clock := &mockOwnerClock{now: time.Now()}
+	m := newOwnerManager(nil, "id", func() {}, clock, newEtcdSession)
+	logger := newOwnerLogger(DDLOwnerKey, "id")
+	var skew clockSkewTracker
+	for i := 1; i <= clockSkewThreshold+1; i++ {
+		session := &mockOwnerSession{done: make(chan struct{}), lease: 1}
+		err := m.handleLeaseNotFound(goctx.Background(), session, DDLOwnerKey, &skew, logger)
+		c.Assert(err, IsNil)
+		c.Assert(session.closeCnt, Equals, 1)
+		c.Assert(clock.sleeps, HasLen, i)
+		c.Assert(clock.sleeps[i-1], Equals, time.Duration(i)*clockSkewBackoffUnit)
+	}
+}
+
+func (s *testOwnerManagerSuite) TestOwnerKeyState(c *C) {
+	defer testleak.AfterTest(c)()
+	val := encodeOwnerValue("id1", ownerVersion)
+	lease := clientv3.LeaseID(1)
+	c.Assert(getOwnerKeyState(nil, val, lease), Equals, ownerKeyDeleted)
+	c.Assert(getOwnerKeyState([]*mvccpb.KeyValue{{Value: []byte(val), Lease: 1}}, val, lease), Equals, ownerKeyHeld)
+	// The key is held by another manager or another session.
+	c.Assert(getOwnerKeyState([]*mvccpb.KeyValue{{Value: []byte(encodeOwnerValue("id2", ownerVersion)), Lease: 1}}, val, lease),
+		Equals, ownerKeyMismatch)
+	c.Assert(getOwnerKeyState([]*mvccpb.KeyValue{{Value: []byte(val), Lease: 2}}, val, lease), Equals, ownerKeyMismatch)
+}
+
+func (s *testOwnerManagerSuite) TestNewSessionRetry(c *C) {
+	defer testleak.AfterTest(c)()
+	clock := &mockOwnerClock{now: time.Now()}
+	failCnt := 2
+	var ttls []int
+	factory := func(ctx goctx.Context, etcdCli *clientv3.Client, ttl int) (*concurrency.Session, error) {
+		ttls = append(ttls, ttl)
+		if len(ttls) <= failCnt {
+			return nil, errors.New("mock new session error")
+		}
+		return nil, nil
+	}
+	m := newOwnerManager(nil, "id", func() {}, clock, factory)
+	_, err := m.newSession(goctx.Background(), "test", newSessionDefaultRetryCnt)
+	c.Assert(err, IsNil)
+	c.Assert(ttls, HasLen, failCnt+1)
+	c.Assert(clock.sleeps, HasLen, failCnt)
+
+	// The new TTL takes effect on the next session.
+	oldTTL := getManagerSessionTTL()
+	defer SetManagerSessionTTL(oldTTL)
+	c.Assert(SetManagerSessionTTL(0), NotNil)
+	c.Assert(SetManagerSessionTTL(oldTTL+1), IsNil)
+	ttls = ttls[:0]
+	failCnt = newSessionDefaultRetryCnt
+	_, err = m.newSession(goctx.Background(), "test", newSessionDefaultRetryCnt)
+	c.Assert(err, NotNil)
+	c.Assert(ttls, HasLen, newSessionDefaultRetryCnt)
+	c.Assert(ttls[0], Equals, oldTTL+1)
+
+	// It stops retrying when the ctx is done.
+	ctx, cancel := goctx.WithCancel(goctx.Background())
+	cancel()
+	_, err = m.newSession(ctx, "test", newSessionRetryUnlimited)
+	c.Assert(terror.ErrorEqual(err, goctx.Canceled), IsTrue)
+}
+
+func (s *testOwnerManagerSuite) TestStopCampaign(c *C) {
+	defer testleak.AfterTest(c)()
+	m := newOwnerManager(nil, "id", func() {}, realClock{}, newEtcdSession)
+	ownerCh := make(chan OwnerChange, 2)
+	m.RegisterOwnerChangeCh(ownerCh)
+
+	// Simulate a running campaign loop that has become the owner.
+	ctx, cancel := goctx.WithCancel(goctx.Background())
+	h := &campaignHandle{cancel: cancel, done: make(chan struct{})}
+	m.campaigns[DDLOwnerKey] = h
+	go func() {
+		<-ctx.Done()
+		close(h.done)
+	}()
+	m.SetOwner(true)
+	c.Assert(<-ownerCh, Equals, OwnerChange{IsOwner: true, OwnerID: "id"})
+	c.Assert(terror.ErrorEqual(m.CampaignOwner(goctx.Background()), errAlreadyCampaign), IsTrue)
+
+	m.StopCampaign()
+	c.Assert(m.IsOwner(), IsFalse)
+	c.Assert(<-ownerCh, Equals, OwnerChange{IsOwner: false})
+	c.Assert(m.campaigns, HasLen, 0)
+	// Stopping a stopped campaign is a no-op.
+	m.StopCampaign()
+
+	// The mock manager can be restarted too.
+	mock := NewMockOwnerManager("id", func() {})
+	mock.RegisterOwnerChangeCh(ownerCh)
+	c.Assert(mock.CampaignOwner(goctx.Background()), IsNil)
+	c.Assert(<-ownerCh, Equals, OwnerChange{IsOwner: true, OwnerID: "id"})
+	mock.StopCampaign()
+	c.Assert(mock.IsOwner(), IsFalse)
+	c.Assert(<-ownerCh, Equals, OwnerChange{IsOwner: false})
+	c.Assert(mock.CampaignOwner(goctx.Background()), IsNil)
+	c.Assert(<-ownerCh, Equals, OwnerChange{IsOwner: true, OwnerID: "id"})
+}
+
+func (s *testOwnerManagerSuite) TestCampaignCreatesSessionWithoutLock(c *C) {
+	defer testleak.AfterTest(c)()
+	created := make(chan struct{}, 1)
+	factory := func(ctx goctx.Context, etcdCli *clientv3.Client, ttl int) (*concurrency.Session, error) {
+		created <- struct{}{}
+		<-ctx.Done()
+		return nil, errors.Trace(ctx.Err())
+	}
+	m := newOwnerManager(nil, "id", func() {}, &mockOwnerClock{now: time.Now()}, factory)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- m.CampaignOwner(goctx.Background())
+	}()
+	<-created
+
+	// The campaigns aren't blocked by the session being created.
+	c.Assert(terror.ErrorEqual(m.CampaignOwner(goctx.Background()), errAlreadyCampaign), IsTrue)
+	m.StopCampaign()
+	c.Assert(terror.ErrorEqual(<-errCh, goctx.Canceled), IsTrue)
+	exited, err := m.CampaignLoopExited(DDLOwnerKey)
+	c.Assert(exited, IsTrue)
+	c.Assert(err, NotNil)
+	c.Assert(m.campaigns, HasLen, 0)
+}
+
+func (s *testOwnerManagerSuite) TestCancelSharedClient(c *C) {
+	defer testleak.AfterTest(c)()
+	cli := clientv3.NewCtxClient(goctx.Background())
+	parentCtx, parentCancel := goctx.WithCancel(goctx.Background())
+	defer parentCancel()
+	ctx1, cancel1 := goctx.WithCancel(parentCtx)
+	ctx2, cancel2 := goctx.WithCancel(parentCtx)
+	m1 := newOwnerManager(cli, "id1", cancel1, realClock{}, newEtcdSession)
+	m2 := newOwnerManager(cli, "id2", cancel2, realClock{}, newEtcdSession)
+
+	// Simulate the campaign loops that are started with the shared ctx instead of the managers' own ctx,
+	// they become the owners of their keys and step down when their ctx is done.
+	startLoop := func(m *ownerManager, key string) *campaignHandle {
+		ctx, cancel := goctx.WithCancel(parentCtx)
+		h := &campaignHandle{cancel: cancel, done: make(chan struct{})}
+		m.campaigns[key] = h
+		m.setOwnerVal(key, true)
+		go func() {
+			<-ctx.Done()
+			m.setOwnerVal(key, false)
+			close(h.done)
+		}()
+		return h
+	}
+	h1 := startLoop(m1, DDLOwnerKey)
+	h2 := startLoop(m2, DDLOwnerKey)
+	h2Stats := startLoop(m2, "/tidb/stats/owner")
+
+	m1.Cancel()
+	<-h1.done
+	c.Assert(ctx1.Err(), NotNil)
+	c.Assert(m1.IsOwner(), IsFalse)
+	c.Assert(terror.ErrorEqual(m1.WaitUntilOwner(goctx.Background()), goctx.Canceled), IsTrue)
+
+	// The other manager keeps campaigning and retains the ownership, and the shared client isn't closed.
+	c.Assert(ctx2.Err(), IsNil)
+	c.Assert(cli.Ctx().Err(), IsNil)
+	for _, h := range []*campaignHandle{h2, h2Stats} {
+		select {
+		case <-h.done:
+			c.Fatal("the campaign loop of the other manager is cancelled")
+		default:
+		}
+	}
+	c.Assert(m2.IsOwner(), IsTrue)
+	c.Assert(m2.IsKeyOwner("/tidb/stats/owner"), IsTrue)
+	c.Assert(m2.WaitUntilOwnerIfNot(goctx.Background()), IsNil)
+
+	m2.Cancel()
+	<-h2.done
+	<-h2Stats.done
+	c.Assert(m2.IsOwner(), IsFalse)
+	c.Assert(m2.IsKeyOwner("/tidb/stats/owner"), IsFalse)
+}
+
+func (s *testOwnerManagerSuite) TestOwnerChangePayload(c *C) {
+	defer testleak.AfterTest(c)()
+	m := newOwnerManager(nil, "id1", func() {}, realClock{}, newEtcdSession)
+	ownerCh := make(chan OwnerChange, 5)
+	m.RegisterOwnerChangeCh(ownerCh)
+
+	m.SetOwner(true)
+	c.Assert(<-ownerCh, Equals, OwnerChange{IsOwner: true, OwnerID: "id1"})
+	// The new owner is unknown when the manager just steps down.
+	m.SetOwner(false)
+	c.Assert(<-ownerCh, Equals, OwnerChange{IsOwner: false})
+	// The observer sees that another manager becomes the owner.
+	m.notifier.notifyOtherOwner("id2")
+	c.Assert(<-ownerCh, Equals, OwnerChange{IsOwner: false, OwnerID: "id2"})
+	// The same owner isn't notified again.
+	m.notifier.notifyOtherOwner("id2")
+	m.notifier.notifyOtherOwner("id3")
+	c.Assert(<-ownerCh, Equals, OwnerChange{IsOwner: false, OwnerID: "id3"})
+	m.SetOwner(true)
+	c.Assert(<-ownerCh, Equals, OwnerChange{IsOwner: true, OwnerID: "id1"})
+	c.Assert(ownerCh, HasLen, 0)
+}
+
+func (s *testOwnerManagerSuite) TestDryRunCampaign(c *C) {
+	defer testleak.AfterTest(c)()
+	newKVs := func(ids ...string) []*mvccpb.KeyValue {
+		kvs := make([]*mvccpb.KeyValue, 0, len(ids))
+		for i, id := range ids {
+			kvs = append(kvs, &mvccpb.KeyValue{
+				Key:            []byte(fmt.Sprintf("%s/%x", DDLOwnerKey, i)),
+				Value:          []byte(encodeOwnerValue(id, ownerVersion)),
+				CreateRevision: int64(i + 1),
+			})
+		}
+		return kvs
+	}
+	pos := newCampaignPosition("id1", nil)
+	c.Assert(pos.LeaderID, Equals, "")
+	c.Assert(pos.Campaigners, HasLen, 0)
+	c.Assert(pos.Position, Equals, 0)
+
+	pos = newCampaignPosition("id1", newKVs("id2", "id1", "id3"))
+	c.Assert(pos.LeaderID, Equals, "id2")
+	c.Assert(pos.Campaigners, DeepEquals, []string{"id2", "id1", "id3"})
+	c.Assert(pos.Position, Equals, 1)
+
+	// The manager that isn't campaigning is queued at the end.
+	pos = newCampaignPosition("id4", newKVs("id2", "id1", "id3"))
+	c.Assert(pos.LeaderID, Equals, "id2")
+	c.Assert(pos.Position, Equals, 3)
+
+	m := NewMockOwnerManager("id", func() {})
+	_, err := m.DryRunCampaign(goctx.Background(), "")
+	c.Assert(err, NotNil)
+	pos, err = m.DryRunCampaign(goctx.Background(), DDLOwnerKey)
+	c.Assert(err, IsNil)
+	c.Assert(pos.LeaderID, Equals, "")
+	c.Assert(m.CampaignOwner(goctx.Background()), IsNil)
+	pos, err = m.DryRunCampaign(goctx.Background(), DDLOwnerKey)
+	c.Assert(err, IsNil)
+	c.Assert(pos.LeaderID, Equals, "id")
+	c.Assert(pos.Position, Equals, 0)
+}
+
+func (s *testOwnerManagerSuite) TestDoIfOwner(c *C) {
+	defer testleak.AfterTest(c)()
+	runCnt := 0
+	fn := func(ctx goctx.Context) error {
+		runCnt++
+		return errors.New("fn error")
+	}
+	m := newOwnerManager(nil, "id", func() {}, realClock{}, newEtcdSession)
+	c.Assert(terror.ErrorEqual(m.DoIfOwner(goctx.Background(), fn), errNotOwner), IsTrue)
+	// The owner without the live session isn't a confirmed owner.
+	m.SetOwner(true)
+	c.Assert(terror.ErrorEqual(m.DoIfOwner(goctx.Background(), fn), errNotOwner), IsTrue)
+	c.Assert(runCnt, Equals, 0)
+	// The check doesn't create the status of the key that isn't campaigned.
+	_, ok := m.statuses.get(DDLOwnerKey)
+	c.Assert(ok, IsFalse)
+
+	mock := NewMockOwnerManager("id", func() {})
+	c.Assert(terror.ErrorEqual(mock.DoIfOwner(goctx.Background(), fn), errNotOwner), IsTrue)
+	c.Assert(mock.CampaignOwner(goctx.Background()), IsNil)
+	c.Assert(mock.DoIfOwner(goctx.Background(), fn), ErrorMatches, "fn error")
+	c.Assert(runCnt, Equals, 1)
+
+	c.Assert(isOwnerKeyDeleted(clientv3.WatchResponse{}), IsFalse)
+	c.Assert(isOwnerKeyDeleted(clientv3.WatchResponse{Events: []*clientv3.Event{
+		{Type: mvccpb.PUT}, {Type: mvccpb.DELETE}}}), IsTrue)
+}
+
+func (s *testOwnerManagerSuite) TestOwnerVersion(c *C) {
+	defer testleak.AfterTest(c)()
+	id, version := decodeOwnerValue(encodeOwnerValue("id1", 2))
+	c.Assert(id, Equals, "id1")
+	c.Assert(version, Equals, int64(2))
+	// The value campaigned by the old release has no version.
+	id, version = decodeOwnerValue("id1")
+	c.Assert(id, Equals, "id1")
+	c.Assert(version, Equals, int64(0))
+	id, version = decodeOwnerValue("id1@x")
+	c.Assert(id, Equals, "id1@x")
+	c.Assert(version, Equals, int64(0))
+
+	kvs := []*mvccpb.KeyValue{
+		{Value: []byte("id0")},
+		{Value: []byte(encodeOwnerValue("id1", 1))},
+		{Value: []byte(encodeOwnerValue("id2", 2))},
+	}
+	higherID, ok := findHigherVersion(1, kvs)
+	c.Assert(ok, IsTrue)
+	c.Assert(higherID, Equals, "id2")
+	_, ok = findHigherVersion(2, kvs)
+	c.Assert(ok, IsFalse)
+
+	m := NewMockOwnerManager("id", func() {})
+	c.Assert(m.CampaignOwner(goctx.Background()), IsNil)
+	id, version, err := m.GetOwnerVersion(goctx.Background(), DDLOwnerKey)
+	c.Assert(err, IsNil)
+	c.Assert(id, Equals, "id")
+	c.Assert(version, Equals, ownerVersion)
+}
+
+func (s *testOwnerManagerSuite) TestCheckOwnerKey(c *C) {
+	defer testleak.AfterTest(c)()
+	c.Assert(checkOwnerKey(DDLOwnerKey), IsNil)
+	c.Assert(checkOwnerKey("/tidb/stats/owner"), IsNil)
+	for _, key := range []string{"", "/tidb/", "/tidb", "tidb/ddl/owner", "/ddl/owner", "/tidb/ddl/owner/"} {
+		c.Assert(checkOwnerKey(key), NotNil, Commentf("key %q", key))
+	}
+
+	m := NewMockOwnerManager("id", func() {})
+	c.Assert(m.CampaignOwnerKey(goctx.Background(), "/custom/owner"), NotNil)
+	SkipOwnerKeyCheck = true
+	defer func() { SkipOwnerKeyCheck = false }()
+	c.Assert(checkOwnerKey(""), NotNil)
+	c.Assert(m.CampaignOwnerKey(goctx.Background(), "/custom/owner"), IsNil)
+}
+
+func (s *testOwnerManagerSuite) TestCampaignLoopPanic(c *C) {
+	defer testleak.AfterTest(c)()
+	clock := &mockOwnerClock{now: time.Now()}
+	newSessionCnt := 0
+	factory := func(ctx goctx.Context, etcdCli *clientv3.Client, ttl int) (*concurrency.Session, error) {
+		newSessionCnt++
+		return nil, nil
+	}
+	// The campaign loop isn't exited while it's waiting to be restarted.
+	afterCnt := 0
+	hooked := &hookedOwnerClock{mockOwnerClock: clock}
+	var m *ownerManager
+	hooked.onAfter = func(d time.Duration) {
+		afterCnt++
+		c.Assert(d, Equals, campaignLoopRestartBackoff)
+		exited, reason := m.CampaignLoopExited(DDLOwnerKey)
+		c.Assert(exited, IsFalse)
+		c.Assert(reason, IsNil)
+		st, ok := m.statuses.get(DDLOwnerKey)
+		c.Assert(ok, IsTrue)
+		c.Assert(st.running, IsFalse)
+	}
+	m = newOwnerManager(nil, "id", func() {}, hooked, factory)
+	m.SetOwner(true)
+	// The campaign loop panics with a nil session every time.
+	m.runCampaignLoop(goctx.Background(), nil, DDLOwnerKey)
+	c.Assert(m.IsOwner(), IsFalse)
+	c.Assert(newSessionCnt, Equals, campaignLoopMaxRestarts)
+	c.Assert(clock.sleeps, HasLen, campaignLoopMaxRestarts)
+	c.Assert(afterCnt, Equals, campaignLoopMaxRestarts)
+	exited, reason := m.CampaignLoopExited(DDLOwnerKey)
+	c.Assert(exited, IsTrue)
+	c.Assert(reason, ErrorMatches, "campaign loop panics.*")
+	healthy, _ := m.HealthStatus()
+	c.Assert(healthy, IsFalse)
+}
@@ -228,8 +228,21 @@ func (do *Domain) InfoSchema() infoschema.InfoSchema {
 
 // GetSnapshotInfoSchema gets a snapshot information schema.
 func (do *Domain) GetSnapshotInfoSchema(snapshotTS uint64) (infoschema.InfoSchema, error) {
+	snapshot, err := do.store.GetSnapshot(kv.NewVersion(snapshotTS))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	snapVersion, err := meta.NewSnapshotMeta(snapshot).GetSchemaVersion()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// The schema isn't changed since the snapshot, reuse the cached one.
+	if is := do.infoHandle.Get(); is != nil && is.SchemaMetaVersion() == snapVersion {
+		return is, nil
+	}
+	// Do a full load, the empty handle has no schema to apply the diffs to.
 	snapHandle := do.infoHandle.EmptyClone()
-	_, _, err := do.loadInfoSchema(snapHandle, do.infoHandle.Get().SchemaMetaVersion(), snapshotTS)
+	_, _, err = do.loadInfoSchema(snapHandle, 0, snapshotTS)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -105,6 +105,24 @@ func (s *testSuite) TestAggregation(c *C) {
 	result.Check(testkit.Rows())
 	result = tk.MustQuery("select c as a from t group by d having sum(a) = 2")
 	result.Check(testkit.Rows("<nil>"))
+	// A GROUP BY column referred by HAVING needn't be selected.
+	result = tk.MustQuery("select count(*) from t group by d having d > 1")
+	result.Check(testkit.Rows("2", "2"))
+	result = tk.MustQuery("select count(*) from t group by t.d having d = 1")
+	result.Check(testkit.Rows("3"))
+	result = tk.MustQuery("select count(*) from t k group by d having k.d < 2 and count(*) > 1")
+	result.Check(testkit.Rows("3"))
+	result = tk.MustQuery("select sum(c) from t group by c, d having d > 2 and c > 3")
+	result.Check(testkit.Rows("4"))
+	result = tk.MustQuery("select count(*) from t group by d having d > 1 order by d")
+	result.Check(testkit.Rows("2", "2"))
+	// An aggregate function only in ORDER BY makes the whole query a single group.
+	result = tk.MustQuery("select count(d) from t order by count(*)")
+	result.Check(testkit.Rows("7"))
+	result = tk.MustQuery("select d from t where c > 100 order by count(*)")
+	result.Check(testkit.Rows("<nil>"))
+	result = tk.MustQuery("select d + 1 from t where d = 3 order by sum(c)")
+	result.Check(testkit.Rows("4"))
 	result = tk.MustQuery("select count(distinct c) from t group by d")
 	result.Check(testkit.Rows("1", "2", "2"))
 	result = tk.MustQuery("select sum(c) from t group by d")
@@ -135,6 +153,8 @@ func (s *testSuite) TestAggregation(c *C) {
 	result.Check(testkit.Rows("2", "2"))
 	result = tk.MustQuery("select max(c) from t group by d having sum(c) > 3 order by avg(c) desc")
 	result.Check(testkit.Rows("4", "3"))
+	result = tk.MustQuery("select d from t group by d order by count(*), d desc")
+	result.Check(testkit.Rows("3", "2", "1"))
 	result = tk.MustQuery("select sum(-1) from t a left outer join t b on not null is null")
 	result.Check(testkit.Rows("-7"))
 	result = tk.MustQuery("select count(*), b.d from t a left join t b on a.c = b.d group by b.d order by b.d")
@@ -216,6 +236,22 @@ func (s *testSuite) TestAggregation(c *C) {
 	c.Assert(err, NotNil)
 	result = tk.MustQuery("select *, c+1 as d from t group by 3")
 	result.Check(testkit.Rows("1 -1 2"))
+	// The select field aliased with the name of a base column is shadowed by the column in GROUP BY.
+	result = tk.MustQuery("select d*d as d from t group by d")
+	result.Sort().Check(testkit.Rows("0", "1", "1"))
+	c.Assert(tk.Se.GetSessionVars().StmtCtx.WarningCount(), Equals, uint16(1))
+	result = tk.MustQuery("select c as d from t group by d")
+	result.Check(testkit.Rows("1", "1", "1"))
+	c.Assert(tk.Se.GetSessionVars().StmtCtx.WarningCount(), Equals, uint16(1))
+	result = tk.MustQuery("select d*d as d from t group by d+0")
+	result.Sort().Check(testkit.Rows("0", "1", "1"))
+	c.Assert(tk.Se.GetSessionVars().StmtCtx.WarningCount(), Equals, uint16(0))
+	result = tk.MustQuery("select t.d as d from t group by d")
+	result.Sort().Check(testkit.Rows("-1", "0", "1"))
+	c.Assert(tk.Se.GetSessionVars().StmtCtx.WarningCount(), Equals, uint16(0))
+	result = tk.MustQuery("select d*d as e from t group by e")
+	result.Sort().Check(testkit.Rows("0", "1"))
+	c.Assert(tk.Se.GetSessionVars().StmtCtx.WarningCount(), Equals, uint16(0))
 	tk.MustExec("drop table if exists t1")
 	tk.MustExec("create table t1(a float, b int default 3)")
 	tk.MustExec("insert into t1 (a) values (2), (11), (8)")
@@ -312,6 +348,17 @@ func (s *testSuite) TestAggregation(c *C) {
 	tk.MustExec("create table idx_agg (a int, b int, index (b))")
 	tk.MustExec("insert idx_agg values (1, 1), (1, 2), (2, 2)")
 	tk.MustQuery("select sum(a), sum(b) from idx_agg where b > 0 and b < 10")
+
+	// GROUP BY () is a single group over all rows.
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int, b int)")
+	tk.MustQuery("select count(*), sum(a) from t group by ()").Check(testkit.Rows("0 <nil>"))
+	tk.MustQuery("select count(*) from t where exists (select count(*) from t group by ())").Check(testkit.Rows("0"))
+	tk.MustQuery("select exists (select count(*) from t group by ())").Check(testkit.Rows("1"))
+	tk.MustExec("insert t values (1, 1), (1, 2), (3, 2)")
+	tk.MustQuery("select count(*), sum(a), max(b) from t group by ()").Check(testkit.Rows("3 5 2"))
+	tk.MustQuery("select count(*) from t group by () having count(*) > 3").Check(testkit.Rows())
+	tk.MustQuery("select a, (select count(*) from t s where s.a = t.a group by ()) from t order by a, b").Check(testkit.Rows("1 2", "1 2", "3 1"))
 }
 
 func (s *testSuite) TestAggPrune(c *C) {
@@ -367,3 +414,26 @@ func (s *testSuite) TestAggPushDown(c *C) {
 	tk.MustQuery("select max(a.b), max(b.b) from t a join tt b on a.a = b.a group by a.c").Check(testkit.Rows("1 2"))
 	tk.MustQuery("select a, count(b) from (select * from t union all select * from tt) k group by a").Check(testkit.Rows("1 2", "2 1"))
 }
+
+func (s *testSuite) TestGroupByIndexHint(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t(a int, b int, c int, index ia (a), index iba (b, a))")
+	tk.MustExec("insert into t values(1, 2, 3), (2, 1, 3), (1, 1, 1), (3, 2, 1), (null, 1, 2)")
+	tk.MustQuery("select a, count(*) from t force index for group by (ia) group by a").Check(testkit.Rows("<nil> 1", "1 2", "2 1", "3 1"))
+	tk.MustQuery("select a, b, sum(c) from t force index for group by (iba) group by a, b").Check(testkit.Rows("<nil> 1 2", "1 1 1", "2 1 3", "1 2 3", "3 2 1"))
+	tk.MustQuery("select a, count(*) from t force index for group by (ia) where c > 1 group by a").Check(testkit.Rows("<nil> 1", "1 1", "2 1"))
+	tk.MustQuery("show warnings").Check(testkit.Rows())
+	tk.MustExec("begin")
+	tk.MustExec("insert into t values(0, 0, 0), (1, 0, 0)")
+	tk.MustQuery("select a, count(*) from t force index for group by (ia) group by a").Check(testkit.Rows("<nil> 1", "0 1", "1 3", "2 1", "3 1"))
+	tk.MustExec("rollback")
+
+	tk.MustQuery("select a, count(*) from t force index for group by (iba) group by a order by a").Check(testkit.Rows("<nil> 1", "1 2", "2 1", "3 1"))
+	tk.MustQuery("show warnings").Check(testkit.Rows("Warning 1105 Index 'iba' of table 't' can't satisfy the GROUP BY, it's ignored by FORCE INDEX FOR GROUP BY"))
+}
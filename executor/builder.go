@@ -203,9 +203,11 @@ func (b *executorBuilder) buildSelectLock(v *plan.SelectLock) Executor {
 
 func (b *executorBuilder) buildLimit(v *plan.Limit) Executor {
 	e := &LimitExec{
-		baseExecutor: newBaseExecutor(v.Schema(), b.ctx, b.build(v.Children()[0])),
-		Offset:       v.Offset,
-		Count:        v.Count,
+		baseExecutor:  newBaseExecutor(v.Schema(), b.ctx, b.build(v.Children()[0])),
+		Offset:        v.Offset,
+		Count:         v.Count,
+		CalcFoundRows: v.CalcFoundRows,
+		ByItems:       v.ByItems,
 	}
 	return e
 }
@@ -275,6 +277,10 @@ func (b *executorBuilder) buildInsert(v *plan.Insert) Executor {
 		Columns: v.Columns,
 		Lists:   v.Lists,
 		Setlist: v.Setlist,
+
+		DefaultFills: v.DefaultFills,
+		GenCols:      v.GenCols,
+		Ignore:       v.Ignore,
 	}
 	if len(v.Children()) > 0 {
 		ivs.SelectExec = b.build(v.Children()[0])
@@ -287,7 +293,6 @@ func (b *executorBuilder) buildInsert(v *plan.Insert) Executor {
 		InsertValues: ivs,
 		OnDuplicate:  v.OnDuplicate,
 		Priority:     v.Priority,
-		Ignore:       v.Ignore,
 	}
 	return insert
 }
@@ -602,6 +607,20 @@ func (b *executorBuilder) getStartTS() uint64 {
 	return startTS
 }
 
+// getTable returns the table with tblInfo. The table read AS OF TIMESTAMP is built from the snapshot meta, because it
+// may have been altered or dropped since then.
+func (b *executorBuilder) getTable(tblInfo *model.TableInfo, asOfTS uint64) table.Table {
+	if asOfTS == 0 {
+		t, _ := b.is.TableByID(tblInfo.ID)
+		return t
+	}
+	t, err := table.TableFromMeta(nil, tblInfo)
+	if err != nil {
+		b.err = errors.Trace(err)
+	}
+	return t
+}
+
 func (b *executorBuilder) buildMemTable(v *plan.PhysicalMemTable) Executor {
 	table, _ := b.is.TableByID(v.Table.ID)
 	ts := &TableScanExec{
@@ -618,10 +637,13 @@ func (b *executorBuilder) buildMemTable(v *plan.PhysicalMemTable) Executor {
 
 func (b *executorBuilder) buildTableScan(v *plan.PhysicalTableScan) Executor {
 	startTS := b.getStartTS()
+	if v.AsOfTS != 0 {
+		startTS = v.AsOfTS
+	}
+	table := b.getTable(v.Table, v.AsOfTS)
 	if b.err != nil {
 		return nil
 	}
-	table, _ := b.is.TableByID(v.Table.ID)
 	client := b.ctx.GetClient()
 	supportDesc := client.IsRequestTypeSupported(kv.ReqTypeSelect, kv.ReqSubTypeDesc)
 	var handleCol *expression.Column
@@ -653,10 +675,13 @@ func (b *executorBuilder) buildTableScan(v *plan.PhysicalTableScan) Executor {
 
 func (b *executorBuilder) buildIndexScan(v *plan.PhysicalIndexScan) Executor {
 	startTS := b.getStartTS()
+	if v.AsOfTS != 0 {
+		startTS = v.AsOfTS
+	}
+	table := b.getTable(v.Table, v.AsOfTS)
 	if b.err != nil {
 		return nil
 	}
-	table, _ := b.is.TableByID(v.Table.ID)
 	client := b.ctx.GetClient()
 	supportDesc := client.IsRequestTypeSupported(kv.ReqTypeIndex, kv.ReqSubTypeDesc)
 	var handleCol *expression.Column
@@ -806,6 +831,7 @@ func (b *executorBuilder) buildExists(v *plan.Exists) Executor {
 func (b *executorBuilder) buildMaxOneRow(v *plan.MaxOneRow) Executor {
 	return &MaxOneRowExec{
 		baseExecutor: newBaseExecutor(v.Schema(), b.ctx, b.build(v.Children()[0])),
+		once:         v.Once,
 	}
 }
 
@@ -999,6 +1025,16 @@ func (b *executorBuilder) buildAnalyze(v *plan.Analyze) Executor {
 func (b *executorBuilder) constructDAGReq(plans []plan.PhysicalPlan) *tipb.DAGRequest {
 	dagReq := &tipb.DAGRequest{}
 	dagReq.StartTs = b.getStartTS()
+	switch x := plans[0].(type) {
+	case *plan.PhysicalTableScan:
+		if x.AsOfTS != 0 {
+			dagReq.StartTs = x.AsOfTS
+		}
+	case *plan.PhysicalIndexScan:
+		if x.AsOfTS != 0 {
+			dagReq.StartTs = x.AsOfTS
+		}
+	}
 	dagReq.TimeZoneOffset = timeZoneOffset(b.ctx)
 	sc := b.ctx.GetSessionVars().StmtCtx
 	dagReq.Flags = statementContextToFlags(sc)
@@ -1038,7 +1074,10 @@ func (b *executorBuilder) buildTableReader(v *plan.PhysicalTableReader) Executor
 		return nil
 	}
 	ts := v.TablePlans[0].(*plan.PhysicalTableScan)
-	table, _ := b.is.TableByID(ts.Table.ID)
+	table := b.getTable(ts.Table, ts.AsOfTS)
+	if b.err != nil {
+		return nil
+	}
 	var handleCol *expression.Column
 	if v.NeedColHandle {
 		handleCol = v.Schema().TblID2Handle[ts.Table.ID][0]
@@ -1073,7 +1112,10 @@ func (b *executorBuilder) buildIndexReader(v *plan.PhysicalIndexReader) Executor
 		return nil
 	}
 	is := v.IndexPlans[0].(*plan.PhysicalIndexScan)
-	table, _ := b.is.TableByID(is.Table.ID)
+	table := b.getTable(is.Table, is.AsOfTS)
+	if b.err != nil {
+		return nil
+	}
 	var handleCol *expression.Column
 	if v.NeedColHandle {
 		handleCol = v.Schema().TblID2Handle[is.Table.ID][0]
@@ -1114,7 +1156,10 @@ func (b *executorBuilder) buildIndexLookUpReader(v *plan.PhysicalIndexLookUpRead
 		return nil
 	}
 	is := v.IndexPlans[0].(*plan.PhysicalIndexScan)
-	table, _ := b.is.TableByID(is.Table.ID)
+	table := b.getTable(is.Table, is.AsOfTS)
+	if b.err != nil {
+		return nil
+	}
 	var handleCol *expression.Column
 	if v.NeedColHandle {
 		handleCol = v.Schema().TblID2Handle[is.Table.ID][0]
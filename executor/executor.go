@@ -275,12 +275,22 @@ type LimitExec struct {
 	Offset uint64
 	Count  uint64
 	Idx    uint64
+	// CalcFoundRows means the rows out of the limit are counted into the found rows of the statement.
+	CalcFoundRows bool
+	// ByItems are the ORDER BY items of a WITH TIES limit, the rows out of the limit are still
+	// returned while they are equal to the last row of the limit on them.
+	ByItems []*plan.ByItems
+
+	lastKey []types.Datum
+	tiesEnd bool
+	// childDone means the child has returned all its rows, so it isn't called again.
+	childDone bool
 }
 
 // Next implements the Executor Next interface.
 func (e *LimitExec) Next() (Row, error) {
 	for e.Idx < e.Offset {
-		srcRow, err := e.children[0].Next()
+		srcRow, err := e.childNext()
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
@@ -288,11 +298,23 @@ func (e *LimitExec) Next() (Row, error) {
 			return nil, nil
 		}
 		e.Idx++
+		if e.CalcFoundRows {
+			e.ctx.GetSessionVars().StmtCtx.AddFoundRows(1)
+		}
 	}
 	if e.Idx >= e.Count+e.Offset {
+		if len(e.ByItems) > 0 && e.Count > 0 && !e.tiesEnd {
+			srcRow, err := e.nextTie()
+			if err != nil || srcRow != nil {
+				return srcRow, errors.Trace(err)
+			}
+		}
+		if e.CalcFoundRows {
+			return nil, errors.Trace(e.countRemainingRows())
+		}
 		return nil, nil
 	}
-	srcRow, err := e.children[0].Next()
+	srcRow, err := e.childNext()
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -300,12 +322,95 @@ func (e *LimitExec) Next() (Row, error) {
 		return nil, nil
 	}
 	e.Idx++
+	if len(e.ByItems) > 0 && e.Idx == e.Count+e.Offset {
+		e.lastKey, err = e.evalByItems(srcRow)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return srcRow, nil
+}
+
+// nextTie returns the next row of the child if it's equal to the last row of the limit on the ORDER BY items,
+// otherwise there are no more ties.
+func (e *LimitExec) nextTie() (Row, error) {
+	srcRow, err := e.childNext()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if srcRow == nil {
+		e.tiesEnd = true
+		return nil, nil
+	}
+	key, err := e.evalByItems(srcRow)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	sc := e.ctx.GetSessionVars().StmtCtx
+	for i := range key {
+		cmp, err := key[i].CompareDatum(sc, e.lastKey[i])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if cmp != 0 {
+			e.tiesEnd = true
+			if e.CalcFoundRows {
+				sc.AddFoundRows(1)
+			}
+			return nil, nil
+		}
+	}
 	return srcRow, nil
 }
 
+func (e *LimitExec) evalByItems(row Row) ([]types.Datum, error) {
+	key := make([]types.Datum, len(e.ByItems))
+	for i, item := range e.ByItems {
+		var err error
+		key[i], err = item.Expr.Eval(row)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return key, nil
+}
+
+// countRemainingRows reads the rows beyond the limit and counts them into the found rows.
+func (e *LimitExec) countRemainingRows() error {
+	sc := e.ctx.GetSessionVars().StmtCtx
+	for {
+		srcRow, err := e.childNext()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if srcRow == nil {
+			return nil
+		}
+		sc.AddFoundRows(1)
+	}
+}
+
+// childNext returns the next row of the child, it doesn't call the child again after the child is exhausted.
+func (e *LimitExec) childNext() (Row, error) {
+	if e.childDone {
+		return nil, nil
+	}
+	row, err := e.children[0].Next()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if row == nil {
+		e.childDone = true
+	}
+	return row, nil
+}
+
 // Open implements the Executor Open interface.
 func (e *LimitExec) Open() error {
 	e.Idx = 0
+	e.lastKey = nil
+	e.tiesEnd = false
+	e.childDone = false
 	return errors.Trace(e.children[0].Open())
 }
 
@@ -648,35 +753,68 @@ type MaxOneRowExec struct {
 	baseExecutor
 
 	evaluated bool
+	// once means the row is the same for every execution, it's fetched by the first execution and reused.
+	once      bool
+	cached    bool
+	cachedRow Row
 }
 
 // Open implements the Executor Open interface.
 func (e *MaxOneRowExec) Open() error {
 	e.evaluated = false
+	if e.cached {
+		return nil
+	}
 	return errors.Trace(e.children[0].Open())
 }
 
+// Close implements the Executor Close interface.
+func (e *MaxOneRowExec) Close() error {
+	if e.cached {
+		return nil
+	}
+	return errors.Trace(e.children[0].Close())
+}
+
 // Next implements the Executor Next interface.
 func (e *MaxOneRowExec) Next() (Row, error) {
-	if !e.evaluated {
-		e.evaluated = true
-		srcRow, err := e.children[0].Next()
-		if err != nil {
-			return nil, errors.Trace(err)
-		}
-		if srcRow == nil {
-			return make([]types.Datum, e.schema.Len()), nil
-		}
-		srcRow1, err := e.children[0].Next()
-		if err != nil {
+	if e.evaluated {
+		return nil, nil
+	}
+	e.evaluated = true
+	if e.cached {
+		return e.cachedRow, nil
+	}
+	row, err := e.fetchRow()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if e.once {
+		// The child won't be opened again, so close it as soon as the row is fetched.
+		if err = e.children[0].Close(); err != nil {
 			return nil, errors.Trace(err)
 		}
-		if srcRow1 != nil {
-			return nil, errors.New("subquery returns more than 1 row")
-		}
-		return srcRow, nil
+		e.cached, e.cachedRow = true, row
 	}
-	return nil, nil
+	return row, nil
+}
+
+func (e *MaxOneRowExec) fetchRow() (Row, error) {
+	srcRow, err := e.children[0].Next()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if srcRow == nil {
+		return make([]types.Datum, e.schema.Len()), nil
+	}
+	srcRow1, err := e.children[0].Next()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if srcRow1 != nil {
+		return nil, errors.New("subquery returns more than 1 row")
+	}
+	return srcRow, nil
 }
 
 // UnionExec represents union executor.
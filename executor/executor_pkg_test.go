@@ -14,9 +14,12 @@
 package executor
 
 import (
+	"github.com/juju/errors"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/util/mock"
+	"github.com/pingcap/tidb/util/types"
 )
 
 var _ = Suite(&testExecSuite{})
@@ -61,3 +64,54 @@ func (s *testExecSuite) TestMergeHandles(c *C) {
 		c.Assert(kr.EndKey, DeepEquals, ekr.EndKey)
 	}
 }
+
+// mockExhaustedExec returns its rows, and fails if it's called again after returning all of them.
+type mockExhaustedExec struct {
+	baseExecutor
+	rows []Row
+	idx  int
+	done bool
+}
+
+func (e *mockExhaustedExec) Next() (Row, error) {
+	if e.done {
+		return nil, errors.New("called after exhausted")
+	}
+	if e.idx >= len(e.rows) {
+		e.done = true
+		return nil, nil
+	}
+	e.idx++
+	return e.rows[e.idx-1], nil
+}
+
+func (s *testExecSuite) TestLimitChildExhausted(c *C) {
+	rows := []Row{types.MakeDatums(1), types.MakeDatums(2)}
+	tests := []struct {
+		offset, count uint64
+		expect        int
+	}{
+		// The offset is beyond the rows.
+		{5, 1, 0},
+		// The limit is beyond the rows.
+		{0, 5, 2},
+		// The limit ends with the rows.
+		{1, 1, 1},
+	}
+	for _, t := range tests {
+		ctx := mock.NewContext()
+		child := &mockExhaustedExec{baseExecutor: newBaseExecutor(nil, ctx), rows: rows}
+		e := &LimitExec{baseExecutor: newBaseExecutor(nil, ctx, child), Offset: t.offset, Count: t.count,
+			CalcFoundRows: true}
+		c.Assert(e.Open(), IsNil)
+		cnt := 0
+		for i := 0; i < 3; i++ {
+			row, err := e.Next()
+			c.Assert(err, IsNil)
+			if row != nil {
+				cnt++
+			}
+		}
+		c.Assert(cnt, Equals, t.expect)
+	}
+}
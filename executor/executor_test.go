@@ -27,6 +27,7 @@ import (
 	. "github.com/pingcap/check"
 	pb "github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pingcap/tidb"
+	"github.com/pingcap/tidb/ast"
 	"github.com/pingcap/tidb/context"
 	"github.com/pingcap/tidb/domain"
 	"github.com/pingcap/tidb/executor"
@@ -497,6 +498,54 @@ func (s *testSuite) TestSelectLimit(c *C) {
 	c.Assert(err, NotNil)
 }
 
+func (s *testSuite) TestSelectLimitWithTies(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int, b int)")
+	tk.MustExec("insert t values (1, 1), (2, 2), (2, 3), (2, 4), (3, 5), (3, 6)")
+	// The order of the ties is arbitrary, so they are sorted again.
+	tk.MustQuery("select b from (select * from t order by a limit 2 with ties) s order by b").Check(testkit.Rows("1", "2", "3", "4"))
+	tk.MustQuery("select b from (select * from t order by a limit 1 offset 1 with ties) s order by b").Check(testkit.Rows("2", "3", "4"))
+	tk.MustQuery("select b from (select * from t order by a limit 1, 3 with ties) s order by b").Check(testkit.Rows("2", "3", "4"))
+	tk.MustQuery("select b from (select * from t order by a limit 5 with ties) s order by b").Check(testkit.Rows("1", "2", "3", "4", "5", "6"))
+	tk.MustQuery("select b from (select * from t order by a desc limit 1 with ties) s order by b").Check(testkit.Rows("5", "6"))
+	tk.MustQuery("select b from (select * from t order by a fetch first 1 row with ties) s order by b").Check(testkit.Rows("1"))
+	tk.MustQuery("select b from t order by a limit 0 with ties").Check(testkit.Rows())
+	tk.MustQuery("select b from t order by a, b fetch next 2 rows only").Check(testkit.Rows("1", "2"))
+	tk.MustQuery("select sql_calc_found_rows a from t order by a limit 2 with ties").Check(testkit.Rows("1", "2", "2", "2"))
+	tk.MustQuery("select found_rows()").Check(testkit.Rows("6"))
+
+	_, err := tk.Exec("select * from t limit 1 with ties")
+	c.Assert(plan.ErrWithTiesNoOrderBy.Equal(err), IsTrue)
+}
+
+func (s *testSuite) TestAuxiliaryFieldAlias(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int, b int)")
+	tk.MustExec("insert t values (1, 5), (2, 1), (2, 2), (3, 3)")
+	// The aliases are the same as the names of the auxiliary fields of the aggregate functions in HAVING and ORDER BY.
+	tk.MustQuery("select a as sel_agg_2, b from t group by a, b having sum(b) > 0 order by sel_agg_2 desc, b").Check(
+		testkit.Rows("3 3", "2 1", "2 2", "1 5"))
+	tk.MustQuery("select a as sel_agg_1 from t group by a having sum(b) > 1 order by sel_agg_1 desc").Check(
+		testkit.Rows("3", "2", "1"))
+	tk.MustQuery("select a as sel_agg_1 from t group by a order by sum(b), sel_agg_1").Check(testkit.Rows("2", "3", "1"))
+	tk.MustQuery("select a as sel_agg_1, count(*) from t group by a having sel_agg_1 > 1 and max(b) > 1 order by sel_agg_1").Check(
+		testkit.Rows("2 2", "3 1"))
+	tk.MustQuery("select sel_agg_1 from (select a as sel_agg_1 from t group by a order by sum(b)) s order by sel_agg_1").Check(
+		testkit.Rows("1", "2", "3"))
+}
+
 func (s *testSuite) TestSelectOrderBy(c *C) {
 	defer func() {
 		s.cleanEnv(c)
@@ -578,6 +627,145 @@ func (s *testSuite) TestSelectOrderBy(c *C) {
 	tk.MustQuery("select * from t where a between 1 and 2 order by a desc").Check(testkit.Rows("2 2", "1 1"))
 }
 
+func (s *testSuite) TestTableStmt(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t, t1")
+	tk.MustExec("create table t (a int, b varchar(10))")
+	tk.MustExec("create table t1 (a int)")
+	tk.MustExec("insert t values (3, 'c'), (1, 'a'), (2, 'b')")
+	tk.MustExec("insert t1 values (2), (4)")
+
+	tk.MustQuery("table t order by a").Check(testkit.Rows("1 a", "2 b", "3 c"))
+	tk.MustQuery("table test.t order by t.b desc limit 2").Check(testkit.Rows("3 c", "2 b"))
+	tk.MustQuery("table t order by 1 limit 1, 1").Check(testkit.Rows("2 b"))
+	tk.MustQuery("select b from t where a in (table t1)").Check(testkit.Rows("b"))
+	tk.MustQuery("(select a from t where a > 2) union all (table t1) order by a").Check(testkit.Rows("2", "3", "4"))
+	tk.MustQuery("select count(*) from t").Check(testkit.Rows("3"))
+	rs, err := tk.Exec("table t1")
+	c.Assert(err, IsNil)
+	fields, err := rs.Fields()
+	c.Assert(err, IsNil)
+	c.Assert(fields, HasLen, 1)
+	c.Assert(fields[0].ColumnAsName.O, Equals, "a")
+	c.Assert(rs.Close(), IsNil)
+
+	_, err = tk.Exec("table t2")
+	c.Assert(err, NotNil)
+	_, err = tk.Exec("table t order by c")
+	c.Assert(err, NotNil)
+}
+
+func (s *testSuite) TestWildcardRowID(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t, t1")
+	tk.MustExec("create table t (a int, b varchar(10), key a (a))")
+	tk.MustExec("create table t1 (a int primary key, b int)")
+	tk.MustExec("insert t values (3, 'c'), (1, 'a')")
+	tk.MustExec("insert t1 values (1, 2)")
+	tk.MustQuery("select * from t order by a").Check(testkit.Rows("1 a", "3 c"))
+
+	tk.MustExec("set @@tidb_opt_wildcard_rowid = 1")
+	rs, err := tk.Exec("select * from t")
+	c.Assert(err, IsNil)
+	fields, err := rs.Fields()
+	c.Assert(err, IsNil)
+	c.Assert(fields, HasLen, 3)
+	c.Assert(fields[2].ColumnAsName.O, Equals, "_rowid")
+	c.Assert(rs.Close(), IsNil)
+	tk.MustQuery("select * from t order by a").Check(testkit.Rows("1 a 2", "3 c 1"))
+	tk.MustQuery("select t.* from t where a = 3").Check(testkit.Rows("3 c 1"))
+	tk.MustQuery("select b from t order by a").Check(testkit.Rows("a", "c"))
+	// The integer primary key is the row handle, there is no hidden column.
+	tk.MustQuery("select * from t1").Check(testkit.Rows("1 2"))
+	tk.MustQuery("select * from t join t1 on t.a = t1.a").Check(testkit.Rows("1 a 2 1 2"))
+	tk.MustExec("begin")
+	tk.MustExec("insert t values (2, 'b')")
+	tk.MustQuery("select * from t order by a").Check(testkit.Rows("1 a 2", "2 b 3", "3 c 1"))
+	tk.MustExec("rollback")
+
+	tk.MustExec("set @@tidb_opt_wildcard_rowid = 0")
+	tk.MustQuery("select * from t order by a").Check(testkit.Rows("1 a", "3 c"))
+}
+
+func (s *testSuite) TestSelectRowID(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t, t1, t2")
+	tk.MustExec("create table t (a int, b varchar(10), key a (a))")
+	tk.MustExec("create table t1 (a int primary key, b int)")
+	tk.MustExec("create table t2 (a int)")
+	tk.MustExec("insert t values (3, 'c'), (1, 'a')")
+	tk.MustExec("insert t1 values (1, 2)")
+	tk.MustExec("insert t2 values (1)")
+	tk.MustQuery("select _rowid, a from t order by a").Check(testkit.Rows("2 1", "1 3"))
+	tk.MustQuery("select t._rowid from t where a = 3").Check(testkit.Rows("1"))
+	tk.MustQuery("select tt._rowid, tt.b from t tt where tt._rowid = 2").Check(testkit.Rows("2 a"))
+	tk.MustQuery("select b from t order by _rowid").Check(testkit.Rows("c", "a"))
+	tk.MustQuery("select t._rowid, t1.b from t join t1 on t.a = t1.a").Check(testkit.Rows("2 2"))
+	tk.MustQuery("select a from t where _rowid in (select _rowid from t2)").Check(testkit.Rows("3"))
+	// The hidden column is never expanded from `*`.
+	tk.MustQuery("select *, _rowid from t order by a").Check(testkit.Rows("1 a 2", "3 c 1"))
+	tk.MustExec("begin")
+	tk.MustExec("insert t values (2, 'b')")
+	tk.MustQuery("select _rowid, a from t order by a").Check(testkit.Rows("2 1", "3 2", "1 3"))
+	tk.MustExec("rollback")
+	// The integer primary key is the row handle, there is no hidden column.
+	_, err := tk.Exec("select _rowid from t1")
+	c.Assert(terror.ErrorEqual(err, plan.ErrUnknownColumn), IsTrue, Commentf("err %v", err))
+	_, err = tk.Exec("select _rowid from t, t2")
+	c.Assert(terror.ErrorEqual(err, plan.ErrAmbiguous), IsTrue, Commentf("err %v", err))
+	tk.MustQuery("select t._rowid, t2._rowid from t, t2 where t.a = t2.a").Check(testkit.Rows("2 1"))
+	// The hidden column can't be assigned.
+	_, err = tk.Exec("update t set _rowid = 7 where a = 3")
+	c.Assert(terror.ErrorEqual(err, plan.ErrUnknownColumn), IsTrue, Commentf("err %v", err))
+	_, err = tk.Exec("update t, t2 set t._rowid = 7 where t.a = t2.a")
+	c.Assert(terror.ErrorEqual(err, plan.ErrUnknownColumn), IsTrue, Commentf("err %v", err))
+	tk.MustQuery("select _rowid, a from t order by a").Check(testkit.Rows("2 1", "1 3"))
+}
+
+func (s *testSuite) TestStableSort(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t, t1")
+	tk.MustExec("create table t (a int, b int)")
+	tk.MustExec("create table t1 (a int primary key, b int)")
+	tk.MustExec("insert t values (1, 1), (0, 2), (1, 3), (0, 4), (1, 5)")
+	tk.MustExec("insert t1 values (3, 1), (1, 1), (2, 0)")
+	tk.MustExec("set @@tidb_opt_stable_sort = 1")
+	tk.MustQuery("select b from t order by a").Check(testkit.Rows("2", "4", "1", "3", "5"))
+	tk.MustQuery("select b from t order by a desc").Check(testkit.Rows("1", "3", "5", "2", "4"))
+	tk.MustQuery("select b from t order by a desc limit 2").Check(testkit.Rows("1", "3"))
+	tk.MustQuery("select * from t order by a desc limit 1, 2").Check(testkit.Rows("1 3", "1 5"))
+	tk.MustQuery("select a from t1 order by b").Check(testkit.Rows("2", "1", "3"))
+	tk.MustQuery("select t.b, t1.a from t join t1 on t.a = t1.b order by t.a desc, t1.b").Check(
+		testkit.Rows("1 1", "1 3", "3 1", "3 3", "5 1", "5 3", "2 2", "4 2"))
+	tk.MustExec("begin")
+	tk.MustExec("insert t values (0, 6)")
+	tk.MustQuery("select b from t order by a").Check(testkit.Rows("2", "4", "6", "1", "3", "5"))
+	tk.MustExec("rollback")
+	tk.MustQuery("select a, count(*) from t group by a order by a").Check(testkit.Rows("0 2", "1 3"))
+	tk.MustQuery("show warnings").Check(testkit.Rows(
+		"Warning 1105 ORDER BY isn't made stable, the row handles aren't available for aggregation"))
+}
+
 func (s *testSuite) TestSelectErrorRow(c *C) {
 	defer func() {
 		s.cleanEnv(c)
@@ -807,6 +995,49 @@ func (s *testSuite) TestIn(c *C) {
 	tk.MustQuery(queryStr).Check(testkit.Rows("7"))
 }
 
+func (s *testSuite) TestRejectImplicitConversion(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t, t1")
+	tk.MustExec("create table t (a int, b varchar(10), c datetime, d decimal(10, 2), key b (b))")
+	tk.MustExec("create table t1 (a int, b varchar(10))")
+	tk.MustExec("insert t values (1, '1abc', '2017-01-01', 1)")
+	tk.MustExec("insert t1 values (1, '1')")
+	tk.MustQuery("select a from t where b = 1").Check(testkit.Rows("1"))
+
+	tk.MustExec("set @@tidb_opt_reject_implicit_conversion = 1")
+	for _, sql := range []string{
+		"select a from t where b = 1",
+		"select a from t where a = '1'",
+		"select a from t where b in ('x', a)",
+		"select a from t where b in (1, 2)",
+		"select a from t where a not in ('1')",
+		"select a from t where (a, b) in ((1, 'x'), ('1', 'x'))",
+		"select a from t where b in (select a from t1)",
+		"select a from t where a > 0 and not (d < b)",
+		"select a from t group by a, b having b <> 0",
+		"select * from t join t1 on t.a = t1.b",
+		"select * from t left join t1 on t.a = t1.a and t1.b = 1",
+		"select * from t join t1 using (b) where t.a = t1.b",
+		"update t set a = 2 where b = 1",
+		"delete from t where b = 1",
+	} {
+		_, err := tk.Exec(sql)
+		c.Check(plan.ErrImplicitConversion.Equal(err), IsTrue, Commentf("for %s", sql))
+	}
+	tk.MustQuery("select a from t where b = '1abc' and a = 1 and d = 1 and c = '2017-01-01'").Check(testkit.Rows("1"))
+	tk.MustQuery("select a from t where cast(b as signed) = 1").Check(testkit.Rows("1"))
+	tk.MustQuery("select t.a from t join t1 on t.a = t1.a and t.b like concat(t1.b, '%')").Check(testkit.Rows("1"))
+	tk.MustQuery("select b = 1 from t").Check(testkit.Rows("1"))
+	tk.MustQuery("select a from t where b in ('1abc', 'x') and a not in (2, 3)").Check(testkit.Rows("1"))
+	tk.MustExec("set @@tidb_opt_reject_implicit_conversion = 0")
+	tk.MustQuery("select a from t where b = 1").Check(testkit.Rows("1"))
+}
+
 func (s *testSuite) TestTablePKisHandleScan(c *C) {
 	defer func() {
 		s.cleanEnv(c)
@@ -969,6 +1200,12 @@ func (s *testSuite) TestIndexReverseOrder(c *C) {
 	tk.MustExec("insert t values (0, 2), (1, 2), (2, 2), (0, 1), (1, 1), (2, 1), (0, 0), (1, 0), (2, 0)")
 	result = tk.MustQuery("select b, a from t order by b, a desc")
 	result.Check(testkit.Rows("0 2", "0 1", "0 0", "1 2", "1 1", "1 0", "2 2", "2 1", "2 0"))
+	result = tk.MustQuery("select b, a from t where b = 1 order by b, a desc")
+	result.Check(testkit.Rows("1 2", "1 1", "1 0"))
+	result = tk.MustQuery("select b, a from t where b = 1 order by b, a desc limit 2")
+	result.Check(testkit.Rows("1 2", "1 1"))
+	result = tk.MustQuery("select b, a from t where b = 1 order by b desc, a limit 2")
+	result.Check(testkit.Rows("1 0", "1 1"))
 }
 
 func (s *testSuite) TestTableReverseOrder(c *C) {
@@ -1133,6 +1370,35 @@ func (s *testSuite) TestMultiUpdate(c *C) {
 	result.Check(testkit.Rows(`1 7 2`, `4 8 8`, `7 8 8`))
 }
 
+func (s *testSuite) TestUpdateDerivedTable(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t, t1")
+	tk.MustExec("create table t (a int, b int)")
+	tk.MustExec("create table t1 (a int, b int)")
+	tk.MustExec("insert t values (1, 2), (2, 3)")
+	tk.MustExec("insert t1 values (1, 5)")
+
+	for _, sql := range []string{
+		"update (select * from t) tt set tt.a = 5",
+		"update t, (select a + 1 as x from t1) tt set tt.x = 5 where t.a = 1",
+		"update t join (select a, count(*) as cnt from t1 group by a) tt on t.a = tt.a set t.b = 1, tt.cnt = 0",
+	} {
+		_, err := tk.Exec(sql)
+		c.Check(plan.ErrNonUpdatableTable.Equal(err), IsTrue, Commentf("for %s", sql))
+	}
+	tk.MustQuery("select * from t order by a").Check(testkit.Rows("1 2", "2 3"))
+
+	// The base tables are still updated by the columns of the derived tables.
+	tk.MustExec("update t, (select a, b + 1 as x from t1) tt set t.b = tt.x where t.a = tt.a")
+	tk.MustQuery("select * from t order by a").Check(testkit.Rows("1 6", "2 3"))
+	tk.MustQuery("select * from t1").Check(testkit.Rows("1 5"))
+}
+
 func (s *testSuite) TestGeneratedColumnWrite(c *C) {
 	defer func() {
 		s.cleanEnv(c)
@@ -1156,6 +1422,9 @@ func (s *testSuite) TestGeneratedColumnWrite(c *C) {
 		{`insert into test_gc_write (a, b) values (1, 1) on duplicate key update c = 1`, mysql.ErrBadGeneratedColumn},
 		// Can't modify generated column by set.
 		{`insert into test_gc_write set a = 1, b = 1, c = 1`, mysql.ErrBadGeneratedColumn},
+		// Can't modify generated column by replace.
+		{`replace into test_gc_write (a, b, c) values (1, 1, 1)`, mysql.ErrBadGeneratedColumn},
+		{`replace into test_gc_write set a = 1, b = 1, c = 1`, mysql.ErrBadGeneratedColumn},
 		// Can't modify generated column by update clause.
 		{`update test_gc_write set c = 1`, mysql.ErrBadGeneratedColumn},
 		// Can't modify generated column by multi-table update clause.
@@ -1164,6 +1433,7 @@ func (s *testSuite) TestGeneratedColumnWrite(c *C) {
 		// Can insert without generated columns.
 		{`insert into test_gc_write (a, b) values (1, 1)`, 0},
 		{`insert into test_gc_write set a = 2, b = 2`, 0},
+		{`replace into test_gc_write (a, b) values (2, 2)`, 0},
 		// Can update without generated columns.
 		{`update test_gc_write set b = 2 where a = 2`, 0},
 		{`update test_gc_write t1, test_gc_write_1 t2 set t1.b = 3, t2.b = 4`, 0},
@@ -1184,6 +1454,68 @@ func (s *testSuite) TestGeneratedColumnWrite(c *C) {
 	}
 }
 
+func (s *testSuite) TestGeneratedColumnIndexSubstitute(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec(`create table t (a int, b int, c varchar(5), d varchar(5), g bigint as (a + b) stored, h int as (a + b) stored,
+		s varchar(10) as (concat(c, d)) stored, v bigint as (a - b) virtual, index ig (g), index ih (h), index i_s (s), index iv (v))`)
+	tk.MustExec("insert into t (a, b, c, d) values (1, 2, 'x', 'y'), (2, 3, 'x', 'z'), (3, 4, 'y', 'y')")
+
+	tk.MustQuery("select a from t where a + b = 3").Check(testkit.Rows("1"))
+	tk.MustQuery("select a from t where a + b in (3, 7)").Check(testkit.Rows("1", "3"))
+	tk.MustQuery("select a from t where concat(c, d) = 'xz'").Check(testkit.Rows("2"))
+	tk.MustQuery("select t1.a, t2.a from t t1 join t t2 on t1.a = t2.a where t1.a + t1.b > 4").Check(testkit.Rows("2 2", "3 3"))
+	// UPDATE doesn't compute g, h and s again, so they may be stale and aren't used for the expressions.
+	tk.MustExec("update t set b = 5 where a = 1")
+	tk.MustQuery("select a from t where a + b = 6").Check(testkit.Rows("1"))
+	tk.MustQuery("select a from t where a + b = 3").Check(testkit.Rows())
+	result := tk.MustQuery("explain select a from t where a + b = 6")
+	c.Assert(fmt.Sprintf("%v", result.Rows()), Not(Matches), ".*index:(g|h).*")
+	result = tk.MustQuery("explain select a from t where concat(c, d) = 'xz'")
+	c.Assert(fmt.Sprintf("%v", result.Rows()), Not(Matches), ".*index:s.*")
+	// The virtual v isn't computed when read.
+	result = tk.MustQuery("explain select a from t where a - b = -1")
+	c.Assert(fmt.Sprintf("%v", result.Rows()), Not(Matches), ".*index:v.*")
+}
+
+func (s *testSuite) TestInsertDefaultFill(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec(`create table t (id int primary key auto_increment, a int not null, b int default 7, c varchar(10) not null default 'x',
+		d int as (a + b) stored, e enum('p', 'q') not null)`)
+
+	tk.MustExec("set @@sql_mode = 'STRICT_TRANS_TABLES'")
+	tk.MustExec("insert into t (a) values (1)")
+	tk.MustExec("insert into t set a = 2, b = 3")
+	tk.MustExec("insert into t (a, b) select 4, null")
+	tk.MustQuery("select * from t").Check(testkit.Rows("1 1 7 x 8 p", "2 2 3 x 5 p", "3 4 <nil> x <nil> p"))
+
+	_, err := tk.Exec("insert into t (b) values (1)")
+	c.Assert(err, NotNil)
+	terr := errors.Trace(err).(*errors.Err).Cause().(*terror.Error)
+	c.Assert(terr.Code(), Equals, terror.ErrCode(mysql.ErrNoDefaultForField))
+	_, err = tk.Exec("insert into t values ()")
+	c.Assert(err, NotNil)
+
+	tk.MustExec("insert ignore into t (b) values (1)")
+	tk.MustQuery("show warnings").Check(testkit.Rows("Warning 1364 Field 'a' doesn't have a default value"))
+	tk.MustQuery("select a, d from t where b = 1").Check(testkit.Rows("0 1"))
+
+	tk.MustExec("set @@sql_mode = ''")
+	tk.MustExec("insert into t values ()")
+	tk.MustQuery("select a, b, c, d, e from t where a = 0 and b = 7").Check(testkit.Rows("0 7 x 7 p"))
+}
+
 func (s *testSuite) TestToPBExpr(c *C) {
 	defer func() {
 		s.cleanEnv(c)
@@ -1225,6 +1557,25 @@ func (s *testSuite) TestToPBExpr(c *C) {
 	result = tk.MustQuery("select * from t where a like 'ab_12'")
 	result.Check(nil)
 	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a varchar(255), index idx(a))")
+	tk.MustExec(`insert t values ('a_b'), ('axb'), ('a!b'), ('a\\xb')`)
+	tk.MustQuery("select a from t where a like 'a!_b' escape '!' order by a").Check(testkit.Rows("a_b"))
+	tk.MustQuery("select a from t where a not like 'a!_b' escape '!' order by a").Check(testkit.Rows("a!b", `a\xb`, "axb"))
+	tk.MustQuery("select a from t use index(idx) where a like 'a!_%' escape '!' order by a").Check(testkit.Rows("a_b"))
+	tk.MustQuery("select a from t where a like 'a_b' escape '!' order by a").Check(testkit.Rows("a!b", "a_b", "axb"))
+	tk.MustQuery("select a from t where a like 'a!!b' escape '!'").Check(testkit.Rows("a!b"))
+	tk.MustQuery(`select a from t where a like 'a\_b'`).Check(testkit.Rows("a_b"))
+	tk.MustQuery(`select a from t where a like 'a\_b' escape ''`).Check(testkit.Rows("a_b"))
+	_, err := tk.Exec("select a from t where a like 'a_b' escape '!!'")
+	c.Assert(err, NotNil)
+	// There is no escape character by default in the NO_BACKSLASH_ESCAPES mode.
+	tk.MustExec("set sql_mode = 'NO_BACKSLASH_ESCAPES'")
+	tk.MustQuery(`select a from t where a like 'a\_b'`).Check(testkit.Rows(`a\xb`))
+	tk.MustQuery("select a from t where a like 'a!_b' escape '!'").Check(testkit.Rows("a_b"))
+	_, err = tk.Exec("select a from t where a like 'a_b' escape ''")
+	c.Assert(err, NotNil)
+	tk.MustExec("set sql_mode = ''")
+	tk.MustExec("drop table if exists t")
 	tk.MustExec("create table t (a int primary key)")
 	tk.MustExec("insert t values (1)")
 	tk.MustExec("insert t values (2)")
@@ -1323,6 +1674,63 @@ func (s *testSuite) TestTableDual(c *C) {
 	result.Check(testkit.Rows("1"))
 }
 
+func (s *testSuite) TestCurrentTimeIndexRange(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists td")
+	tk.MustExec("create table td (a datetime, index ia (a))")
+	tk.MustExec("insert td values ('2000-01-01 00:00:00'), ('9999-01-01 00:00:00')")
+	// NOW() is folded when the statement is built, so it's used to build the index range.
+	result := tk.MustQuery("explain select * from td where a > now()")
+	c.Assert(fmt.Sprintf("%v", result.Rows()), Matches, ".*IndexScan.*range:\\(.*")
+	tk.MustQuery("select * from td where a > now()").Check(testkit.Rows("9999-01-01 00:00:00"))
+	tk.MustQuery("select * from td where a > curdate()").Check(testkit.Rows("9999-01-01 00:00:00"))
+	// SYSDATE() is evaluated for every row.
+	result = tk.MustQuery("explain select * from td where a > sysdate()")
+	c.Assert(fmt.Sprintf("%v", result.Rows()), Matches, ".*Selection.*gt\\(test.td.a, sysdate\\(\\)\\).*")
+	tk.MustQuery("select * from td where a > sysdate()").Check(testkit.Rows("9999-01-01 00:00:00"))
+}
+
+func (s *testSuite) TestConstantFalseWhere(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	result := tk.MustQuery("Select 1 from dual where 1 = 0")
+	result.Check(testkit.Rows())
+
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int, b int)")
+	tk.MustExec("insert t values (1, 1), (2, 2)")
+	rs, err := tk.Exec("select a, b + 1 as c from t where 1 = 0 order by a limit 1")
+	c.Assert(err, IsNil)
+	fields, err := rs.Fields()
+	c.Assert(err, IsNil)
+	c.Assert(fields, HasLen, 2)
+	c.Assert(fields[0].Column.Name.L, Equals, "a")
+	c.Assert(fields[1].Column.Name.L, Equals, "c")
+	c.Assert(rs.Close(), IsNil)
+	result = tk.MustQuery("select count(*), sum(a) from t where a > 0 and 1 = 0")
+	result.Check(testkit.Rows("0 <nil>"))
+	result = tk.MustQuery("select a from t where 1 = 1 order by a")
+	result.Check(testkit.Rows("1", "2"))
+	result = tk.MustQuery("select a from t where 1 = 1 and a > 1")
+	result.Check(testkit.Rows("2"))
+	result = tk.MustQuery("select a from t where a in (select a from t where 1 = 0)")
+	result.Check(testkit.Rows())
+	// The empty dual of the scalar subquery returns NULL.
+	result = tk.MustQuery("select (select a from t where 1 = 0)")
+	result.Check(testkit.Rows("<nil>"))
+	result = tk.MustQuery("select a, (select b from t where 1 = 0) from t order by a")
+	result.Check(testkit.Rows("1 <nil>", "2 <nil>"))
+}
+
 func (s *testSuite) TestTableScan(c *C) {
 	defer testleak.AfterTest(c)()
 	tk := testkit.NewTestKit(c, s.store)
@@ -1427,6 +1835,18 @@ func (s *testSuite) TestRow(c *C) {
 	result.Check(testkit.Rows("1"))
 	result = tk.MustQuery("select (2, 3, 4) != (2, 3, 4)")
 	result.Check(testkit.Rows("0"))
+	result = tk.MustQuery("select * from t where (c, d) in ((1, 3), (2, 1), (3, 3))")
+	result.Check(testkit.Rows("1 3", "2 1"))
+	result = tk.MustQuery("select * from t where (c, d) not in ((1, 3), (2, 1))")
+	result.Check(testkit.Rows("1 1", "2 3"))
+	result = tk.MustQuery("select * from t where row(c, d + 1) in (row(1, 2), (2, 4))")
+	result.Check(testkit.Rows("1 1", "2 3"))
+	result = tk.MustQuery("select (1, 2) in ((1, 2), (3, 4)), (1, 2) in ((3, 4)), (1, null) in ((1, 2))")
+	result.Check(testkit.Rows("1 0 <nil>"))
+	_, err := tk.Exec("select * from t where (c, d) in ((1, 3), (2, 1, 1))")
+	c.Assert(err.Error(), Equals, "[optimizer:1]Operand should contain 2 column(s)")
+	_, err = tk.Exec("select * from t where (c, d) in (1, 2)")
+	c.Assert(err.Error(), Equals, "[optimizer:1]Operand should contain 2 column(s)")
 }
 
 func (s *testSuite) TestColumnName(c *C) {
@@ -1540,6 +1960,64 @@ func (s *testSuite) TestHistoryRead(c *C) {
 	tk.MustQuery("select * from history_read order by a").Check(testkit.Rows("2 <nil>", "4 <nil>", "8 8", "9 9"))
 }
 
+func (s *testSuite) TestAsOfTimestamp(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists as_of_t1, as_of_t2")
+	tk.MustExec("create table as_of_t1 (a int, b int, index idx_b(b))")
+	tk.MustExec("insert as_of_t1 values (1, 1), (2, 2)")
+
+	time.Sleep(time.Millisecond)
+	before := time.Now().Format("2006-01-02 15:04:05.999999")
+	time.Sleep(time.Millisecond)
+	_, err := tk.Exec("select * from as_of_t2 as of timestamp '" + before + "'")
+	c.Assert(terror.ErrorEqual(err, plan.ErrAsOfTableNotExists), IsTrue, Commentf("err %v", err))
+
+	tk.MustExec("create table as_of_t2 (a int)")
+	tk.MustExec("insert as_of_t2 values (1), (2)")
+	time.Sleep(time.Millisecond)
+	snapshotTime := time.Now()
+	snapshot := snapshotTime.Format("2006-01-02 15:04:05.999999")
+	time.Sleep(time.Millisecond)
+	tk.MustExec("delete from as_of_t1 where a = 1")
+	tk.MustExec("alter table as_of_t1 add column c int")
+	tk.MustExec("insert as_of_t1 values (3, 3, 3)")
+
+	asOf := " as of timestamp '" + snapshot + "'"
+	tk.MustQuery("select * from as_of_t1 order by a").Check(testkit.Rows("2 2 <nil>", "3 3 3"))
+	tk.MustQuery("select * from as_of_t1" + asOf + " order by a").Check(testkit.Rows("1 1", "2 2"))
+	tk.MustQuery("select b from as_of_t1" + asOf + " use index (idx_b) where b > 0 order by b").Check(testkit.Rows("1", "2"))
+	tk.MustQuery("select t1.a from as_of_t1" + asOf + " t1 join as_of_t2" + asOf + " t2 on t1.a = t2.a order by t1.a").Check(testkit.Rows("1", "2"))
+	tk.MustQuery("select /*+ TIDB_INLJ(t1) */ t1.a from as_of_t2" + asOf + " t2 join as_of_t1" + asOf + " t1 on t1.b = t2.a order by t1.a").Check(testkit.Rows("1", "2"))
+
+	// The timestamp is in the session time zone.
+	tk.MustExec("set @@time_zone = '+13:00'")
+	zoneSnapshot := snapshotTime.In(time.FixedZone("", 13*3600)).Format("2006-01-02 15:04:05.999999")
+	tk.MustQuery("select a from as_of_t1 as of timestamp '" + zoneSnapshot + "' order by a").Check(testkit.Rows("1", "2"))
+	tk.MustExec("set @@time_zone = 'SYSTEM'")
+
+	// The dirty data of the transaction is not visible to the table read as of the timestamp.
+	tk.MustExec("begin")
+	tk.MustExec("insert as_of_t1 values (4, 4, 4)")
+	tk.MustQuery("select a from as_of_t1" + asOf + " order by a").Check(testkit.Rows("1", "2"))
+	tk.MustExec("rollback")
+
+	_, err = tk.Exec("select * from as_of_t2" + asOf + " join as_of_t1 as of timestamp '" + before + "'")
+	c.Assert(terror.ErrorEqual(err, plan.ErrAsOfTimestampMixed), IsTrue, Commentf("err %v", err))
+	_, err = tk.Exec("select * from as_of_t2" + asOf + " join as_of_t1")
+	c.Assert(terror.ErrorEqual(err, plan.ErrAsOfTimestampMixed), IsTrue, Commentf("err %v", err))
+	_, err = tk.Exec("select * from as_of_t1 where a in (select a from as_of_t2" + asOf + ")")
+	c.Assert(terror.ErrorEqual(err, plan.ErrAsOfTimestampMixed), IsTrue, Commentf("err %v", err))
+	_, err = tk.Exec("select * from as_of_t1" + asOf + " for update")
+	c.Assert(terror.ErrorEqual(err, plan.ErrAsOfTimestampWrite), IsTrue, Commentf("err %v", err))
+	_, err = tk.Exec("select * from as_of_t1 as of timestamp 1")
+	c.Assert(terror.ErrorEqual(err, plan.ErrAsOfTimestamp), IsTrue, Commentf("err %v", err))
+}
+
 func (s *testSuite) TestScanControlSelection(c *C) {
 	defer func() {
 		s.cleanEnv(c)
@@ -1941,3 +2419,136 @@ func (s testPrioritySuite) TestCoprocessorPriority(c *C) {
 	cli.priority = pb.CommandPri_Low
 	tk.MustQuery("select LOW_PRIORITY id from t where id = 1")
 }
+
+func (s *testSuite) TestRowFilter(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int primary key, owner varchar(10))")
+	tk.MustExec("insert t values (1, 'u1'), (2, 'u2'), (3, 'u1')")
+	tbl, err := sessionctx.GetDomain(tk.Se).InfoSchema().TableByName(model.NewCIStr("test"), model.NewCIStr("t"))
+	c.Assert(err, IsNil)
+
+	// Every session only sees the rows owned by the user in @owner.
+	plan.RegisterRowFilter(tbl.Meta().ID, func(ctx context.Context, schema *expression.Schema) (expression.Expression, error) {
+		col, err := schema.FindColumn(&ast.ColumnName{Name: model.NewCIStr("owner")})
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		owner := &expression.Constant{
+			Value:   types.NewStringDatum(ctx.GetSessionVars().Users["owner"]),
+			RetType: types.NewFieldType(mysql.TypeVarString),
+		}
+		return expression.NewFunction(ctx, ast.EQ, types.NewFieldType(mysql.TypeTiny), col, owner)
+	})
+	defer plan.UnregisterRowFilter(tbl.Meta().ID)
+
+	tk.MustExec("set @owner = 'u1'")
+	tk.MustQuery("select a from t").Check(testkit.Rows("1", "3"))
+	tk.MustQuery("select a from t where a > 1").Check(testkit.Rows("3"))
+	tk.MustQuery("select x.a, y.a from t x join t y on x.a < y.a").Check(testkit.Rows("1 3"))
+	tk.MustQuery("select count(*) from t where a in (select a from t where owner = 'u2')").Check(testkit.Rows("0"))
+	tk.MustExec("update t set a = a + 10")
+	tk.MustExec("set @owner = 'u2'")
+	tk.MustQuery("select a from t").Check(testkit.Rows("2"))
+	tk.MustExec("delete from t")
+	tk.MustQuery("select a from t").Check(testkit.Rows())
+
+	plan.UnregisterRowFilter(tbl.Meta().ID)
+	tk.MustQuery("select a, owner from t").Check(testkit.Rows("11 u1", "13 u1"))
+
+	// The filter must refer to the columns of the table.
+	plan.RegisterRowFilter(tbl.Meta().ID, func(ctx context.Context, _ *expression.Schema) (expression.Expression, error) {
+		col := &expression.Column{ColName: model.NewCIStr("owner"), RetType: types.NewFieldType(mysql.TypeVarString)}
+		return expression.NewFunction(ctx, ast.IsNull, types.NewFieldType(mysql.TypeTiny), col)
+	})
+	_, err = tk.Exec("select a from t")
+	c.Assert(plan.ErrUnknownColumn.Equal(err), IsTrue)
+}
+
+func (s *testSuite) TestTableAliasSameAsColumn(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t, b")
+	tk.MustExec("create table t (a int, b int)")
+	tk.MustExec("create table b (a int, b int)")
+	tk.MustExec("insert t values (1, 2), (3, 4)")
+	tk.MustExec("insert b values (1, 5), (2, 6)")
+
+	tk.MustQuery("select a.a from t a").Check(testkit.Rows("1", "3"))
+	tk.MustQuery("select a.* from t a").Check(testkit.Rows("1 2", "3 4"))
+	tk.MustQuery("select a.a, a.b from t a where a.a > 1").Check(testkit.Rows("3 4"))
+	tk.MustQuery("select a from t a where a = 1").Check(testkit.Rows("1"))
+	tk.MustQuery("select b.a, b.b from t as b where b.b = 4").Check(testkit.Rows("3 4"))
+	tk.MustQuery("select a.b as a from t a where a.a = 1").Check(testkit.Rows("2"))
+	tk.MustQuery("select a.b as a from t a order by a.a desc").Check(testkit.Rows("4", "2"))
+	tk.MustQuery("select a.a, count(*) from t a group by a.a having a.a > 1").Check(testkit.Rows("3 1"))
+	_, err := tk.Exec("select t.a from t a")
+	c.Assert(plan.ErrUnknownColumn.Equal(err), IsTrue)
+
+	// The aliases equal to the column names in join conditions.
+	tk.MustQuery("select a.a, b.b from t a join t b on a.a = b.a").Check(testkit.Rows("1 2", "3 4"))
+	tk.MustQuery("select a.a, b.b from t a, t b where a.b = b.b").Check(testkit.Rows("1 2", "3 4"))
+	tk.MustQuery("select b.a, a.b from t a join b on a.a = b.a").Check(testkit.Rows("1 2"))
+	tk.MustQuery("select b.b, a.b from t b join b a on b.a = a.a").Check(testkit.Rows("2 5"))
+	tk.MustQuery("select a.a from t a join t b on a.a = b.a where b.b > a.a order by b.a").Check(testkit.Rows("1", "3"))
+	_, err = tk.Exec("select a from t a, b")
+	c.Assert(plan.ErrAmbiguous.Equal(err), IsTrue)
+
+	// The aliases equal to the column names in subqueries.
+	tk.MustQuery("select * from t a where a.a = (select max(b.a) from t b where b.b = a.b)").Check(testkit.Rows("1 2", "3 4"))
+	tk.MustQuery("select (select a.a from t b where b.b = a.b) from t a").Check(testkit.Rows("1", "3"))
+	tk.MustQuery("select a.a from t a where a.a in (select a.a from t a where a.b = 4)").Check(testkit.Rows("3"))
+	tk.MustQuery("select a.a from (select a, b from t) a where a.b = 4").Check(testkit.Rows("3"))
+
+	tk.MustExec("update t a set a.b = a.a + 10 where a.a = 1")
+	tk.MustQuery("select * from t").Check(testkit.Rows("1 11", "3 4"))
+	tk.MustExec("update t a, b set a.b = b.b where a.a = b.a")
+	tk.MustQuery("select * from t").Check(testkit.Rows("1 5", "3 4"))
+	tk.MustExec("delete a from t a where a.a = 3")
+	tk.MustQuery("select * from t").Check(testkit.Rows("1 5"))
+}
+
+func (s *testSuite) TestSchemaQualifiedWildcard(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("drop database if exists wildcard1")
+	tk.MustExec("drop database if exists wildcard2")
+	tk.MustExec("create database wildcard1")
+	tk.MustExec("create database wildcard2")
+	tk.MustExec("create table wildcard1.t (a int)")
+	tk.MustExec("create table wildcard2.t (b int)")
+	tk.MustExec("insert wildcard1.t values (1)")
+	tk.MustExec("insert wildcard2.t values (2)")
+	tk.MustExec("use wildcard1")
+	tk.MustQuery("select wildcard1.t.* from wildcard1.t join wildcard2.t").Check(testkit.Rows("1"))
+	tk.MustQuery("select wildcard2.t.* from wildcard1.t join wildcard2.t").Check(testkit.Rows("2"))
+	tk.MustQuery("select t.* from wildcard1.t join wildcard2.t").Check(testkit.Rows("1"))
+	tk.MustQuery("select t.* from wildcard2.t").Check(testkit.Rows("2"))
+	// The aliased table is only visible by its alias.
+	tk.MustQuery("select x.* from wildcard1.t x join wildcard2.t").Check(testkit.Rows("1"))
+	tk.MustQuery("select t.* from wildcard1.t x join wildcard2.t").Check(testkit.Rows("2"))
+	_, err := tk.Exec("select wildcard1.t.* from wildcard1.t x join wildcard2.t")
+	c.Assert(err.Error(), Equals, "[plan:1054]Unknown table 'wildcard1.t'")
+	_, err = tk.Exec("select wildcard1.x.* from wildcard1.t x join wildcard2.t")
+	c.Assert(err.Error(), Equals, "[plan:1054]Unknown table 'wildcard1.x'")
+	_, err = tk.Exec("select wildcard1.t.* from (select * from wildcard1.t) t")
+	c.Assert(err.Error(), Equals, "[plan:1054]Unknown table 'wildcard1.t'")
+
+	tk.MustExec("use test")
+	_, err = tk.Exec("select t.* from wildcard1.t join wildcard2.t")
+	c.Assert(plan.ErrNonUniqTable.Equal(err), IsTrue)
+	tk.MustExec("drop database wildcard1")
+	tk.MustExec("drop database wildcard2")
+}
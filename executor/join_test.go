@@ -187,6 +187,26 @@ func (s *testSuite) TestJoin(c *C) {
 	_, err = tk.Exec("select * from t right join t1 on 1")
 	c.Check(plan.ErrCartesianProductUnsupported.Equal(err), IsTrue)
 	plan.AllowCartesianProduct = true
+
+	tk.MustExec("set @@tidb_opt_no_cartesian_join = 1")
+	_, err = tk.Exec("select * from t, t1")
+	c.Check(plan.ErrImplicitCartesianJoin.Equal(err), IsTrue)
+	_, err = tk.Exec("select * from t join t1 where t.c1 > 1")
+	c.Check(plan.ErrImplicitCartesianJoin.Equal(err), IsTrue)
+	_, err = tk.Exec("select * from t, t1, t t2 where t.c1 = t1.c1")
+	c.Check(plan.ErrImplicitCartesianJoin.Equal(err), IsTrue)
+	tk.MustQuery("select count(*) from t cross join t1").Check(testkit.Rows("49"))
+	tk.MustQuery("select count(*) from t, t1 where t.c1 = t1.c1").Check(testkit.Rows("7"))
+	tk.MustQuery("select count(*) from t join t1 on t.c1 < t1.c1").Check(testkit.Rows("21"))
+	tk.MustQuery("select count(*) from t, (select count(*) from t1) s").Check(testkit.Rows("7"))
+	// The explicit cross join is kept through join reorder, which is used when the store doesn't support DAG requests,
+	// e.g. run the test with -mockTikv=false.
+	tk.MustQuery("select count(*) from t cross join t1 cross join t t2").Check(testkit.Rows("343"))
+	tk.MustQuery("select t.c1 from t cross join t1 where t1.c1 = 1 and t.c1 < 3").Check(testkit.Rows("1", "2"))
+	_, err = tk.Exec("select * from t cross join t1, t t2")
+	c.Check(plan.ErrImplicitCartesianJoin.Equal(err), IsTrue)
+	tk.MustExec("set @@tidb_opt_no_cartesian_join = 0")
+	tk.MustQuery("select count(*) from t, t1").Check(testkit.Rows("49"))
 	tk.MustExec("drop table if exists t,t2,t1")
 	tk.MustExec("create table t(c1 int)")
 	tk.MustExec("create table t1(c1 int, c2 int)")
@@ -312,6 +332,9 @@ func (s *testSuite) TestUsing(c *C) {
 
 	tk.MustQuery("select * from t1 left join t2 using (a) order by a").Check(testkit.Rows("1 3 <nil>", "2 4 5"))
 	tk.MustQuery("select t1.a, t2.a from t1 left join t2 using (a) order by t1.a").Check(testkit.Rows("1 <nil>", "2 2"))
+	tk.MustQuery("select * from t1 join t2 using (a) order by t2.a").Check(testkit.Rows("2 4 5"))
+	tk.MustQuery("select t1.c from t1 left join t2 using (a) order by t2.a").Check(testkit.Rows("3", "4"))
+	tk.MustQuery("select t2.d from t1 right join t2 using (a) where t2.d > 0 order by t1.a desc").Check(testkit.Rows("5", "6"))
 
 	tk.MustQuery("select * from t1 join t2 using (a) right join t3 using (a)").Check(testkit.Rows("1 <nil> <nil>"))
 	tk.MustQuery("select * from t1 join t2 using (a) right join t3 on (t2.a = t3.a)").Check(testkit.Rows("<nil> <nil> <nil> 1"))
@@ -324,6 +347,13 @@ func (s *testSuite) TestUsing(c *C) {
 	tk.MustQuery("select * from t1 join t2 using (b, a)").Check(testkit.Rows("2 1 4 5"))
 
 	tk.MustExec("select * from (t1 join t2 using (a)) join (t3 join t4 using (a)) on (t2.a = t4.a and t1.a = t3.a)")
+
+	_, err := tk.Exec("select * from t1 join (select a as x, d from t2) t using (a)")
+	c.Assert(err.Error(), Equals, "[plan:1054]Unknown column 'a' in 'right side of the USING clause'")
+	_, err = tk.Exec("select * from (select a as x, c from t1) t right join t2 using (a)")
+	c.Assert(err.Error(), Equals, "[plan:1054]Unknown column 'a' in 'left side of the USING clause'")
+	_, err = tk.Exec("select * from t1 join t2 using (x)")
+	c.Assert(err.Error(), Equals, "[plan:1054]Unknown column 'x' in 'from clause'")
 }
 
 func (s *testSuite) TestNaturalJoin(c *C) {
@@ -343,6 +373,21 @@ func (s *testSuite) TestNaturalJoin(c *C) {
 	tk.MustQuery("select * from t1 natural join t2").Check(testkit.Rows("1 2 3"))
 	tk.MustQuery("select * from t1 natural left join t2 order by a").Check(testkit.Rows("1 2 3", "10 20 <nil>"))
 	tk.MustQuery("select * from t1 natural right join t2 order by a").Check(testkit.Rows("1 3 2", "100 200 <nil>"))
+
+	// The common columns come first in the order of the first table, which is the right table of a right join,
+	// then the columns unique to the first table, then the columns unique to the second table.
+	tk.MustExec("drop table if exists t1, t2")
+	tk.MustExec("create table t1 (a int, b int, c int)")
+	tk.MustExec("create table t2 (c int, b int, d int)")
+	tk.MustExec("insert t1 values (1, 2, 3), (5, 6, 7)")
+	tk.MustExec("insert t2 values (3, 2, 4), (8, 9, 10)")
+	tk.MustQuery("select * from t1 natural join t2").Check(testkit.Rows("2 3 1 4"))
+	tk.MustQuery("select * from t1 natural left join t2 order by a").Check(testkit.Rows("2 3 1 4", "6 7 5 <nil>"))
+	tk.MustQuery("select * from t1 natural right join t2 order by d").Check(testkit.Rows("3 2 4 1", "8 9 10 <nil>"))
+	tk.MustQuery("select * from t1 join t2 using (c, b)").Check(testkit.Rows("2 3 1 4"))
+	tk.MustQuery("select * from t1 right join t2 using (b, c) order by d").Check(testkit.Rows("3 2 4 1", "8 9 10 <nil>"))
+	tk.MustQuery("select * from t1 left join t2 using (c) order by a").Check(testkit.Rows("3 1 2 2 4", "7 5 6 <nil> <nil>"))
+	tk.MustQuery("select * from t1 right join t2 using (c) order by d").Check(testkit.Rows("3 2 4 1 2", "8 9 10 <nil> <nil>"))
 }
 
 func (s *testSuite) TestMultiJoin(c *C) {
@@ -457,6 +502,37 @@ func (s *testSuite) TestSubquerySameTable(c *C) {
 	result.Check(testkit.Rows("1"))
 }
 
+func (s *testSuite) TestSubqueryDepth(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int)")
+	tk.MustExec("insert t values (1)")
+	nested := func(depth int) string {
+		sql := "select a from t"
+		for i := 1; i < depth; i++ {
+			sql = fmt.Sprintf("select a from (%s) t%d", sql, i)
+		}
+		return sql
+	}
+	tk.MustQuery(nested(63)).Check(testkit.Rows("1"))
+	_, err := tk.Exec(nested(64))
+	c.Assert(plan.ErrTooHighLevelOfNesting.Equal(err), IsTrue, Commentf("err %v", err))
+	_, err = tk.Exec(fmt.Sprintf("select a from t where a in (%s)", nested(63)))
+	c.Assert(plan.ErrTooHighLevelOfNesting.Equal(err), IsTrue, Commentf("err %v", err))
+
+	tk.MustExec("set @@tidb_max_subquery_depth = 100")
+	tk.MustQuery(nested(100)).Check(testkit.Rows("1"))
+	tk.MustExec("set @@tidb_max_subquery_depth = 2")
+	tk.MustQuery("select a from t where a in (select a from t)").Check(testkit.Rows("1"))
+	_, err = tk.Exec("select a from t where a in (select a from t where a in (select a from t))")
+	c.Assert(plan.ErrTooHighLevelOfNesting.Equal(err), IsTrue, Commentf("err %v", err))
+}
+
 func (s *testSuite) TestSubquery(c *C) {
 	plan.JoinConcurrency = 1
 	defer func() {
@@ -474,6 +550,10 @@ func (s *testSuite) TestSubquery(c *C) {
 	tk.MustExec("commit")
 	result := tk.MustQuery("select * from t where exists(select * from t k where t.c = k.c having sum(c) = 1)")
 	result.Check(testkit.Rows("1 1"))
+	result = tk.MustQuery("select * from t where exists(select k.d from t k group by k.d having count(*) = 1 and k.d > t.d)")
+	result.Check(testkit.Rows("1 1", "2 2"))
+	result = tk.MustQuery("select c, (select sum(k.d) from t k where k.c <= t.c having sum(k.d) > t.d) from t")
+	result.Check(testkit.Rows("1 <nil>", "2 3", "3 7"))
 	result = tk.MustQuery("select * from t where exists(select k.c, k.d from t k, t p where t.c = k.d)")
 	result.Check(testkit.Rows("1 1", "2 2"))
 	result = tk.MustQuery("select 1 = (select count(*) from t where t.c = k.d) from t k")
@@ -588,6 +668,100 @@ func (s *testSuite) TestSubquery(c *C) {
 	tk.MustExec("insert into s values(2)")
 	result = tk.MustQuery("select (select id from s where s.id = t.id order by s.id) from t")
 	result.Check(testkit.Rows("2", "2"))
+
+	// The correlated column is resolved from the nearest outer scope.
+	tk.MustExec("drop table if exists t1, t2, t3")
+	tk.MustExec("create table t1(a int, b int)")
+	tk.MustExec("create table t2(a int, b int)")
+	tk.MustExec("create table t3(a int)")
+	tk.MustExec("insert into t1 values(1, 10), (2, 20)")
+	tk.MustExec("insert into t2 values(1, 100), (2, 5)")
+	tk.MustExec("insert into t3 values(5), (100)")
+	result = tk.MustQuery("select a from t1 where exists (select 1 from t2 where t2.a = t1.a and exists (select 1 from t3 having max(t3.a) = b))")
+	result.Check(testkit.Rows("1"))
+	result = tk.MustQuery("select a from t1 where exists (select 1 from t2 where t2.a = t1.a and exists (select a from t3 where t3.a < 50 order by b))")
+	result.Check(testkit.Rows("1", "2"))
+	_, err = tk.Exec("select a from t1 where exists (select 1 from t2, t2 k where t2.a = t1.a and exists (select 1 from t3 order by b))")
+	c.Check(plan.ErrAmbiguous.Equal(err), IsTrue)
+
+	// A name present in the local scope is never resolved as a correlated column.
+	result = tk.MustQuery("select a from t1 where exists (select 1 from (select b as a from t2) x where a = 100)")
+	result.Check(testkit.Rows("1", "2"))
+	result = tk.MustQuery("select (select a from (select b as a from t2) x order by a limit 1) from t1")
+	result.Check(testkit.Rows("5", "5"))
+	result = tk.MustQuery("select (select max(a) from (select b as a from t2) x having max(a) > 0) from t1")
+	result.Check(testkit.Rows("100", "100"))
+	result = tk.MustQuery("select (select a from (select b as a from t2) x group by a having a < 10) from t1")
+	result.Check(testkit.Rows("5", "5"))
+	tk.MustExec("insert into t3 values(1)")
+	result = tk.MustQuery("select (select t3.a from t2 join t3 using (a)) from t1 t3")
+	result.Check(testkit.Rows("1", "1"))
+	result = tk.MustQuery("select (select t3.a from t2 join t3 using (a) order by t3.a) from t1 t3")
+	result.Check(testkit.Rows("1", "1"))
+
+	// The outer columns only referred by the arguments of the aggregate functions.
+	tk.MustExec("drop table if exists t1, t2")
+	tk.MustExec("create table t1 (a int primary key, b int)")
+	tk.MustExec("create table t2 (a int, b int)")
+	tk.MustExec("insert into t1 values (1, 10), (2, 20), (3, 30)")
+	tk.MustExec("insert into t2 values (1, 1), (2, 2)")
+	result = tk.MustQuery("select a, (select sum(t1.b + t2.b) from t2) from t1")
+	result.Check(testkit.Rows("1 23", "2 43", "3 63"))
+	result = tk.MustQuery("select a, (select max(t1.a * t2.b) from t2 where t2.a < t1.a) from t1")
+	result.Check(testkit.Rows("1 <nil>", "2 2", "3 6"))
+	result = tk.MustQuery("select a from t1 where b > (select sum(t1.a + t2.b) from t2)")
+	result.Check(testkit.Rows("1", "2", "3"))
+
+	// The scalar subqueries known to return at most one row skip the row count check.
+	result = tk.MustQuery("select a, (select b from t2 order by b limit 1) from t1")
+	result.Check(testkit.Rows("1 1", "2 1", "3 1"))
+	result = tk.MustQuery("select a, (select count(*) from t2 where t2.a = t1.a) from t1")
+	result.Check(testkit.Rows("1 1", "2 1", "3 0"))
+	_, err = tk.Exec("select a, (select b from t2) from t1")
+	c.Assert(err, NotNil)
+	// The empty result of the scalar subquery is NULL.
+	tk.MustExec("drop table if exists t3")
+	tk.MustExec("create table t3 (a int)")
+	tk.MustQuery("select (select a from t3 limit 1)").Check(testkit.Rows("<nil>"))
+	tk.MustQuery("select (select a from t3 where 1=0)").Check(testkit.Rows("<nil>"))
+	tk.MustQuery("select (select count(*) from t3)").Check(testkit.Rows("0"))
+}
+
+func (s *testSuite) TestMaterializeScalarSubquery(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t1, t2")
+	tk.MustExec("create table t1 (a int, b int)")
+	tk.MustExec("create table t2 (a int, b int)")
+	tk.MustExec("insert into t1 values (1, 10), (2, 20), (3, 30)")
+	tk.MustExec("insert into t2 values (1, 1), (2, 2)")
+	tk.MustExec("set @@session.tidb_opt_materialize_scalar_subquery = 1")
+
+	result := tk.MustQuery("select a, (select max(b) from t2) from t1")
+	result.Check(testkit.Rows("1 2", "2 2", "3 2"))
+	result = tk.MustQuery("select a, (select max(b) from t2 where t2.b > 5) from t1")
+	result.Check(testkit.Rows("1 <nil>", "2 <nil>", "3 <nil>"))
+	result = tk.MustQuery("select a, (select a, b from t2 where b = 2) = (2, 2) from t1")
+	result.Check(testkit.Rows("1 1", "2 1", "3 1"))
+	result = tk.MustQuery("select a + (select count(*) from t2) from t1 where a > (select min(a) from t2)")
+	result.Check(testkit.Rows("4", "5"))
+	// The uncorrelated subquery is evaluated once in the inner plan of the correlated apply.
+	result = tk.MustQuery("select a, (select t1.a + (select max(a) from t2) from t2 where t2.a = t1.a) from t1")
+	result.Check(testkit.Rows("1 3", "2 4", "3 <nil>"))
+	rs, err := tk.Exec("select a, (select b from t2) from t1")
+	c.Assert(err, IsNil)
+	_, err = tidb.GetRows(rs)
+	c.Assert(err, NotNil)
+
+	result = tk.MustQuery("explain select a, (select max(b) from t2) from t1")
+	c.Assert(fmt.Sprintf("%v", result.Rows()), Matches, ".*MaxOneRow.*once.*")
+	tk.MustExec("set @@session.tidb_opt_materialize_scalar_subquery = 0")
+	result = tk.MustQuery("explain select a, (select max(b) from t2) from t1")
+	c.Assert(fmt.Sprintf("%v", result.Rows()), Not(Matches), ".*MaxOneRow.*")
 }
 
 func (s *testSuite) TestInSubquery(c *C) {
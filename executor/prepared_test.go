@@ -119,3 +119,38 @@ func (s *testSuite) TestPreparedLimitOffset(c *C) {
 	_, err = tk.Se.ExecutePreparedStmt(stmtID, 1)
 	c.Assert(err, IsNil)
 }
+
+func (s *testSuite) TestPreparedOrderByGroupByParam(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists prepare_test")
+	tk.MustExec("create table prepare_test (id int primary key, c1 int)")
+	tk.MustExec("insert prepare_test values (1, 3), (2, 1), (3, 2), (4, 1)")
+	tk.MustExec("set @a = 1, @b = 2, @c = 'c1'")
+
+	// The placeholders in ORDER BY and GROUP BY are constants, rather than the positions of the select fields.
+	tk.MustExec("prepare stmt from 'select c1, id from prepare_test order by ?, id desc'")
+	tk.MustQuery("execute stmt using @a").Check(testkit.Rows("1 4", "2 3", "1 2", "3 1"))
+	tk.MustQuery("execute stmt using @b").Check(testkit.Rows("1 4", "2 3", "1 2", "3 1"))
+	tk.MustQuery("execute stmt using @c").Check(testkit.Rows("1 4", "2 3", "1 2", "3 1"))
+	tk.MustExec("prepare stmt from 'select c1, id from prepare_test order by c1 + ?, id'")
+	tk.MustQuery("execute stmt using @a").Check(testkit.Rows("1 2", "1 4", "2 3", "3 1"))
+	tk.MustExec("prepare stmt from 'select count(*) from prepare_test group by ?'")
+	tk.MustQuery("execute stmt using @a").Check(testkit.Rows("4"))
+	tk.MustExec("prepare stmt from 'select c1, count(*) from prepare_test group by c1, ? order by c1'")
+	tk.MustQuery("execute stmt using @b").Check(testkit.Rows("1 2", "2 1", "3 1"))
+	tk.MustExec("prepare stmt from 'select id from prepare_test where id > ? union select c1 from prepare_test order by ?'")
+	tk.MustQuery("execute stmt using @b, @a").Sort().Check(testkit.Rows("1", "2", "3", "4"))
+
+	// The literal integers are still positions.
+	tk.MustExec("prepare stmt from 'select c1, id from prepare_test where id > ? order by 1, 2'")
+	tk.MustQuery("execute stmt using @a").Check(testkit.Rows("1 2", "1 4", "2 3"))
+
+	tk.MustExec("prepare stmt from 'update prepare_test set c1 = c1 + 10 order by ?, id desc limit 1'")
+	tk.MustExec("execute stmt using @a")
+	tk.MustQuery("select c1 from prepare_test where id = 4").Check(testkit.Rows("11"))
+}
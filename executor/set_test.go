@@ -16,6 +16,7 @@ package executor_test
 import (
 	. "github.com/pingcap/check"
 	"github.com/pingcap/tidb/context"
+	"github.com/pingcap/tidb/plan"
 	"github.com/pingcap/tidb/sessionctx/variable"
 	"github.com/pingcap/tidb/sessionctx/varsutil"
 	"github.com/pingcap/tidb/util/testkit"
@@ -152,6 +153,35 @@ func (s *testSuite) TestSetVar(c *C) {
 	tk.MustQuery(`select @@session.sql_log_bin;`).Check(testkit.Rows("ON"))
 }
 
+func (s *testSuite) TestSetVarFromSubquery(c *C) {
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int, b int)")
+	tk.MustExec("insert t values (1, 2), (3, 4)")
+
+	tk.MustExec("set @x = (select max(a) from t)")
+	tk.MustQuery("select @x").Check(testkit.Rows("3"))
+	tk.MustExec("set @x = (select a from t where a > 10)")
+	tk.MustQuery("select @x").Check(testkit.Rows("<nil>"))
+	// Every assignment is resolved independently.
+	tk.MustExec("set @x = (select min(b) from t), @y = 1 + (select max(b) from t), @z = 7")
+	tk.MustQuery("select @x, @y, @z").Check(testkit.Rows("2 5 7"))
+	tk.MustExec("set @@autocommit = (select count(*) - 2 from t)")
+	tk.MustQuery("select @@autocommit").Check(testkit.Rows("0"))
+	tk.MustExec("set @@autocommit = 1")
+
+	_, err := tk.Exec("set @x = (select a from t)")
+	c.Assert(err, NotNil)
+	_, err = tk.Exec("set @x = (select a, b from t limit 1)")
+	c.Assert(plan.ErrOperandColumns.Equal(err), IsTrue)
+	tk.MustQuery("select @x").Check(testkit.Rows("2"))
+}
+
 func (s *testSuite) TestSetCharset(c *C) {
 	defer testleak.AfterTest(c)()
 	tk := testkit.NewTestKit(c, s.store)
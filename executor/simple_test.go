@@ -49,10 +49,25 @@ func (s *testSuite) TestCharsetDatabase(c *C) {
 }
 
 func (s *testSuite) TestDo(c *C) {
-	defer testleak.AfterTest(c)()
+	defer func() {
+		s.cleanEnv(c)
+		testleak.AfterTest(c)()
+	}()
 	tk := testkit.NewTestKit(c, s.store)
 	tk.MustExec("do 1, @a:=1")
 	tk.MustQuery("select @a").Check(testkit.Rows("1"))
+
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int, b int)")
+	tk.MustExec("insert t values (1, 1), (2, 2)")
+	tk.MustExec("do @b := (select max(a) from t), @c := exists (select 1 from t where a > 1)")
+	tk.MustExec("do @d := 1 in (select a from t), @e := 3 in (select a from t)")
+	tk.MustExec("do @f := (select count(*) from t s where s.a > (select min(a) from t)) + 1")
+	tk.MustQuery("select @b, @c, @d, @e, @f").Check(testkit.Rows("2 1 1 0 2"))
+	rs, err := tk.Exec("do (select a from t)")
+	c.Assert(err, NotNil)
+	c.Assert(rs, IsNil)
 }
 
 func (s *testSuite) TestTransaction(c *C) {
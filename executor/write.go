@@ -637,6 +637,12 @@ type InsertValues struct {
 	Lists     [][]expression.Expression
 	Setlist   []*expression.Assignment
 	IsPrepare bool
+
+	DefaultFills []*expression.Assignment
+	GenCols      []*expression.Assignment
+
+	// Ignore is set by INSERT IGNORE, the errors of a row that can be ignored are appended as warnings instead.
+	Ignore bool
 }
 
 // InsertExec represents an insert executor.
@@ -646,7 +652,6 @@ type InsertExec struct {
 	OnDuplicate []*expression.Assignment
 
 	Priority mysql.PriorityEnum
-	Ignore   bool
 
 	finished bool
 }
@@ -706,18 +711,22 @@ func (e *InsertExec) Next() (Row, error) {
 		}
 
 		if kv.ErrKeyExists.Equal(err) {
-			// If you use the IGNORE keyword, duplicate-key error that occurs while executing the INSERT statement are ignored.
-			// For example, without IGNORE, a row that duplicates an existing UNIQUE index or PRIMARY KEY value in
-			// the table causes a duplicate-key error and the statement is aborted. With IGNORE, the row is discarded and no error occurs.
-			if e.Ignore {
-				continue
-			}
+			// Like MySQL, ON DUPLICATE KEY UPDATE takes precedence over IGNORE, the duplicated row is updated.
+			// The error of the update is not ignored, since the update may be partially written when it fails.
 			if len(e.OnDuplicate) > 0 {
 				if err = e.onDuplicateUpdate(row, h, e.OnDuplicate); err != nil {
 					return nil, errors.Trace(err)
 				}
 				continue
 			}
+			// If you use the IGNORE keyword, duplicate-key error that occurs while executing the INSERT statement are ignored.
+			// For example, without IGNORE, a row that duplicates an existing UNIQUE index or PRIMARY KEY value in
+			// the table causes a duplicate-key error and the statement is aborted. With IGNORE, the row is discarded
+			// and the error is appended as a warning.
+			if e.Ignore {
+				e.ctx.GetSessionVars().StmtCtx.AppendWarning(err)
+				continue
+			}
 		}
 		return nil, errors.Trace(err)
 	}
@@ -811,25 +820,6 @@ func (e *InsertValues) fillValueList() error {
 	return nil
 }
 
-func (e *InsertValues) checkValueCount(insertValueCount, valueCount, num int, cols []*table.Column) error {
-	// TODO: This check should be done in plan builder.
-	if insertValueCount != valueCount {
-		// "insert into t values (), ()" is valid.
-		// "insert into t values (), (1)" is not valid.
-		// "insert into t values (1), ()" is not valid.
-		// "insert into t values (1,2), (1)" is not valid.
-		// So the value count must be same for all insert list.
-		return ErrWrongValueCountOnRow.GenByArgs(num + 1)
-	}
-	if valueCount == 0 && len(e.Columns) > 0 {
-		// "insert into t (c1) values ()" is not valid.
-		return ErrWrongValueCountOnRow.GenByArgs(num + 1)
-	} else if valueCount > 0 && valueCount != len(cols) {
-		return ErrWrongValueCountOnRow.GenByArgs(num + 1)
-	}
-	return nil
-}
-
 func (e *InsertValues) getRows(cols []*table.Column) (rows [][]types.Datum, err error) {
 	// process `insert|replace ... set x=y...`
 	if err = e.fillValueList(); err != nil {
@@ -837,10 +827,11 @@ func (e *InsertValues) getRows(cols []*table.Column) (rows [][]types.Datum, err
 	}
 
 	rows = make([][]types.Datum, len(e.Lists))
-	length := len(e.Lists[0])
 	for i, list := range e.Lists {
-		if err = e.checkValueCount(length, len(list), i, cols); err != nil {
-			return nil, errors.Trace(err)
+		// The rows are checked against each other and the column list by the plan builder, but the table width is
+		// checked here, because the table may change after the plan is built.
+		if len(list) > 0 && len(list) != len(cols) {
+			return nil, ErrWrongValueCountOnRow.GenByArgs(i + 1)
 		}
 		e.currRow = int64(i)
 		rows[i], err = e.getRow(cols, list)
@@ -895,6 +886,17 @@ func (e *InsertValues) fillRowData(cols []*table.Column, vals []types.Datum, ign
 		row[offset] = v
 		hasValue[offset] = true
 	}
+	for _, fill := range e.DefaultFills {
+		if hasValue[fill.Col.Index] {
+			continue
+		}
+		val, err := fill.Expr.Eval(nil)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		row[fill.Col.Index] = val
+		hasValue[fill.Col.Index] = true
+	}
 	err := e.initDefaultValues(row, hasValue, ignoreErr)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -902,12 +904,32 @@ func (e *InsertValues) fillRowData(cols []*table.Column, vals []types.Datum, ign
 	if err = table.CastValues(e.ctx, row, cols, ignoreErr); err != nil {
 		return nil, errors.Trace(err)
 	}
+	if err = e.fillGenCols(row); err != nil {
+		return nil, errors.Trace(err)
+	}
 	if err = table.CheckNotNull(e.Table.Cols(), row); err != nil {
 		return nil, errors.Trace(err)
 	}
 	return row, nil
 }
 
+// fillGenCols computes the generated columns of the row, in the order of the table columns,
+// so a generated column can refer to the generated columns defined before it.
+func (e *InsertValues) fillGenCols(row []types.Datum) error {
+	tableCols := e.Table.Cols()
+	for _, gen := range e.GenCols {
+		val, err := gen.Expr.Eval(row)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		row[gen.Col.Index], err = table.CastValue(e.ctx, val, tableCols[gen.Col.Index].ToInfo())
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
 func (e *InsertValues) filterErr(err error, ignoreErr bool) error {
 	if err == nil {
 		return nil
@@ -932,6 +954,10 @@ func (e *InsertValues) initDefaultValues(row []types.Datum, hasValue []bool, ign
 		} else if mysql.HasNotNullFlag(c.Flag) && row[i].IsNull() && !strictSQL {
 			needDefaultValue = true
 			// TODO: Append Warning ErrColumnCantNull.
+		} else if mysql.HasNotNullFlag(c.Flag) && row[i].IsNull() && e.Ignore && !mysql.HasAutoIncrementFlag(c.Flag) {
+			// With IGNORE, a NULL of a NOT NULL column gets the implicit default value of the type instead.
+			e.ctx.GetSessionVars().StmtCtx.AppendWarning(c.CheckNotNull(row[i]))
+			row[i] = table.GetZeroValue(c.ToInfo())
 		}
 		if mysql.HasAutoIncrementFlag(c.Flag) {
 			needDefaultValue = false
@@ -355,6 +355,23 @@ func (s *testSuite) TestInsertIgnore(c *C) {
 	_, err := tk.Exec("insert ignore into t values (1, 3)")
 	c.Assert(err, NotNil)
 	cfg.SetGetError(nil)
+
+	tk.MustExec("insert ignore into t values (1, 4), (3, 4)")
+	c.Assert(tk.Se.GetSessionVars().StmtCtx.WarningCount(), Equals, uint16(1))
+	tk.MustQuery("select * from t").Check(testkit.Rows("1 2", "2 3", "3 4"))
+
+	// ON DUPLICATE KEY UPDATE takes precedence over IGNORE.
+	tk.MustExec("insert ignore into t values (1, 5) on duplicate key update c1 = values(c1)")
+	c.Assert(tk.Se.GetSessionVars().StmtCtx.WarningCount(), Equals, uint16(0))
+	tk.MustQuery("select * from t").Check(testkit.Rows("1 5", "2 3", "3 4"))
+
+	tk.MustExec("create table t1 (a int not null, b varchar(10) not null)")
+	_, err = tk.Exec("insert into t1 values (null, 'a')")
+	c.Assert(err, NotNil)
+	tk.MustExec("insert ignore into t1 values (null, 'a'), (1, null)")
+	c.Assert(tk.Se.GetSessionVars().StmtCtx.WarningCount(), Equals, uint16(2))
+	tk.MustQuery("select * from t1").Check(testkit.Rows("0 a", "1 "))
+	tk.MustExec("drop table t1")
 }
 
 func (s *testSuite) TestReplace(c *C) {
@@ -546,6 +563,19 @@ func (s *testSuite) TestUpdate(c *C) {
 	c.Assert(err, NotNil)
 	tk.MustExec("commit")
 	tk.MustQuery("select * from update_unique").Check(testkit.Rows("1 1", "2 2"))
+
+	// ORDER BY sorts the rows by the values before the update, the assignments are evaluated from left to right,
+	// so an assignment reads the new values of the columns assigned before it, like MySQL.
+	tk.MustExec("create table update_order (id int primary key, a int, b int)")
+	tk.MustExec("insert update_order values (1, 3, 0), (2, 1, 0), (3, 2, 0)")
+	tk.MustExec("update update_order set a = a + 10, b = a order by a limit 2")
+	tk.MustQuery("select * from update_order").Check(testkit.Rows("1 3 0", "2 11 11", "3 12 12"))
+	tk.MustExec("update update_order set b = a, a = a + 10 order by a desc limit 1")
+	tk.MustQuery("select * from update_order").Check(testkit.Rows("1 3 0", "2 11 11", "3 22 12"))
+	tk.MustExec("update update_order set a = 1, b = a where id = 1")
+	tk.MustQuery("select * from update_order where id = 1").Check(testkit.Rows("1 1 1"))
+	tk.MustExec("update update_order set b = a, a = 5 where id = 1")
+	tk.MustQuery("select * from update_order where id = 1").Check(testkit.Rows("1 5 1"))
 }
 
 func (s *testSuite) fillMultiTableForUpdate(tk *testkit.TestKit) {
@@ -1310,6 +1310,8 @@ func (c *sysDateFunctionClass) getFunction(args []Expression, ctx context.Contex
 		return nil, errors.Trace(err)
 	}
 	sig := &builtinSysDateSig{newBaseBuiltinFunc(args, ctx)}
+	// SYSDATE() returns the time at which it executes, unlike NOW() which is fixed when the statement is built.
+	sig.deterministic = false
 	return sig.setSelf(sig), nil
 }
 
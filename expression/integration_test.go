@@ -23,6 +23,7 @@ import (
 	"github.com/pingcap/tidb"
 	"github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/mysql"
+	"github.com/pingcap/tidb/plan"
 	"github.com/pingcap/tidb/terror"
 	"github.com/pingcap/tidb/util/testkit"
 	"github.com/pingcap/tidb/util/testleak"
@@ -1433,6 +1434,35 @@ func (s *testIntegrationSuite) TestBuiltin(c *C) {
 	tk.MustQuery("select count(*) from t") // Test ProjectionExec
 	result = tk.MustQuery("select found_rows()")
 	result.Check(testkit.Rows("1"))
+
+	// for SQL_CALC_FOUND_ROWS
+	tk.MustQuery("select sql_calc_found_rows * from t order by a limit 1").Check(testkit.Rows("1"))
+	result = tk.MustQuery("select found_rows()")
+	result.Check(testkit.Rows("3"))
+	tk.MustQuery("select sql_calc_found_rows * from t where a = 2 limit 1, 5").Check(testkit.Rows("2"))
+	result = tk.MustQuery("select found_rows()")
+	result.Check(testkit.Rows("2"))
+	tk.MustQuery("select sql_calc_found_rows * from t limit 5, 1").Check(testkit.Rows())
+	result = tk.MustQuery("select found_rows()")
+	result.Check(testkit.Rows("3"))
+	tk.MustQuery("(select sql_calc_found_rows a from t where a = 1) union all (select a from t) order by a limit 1").Check(testkit.Rows("1"))
+	result = tk.MustQuery("select found_rows()")
+	result.Check(testkit.Rows("4"))
+	tk.MustQuery("select sql_calc_found_rows * from t where a > 1").Check(testkit.Rows("2", "2"))
+	result = tk.MustQuery("select found_rows()")
+	result.Check(testkit.Rows("2"))
+	tk.MustQuery("select * from t order by a limit 1").Check(testkit.Rows("1"))
+	result = tk.MustQuery("select found_rows()")
+	result.Check(testkit.Rows("1"))
+	tk.MustQuery("select sql_calc_found_rows * from t limit 1")
+	tk.MustQuery("show warnings").Check(testutil.RowsWithSep("|",
+		"Warning|1287|'SQL_CALC_FOUND_ROWS' is deprecated and will be removed in a future release. Please use SELECT COUNT(*) instead"))
+	_, err = tk.Exec("select * from t where a in (select sql_calc_found_rows a from t limit 1)")
+	c.Assert(terror.ErrorEqual(err, plan.ErrCantUseOptionHere), IsTrue)
+	_, err = tk.Exec("select * from (select sql_calc_found_rows a from t limit 1) s")
+	c.Assert(terror.ErrorEqual(err, plan.ErrCantUseOptionHere), IsTrue)
+	_, err = tk.Exec("select a from t union select sql_calc_found_rows a from t limit 1")
+	c.Assert(terror.ErrorEqual(err, plan.ErrCantUseOptionHere), IsTrue)
 }
 
 func (s *testIntegrationSuite) TestInfoBuiltin(c *C) {
@@ -234,6 +234,10 @@ var tokenMap = map[string]int{
 	"DISTINCTROW":                distinctRow,
 	"TIDB_SMJ":                   tidbSMJ,
 	"TIDB_INLJ":                  tidbINLJ,
+	"NO_DECORRELATE":             noDecorrelate,
+	"QB_NAME":                    qbName,
+	"USE_INDEX_ONLY":             useIndexOnly,
+	"TIDB_CARD":                  tidbCard,
 	"TIDB_VERSION":               tidbVersion,
 	"DIV":                        div,
 	"DO":                         do,
@@ -261,6 +265,7 @@ var tokenMap = map[string]int{
 	"EXPORT_SET":                 exportSet,
 	"EXTRACT":                    extract,
 	"FALSE":                      falseKwd,
+	"FETCH":                      fetch,
 	"FIELD":                      fieldKwd,
 	"FIELDS":                     fields,
 	"FIND_IN_SET":                findInSet,
@@ -357,6 +362,7 @@ var tokenMap = map[string]int{
 	"MONTHNAME":                  monthname,
 	"NAMES":                      names,
 	"NATIONAL":                   national,
+	"NEXT":                       next,
 	"NONE":                       none,
 	"NOT":                        not,
 	"NO_WRITE_TO_BINLOG":         noWriteToBinLog,
@@ -364,6 +370,7 @@ var tokenMap = map[string]int{
 	"NULLIF":                     nullIf,
 	"OCT":                        oct,
 	"OCTET_LENGTH":               octetLength,
+	"OF":                         of,
 	"OFFSET":                     offset,
 	"ON":                         on,
 	"ONLY":                       only,
@@ -408,6 +415,7 @@ var tokenMap = map[string]int{
 	"ROUND":                      round,
 	"ROW":                        row,
 	"ROW_FORMAT":                 rowFormat,
+	"ROWS":                       rows,
 	"RTRIM":                      rtrim,
 	"REVERSE":                    reverse,
 	"SCHEMA":                     schema,
@@ -459,6 +467,7 @@ var tokenMap = map[string]int{
 	"TIMESTAMPADD":               timestampAdd,
 	"TIMESTAMPDIFF":              timestampDiff,
 	"THAN":                       than,
+	"TIES":                       ties,
 	"THEN":                       then,
 	"TO":                         to,
 	"TO_BASE64":                  toBase64,
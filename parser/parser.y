@@ -102,6 +102,10 @@ import (
 	distinctRow		"DISTINCTROW"
 	tidbSMJ			"TIDB_SMJ"
 	tidbINLJ		"TIDB_INLJ"
+	noDecorrelate		"NO_DECORRELATE"
+	qbName			"QB_NAME"
+	useIndexOnly		"USE_INDEX_ONLY"
+	tidbCard		"TIDB_CARD"
 	tidbVersion		"TIDB_VERSION"
 	div 			"DIV"
 	doubleType		"DOUBLE"
@@ -113,6 +117,7 @@ import (
 	exists			"EXISTS"
 	explain			"EXPLAIN"
 	falseKwd		"FALSE"
+	fetch			"FETCH"
 	floatType		"FLOAT"
 	forKwd			"FOR"
 	force			"FORCE"
@@ -483,8 +488,10 @@ import (
 	minRows		"MIN_ROWS"
 	names		"NAMES"
 	national	"NATIONAL"
+	next		"NEXT"
 	no		"NO"
 	none		"NONE"
+	of		"OF"
 	offset		"OFFSET"
 	only		"ONLY"
 	password	"PASSWORD"
@@ -499,6 +506,7 @@ import (
 	rollback	"ROLLBACK"
 	row 		"ROW"
 	rowFormat	"ROW_FORMAT"
+	rows		"ROWS"
 	serializable	"SERIALIZABLE"
 	session		"SESSION"
 	share		"SHARE"
@@ -520,6 +528,7 @@ import (
 	tables		"TABLES"
 	textType	"TEXT"
 	than		"THAN"
+	ties		"TIES"
 	tidb		"TIDB"
 	timeType	"TIME"
 	timestampType	"TIMESTAMP"
@@ -574,6 +583,7 @@ import (
 	AlterUserStmt		"Alter user statement"
 	AnalyzeTableStmt	"Analyze table statement"
 	AnyOrAll		"Any or All for subquery"
+	AsOfClause		"AS OF TIMESTAMP clause"
 	Assignment		"assignment"
 	AssignmentList		"assignment list"
 	AssignmentListOpt	"assignment list opt"
@@ -607,6 +617,7 @@ import (
 	DatabaseOptionListOpt	"CREATE Database specification list opt"
 	CreateTableStmt		"CREATE TABLE statement"
 	CreateUserStmt		"CREATE User statement"
+	CrossOpt		"Cross join option"
 	DBName			"Database Name"
 	DeallocateStmt		"Deallocate prepared statement"
 	DefaultValueExpr	"DefaultValueExpr(Now or Signed Literal)"
@@ -641,6 +652,7 @@ import (
 	FieldsTerminated	"Fields terminated by"
 	FieldAsName		"Field alias name"
 	FieldAsNameOpt		"Field alias name opt"
+	FetchFirstClause	"FETCH FIRST clause"
 	FieldList		"field expression list"
 	FlushStmt		"Flush statement"
 	FlushOption		"Flush option"
@@ -796,6 +808,7 @@ import (
 	Variable		"User or system variable"
 	WhereClause		"WHERE clause"
 	WhereClauseOptional	"Optional WHERE clause"
+	WithTiesOpt		"Optional WITH TIES"
 	WhenClause		"When clause"
 	WhenClauseList		"When clause list"
 	WithReadLockOpt		"With Read Lock opt"
@@ -832,6 +845,7 @@ import (
 	NUM			"numbers"
 	LengthNum		"Field length num(uint64)"
 	HintTableList		"Table list in optimizer hint"
+	HintQueryBlockOpt	"Optional query block name in optimizer hint"
 	TableOptimizerHintOpt	"Table level optimizer hint"
 	TableOptimizerHints	"Table level optimizer hints"
 	TableOptimizerHintList	"Table level optimizer hint list"
@@ -852,12 +866,13 @@ import (
 	TimestampUnit		"Time unit for 'TIMESTAMPADD' and 'TIMESTAMPDIFF'"
 	DeallocateSym		"Deallocate or drop"
 	OuterOpt		"optional OUTER clause"
-	CrossOpt		"Cross join option"
 	TablesTerminalSym 	"{TABLE|TABLES}"
 	IsolationLevel		"Isolation level"
 	ShowIndexKwd		"Show index/indexs/key keyword"
 	DistinctKwd		"DISTINCT/DISTINCTROW keyword"
 	FromOrIn		"From or In"
+	FirstOrNext		"{FIRST|NEXT}"
+	RowOrRows		"{ROW|ROWS}"
 	OptTable		"Optional table keyword"
 	OptInteger		"Optional Integer keyword"
 	NationalOpt		"National option"
@@ -2179,18 +2194,30 @@ PredicateExpr:
 	}
 |	PrimaryFactor LikeOrNotOp PrimaryExpression LikeEscapeOpt
 	{
-		escape := $4.(string)
-		if len(escape) > 1 {
-			yylex.Errorf("Incorrect arguments %s to ESCAPE", escape)
-			return 1
-		} else if len(escape) == 0 {
-			escape = "\\"
+		// The backslash is the default escape character, there is no escape character if the NO_BACKSLASH_ESCAPES
+		// mode is enabled, the escape character can't be omitted by an empty ESCAPE either in that mode.
+		noBackslashEscapes := parser.lexer.sqlMode&mysql.ModeNoBackslashEscapes > 0
+		var escape byte
+		if $4 == nil {
+			if !noBackslashEscapes {
+				escape = '\\'
+			}
+		} else {
+			escapeStr := $4.(string)
+			if len(escapeStr) > 1 || (len(escapeStr) == 0 && noBackslashEscapes) {
+				yylex.Errorf("Incorrect arguments %s to ESCAPE", escapeStr)
+				return 1
+			} else if len(escapeStr) == 0 {
+				escape = '\\'
+			} else {
+				escape = escapeStr[0]
+			}
 		}
 		$$ = &ast.PatternLikeExpr{
 			Expr:		$1.(ast.ExprNode),
 			Pattern:	$3.(ast.ExprNode),
 			Not: 		!$2.(bool),
-			Escape: 	escape[0],
+			Escape: 	escape,
 		}
 	}
 |	PrimaryFactor RegexpOrNotOp PrimaryExpression
@@ -2205,7 +2232,7 @@ RegexpSym:
 LikeEscapeOpt:
 	%prec lowerThanEscape
 	{
-		$$ = "\\"
+		$$ = nil
 	}
 |	"ESCAPE" stringLit
 	{
@@ -2288,6 +2315,10 @@ GroupByClause:
 	{
 		$$ = &ast.GroupByClause{Items: $3.([]*ast.ByItem)}
 	}
+|	"GROUP" "BY" '(' ')'
+	{
+		$$ = &ast.GroupByClause{Items: []*ast.ByItem{}}
+	}
 
 HavingClause:
 	{
@@ -2412,7 +2443,7 @@ UnReservedKeyword:
  "ACTION" | "ASCII" | "AUTO_INCREMENT" | "AFTER" | "ALWAYS" | "AT" | "AVG" | "BEGIN" | "BIT" | "BOOL" | "BOOLEAN" | "BTREE" | "CHARSET"
 | "COLUMNS" | "COMMIT" | "COMPACT" | "COMPRESSED" | "CONSISTENT" | "DATA" | "DATE" | "DATETIME" | "DEALLOCATE" | "DO"
 | "DYNAMIC"| "END" | "ENGINE" | "ENGINES" | "ESCAPE" | "EXECUTE" | "FIELDS" | "FIRST" | "FIXED" | "FORMAT" | "FULL" |"GLOBAL"
-| "HASH" | "LESS" | "LOCAL" | "NAMES" | "OFFSET" | "PASSWORD" %prec lowerThanEq | "PREPARE" | "QUICK" | "REDUNDANT"
+| "HASH" | "LESS" | "LOCAL" | "NAMES" | "OF" | "OFFSET" | "PASSWORD" %prec lowerThanEq | "PREPARE" | "QUICK" | "REDUNDANT"
 | "ROLLBACK" | "SESSION" | "SIGNED" | "SNAPSHOT" | "START" | "STATUS" | "TABLES" | "TEXT" | "THAN" | "TIDB" | "TIME" | "TIMESTAMP"
 | "TRANSACTION" | "TRUNCATE" | "UNKNOWN" | "VALUE" | "WARNINGS" | "YEAR" | "MODE"  | "WEEK"  | "ANY" | "SOME" | "USER" | "IDENTIFIED"
 | "COLLATION" | "COMMENT" | "AVG_ROW_LENGTH" | "CONNECTION" | "CHECKSUM" | "COMPRESSION" | "KEY_BLOCK_SIZE" | "MAX_ROWS"
@@ -2420,6 +2451,7 @@ UnReservedKeyword:
 | "REPEATABLE" | "COMMITTED" | "UNCOMMITTED" | "ONLY" | "SERIALIZABLE" | "LEVEL" | "VARIABLES" | "SQL_CACHE" | "INDEXES" | "PROCESSLIST"
 | "SQL_NO_CACHE" | "DISABLE"  | "ENABLE" | "REVERSE" | "SPACE" | "PRIVILEGES" | "NO" | "BINLOG" | "FUNCTION" | "VIEW" | "MODIFY" | "EVENTS" | "PARTITIONS"
 | "TIMESTAMPDIFF" | "NONE" | "SUPER" | "SHARED" | "EXCLUSIVE" | "STATS" | "STATS_META" | "STATS_HISTOGRAMS" | "STATS_BUCKETS"
+| "NEXT" | "ROWS" | "TIES"
 
 ReservedKeyword:
 "ADD" | "ALL" | "ALTER" | "ANALYZE" | "AND" | "AS" | "ASC" | "BETWEEN" | "BIGINT"
@@ -2428,7 +2460,7 @@ ReservedKeyword:
 | "CURRENT_TIMESTAMP" | "CURRENT_USER" | "DATABASE" | "DATABASES" | "DAY_HOUR" | "DAY_MICROSECOND"
 | "DAY_MINUTE" | "DAY_SECOND" | "DECIMAL" | "DEFAULT" | "DELETE" | "DESC" | "DESCRIBE"
 | "DISTINCT" | "DISTINCTROW" | "DIV" | "DOUBLE" | "DROP" | "DUAL" | "ELSE" | "ENCLOSED" | "ESCAPED"
-| "EXISTS" | "EXPLAIN" | "FALSE" | "FLOAT" | "FOR" | "FORCE" | "FOREIGN" | "FROM"
+| "EXISTS" | "EXPLAIN" | "FALSE" | "FETCH" | "FLOAT" | "FOR" | "FORCE" | "FOREIGN" | "FROM"
 | "FULLTEXT" | "GENERATED" | "GRANT" | "GROUP" | "HAVING" | "HOUR_MICROSECOND" | "HOUR_MINUTE"
 | "HOUR_SECOND" | "IF" | "IGNORE" | "IN" | "INDEX" | "INFILE" | "INNER" | "INSERT" | "INT" | "INTO" | "INTEGER"
 | "INTERVAL" | "IS" | "JOIN" | "KEY" | "KEYS" | "KILL" | "LEADING" | "LEFT" | "LIKE" | "LIMIT" | "LINES" | "LOAD"
@@ -2719,6 +2751,7 @@ ByList:
 ByItem:
 	Expression Order
 	{
+		// The placeholder is not a ValueExpr, so it is never a position.
 		expr := $1
 		valueExpr, ok := expr.(*ast.ValueExpr)
 		if ok {
@@ -4492,6 +4525,24 @@ SelectStmt:
 
 		$$ = st
 	}
+|	"TABLE" TableName OrderByOptional SelectStmtLimit
+	{
+		/* TABLE t is the shorthand of SELECT * FROM t */
+		st := &ast.SelectStmt{
+			SelectStmtOpts:	&ast.SelectStmtOpts{},
+			IsTable:	true,
+			Fields:		&ast.FieldList{Fields: []*ast.SelectField{{WildCard: &ast.WildCardField{}}}},
+			From:		&ast.TableRefsClause{TableRefs: &ast.Join{Left: &ast.TableSource{Source: $2.(*ast.TableName)}}},
+			LockTp:		ast.SelectLockNone,
+		}
+		if $3 != nil {
+			st.OrderBy = $3.(*ast.OrderByClause)
+		}
+		if $4 != nil {
+			st.Limit = $4.(*ast.Limit)
+		}
+		$$ = st
+	}
 
 FromDual:
 	"FROM" "DUAL"
@@ -4550,6 +4601,13 @@ TableFactor:
 		tn.IndexHints = $3.([]*ast.IndexHint)
 		$$ = &ast.TableSource{Source: tn, AsName: $2.(model.CIStr)}
 	}
+|	TableName AsOfClause TableAsNameOpt IndexHintListOpt
+	{
+		tn := $1.(*ast.TableName)
+		tn.AsOf = $2.(*ast.AsOfClause)
+		tn.IndexHints = $4.([]*ast.IndexHint)
+		$$ = &ast.TableSource{Source: tn, AsName: $3.(model.CIStr)}
+	}
 |	'(' SelectStmt ')' TableAsName
 	{
 		st := $2.(*ast.SelectStmt)
@@ -4566,6 +4624,12 @@ TableFactor:
 		$$ = $2
 	}
 
+AsOfClause:
+	"AS" "OF" "TIMESTAMP" Expression
+	{
+		$$ = &ast.AsOfClause{TsExpr: $4.(ast.ExprNode)}
+	}
+
 TableAsNameOpt:
 	{
 		$$ = model.CIStr{}
@@ -4666,7 +4730,7 @@ JoinTable:
 	/* Use %prec to evaluate production TableRef before cross join */
 	TableRef CrossOpt TableRef %prec tableRefPriority
 	{
-		$$ = &ast.Join{Left: $1.(ast.ResultSetNode), Right: $3.(ast.ResultSetNode), Tp: ast.CrossJoin}
+		$$ = &ast.Join{Left: $1.(ast.ResultSetNode), Right: $3.(ast.ResultSetNode), Tp: ast.CrossJoin, ExplicitCross: $2.(bool)}
 	}
 |	TableRef CrossOpt TableRef "ON" Expression
 	{
@@ -4711,8 +4775,17 @@ OuterOpt:
 
 CrossOpt:
 	"JOIN"
+	{
+		$$ = false
+	}
 |	"CROSS" "JOIN"
+	{
+		$$ = true
+	}
 |	"INNER" "JOIN"
+	{
+		$$ = false
+	}
 
 LimitClause:
 	{
@@ -4739,17 +4812,46 @@ SelectStmtLimit:
 	{
 		$$ = nil
 	}
-|	"LIMIT" LimitOption
+|	"LIMIT" LimitOption WithTiesOpt
 	{
-		$$ = &ast.Limit{Count: $2.(ast.ExprNode)}
+		$$ = &ast.Limit{Count: $2.(ast.ExprNode), WithTies: $3.(bool)}
+	}
+|	"LIMIT" LimitOption ',' LimitOption WithTiesOpt
+	{
+		$$ = &ast.Limit{Offset: $2.(ast.ExprNode), Count: $4.(ast.ExprNode), WithTies: $5.(bool)}
 	}
-|	"LIMIT" LimitOption ',' LimitOption
+|	"LIMIT" LimitOption "OFFSET" LimitOption WithTiesOpt
 	{
-		$$ = &ast.Limit{Offset: $2.(ast.ExprNode), Count: $4.(ast.ExprNode)}
+		$$ = &ast.Limit{Offset: $4.(ast.ExprNode), Count: $2.(ast.ExprNode), WithTies: $5.(bool)}
 	}
-|	"LIMIT" LimitOption "OFFSET" LimitOption
+|	FetchFirstClause
 	{
-		$$ = &ast.Limit{Offset: $4.(ast.ExprNode), Count: $2.(ast.ExprNode)}
+		$$ = $1
+	}
+
+FetchFirstClause:
+	"FETCH" FirstOrNext LimitOption RowOrRows "ONLY"
+	{
+		$$ = &ast.Limit{Count: $3.(ast.ExprNode)}
+	}
+|	"FETCH" FirstOrNext LimitOption RowOrRows "WITH" "TIES"
+	{
+		$$ = &ast.Limit{Count: $3.(ast.ExprNode), WithTies: true}
+	}
+
+FirstOrNext:
+	"FIRST" | "NEXT"
+
+RowOrRows:
+	"ROW" | "ROWS"
+
+WithTiesOpt:
+	{
+		$$ = false
+	}
+|	"WITH" "TIES"
+	{
+		$$ = true
 	}
 
 
@@ -4807,13 +4909,42 @@ TableOptimizerHintList:
 	}
 
 TableOptimizerHintOpt:
-	tidbSMJ '(' HintTableList ')'
+	tidbSMJ '(' HintQueryBlockOpt HintTableList ')'
+	{
+		$$ = &ast.TableOptimizerHint{HintName: model.NewCIStr($1), QBName: $3.(model.CIStr), Tables: $4.([]model.CIStr)}
+	}
+|	tidbINLJ '(' HintQueryBlockOpt HintTableList ')'
+	{
+		$$ = &ast.TableOptimizerHint{HintName: model.NewCIStr($1), QBName: $3.(model.CIStr), Tables: $4.([]model.CIStr)}
+	}
+|	noDecorrelate '(' HintQueryBlockOpt ')'
+	{
+		$$ = &ast.TableOptimizerHint{HintName: model.NewCIStr($1), QBName: $3.(model.CIStr)}
+	}
+|	useIndexOnly '(' HintQueryBlockOpt HintTableList ')'
+	{
+		$$ = &ast.TableOptimizerHint{HintName: model.NewCIStr($1), QBName: $3.(model.CIStr), Tables: $4.([]model.CIStr)}
+	}
+|	qbName '(' Identifier ')'
+	{
+		$$ = &ast.TableOptimizerHint{HintName: model.NewCIStr($1), QBName: model.NewCIStr($3)}
+	}
+|	tidbCard '(' HintQueryBlockOpt Identifier LengthNum ')'
+	{
+		$$ = &ast.TableOptimizerHint{HintName: model.NewCIStr($1), QBName: $3.(model.CIStr), Tables: []model.CIStr{model.NewCIStr($4)}, Cardinality: $5.(uint64)}
+	}
+|	tidbCard '(' HintQueryBlockOpt Identifier '.' Identifier LengthNum ')'
 	{
-		$$ = &ast.TableOptimizerHint{HintName: model.NewCIStr($1), Tables: $3.([]model.CIStr)}
+		$$ = &ast.TableOptimizerHint{HintName: model.NewCIStr($1), QBName: $3.(model.CIStr), Tables: []model.CIStr{model.NewCIStr($4)}, Column: model.NewCIStr($6), Cardinality: $7.(uint64)}
+	}
+
+HintQueryBlockOpt:
+	{
+		$$ = model.CIStr{}
 	}
-|	tidbINLJ '(' HintTableList ')'
+|	singleAtIdentifier
 	{
-		$$ = &ast.TableOptimizerHint{HintName: model.NewCIStr($1), Tables: $3.([]model.CIStr)}
+		$$ = model.NewCIStr(strings.TrimPrefix($1, "@"))
 	}
 
 SelectStmtCalcFoundRows:
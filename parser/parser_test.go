@@ -47,7 +47,7 @@ func (s *testParserSuite) TestSimple(c *C) {
 		"current_timestamp", "current_user", "database", "databases", "day_hour", "day_microsecond",
 		"day_minute", "day_second", "decimal", "default", "delete", "desc", "describe",
 		"distinct", "distinctRow", "div", "double", "drop", "dual", "else", "enclosed", "escaped",
-		"exists", "explain", "false", "float", "for", "force", "foreign", "from",
+		"exists", "explain", "false", "fetch", "float", "for", "force", "foreign", "from",
 		"fulltext", "grant", "group", "having", "hour_microsecond", "hour_minute",
 		"hour_second", "if", "ignore", "in", "index", "infile", "inner", "insert", "int", "into", "integer",
 		"interval", "is", "join", "key", "keys", "kill", "leading", "left", "like", "limit", "lines", "load",
@@ -93,7 +93,7 @@ func (s *testParserSuite) TestSimple(c *C) {
 		"enable", "disable", "reverse", "space", "privileges", "get_lock", "release_lock", "sleep", "no", "greatest", "least",
 		"binlog", "hex", "unhex", "function", "indexes", "from_unixtime", "processlist", "events", "less", "than", "timediff",
 		"ln", "log", "log2", "log10", "timestampdiff", "pi", "quote", "none", "super", "default", "shared", "exclusive",
-		"always", "stats", "stats_meta", "stats_histogram", "stats_buckets", "tidb_version",
+		"always", "stats", "stats_meta", "stats_histogram", "stats_buckets", "tidb_version", "next", "rows", "ties",
 	}
 	for _, kw := range unreservedKws {
 		src := fmt.Sprintf("SELECT %s FROM tbl;", kw)
@@ -350,6 +350,28 @@ func (s *testParserSuite) TestDMLStmt(c *C) {
 		{"select 1 where exists (select 2)", false},
 		{"select 1 from dual where not exists (select 2)", true},
 
+		// for TABLE statement
+		{"table t", true},
+		{"table test.t order by a desc, b limit 5", true},
+		{"table t limit 1, 2", true},
+		{"table t union all table t1", true},
+		{"select * from t where a in (table t1)", true},
+		{"table t where a = 1", false},
+		{"table t, t1", false},
+		{"table t as t1", false},
+
+		// for LIMIT ... WITH TIES and FETCH FIRST
+		{"select * from t order by a limit 2 with ties", true},
+		{"select * from t order by a limit 1, 2 with ties", true},
+		{"select * from t order by a limit 2 offset 1 with ties", true},
+		{"select * from t order by a fetch first 2 rows with ties", true},
+		{"select * from t order by a fetch next 1 row only", true},
+		{"select * from t order by a fetch first ? rows with ties", true},
+		{"select * from t order by a fetch first 2 rows", false},
+		{"select * from t order by a limit 2 with", false},
+		{"select c1 from t1 union select c2 from t2 order by c1 limit 2 with ties", true},
+		{"update t set a = 1 order by a limit 2 with ties", false},
+
 		// for https://github.com/pingcap/tidb/issues/320
 		{`(select 1);`, true},
 
@@ -1057,6 +1079,8 @@ func (s *testParserSuite) TestBuiltin(c *C) {
 		{`select group_concat(c2,c1) from t group by c1;`, true},
 		{`select group_concat(distinct c2,c1) from t group by c1;`, true},
 		{`select group_concat(distinctrow c2,c1) from t group by c1;`, true},
+		{`select count(*) from t group by ();`, true},
+		{`select count(*) from t group by (), c1;`, false},
 
 		// for encryption and compression functions
 		{`select AES_ENCRYPT('text',UNHEX('F3229A0B371ED2D9441B830D21A390C3'))`, true},
@@ -1435,6 +1459,58 @@ func (s *testParserSuite) TestOptimizerHints(c *C) {
 	c.Assert(hints[1].HintName.L, Equals, "tidb_inlj")
 	c.Assert(hints[1].Tables[0].L, Equals, "t3")
 	c.Assert(hints[1].Tables[1].L, Equals, "t4")
+
+	stmt, err = parser.Parse("select c1 from t1 where c1 in (select /*+ NO_DECORRELATE() */ c1 from t2 where t2.c2 = t1.c2)", "", "")
+	c.Assert(err, IsNil)
+	selectStmt = stmt[0].(*ast.SelectStmt)
+	c.Assert(selectStmt.TableHints, HasLen, 0)
+
+	subq := selectStmt.Where.(*ast.PatternInExpr).Sel.(*ast.SubqueryExpr)
+	hints = subq.Query.(*ast.SelectStmt).TableHints
+	c.Assert(hints, HasLen, 1)
+	c.Assert(hints[0].HintName.L, Equals, "no_decorrelate")
+	c.Assert(hints[0].Tables, HasLen, 0)
+
+	stmt, err = parser.Parse("select /*+ TIDB_INLJ(@qb1 t2) TIDB_SMJ(t1, t3) */ c1 from t1, t3 where c1 in (select /*+ QB_NAME(qb1) */ c1 from t2)", "", "")
+	c.Assert(err, IsNil)
+	selectStmt = stmt[0].(*ast.SelectStmt)
+	hints = selectStmt.TableHints
+	c.Assert(hints, HasLen, 2)
+	c.Assert(hints[0].HintName.L, Equals, "tidb_inlj")
+	c.Assert(hints[0].QBName.L, Equals, "qb1")
+	c.Assert(hints[0].Tables[0].L, Equals, "t2")
+	c.Assert(hints[1].QBName.L, Equals, "")
+
+	subq = selectStmt.Where.(*ast.PatternInExpr).Sel.(*ast.SubqueryExpr)
+	hints = subq.Query.(*ast.SelectStmt).TableHints
+	c.Assert(hints, HasLen, 1)
+	c.Assert(hints[0].HintName.L, Equals, "qb_name")
+	c.Assert(hints[0].QBName.L, Equals, "qb1")
+
+	stmt, err = parser.Parse("select /*+ USE_INDEX_ONLY(t1, t2) */ c1 from t1, t2", "", "")
+	c.Assert(err, IsNil)
+	hints = stmt[0].(*ast.SelectStmt).TableHints
+	c.Assert(hints, HasLen, 1)
+	c.Assert(hints[0].HintName.L, Equals, "use_index_only")
+	c.Assert(hints[0].Tables, HasLen, 2)
+
+	stmt, err = parser.Parse("select /*+ TIDB_CARD(t1 10000) TIDB_CARD(@qb1 t2.c1 100) */ c1 from t1, t2", "", "")
+	c.Assert(err, IsNil)
+	hints = stmt[0].(*ast.SelectStmt).TableHints
+	c.Assert(hints, HasLen, 2)
+	c.Assert(hints[0].HintName.L, Equals, "tidb_card")
+	c.Assert(hints[0].Tables[0].L, Equals, "t1")
+	c.Assert(hints[0].Column.L, Equals, "")
+	c.Assert(hints[0].Cardinality, Equals, uint64(10000))
+	c.Assert(hints[1].QBName.L, Equals, "qb1")
+	c.Assert(hints[1].Tables[0].L, Equals, "t2")
+	c.Assert(hints[1].Column.L, Equals, "c1")
+	c.Assert(hints[1].Cardinality, Equals, uint64(100))
+
+	_, err = parser.Parse("select /*+ TIDB_CARD(t1, t2 10) */ c1 from t1, t2", "", "")
+	c.Assert(err, NotNil)
+	_, err = parser.Parse("select /*+ TIDB_CARD(t1 -10) */ c1 from t1", "", "")
+	c.Assert(err, NotNil)
 }
 
 func (s *testParserSuite) TestType(c *C) {
@@ -1613,6 +1689,48 @@ func (s *testParserSuite) TestLikeEscape(c *C) {
 	s.RunTest(c, table)
 }
 
+func (s *testParserSuite) TestLikeEscapeChar(c *C) {
+	defer testleak.AfterTest(c)()
+	parser := New()
+	for _, mode := range []mysql.SQLMode{0, mysql.ModeNoBackslashEscapes} {
+		parser.SetSQLMode(mode)
+		for _, tt := range []struct {
+			sql    string
+			escape byte
+			ok     bool
+		}{
+			{`select * from t where a like 'a!_b' escape '!'`, '!', true},
+			{`select * from t where a not like 'a!_b' escape '!'`, '!', true},
+			{`select * from t where a like 'a\\_b' escape '\\'`, '\\', true},
+			{`select * from t where a like 'a_b' escape '!!'`, 0, false},
+		} {
+			stmt, err := parser.ParseOneStmt(tt.sql, "", "")
+			if !tt.ok {
+				c.Assert(err, NotNil, Commentf("sql %s", tt.sql))
+				continue
+			}
+			c.Assert(err, IsNil, Commentf("sql %s", tt.sql))
+			like := stmt.(*ast.SelectStmt).Where.(*ast.PatternLikeExpr)
+			c.Assert(like.Escape, Equals, tt.escape, Commentf("sql %s", tt.sql))
+		}
+	}
+
+	// The backslash is the default escape character, but not in the NO_BACKSLASH_ESCAPES mode.
+	parser.SetSQLMode(0)
+	stmt, err := parser.ParseOneStmt(`select * from t where a like 'a_b'`, "", "")
+	c.Assert(err, IsNil)
+	c.Assert(stmt.(*ast.SelectStmt).Where.(*ast.PatternLikeExpr).Escape, Equals, byte('\\'))
+	stmt, err = parser.ParseOneStmt(`select * from t where a like 'a_b' escape ''`, "", "")
+	c.Assert(err, IsNil)
+	c.Assert(stmt.(*ast.SelectStmt).Where.(*ast.PatternLikeExpr).Escape, Equals, byte('\\'))
+	parser.SetSQLMode(mysql.ModeNoBackslashEscapes)
+	stmt, err = parser.ParseOneStmt(`select * from t where a like 'a_b'`, "", "")
+	c.Assert(err, IsNil)
+	c.Assert(stmt.(*ast.SelectStmt).Where.(*ast.PatternLikeExpr).Escape, Equals, byte(0))
+	_, err = parser.ParseOneStmt(`select * from t where a like 'a_b' escape ''`, "", "")
+	c.Assert(err, NotNil)
+}
+
 func (s *testParserSuite) TestMysqlDump(c *C) {
 	defer testleak.AfterTest(c)()
 	// Statements used by mysqldump.
@@ -1647,6 +1765,45 @@ func (s *testParserSuite) TestIndexHint(c *C) {
 	s.RunTest(c, table)
 }
 
+func (s *testParserSuite) TestAsOfTimestamp(c *C) {
+	defer testleak.AfterTest(c)()
+	table := []testCase{
+		{`select * from t as of timestamp '2017-01-01 00:00:00'`, true},
+		{`select * from t as of timestamp '2017-01-01 00:00:00' as t1 use index (idx)`, true},
+		{`select * from t as of timestamp '2017-01-01 00:00:00' t1 join t2 as of timestamp '2017-01-01 00:00:00' t2 on t1.a = t2.a`, true},
+		{`select * from t as of '2017-01-01 00:00:00'`, false},
+		{`select * from t as of timestamp`, false},
+		{`create table of (a int)`, true},
+	}
+	s.RunTest(c, table)
+
+	parser := New()
+	stmt, err := parser.ParseOneStmt(`select * from t as of timestamp '2017-01-01 00:00:00' as t1`, "", "")
+	c.Assert(err, IsNil)
+	ts := stmt.(*ast.SelectStmt).From.TableRefs.Left.(*ast.TableSource)
+	c.Assert(ts.AsName.L, Equals, "t1")
+	tn := ts.Source.(*ast.TableName)
+	c.Assert(tn.AsOf, NotNil)
+	c.Assert(tn.AsOf.TsExpr.GetValue(), Equals, "2017-01-01 00:00:00")
+}
+
+func (s *testParserSuite) TestExplicitCrossJoin(c *C) {
+	defer testleak.AfterTest(c)()
+	parser := New()
+	for sql, explicit := range map[string]bool{
+		"select * from t1 cross join t2":             true,
+		"select * from t1 join t2":                   false,
+		"select * from t1 inner join t2":             false,
+		"select * from t1, t2":                       false,
+		"select * from t1 cross join t2 on t1.a = 1": false,
+	} {
+		stmt, err := parser.ParseOneStmt(sql, "", "")
+		c.Assert(err, IsNil)
+		join := stmt.(*ast.SelectStmt).From.TableRefs
+		c.Assert(join.ExplicitCross, Equals, explicit, Commentf("sql %s", sql))
+	}
+}
+
 func (s *testParserSuite) TestPriority(c *C) {
 	defer testleak.AfterTest(c)()
 	table := []testCase{
@@ -316,15 +316,45 @@ func (a *aggregationOptimizer) pushAggCrossUnion(agg *LogicalAggregation, unionS
 }
 
 func (a *aggregationOptimizer) optimize(p LogicalPlan, ctx context.Context, alloc *idAllocator) (LogicalPlan, error) {
-	if !ctx.GetSessionVars().AllowAggPushDown {
-		return p, nil
-	}
 	a.ctx = ctx
 	a.allocator = alloc
+	if !ctx.GetSessionVars().AllowAggPushDown {
+		// Eliminating the DISTINCT grouped by unique key pushes nothing down, e.g. the DISTINCT of
+		// `select distinct a, count(*) from t group by a`, so it is always done.
+		return a.eliminateDistinct(p), nil
+	}
 	a.aggPushDown(p)
 	return p, nil
 }
 
+// eliminateDistinct eliminates every DISTINCT grouped by unique key in the plan tree. The DISTINCT is the aggregation
+// that has only firstrow functions, other aggregations are left to the aggregation push down.
+func (a *aggregationOptimizer) eliminateDistinct(p LogicalPlan) LogicalPlan {
+	if agg, ok := p.(*LogicalAggregation); ok && isDistinctAggregation(agg) {
+		if proj := a.tryToEliminateAggregation(agg); proj != nil {
+			p = proj
+		}
+	}
+	newChildren := make([]Plan, 0, len(p.Children()))
+	for _, child := range p.Children() {
+		newChild := a.eliminateDistinct(child.(LogicalPlan))
+		newChild.SetParents(p)
+		newChildren = append(newChildren, newChild)
+	}
+	p.SetChildren(newChildren...)
+	return p
+}
+
+// isDistinctAggregation checks whether the aggregation only has firstrow functions.
+func isDistinctAggregation(agg *LogicalAggregation) bool {
+	for _, fun := range agg.AggFuncs {
+		if fun.GetName() != ast.AggFuncFirstRow {
+			return false
+		}
+	}
+	return true
+}
+
 // aggPushDown tries to push down aggregate functions to join paths.
 func (a *aggregationOptimizer) aggPushDown(p LogicalPlan) LogicalPlan {
 	if agg, ok := p.(*LogicalAggregation); ok {
@@ -18,15 +18,76 @@ import (
 	"github.com/pingcap/tidb/context"
 	"github.com/pingcap/tidb/expression"
 	"github.com/pingcap/tidb/mysql"
+	"github.com/pingcap/tidb/sessionctx/variable"
 )
 
 type buildKeySolver struct{}
 
-func (s *buildKeySolver) optimize(lp LogicalPlan, _ context.Context, _ *idAllocator) (LogicalPlan, error) {
+func (s *buildKeySolver) optimize(lp LogicalPlan, ctx context.Context, _ *idAllocator) (LogicalPlan, error) {
 	lp.buildKeyInfo()
+	if ctx.GetSessionVars().WarnNondeterministicLimit {
+		checkLimitDeterminism(lp, ctx.GetSessionVars().StmtCtx)
+	}
 	return lp, nil
 }
 
+// checkLimitDeterminism appends a warning for every LIMIT whose input isn't ordered by a unique key, the ties of such
+// LIMIT are broken arbitrarily, so it may return different rows in different executions. A WITH TIES LIMIT returns
+// all the ties, so it's always deterministic. The LIMIT of an EXISTS or IN subquery isn't warned either.
+func checkLimitDeterminism(p LogicalPlan, sc *variable.StatementContext) {
+	if limit, ok := p.(*Limit); ok && limit.Count > 0 && !limit.WithTies && !limit.inSubqueryPredicate &&
+		!isOrderedByKey(limit.children[0].(LogicalPlan)) {
+		sc.AppendWarning(ErrNondeterministicLimit)
+	}
+	for _, child := range p.Children() {
+		checkLimitDeterminism(child.(LogicalPlan), sc)
+	}
+}
+
+// markSubqueryPredicateLimit marks the top level LIMIT of the plan of an EXISTS or IN subquery, the rows it returns
+// are only tested against the outer row, see checkLimitDeterminism.
+func markSubqueryPredicateLimit(p LogicalPlan) {
+	for {
+		switch x := p.(type) {
+		case *Projection:
+			p = x.children[0].(LogicalPlan)
+		case *Limit:
+			x.inSubqueryPredicate = true
+			return
+		default:
+			return
+		}
+	}
+}
+
+// isOrderedByKey checks whether the rows of p are in a deterministic order, i.e. p returns at most one row, or p is a
+// sort whose order columns include a unique key.
+func isOrderedByKey(p LogicalPlan) bool {
+	if p.Schema().MaxOneRow {
+		return true
+	}
+	sort, ok := p.(*Sort)
+	if !ok {
+		return false
+	}
+	if sort.children[0].Schema().MaxOneRow {
+		return true
+	}
+	orderCols := make([]*expression.Column, 0, len(sort.ByItems))
+	for _, item := range sort.ByItems {
+		if col, ok := item.Expr.(*expression.Column); ok {
+			orderCols = append(orderCols, col)
+		}
+	}
+	orderSchema := expression.NewSchema(orderCols...)
+	for _, key := range sort.schema.Keys {
+		if orderSchema.ColumnsIndices(key) != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *LogicalAggregation) buildKeyInfo() {
 	p.baseLogicalPlan.buildKeyInfo()
 	for _, key := range p.Children()[0].Schema().Keys {
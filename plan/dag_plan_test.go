@@ -14,11 +14,17 @@
 package plan_test
 
 import (
+	"strings"
+
 	. "github.com/pingcap/check"
 	"github.com/pingcap/tidb"
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/infoschema"
+	"github.com/pingcap/tidb/model"
 	"github.com/pingcap/tidb/parser"
 	"github.com/pingcap/tidb/plan"
 	"github.com/pingcap/tidb/util/testleak"
+	"github.com/pingcap/tidb/util/types"
 )
 
 var _ = Suite(&testPlanSuite{})
@@ -86,6 +92,16 @@ func (s *testPlanSuite) TestDAGPlanBuilderSimpleCase(c *C) {
 			sql:  "select c from t where t.c = 1 and t.e = 1 order by t.d limit 1",
 			best: "IndexReader(Index(t.c_d_e)[[1,1]]->Sel([eq(test.t.e, 1)])->Limit)->Limit->Projection",
 		},
+		// Test TopN in mixed directions to Limit, the direction of the column fixed by equal condition doesn't matter.
+		{
+			sql:  "select c from t where t.c = 1 order by t.c, t.d desc limit 1",
+			best: "IndexReader(Index(t.c_d_e)[[1,1]]->Limit)->Limit->Projection",
+		},
+		// Test TopN in mixed directions can't be satisfied by the index.
+		{
+			sql:  "select c from t where t.c = 1 order by t.d, t.e desc limit 1",
+			best: "IndexReader(Index(t.c_d_e)[[1,1]]->TopN([test.t.d test.t.e true],0,1))->TopN([test.t.d test.t.e true],0,1)->Projection",
+		},
 		// Test TopN to Limit in table single read.
 		{
 			sql:  "select c from t order by t.a limit 1",
@@ -340,7 +356,7 @@ func (s *testPlanSuite) TestDAGPlanBuilderJoin(c *C) {
 	}
 }
 
-func (s *testPlanSuite) TestDAGPlanBuilderSubquery(c *C) {
+func (s *testPlanSuite) TestDAGPlanBuilderHintOverride(c *C) {
 	store, err := newStoreWithBootstrap()
 	c.Assert(err, IsNil)
 	defer store.Close()
@@ -350,50 +366,100 @@ func (s *testPlanSuite) TestDAGPlanBuilderSubquery(c *C) {
 	defer func() {
 		testleak.AfterTest(c)()
 	}()
+	smj := &ast.TableOptimizerHint{HintName: model.NewCIStr("tidb_smj"), Tables: []model.CIStr{model.NewCIStr("t1"), model.NewCIStr("t2")}}
+	inlj := &ast.TableOptimizerHint{HintName: model.NewCIStr("tidb_inlj"), Tables: []model.CIStr{model.NewCIStr("t1"), model.NewCIStr("t2")}}
 	tests := []struct {
-		sql  string
-		best string
+		sql   string
+		hints []*ast.TableOptimizerHint
+		best  string
 	}{
-		// Test join key with cast.
 		{
-			sql:  "select * from t where exists (select s.a from t s having sum(s.a) = t.a )",
-			best: "SemiJoin{TableReader(Table(t))->Projection->TableReader(Table(t)->HashAgg)->HashAgg}(cast(test.t.a),sel_agg_1)->Projection",
+			sql:   "select /*+ TIDB_INLJ(t1, t2) */ * from t t1, t t2 where t1.a = t2.c",
+			hints: nil,
+			best:  "MergeJoin{TableReader(Table(t))->IndexLookUp(Index(t.c_d_e)[[<nil>,+inf]], Table(t))}(t1.a,t2.c)",
 		},
 		{
-			sql:  "select * from t where exists (select s.a from t s having sum(s.a) = t.a ) order by t.a",
-			best: "SemiJoin{TableReader(Table(t))->Projection->TableReader(Table(t)->HashAgg)->HashAgg}(cast(test.t.a),sel_agg_1)->Projection",
+			sql:   "select * from t t1, t t2 where t1.a = t2.c",
+			hints: []*ast.TableOptimizerHint{inlj},
+			best:  "IndexJoin{TableReader(Table(t))->IndexLookUp(Index(t.c_d_e)[[<nil>,+inf]], Table(t))}(t1.a,t2.c)",
 		},
-		// FIXME: Report error by resolver.
-		//{
-		//	sql:  "select * from t where exists (select s.a from t s having s.a = t.a ) order by t.a",
-		//	best: "SemiJoin{TableReader(Table(t))->Projection->TableReader(Table(t)->HashAgg)->HashAgg}(cast(test.t.a),sel_agg_1)->Projection->Sort",
-		//},
 		{
-			sql:  "select * from t where a in (select s.a from t s) order by t.a",
-			best: "SemiJoin{TableReader(Table(t))->TableReader(Table(t))}(test.t.a,s.a)",
+			sql:   "select /*+ TIDB_SMJ(t1, t2) */ * from t t1, t t2 where t1.a = t2.c",
+			hints: []*ast.TableOptimizerHint{inlj},
+			best:  "IndexJoin{TableReader(Table(t))->IndexLookUp(Index(t.c_d_e)[[<nil>,+inf]], Table(t))}(t1.a,t2.c)",
 		},
-		// Test Nested sub query.
 		{
-			sql:  "select * from t where exists (select s.a from t s where s.c in (select c from t as k where k.d = s.d) having sum(s.a) = t.a )",
-			best: "SemiJoin{TableReader(Table(t))->Projection->SemiJoin{TableReader(Table(t))->TableReader(Table(t))}(s.d,k.d)(s.c,k.c)->HashAgg}(cast(test.t.a),sel_agg_1)->Projection",
+			sql:   "select t1.a from t t1, t t2 where t1.a = t2.c union all select t1.a from t t1, t t2 where t1.a = t2.c",
+			hints: []*ast.TableOptimizerHint{inlj},
+			best:  "UnionAll{IndexJoin{TableReader(Table(t))->IndexReader(Index(t.c_d_e)[[<nil>,+inf]])}(t1.a,t2.c)->Projection->IndexJoin{TableReader(Table(t))->IndexReader(Index(t.c_d_e)[[<nil>,+inf]])}(t1.a,t2.c)->Projection}",
 		},
-		// Test Semi Join + Order by.
 		{
-			sql:  "select * from t where a in (select a from t) order by b",
-			best: "SemiJoin{TableReader(Table(t))->TableReader(Table(t))}(test.t.a,test.t.a)->Sort",
+			// The hints of the nested query blocks are not overridden.
+			sql:   "select * from (select /*+ TIDB_INLJ(t1, t2) */ t1.a from t t1, t t2 where t1.a = t2.c) s",
+			hints: []*ast.TableOptimizerHint{smj},
+			best:  "IndexJoin{TableReader(Table(t))->IndexReader(Index(t.c_d_e)[[<nil>,+inf]])}(t1.a,t2.c)->Projection",
 		},
-		// Test Apply.
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := plan.MockResolve(stmt)
+		c.Assert(err, IsNil)
+		p, err := plan.OptimizeWithTableHints(se, stmt, is, tt.hints)
+		c.Assert(err, IsNil)
+		c.Assert(plan.ToString(p), Equals, tt.best, comment)
+	}
+}
+
+func (s *testPlanSuite) TestDAGPlanBuilderWithTableSchema(c *C) {
+	store, err := newStoreWithBootstrap()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	se, err := tidb.CreateSession(store)
+	c.Assert(err, IsNil)
+
+	defer func() {
+		testleak.AfterTest(c)()
+	}()
+	se.GetSessionVars().CurrentDB = "test"
+	// The hypothetical schema has a proposed index on t.b and a proposed table t2.
+	t := plan.MockTable()
+	t.Indices = append(t.Indices, &model.IndexInfo{
+		Name:    model.NewCIStr("b"),
+		Columns: []*model.IndexColumn{{Name: model.NewCIStr("b"), Length: types.UnspecifiedLength, Offset: 1}},
+		State:   model.StatePublic,
+	})
+	t2 := plan.MockTable()
+	t2.ID = 100
+	t2.Name = model.NewCIStr("t2")
+	is := infoschema.MockInfoSchema([]*model.TableInfo{plan.MockTable()})
+	tableIS := infoschema.MockInfoSchema([]*model.TableInfo{t, t2})
+	tests := []struct {
+		sql          string
+		hypothetical bool
+		best         string
+	}{
 		{
-			sql:  "select t.c in (select count(*) from t s , t t1 where s.a = t.a and s.a = t1.a) from t",
-			best: "Apply{TableReader(Table(t))->MergeJoin{TableReader(Table(t))->Sel([eq(s.a, test.t.a)])->TableReader(Table(t))}(s.a,t1.a)->HashAgg}->Projection",
+			sql:  "select * from t where b = 1",
+			best: "TableReader(Table(t)->Sel([eq(test.t.b, 1)]))",
 		},
 		{
-			sql:  "select (select count(*) from t s , t t1 where s.a = t.a and s.a = t1.a) from t",
-			best: "Apply{TableReader(Table(t))->MergeJoin{TableReader(Table(t))->Sel([eq(s.a, test.t.a)])->TableReader(Table(t))}(s.a,t1.a)->HashAgg}->Projection",
+			sql:          "select * from t where b = 1",
+			hypothetical: true,
+			best:         "IndexLookUp(Index(t.b)[[1,1]], Table(t))",
 		},
 		{
-			sql:  "select (select count(*) from t s , t t1 where s.a = t.a and s.a = t1.a) from t order by t.a",
-			best: "Apply{TableReader(Table(t))->MergeJoin{TableReader(Table(t))->Sel([eq(s.a, test.t.a)])->TableReader(Table(t))}(s.a,t1.a)->HashAgg}->Projection",
+			sql:          "select * from t t1 use index(b) where b > 1",
+			hypothetical: true,
+			best:         "IndexLookUp(Index(t.b)[(1,+inf]], Table(t))",
+		},
+		// The hypothetical table has no statistics, the pseudo statistics are used.
+		{
+			sql:          "select * from t2 where c = 1",
+			hypothetical: true,
+			best:         "IndexLookUp(Index(t2.c_d_e)[[1,1]], Table(t2))",
 		},
 	}
 	for _, tt := range tests {
@@ -401,15 +467,21 @@ func (s *testPlanSuite) TestDAGPlanBuilderSubquery(c *C) {
 		stmt, err := s.ParseOneStmt(tt.sql, "", "")
 		c.Assert(err, IsNil, comment)
 
-		is, err := plan.MockResolve(stmt)
-		c.Assert(err, IsNil)
-		p, err := plan.Optimize(se, stmt, is)
-		c.Assert(err, IsNil)
-		c.Assert(plan.ToString(p), Equals, tt.best, Commentf("for %s", tt.sql))
+		var p plan.Plan
+		if tt.hypothetical {
+			// The names are resolved in the hypothetical schema too, so the hypothetical tables can be referred to.
+			c.Assert(plan.MockResolveName(stmt, tableIS, "test", se), IsNil, comment)
+			p, err = plan.OptimizeWithTableSchema(se, stmt, is, tableIS)
+		} else {
+			c.Assert(plan.MockResolveName(stmt, is, "test", se), IsNil, comment)
+			p, err = plan.Optimize(se, stmt, is)
+		}
+		c.Assert(err, IsNil, comment)
+		c.Assert(plan.ToString(p), Equals, tt.best, comment)
 	}
 }
 
-func (s *testPlanSuite) TestDAGPlanTopN(c *C) {
+func (s *testPlanSuite) TestDAGPlanBuilderIndexOnly(c *C) {
 	store, err := newStoreWithBootstrap()
 	c.Assert(err, IsNil)
 	defer store.Close()
@@ -422,18 +494,36 @@ func (s *testPlanSuite) TestDAGPlanTopN(c *C) {
 	tests := []struct {
 		sql  string
 		best string
+		err  bool
 	}{
 		{
-			sql:  "select * from t t1 left join t t2 on t1.b = t2.b left join t t3 on t2.b = t3.b order by t1.a limit 1",
-			best: "LeftHashJoin{LeftHashJoin{TableReader(Table(t)->Limit)->TableReader(Table(t))}(t1.b,t2.b)->TableReader(Table(t))}(t2.b,t3.b)->TopN([t1.a],0,1)",
+			sql:  "select c from t",
+			best: "TableReader(Table(t))",
 		},
 		{
-			sql:  "select * from t t1 left join t t2 on t1.b = t2.b left join t t3 on t2.b = t3.b order by t1.b limit 1",
-			best: "LeftHashJoin{LeftHashJoin{TableReader(Table(t)->TopN([t1.b],0,1))->TableReader(Table(t))}(t1.b,t2.b)->TableReader(Table(t))}(t2.b,t3.b)->TopN([t1.b],0,1)",
+			sql:  "select /*+ USE_INDEX_ONLY(t) */ c from t",
+			best: "IndexReader(Index(t.c_d_e)[[<nil>,+inf]])",
 		},
 		{
-			sql:  "select * from t t1 left join t t2 on t1.b = t2.b left join t t3 on t2.b = t3.b limit 1",
-			best: "LeftHashJoin{LeftHashJoin{TableReader(Table(t)->Limit)->TableReader(Table(t))}(t1.b,t2.b)->TableReader(Table(t))}(t2.b,t3.b)->Limit",
+			sql:  "select /*+ USE_INDEX_ONLY(t) */ c, e from t where d = 1",
+			best: "IndexReader(Index(t.c_d_e)[[<nil>,+inf]]->Sel([eq(test.t.d, 1)]))->Projection",
+		},
+		{
+			sql:  "select /*+ USE_INDEX_ONLY(t1) */ t1.c from t t1, t t2 where t1.c = t2.c",
+			best: "MergeJoin{IndexReader(Index(t.c_d_e)[[<nil>,+inf]])->IndexReader(Index(t.c_d_e)[[<nil>,+inf]])}(t1.c,t2.c)->Projection",
+		},
+		{
+			sql: "select /*+ USE_INDEX_ONLY(t) */ b from t where c = 1",
+			err: true,
+		},
+		{
+			sql:  "select /*+ TIDB_INLJ(t1) USE_INDEX_ONLY(t2) */ t1.a, t2.g from t t1, t t2 where t1.a = t2.f",
+			best: "IndexJoin{TableReader(Table(t))->IndexReader(Index(t.f_g)[[<nil>,+inf]])}(t1.a,t2.f)->Projection",
+		},
+		{
+			// The inner table can't be read by the handle.
+			sql:  "select /*+ TIDB_INLJ(t1) USE_INDEX_ONLY(t2) */ t1.b, t2.c from t t1, t t2 where t1.b = t2.a",
+			best: "LeftHashJoin{TableReader(Table(t))->IndexReader(Index(t.c_d_e)[[<nil>,+inf]])}(t1.b,t2.a)->Projection",
 		},
 	}
 	for _, tt := range tests {
@@ -444,12 +534,16 @@ func (s *testPlanSuite) TestDAGPlanTopN(c *C) {
 		is, err := plan.MockResolve(stmt)
 		c.Assert(err, IsNil)
 		p, err := plan.Optimize(se, stmt, is)
-		c.Assert(err, IsNil)
-		c.Assert(plan.ToString(p), Equals, tt.best, Commentf("for %s", tt.sql))
+		if tt.err {
+			c.Assert(plan.ErrNoCoveringIndex.Equal(err), IsTrue, comment)
+			continue
+		}
+		c.Assert(err, IsNil, comment)
+		c.Assert(plan.ToString(p), Equals, tt.best, comment)
 	}
 }
 
-func (s *testPlanSuite) TestDAGPlanBuilderBasePhysicalPlan(c *C) {
+func (s *testPlanSuite) TestDAGPlanBuilderCardinalityHints(c *C) {
 	store, err := newStoreWithBootstrap()
 	c.Assert(err, IsNil)
 	defer store.Close()
@@ -460,59 +554,107 @@ func (s *testPlanSuite) TestDAGPlanBuilderBasePhysicalPlan(c *C) {
 		testleak.AfterTest(c)()
 	}()
 	tests := []struct {
-		sql  string
-		best string
+		sql      string
+		best     string
+		warnings int
 	}{
-		// Test for update.
 		{
-			sql: "select * from t order by b limit 1 for update",
-			// TODO: This is not reasonable. Mysql do like this because the limit of InnoDB, should TiDB keep consistency with MySQL?
-			best: "TableReader(Table(t))->Lock->TopN([test.t.b],0,1)",
+			sql:  "select * from t t1, t t2 where t1.c = t2.c",
+			best: "LeftHashJoin{TableReader(Table(t))->TableReader(Table(t))}(t1.c,t2.c)",
 		},
-		// Test complex update.
 		{
-			sql:  "update t set a = 5 where b < 1 order by d limit 1",
-			best: "TableReader(Table(t)->Sel([lt(test.t.b, 1)])->TopN([test.t.d],0,1))->TopN([test.t.d],0,1)->*plan.Update",
+			sql:  "select /*+ TIDB_CARD(t1 10) */ * from t t1, t t2 where t1.c = t2.c",
+			best: "IndexJoin{TableReader(Table(t))->IndexLookUp(Index(t.c_d_e)[[<nil>,+inf]], Table(t))}(t1.c,t2.c)",
 		},
-		// Test simple update.
 		{
-			sql:  "update t set a = 5",
-			best: "TableReader(Table(t))->*plan.Update",
+			sql:  "select /*+ TIDB_CARD(t2 10) */ * from t t1, t t2 where t1.c = t2.c",
+			best: "IndexJoin{TableReader(Table(t))->IndexLookUp(Index(t.c_d_e)[[<nil>,+inf]], Table(t))}(t2.c,t1.c)->Projection",
 		},
-		// TODO: Test delete/update with join.
-		// Test complex delete.
 		{
-			sql:  "delete from t where b < 1 order by d limit 1",
-			best: "TableReader(Table(t)->Sel([lt(test.t.b, 1)])->TopN([test.t.d],0,1))->TopN([test.t.d],0,1)->*plan.Delete",
+			sql:  "select /*+ TIDB_CARD(@sel_2 t1 10) */ * from t where t.c in (select t1.c from t t1, t t2 where t1.c = t2.c)",
+			best: "SemiJoin{TableReader(Table(t))->IndexJoin{TableReader(Table(t))->IndexReader(Index(t.c_d_e)[[<nil>,+inf]])}(t1.c,t2.c)}(test.t.c,t1.c)",
 		},
-		// Test simple delete.
 		{
-			sql:  "delete from t",
-			best: "TableReader(Table(t))->*plan.Delete",
+			sql:  "select * from (select c from t t1 group by c) x, t t2 where x.c = t2.c",
+			best: "RightHashJoin{TableReader(Table(t)->HashAgg)->HashAgg->TableReader(Table(t))}(t1.c,t2.c)",
 		},
-		// Test complex insert.
 		{
-			sql:  "insert into t select * from t where b < 1 order by d limit 1",
-			best: "TableReader(Table(t)->Sel([lt(test.t.b, 1)])->TopN([test.t.d],0,1))->TopN([test.t.d],0,1)->*plan.Insert",
+			// The NDV of t1.c is the row count of the aggregation, the few groups are joined by the index of t2.
+			sql:  "select /*+ TIDB_CARD(t1.c 1) */ * from (select c from t t1 group by c) x, t t2 where x.c = t2.c",
+			best: "IndexJoin{TableReader(Table(t)->HashAgg)->HashAgg->IndexLookUp(Index(t.c_d_e)[[<nil>,+inf]], Table(t))}(t1.c,t2.c)",
 		},
-		// Test simple insert.
 		{
-			sql:  "insert into t values(0,0,0,0,0,0,0)",
-			best: "*plan.Insert",
+			sql:      "select /*+ TIDB_CARD(t1 0) */ * from t t1, t t2 where t1.c = t2.c",
+			best:     "LeftHashJoin{TableReader(Table(t))->TableReader(Table(t))}(t1.c,t2.c)",
+			warnings: 1,
 		},
-		// Test dual.
 		{
-			sql:  "select 1",
-			best: "Dual->Projection",
+			sql:      "select /*+ TIDB_CARD(t1.x 10) */ * from t t1, t t2 where t1.c = t2.c",
+			best:     "LeftHashJoin{TableReader(Table(t))->TableReader(Table(t))}(t1.c,t2.c)",
+			warnings: 1,
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := plan.MockResolve(stmt)
+		c.Assert(err, IsNil)
+		sc := se.GetSessionVars().StmtCtx
+		sc.SetWarnings(nil)
+		p, err := plan.Optimize(se, stmt, is)
+		c.Assert(err, IsNil)
+		c.Assert(plan.ToString(p), Equals, tt.best, comment)
+		c.Assert(sc.GetWarnings(), HasLen, tt.warnings, comment)
+	}
+}
+
+func (s *testPlanSuite) TestDAGPlanBuilderQueryBlockHints(c *C) {
+	store, err := newStoreWithBootstrap()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	se, err := tidb.CreateSession(store)
+	c.Assert(err, IsNil)
+
+	defer func() {
+		testleak.AfterTest(c)()
+	}()
+	tests := []struct {
+		sql      string
+		best     string
+		warnings int
+	}{
+		{
+			sql:  "select * from t where t.c in (select t1.a from t t1, t t2 where t1.a = t2.a)",
+			best: "SemiJoin{TableReader(Table(t))->MergeJoin{TableReader(Table(t))->TableReader(Table(t))}(t1.a,t2.a)}(test.t.c,t1.a)",
 		},
 		{
-			sql:  "select * from t where false",
-			best: "Dual",
+			// The query block without hints inherits the hints of its outer block.
+			sql:  "select /*+ TIDB_INLJ(t1) */ * from t where t.c in (select t1.a from t t1, t t2 where t1.a = t2.a)",
+			best: "SemiJoin{TableReader(Table(t))->IndexJoin{TableReader(Table(t))->TableReader(Table(t))}(t1.a,t2.a)}(test.t.c,t1.a)",
 		},
-		// Test show.
 		{
-			sql:  "show tables",
-			best: "*plan.Show",
+			// The hints of the query block override the ones of its outer block.
+			sql:  "select /*+ TIDB_INLJ(t1) */ * from t where t.c in (select /*+ TIDB_SMJ(t2) */ t1.a from t t1, t t2 where t1.a = t2.a)",
+			best: "SemiJoin{TableReader(Table(t))->MergeJoin{TableReader(Table(t))->TableReader(Table(t))}(t1.a,t2.a)}(test.t.c,t1.a)",
+		},
+		{
+			sql:  "select * from t where t.c in (select /*+ TIDB_INLJ(t1) */ t1.a from t t1, t t2 where t1.a = t2.a)",
+			best: "SemiJoin{TableReader(Table(t))->IndexJoin{TableReader(Table(t))->TableReader(Table(t))}(t1.a,t2.a)}(test.t.c,t1.a)",
+		},
+		{
+			sql:  "select /*+ TIDB_INLJ(@sel_2 t1) */ * from t where t.c in (select t1.a from t t1, t t2 where t1.a = t2.a)",
+			best: "SemiJoin{TableReader(Table(t))->IndexJoin{TableReader(Table(t))->TableReader(Table(t))}(t1.a,t2.a)}(test.t.c,t1.a)",
+		},
+		{
+			sql:  "select /*+ TIDB_INLJ(@qb t1) */ * from t where t.c in (select /*+ QB_NAME(qb) */ t1.a from t t1, t t2 where t1.a = t2.a)",
+			best: "SemiJoin{TableReader(Table(t))->IndexJoin{TableReader(Table(t))->TableReader(Table(t))}(t1.a,t2.a)}(test.t.c,t1.a)",
+		},
+		{
+			sql:      "select /*+ TIDB_INLJ(@qb t1) */ * from t where t.c in (select t1.a from t t1, t t2 where t1.a = t2.a)",
+			best:     "SemiJoin{TableReader(Table(t))->MergeJoin{TableReader(Table(t))->TableReader(Table(t))}(t1.a,t2.a)}(test.t.c,t1.a)",
+			warnings: 1,
 		},
 	}
 	for _, tt := range tests {
@@ -522,13 +664,16 @@ func (s *testPlanSuite) TestDAGPlanBuilderBasePhysicalPlan(c *C) {
 
 		is, err := plan.MockResolve(stmt)
 		c.Assert(err, IsNil)
+		sc := se.GetSessionVars().StmtCtx
+		sc.SetWarnings(nil)
 		p, err := plan.Optimize(se, stmt, is)
 		c.Assert(err, IsNil)
-		c.Assert(plan.ToString(p), Equals, tt.best, Commentf("for %s", tt.sql))
+		c.Assert(plan.ToString(p), Equals, tt.best, comment)
+		c.Assert(sc.GetWarnings(), HasLen, tt.warnings, comment)
 	}
 }
 
-func (s *testPlanSuite) TestDAGPlanBuilderUnion(c *C) {
+func (s *testPlanSuite) TestDAGPlanBuilderScopedIndexHints(c *C) {
 	store, err := newStoreWithBootstrap()
 	c.Assert(err, IsNil)
 	defer store.Close()
@@ -539,28 +684,67 @@ func (s *testPlanSuite) TestDAGPlanBuilderUnion(c *C) {
 		testleak.AfterTest(c)()
 	}()
 	tests := []struct {
-		sql  string
-		best string
+		sql      string
+		best     string
+		warnings int
 	}{
-		// Test simple union.
 		{
-			sql:  "select * from t union all select * from t",
-			best: "UnionAll{TableReader(Table(t))->TableReader(Table(t))}",
+			sql:  "select c, count(*) from t group by c",
+			best: "TableReader(Table(t)->HashAgg)->HashAgg->Projection",
 		},
-		// Test Order by + Union.
 		{
-			sql:  "select * from t union all (select * from t) order by a ",
-			best: "UnionAll{TableReader(Table(t))->TableReader(Table(t))}->Sort",
+			sql:  "select c, count(*) from t force index for group by (c_d_e) group by c",
+			best: "IndexReader(Index(t.c_d_e)[[<nil>,+inf]])->StreamAgg->Projection",
 		},
-		// Test Limit + Union.
 		{
-			sql:  "select * from t union all (select * from t) limit 1",
-			best: "UnionAll{TableReader(Table(t)->Limit)->TableReader(Table(t)->Limit)}->Limit",
+			sql:  "select c, d, count(*) from t force index for group by (c_d_e) group by d, c",
+			best: "IndexReader(Index(t.c_d_e)[[<nil>,+inf]])->StreamAgg->Projection",
 		},
-		// Test TopN + Union.
 		{
-			sql:  "select a from t union all (select c from t) order by a limit 1",
-			best: "UnionAll{TableReader(Table(t)->Limit)->IndexReader(Index(t.c_d_e)[[<nil>,+inf]]->Limit)}->TopN([a],0,1)",
+			sql:  "select c, count(*) from t force index for group by (c_d_e) where b > 1 group by c",
+			best: "IndexLookUp(Index(t.c_d_e)[[<nil>,+inf]], Table(t)->Sel([gt(test.t.b, 1)]))->StreamAgg->Projection",
+		},
+		{
+			sql:      "select d, count(*) from t force index for group by (c_d_e) group by d",
+			best:     "TableReader(Table(t)->HashAgg)->HashAgg->Projection",
+			warnings: 1,
+		},
+		{
+			sql:      "select t1.c, count(*) from t t1 force index for group by (c_d_e), t t2 where t1.c = t2.c group by t1.c",
+			best:     "LeftHashJoin{TableReader(Table(t))->TableReader(Table(t)->HashAgg)->HashAgg}(t1.c,t2.c)->HashAgg->Projection",
+			warnings: 1,
+		},
+		{
+			sql:  "select c, count(*) from t ignore index for group by (c_d_e) group by c",
+			best: "TableReader(Table(t)->HashAgg)->HashAgg->Projection",
+		},
+		{
+			sql:  "select c from t order by c limit 1",
+			best: "IndexReader(Index(t.c_d_e)[[<nil>,+inf]]->Limit)->Limit",
+		},
+		{
+			sql:  "select c from t ignore index for order by (c_d_e) order by c limit 1",
+			best: "TableReader(Table(t)->TopN([test.t.c],0,1))->TopN([test.t.c],0,1)",
+		},
+		{
+			sql:  "select c from t ignore index for order by (c_d_e) where c > 1 order by c limit 1",
+			best: "IndexReader(Index(t.c_d_e)[(1,+inf]]->TopN([test.t.c],0,1))->TopN([test.t.c],0,1)",
+		},
+		{
+			sql:  "select c from t ignore index for group by (c_d_e) where c > 1 order by c limit 1",
+			best: "IndexReader(Index(t.c_d_e)[(1,+inf]]->Limit)->Limit",
+		},
+		{
+			sql:  "select * from t ignore index for join (c_d_e) where c = 1",
+			best: "TableReader(Table(t)->Sel([eq(test.t.c, 1)]))",
+		},
+		{
+			sql:  "select /*+ TIDB_INLJ(t1) */ * from t t1, t t2 ignore index for order by (c_d_e) where t1.c = t2.c",
+			best: "IndexJoin{TableReader(Table(t))->IndexLookUp(Index(t.c_d_e)[[<nil>,+inf]], Table(t))}(t1.c,t2.c)",
+		},
+		{
+			sql:  "select /*+ TIDB_INLJ(t1) */ * from t t1, t t2 ignore index for join (c_d_e) where t1.c = t2.c",
+			best: "LeftHashJoin{TableReader(Table(t))->TableReader(Table(t))}(t1.c,t2.c)",
 		},
 	}
 	for _, tt := range tests {
@@ -570,13 +754,19 @@ func (s *testPlanSuite) TestDAGPlanBuilderUnion(c *C) {
 
 		is, err := plan.MockResolve(stmt)
 		c.Assert(err, IsNil)
+		sc := se.GetSessionVars().StmtCtx
+		sc.SetWarnings(nil)
 		p, err := plan.Optimize(se, stmt, is)
 		c.Assert(err, IsNil)
-		c.Assert(plan.ToString(p), Equals, tt.best, Commentf("for %s", tt.sql))
+		c.Assert(plan.ToString(p), Equals, tt.best, comment)
+		c.Assert(sc.GetWarnings(), HasLen, tt.warnings, comment)
+		for _, warn := range sc.GetWarnings() {
+			c.Assert(plan.ErrGroupByIndexHint.Equal(warn), IsTrue, comment)
+		}
 	}
 }
 
-func (s *testPlanSuite) TestDAGPlanBuilderUnionScan(c *C) {
+func (s *testPlanSuite) TestDAGPlanBuilderConflictingIndexHints(c *C) {
 	store, err := newStoreWithBootstrap()
 	c.Assert(err, IsNil)
 	defer store.Close()
@@ -587,41 +777,54 @@ func (s *testPlanSuite) TestDAGPlanBuilderUnionScan(c *C) {
 		testleak.AfterTest(c)()
 	}()
 	tests := []struct {
-		sql  string
-		best string
+		sql      string
+		best     string
+		warnings int
 	}{
-		// Read table.
 		{
-			sql:  "select * from t",
-			best: "TableReader(Table(t))->UnionScan([])",
+			sql:      "select * from t use index(c_d_e) ignore index(c_d_e) where c = 1",
+			best:     "TableReader(Table(t)->Sel([eq(test.t.c, 1)]))",
+			warnings: 1,
 		},
 		{
-			sql:  "select * from t where b = 1",
-			best: "TableReader(Table(t)->Sel([eq(test.t.b, 1)]))->UnionScan([eq(test.t.b, 1)])",
+			sql:      "select * from t force index(c_d_e) ignore index(c_d_e) where c = 1",
+			best:     "TableReader(Table(t)->Sel([eq(test.t.c, 1)]))",
+			warnings: 1,
 		},
 		{
-			sql:  "select * from t where a = 1",
-			best: "TableReader(Table(t))->UnionScan([eq(test.t.a, 1)])",
+			sql:      "select * from t use index(c_d_e, f) ignore index(c_d_e) where c = 1 and f = 1",
+			best:     "IndexLookUp(Index(t.f)[[1,1]], Table(t)->Sel([eq(test.t.c, 1)]))",
+			warnings: 1,
 		},
 		{
-			sql:  "select * from t where a = 1 order by a",
-			best: "TableReader(Table(t))->UnionScan([eq(test.t.a, 1)])",
+			sql:      "select * from t force index(c_d_e) ignore index(C_D_E, f) use index(f) where c = 1",
+			best:     "TableReader(Table(t)->Sel([eq(test.t.c, 1)]))",
+			warnings: 2,
 		},
+		// The hints without a scope conflict with the hints of any scope.
 		{
-			sql:  "select * from t where a = 1 order by b",
-			best: "TableReader(Table(t))->UnionScan([eq(test.t.a, 1)])->Sort",
+			sql:      "select c from t force index for order by (c_d_e) ignore index (c_d_e) order by c limit 1",
+			best:     "TableReader(Table(t)->TopN([test.t.c],0,1))->TopN([test.t.c],0,1)",
+			warnings: 1,
 		},
 		{
-			sql:  "select * from t where a = 1 limit 1",
-			best: "TableReader(Table(t))->UnionScan([eq(test.t.a, 1)])->Limit",
+			sql:      "select c from t force index (c_d_e) ignore index for order by (c_d_e) order by c limit 1",
+			best:     "IndexReader(Index(t.c_d_e)[[<nil>,+inf]]->TopN([test.t.c],0,1))->TopN([test.t.c],0,1)",
+			warnings: 1,
 		},
 		{
-			sql:  "select * from t where c = 1",
-			best: "IndexLookUp(Index(t.c_d_e)[[1,1]], Table(t))->UnionScan([eq(test.t.c, 1)])",
+			sql:      "select c from t force index for order by (c_d_e) ignore index for order by (c_d_e) order by c limit 1",
+			best:     "TableReader(Table(t)->TopN([test.t.c],0,1))->TopN([test.t.c],0,1)",
+			warnings: 1,
 		},
+		// The hints of different scopes don't conflict.
 		{
-			sql:  "select c from t where c = 1",
-			best: "IndexReader(Index(t.c_d_e)[[1,1]])->UnionScan([eq(test.t.c, 1)])",
+			sql:  "select c from t force index for group by (c_d_e) ignore index for order by (c_d_e) order by c limit 1",
+			best: "TableReader(Table(t)->TopN([test.t.c],0,1))->TopN([test.t.c],0,1)",
+		},
+		{
+			sql:  "select * from t use index(c_d_e) ignore index(f) where c = 1",
+			best: "IndexLookUp(Index(t.c_d_e)[[1,1]], Table(t))",
 		},
 	}
 	for _, tt := range tests {
@@ -631,14 +834,822 @@ func (s *testPlanSuite) TestDAGPlanBuilderUnionScan(c *C) {
 
 		is, err := plan.MockResolve(stmt)
 		c.Assert(err, IsNil)
-
-		err = se.NewTxn()
-		c.Assert(err, IsNil)
-		// Make txn not read only.
-		se.Txn().Set(nil, nil)
+		sc := se.GetSessionVars().StmtCtx
+		sc.SetWarnings(nil)
 		p, err := plan.Optimize(se, stmt, is)
 		c.Assert(err, IsNil)
-		c.Assert(plan.ToString(p), Equals, tt.best, Commentf("for %s", tt.sql))
+		c.Assert(plan.ToString(p), Equals, tt.best, comment)
+		c.Assert(sc.GetWarnings(), HasLen, tt.warnings, comment)
+		for _, warn := range sc.GetWarnings() {
+			c.Assert(plan.ErrIndexHintConflict.Equal(warn), IsTrue, comment)
+		}
+	}
+}
+
+func (s *testPlanSuite) TestNondeterministicLimitWarning(c *C) {
+	store, err := newStoreWithBootstrap()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	se, err := tidb.CreateSession(store)
+	c.Assert(err, IsNil)
+
+	defer func() {
+		testleak.AfterTest(c)()
+	}()
+	se.GetSessionVars().WarnNondeterministicLimit = true
+	tests := []struct {
+		sql      string
+		warnings int
+	}{
+		{"select * from t limit 5", 1},
+		{"select * from t order by b limit 5", 1},
+		{"select * from t order by b, c limit 5", 1},
+		// e is a unique key but nullable.
+		{"select * from t order by e limit 5", 1},
+		{"select * from t order by a limit 5", 0},
+		{"select * from t order by b, f limit 5", 0},
+		{"select b from t order by f desc limit 5", 0},
+		{"select * from t order by b limit 0", 0},
+		{"select * from t order by b limit 5 with ties", 0},
+		{"select * from t where a = 1 limit 5", 0},
+		{"select count(*) from t limit 5", 0},
+		{"select b, count(*) from t group by b order by b limit 5", 0},
+		{"select distinct b, c from t order by b, c limit 5", 0},
+		{"select * from t order by b", 0},
+		// The LIMIT of an EXISTS or IN subquery isn't warned, but the LIMIT of a subquery in it is.
+		{"select * from t where exists (select 1 from t s where s.a < t.a order by c limit 1) order by a limit 5", 0},
+		{"select * from t where b in (select b from t s order by c limit 3) order by a limit 5", 0},
+		{"select * from t where exists (select 1 from t s where s.a < t.a and s.b > (select b from t u where u.c = s.c limit 1)) order by a", 1},
+		{"select * from t where b > (select b from t s where s.c = t.c order by d limit 1) order by a", 1},
+		{"select * from (select * from t limit 5) s order by a limit 1", 1},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := plan.MockResolve(stmt)
+		c.Assert(err, IsNil)
+		sc := se.GetSessionVars().StmtCtx
+		sc.SetWarnings(nil)
+		_, err = plan.Optimize(se, stmt, is)
+		c.Assert(err, IsNil, comment)
+		c.Assert(sc.GetWarnings(), HasLen, tt.warnings, comment)
+		for _, warn := range sc.GetWarnings() {
+			c.Assert(plan.ErrNondeterministicLimit.Equal(warn), IsTrue, comment)
+		}
+	}
+}
+
+func (s *testPlanSuite) TestDAGPlanBuilderStableSort(c *C) {
+	store, err := newStoreWithBootstrap()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	se, err := tidb.CreateSession(store)
+	c.Assert(err, IsNil)
+
+	defer func() {
+		testleak.AfterTest(c)()
+	}()
+	se.GetSessionVars().StableSort = true
+	tests := []struct {
+		sql      string
+		best     string
+		warnings int
+	}{
+		// The primary key a is the handle.
+		{
+			sql:  "select * from t order by b limit 2",
+			best: "TableReader(Table(t)->TopN([test.t.b test.t.a],0,2))->TopN([test.t.b test.t.a],0,2)",
+		},
+		{
+			sql:  "select c from t order by b desc limit 2",
+			best: "TableReader(Table(t)->TopN([test.t.b true test.t.a],0,2))->TopN([test.t.b true test.t.a],0,2)->Projection",
+		},
+		{
+			sql:  "select t1.c from t t1, t t2 where t1.b = t2.b order by t1.c limit 1",
+			best: "LeftHashJoin{TableReader(Table(t))->TableReader(Table(t))}(t1.b,t2.b)->TopN([t1.c t1.a t2.a],0,1)->Projection",
+		},
+		// The handle isn't appended if it's sorted by already.
+		{
+			sql:  "select * from t order by a limit 1",
+			best: "TableReader(Table(t)->Limit)->Limit",
+		},
+		{
+			sql:  "select * from t order by c, d, e limit 1",
+			best: "TableReader(Table(t)->TopN([test.t.c test.t.d test.t.e test.t.a],0,1))->TopN([test.t.c test.t.d test.t.e test.t.a],0,1)",
+		},
+		{
+			sql:  "select c from t where c > 1 order by c limit 1",
+			best: "IndexReader(Index(t.c_d_e)[(1,+inf]]->TopN([test.t.c test.t.a],0,1))->TopN([test.t.c test.t.a],0,1)->Projection",
+		},
+		{
+			sql:      "select b, count(*) from t group by b order by b limit 1",
+			best:     "TableReader(Table(t)->HashAgg)->HashAgg->TopN([test.t.b],0,1)->Projection",
+			warnings: 1,
+		},
+		{
+			sql:      "select distinct b from t order by b limit 1",
+			best:     "TableReader(Table(t)->HashAgg)->HashAgg->TopN([b],0,1)",
+			warnings: 1,
+		},
+		{
+			sql:      "select * from (select b, c from t) s order by b limit 1",
+			best:     "TableReader(Table(t)->TopN([test.t.b],0,1))->TopN([test.t.b],0,1)",
+			warnings: 1,
+		},
+		{
+			sql:      "(select b from t) union (select c from t) order by b limit 1",
+			best:     "UnionAll{TableReader(Table(t)->HashAgg)->HashAgg->TableReader(Table(t)->HashAgg)->HashAgg}->HashAgg->TopN([b],0,1)",
+			warnings: 1,
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := plan.MockResolve(stmt)
+		c.Assert(err, IsNil)
+		sc := se.GetSessionVars().StmtCtx
+		sc.SetWarnings(nil)
+		p, err := plan.Optimize(se, stmt, is)
+		c.Assert(err, IsNil, comment)
+		c.Assert(plan.ToString(p), Equals, tt.best, comment)
+		c.Assert(sc.GetWarnings(), HasLen, tt.warnings, comment)
+		for _, warn := range sc.GetWarnings() {
+			c.Assert(plan.ErrNoStableSortHandle.Equal(warn), IsTrue, comment)
+		}
+	}
+}
+
+func (s *testPlanSuite) TestDAGPlanBuilderWithTies(c *C) {
+	store, err := newStoreWithBootstrap()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	se, err := tidb.CreateSession(store)
+	c.Assert(err, IsNil)
+
+	defer func() {
+		testleak.AfterTest(c)()
+	}()
+	tests := []struct {
+		sql  string
+		best string
+		err  bool
+	}{
+		// The limit isn't pushed down, the rows tying with the last row are needed.
+		{
+			sql:  "select * from t order by b limit 2 with ties",
+			best: "TableReader(Table(t))->Sort->Limit",
+		},
+		{
+			sql:  "select * from t order by b limit 1, 2 with ties",
+			best: "TableReader(Table(t))->Sort->Limit",
+		},
+		{
+			sql:  "select * from t order by b fetch first 2 rows with ties",
+			best: "TableReader(Table(t))->Sort->Limit",
+		},
+		{
+			sql:  "select * from t order by b fetch first 2 rows only",
+			best: "TableReader(Table(t)->TopN([test.t.b],0,2))->TopN([test.t.b],0,2)",
+		},
+		{
+			sql:  "select * from t order by a limit 2 with ties",
+			best: "TableReader(Table(t))->Limit",
+		},
+		{
+			sql:  "select c from t order by c limit 2 with ties",
+			best: "IndexReader(Index(t.c_d_e)[[<nil>,+inf]])->Limit",
+		},
+		{
+			sql:  "select b from t order by c limit 2 with ties",
+			best: "IndexLookUp(Index(t.c_d_e)[[<nil>,+inf]], Table(t))->Limit->Projection",
+		},
+		{
+			sql:  "(select b from t) union (select c from t) order by b limit 2 with ties",
+			best: "UnionAll{TableReader(Table(t)->HashAgg)->HashAgg->TableReader(Table(t)->HashAgg)->HashAgg}->HashAgg->Sort->Limit",
+		},
+		{
+			sql:  "select * from (select * from t order by b limit 2 with ties) s order by c limit 1",
+			best: "TableReader(Table(t))->Sort->Limit->TopN([test.t.c],0,1)",
+		},
+		{
+			sql: "select * from t limit 2 with ties",
+			err: true,
+		},
+		{
+			sql: "select * from t where a in (select b from t limit 1 with ties)",
+			err: true,
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := plan.MockResolve(stmt)
+		c.Assert(err, IsNil)
+		p, err := plan.Optimize(se, stmt, is)
+		if tt.err {
+			c.Assert(plan.ErrWithTiesNoOrderBy.Equal(err), IsTrue, comment)
+			continue
+		}
+		c.Assert(err, IsNil, comment)
+		c.Assert(plan.ToString(p), Equals, tt.best, comment)
+	}
+}
+
+func (s *testPlanSuite) TestDAGPlanBuilderPropagateConstant(c *C) {
+	store, err := newStoreWithBootstrap()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	se, err := tidb.CreateSession(store)
+	c.Assert(err, IsNil)
+
+	defer func() {
+		testleak.AfterTest(c)()
+	}()
+	tests := []struct {
+		sql        string
+		best       string
+		noPropBest string
+	}{
+		{
+			sql:        "select * from t where c = 5 and d = c",
+			best:       "IndexLookUp(Index(t.c_d_e)[[5 5,5 5]], Table(t))",
+			noPropBest: "IndexLookUp(Index(t.c_d_e)[[5,5]]->Sel([eq(test.t.d, test.t.c)]), Table(t))",
+		},
+		{
+			sql:        "select * from t where b = 5 and c = b",
+			best:       "IndexLookUp(Index(t.c_d_e)[[5,5]], Table(t)->Sel([eq(test.t.b, 5)]))",
+			noPropBest: "TableReader(Table(t)->Sel([eq(test.t.b, 5) eq(test.t.c, test.t.b)]))",
+		},
+		{
+			sql:        "select * from t where b = 5 and c = b and c > 3",
+			best:       "IndexLookUp(Index(t.c_d_e)[[5 <nil>,5 +inf]], Table(t)->Sel([eq(test.t.b, 5)]))",
+			noPropBest: "IndexLookUp(Index(t.c_d_e)[(3 +inf,+inf +inf]], Table(t)->Sel([eq(test.t.b, 5) eq(test.t.c, test.t.b)]))",
+		},
+	}
+	defer func() {
+		se.GetSessionVars().AllowPropagateConstant = true
+	}()
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := plan.MockResolve(stmt)
+		c.Assert(err, IsNil)
+		se.GetSessionVars().AllowPropagateConstant = true
+		p, err := plan.Optimize(se, stmt, is)
+		c.Assert(err, IsNil)
+		c.Assert(plan.ToString(p), Equals, tt.best, comment)
+
+		se.GetSessionVars().AllowPropagateConstant = false
+		p, err = plan.Optimize(se, stmt, is)
+		c.Assert(err, IsNil)
+		c.Assert(plan.ToString(p), Equals, tt.noPropBest, comment)
+	}
+}
+
+func (s *testPlanSuite) TestDAGPlanBuilderQueryBlocks(c *C) {
+	store, err := newStoreWithBootstrap()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	se, err := tidb.CreateSession(store)
+	c.Assert(err, IsNil)
+
+	defer func() {
+		testleak.AfterTest(c)()
+	}()
+	tests := []struct {
+		sql    string
+		blocks string
+	}{
+		{
+			sql:    "select * from t",
+			blocks: "TableScan:sel_1,TableReader:sel_1",
+		},
+		{
+			sql:    "select a from t where b > (select max(b) from t s where s.c = t.c)",
+			blocks: "TableScan:sel_1,TableReader:sel_1,TableScan:sel_2,HashAgg:sel_2,TableReader:sel_2,HashAgg:sel_2,HashLeftJoin:sel_1,Projection:sel_1,Selection:sel_1,Projection:sel_1",
+		},
+		{
+			sql:    "select * from t where exists (select s.a from t s having sum(s.a) = t.a)",
+			blocks: "TableScan:sel_1,TableReader:sel_1,Projection:sel_1,TableScan:sel_2,HashAgg:sel_2,TableReader:sel_2,HashAgg:sel_2,HashSemiJoin:sel_1,Projection:sel_1",
+		},
+		{
+			sql:    "select k.a from (select a from t where b > 0 order by c limit 1) k, t where k.a = t.a",
+			blocks: "IndexScan:sel_2,TableScan:sel_2,Selection:sel_2,Limit:sel_2,IndexLookUp:sel_2,Limit:sel_2,TableScan:sel_1,TableReader:sel_1,IndexJoin:sel_1,Projection:sel_1",
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt("explain "+tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := plan.MockResolve(stmt)
+		c.Assert(err, IsNil)
+
+		p, err := plan.Optimize(se, stmt, is)
+		c.Assert(err, IsNil)
+		// The plans out of the top level block are rendered with their query block in the operator info.
+		explain := p.(*plan.Explain)
+		blocks := make([]string, 0, len(explain.Rows))
+		for _, row := range explain.Rows {
+			name := row[0].GetString()
+			name = name[:strings.LastIndex(name, "_")]
+			block := plan.QueryBlockName(1)
+			if info := row[4].GetString(); strings.Contains(info, "query block:") {
+				block = info[strings.LastIndex(info, "query block:")+len("query block:"):]
+			}
+			blocks = append(blocks, name+":"+block)
+		}
+		c.Assert(strings.Join(blocks, ","), Equals, tt.blocks, comment)
+	}
+}
+
+func (s *testPlanSuite) TestDAGPlanBuilderSubquery(c *C) {
+	store, err := newStoreWithBootstrap()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	se, err := tidb.CreateSession(store)
+	c.Assert(err, IsNil)
+
+	defer func() {
+		testleak.AfterTest(c)()
+	}()
+	tests := []struct {
+		sql  string
+		best string
+	}{
+		// Test join key with cast.
+		{
+			sql:  "select * from t where exists (select s.a from t s having sum(s.a) = t.a )",
+			best: "SemiJoin{TableReader(Table(t))->Projection->TableReader(Table(t)->HashAgg)->HashAgg}(cast(test.t.a),sel_agg_1)->Projection",
+		},
+		{
+			sql:  "select * from t where exists (select s.a from t s having sum(s.a) = t.a ) order by t.a",
+			best: "SemiJoin{TableReader(Table(t))->Projection->TableReader(Table(t)->HashAgg)->HashAgg}(cast(test.t.a),sel_agg_1)->Projection",
+		},
+		// FIXME: Report error by resolver.
+		//{
+		//	sql:  "select * from t where exists (select s.a from t s having s.a = t.a ) order by t.a",
+		//	best: "SemiJoin{TableReader(Table(t))->Projection->TableReader(Table(t)->HashAgg)->HashAgg}(cast(test.t.a),sel_agg_1)->Projection->Sort",
+		//},
+		{
+			sql:  "select * from t where a in (select s.a from t s) order by t.a",
+			best: "SemiJoin{TableReader(Table(t))->TableReader(Table(t))}(test.t.a,s.a)",
+		},
+		// Test Nested sub query.
+		{
+			sql:  "select * from t where exists (select s.a from t s where s.c in (select c from t as k where k.d = s.d) having sum(s.a) = t.a )",
+			best: "SemiJoin{TableReader(Table(t))->Projection->SemiJoin{TableReader(Table(t))->TableReader(Table(t))}(s.d,k.d)(s.c,k.c)->HashAgg}(cast(test.t.a),sel_agg_1)->Projection",
+		},
+		// Test Semi Join + Order by.
+		{
+			sql:  "select * from t where a in (select a from t) order by b",
+			best: "SemiJoin{TableReader(Table(t))->TableReader(Table(t))}(test.t.a,test.t.a)->Sort",
+		},
+		// Test Apply.
+		{
+			sql:  "select t.c in (select count(*) from t s , t t1 where s.a = t.a and s.a = t1.a) from t",
+			best: "Apply{TableReader(Table(t))->MergeJoin{TableReader(Table(t))->Sel([eq(s.a, test.t.a)])->TableReader(Table(t))}(s.a,t1.a)->HashAgg}->Projection",
+		},
+		{
+			sql:  "select (select count(*) from t s , t t1 where s.a = t.a and s.a = t1.a) from t",
+			best: "Apply{TableReader(Table(t))->MergeJoin{TableReader(Table(t))->Sel([eq(s.a, test.t.a)])->TableReader(Table(t))}(s.a,t1.a)->HashAgg}->Projection",
+		},
+		{
+			sql:  "select (select count(*) from t s , t t1 where s.a = t.a and s.a = t1.a) from t order by t.a",
+			best: "Apply{TableReader(Table(t))->MergeJoin{TableReader(Table(t))->Sel([eq(s.a, test.t.a)])->TableReader(Table(t))}(s.a,t1.a)->HashAgg}->Projection",
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := plan.MockResolve(stmt)
+		c.Assert(err, IsNil)
+		p, err := plan.Optimize(se, stmt, is)
+		c.Assert(err, IsNil)
+		c.Assert(plan.ToString(p), Equals, tt.best, Commentf("for %s", tt.sql))
+	}
+}
+
+func (s *testPlanSuite) TestDAGPlanBuilderSubqueryAlternatives(c *C) {
+	store, err := newStoreWithBootstrap()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	se, err := tidb.CreateSession(store)
+	c.Assert(err, IsNil)
+
+	defer func() {
+		testleak.AfterTest(c)()
+	}()
+	tests := []struct {
+		sql   string
+		apply []string
+		join  string
+	}{
+		{
+			sql:   "select * from t where exists (select s.a from t s where s.b = t.b)",
+			apply: []string{"Apply{TableReader(Table(t))->TableReader(Table(t))->Sel([eq(s.b, test.t.b)])}"},
+			join:  "SemiJoin{TableReader(Table(t))->TableReader(Table(t))}(test.t.b,s.b)",
+		},
+		// The uncorrelated subquery has only one form.
+		{
+			sql: "select * from t where a in (select s.a from t s)",
+		},
+		// The hinted subquery is always kept correlated.
+		{
+			sql: "select * from t where exists (select /*+ NO_DECORRELATE() */ s.a from t s where s.b = t.b)",
+		},
+		{
+			sql: "select * from t where exists (select s.a from t s where s.b = t.b) and t.c in (select k.c from t k where k.d = t.d)",
+			apply: []string{
+				"SemiJoin{Apply{TableReader(Table(t))->TableReader(Table(t))->Sel([eq(s.b, test.t.b)])}->TableReader(Table(t))}(test.t.d,k.d)(test.t.c,k.c)",
+				"Apply{SemiJoin{TableReader(Table(t))->TableReader(Table(t))}(test.t.b,s.b)->TableReader(Table(t))->Sel([eq(k.d, test.t.d)])}",
+			},
+			join: "SemiJoin{SemiJoin{TableReader(Table(t))->TableReader(Table(t))}(test.t.b,s.b)->TableReader(Table(t))}(test.t.d,k.d)(test.t.c,k.c)",
+		},
+		// The nested subquery is built before the outer one.
+		{
+			sql: "select * from t where exists (select s.a from t s where s.b = t.b and s.c in (select k.c from t k where k.d = s.d))",
+			apply: []string{
+				"SemiJoin{TableReader(Table(t))->Apply{TableReader(Table(t))->TableReader(Table(t))->Sel([eq(k.d, s.d)])}}(test.t.b,s.b)",
+				"Apply{TableReader(Table(t))->SemiJoin{TableReader(Table(t))->Sel([eq(s.b, test.t.b)])->TableReader(Table(t))}(s.d,k.d)(s.c,k.c)}",
+			},
+			join: "SemiJoin{TableReader(Table(t))->SemiJoin{TableReader(Table(t))->TableReader(Table(t))}(s.d,k.d)(s.c,k.c)}(test.t.b,s.b)",
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := plan.MockResolve(stmt)
+		c.Assert(err, IsNil)
+		alternatives, err := plan.OptimizeSubqueryAlternatives(se, stmt, is)
+		c.Assert(err, IsNil)
+		c.Assert(alternatives, HasLen, len(tt.apply), comment)
+		for i, alt := range alternatives {
+			c.Assert(plan.ToString(alt.Apply), Equals, tt.apply[i], comment)
+			c.Assert(plan.ToString(alt.Join), Equals, tt.join, comment)
+		}
+	}
+}
+
+func (s *testPlanSuite) TestDAGPlanTopN(c *C) {
+	store, err := newStoreWithBootstrap()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	se, err := tidb.CreateSession(store)
+	c.Assert(err, IsNil)
+
+	defer func() {
+		testleak.AfterTest(c)()
+	}()
+	tests := []struct {
+		sql  string
+		best string
+	}{
+		{
+			sql:  "select * from t t1 left join t t2 on t1.b = t2.b left join t t3 on t2.b = t3.b order by t1.a limit 1",
+			best: "LeftHashJoin{LeftHashJoin{TableReader(Table(t)->Limit)->TableReader(Table(t))}(t1.b,t2.b)->TableReader(Table(t))}(t2.b,t3.b)->TopN([t1.a],0,1)",
+		},
+		{
+			sql:  "select * from t t1 left join t t2 on t1.b = t2.b left join t t3 on t2.b = t3.b order by t1.b limit 1",
+			best: "LeftHashJoin{LeftHashJoin{TableReader(Table(t)->TopN([t1.b],0,1))->TableReader(Table(t))}(t1.b,t2.b)->TableReader(Table(t))}(t2.b,t3.b)->TopN([t1.b],0,1)",
+		},
+		{
+			sql:  "select * from t t1 left join t t2 on t1.b = t2.b left join t t3 on t2.b = t3.b limit 1",
+			best: "LeftHashJoin{LeftHashJoin{TableReader(Table(t)->Limit)->TableReader(Table(t))}(t1.b,t2.b)->TableReader(Table(t))}(t2.b,t3.b)->Limit",
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := plan.MockResolve(stmt)
+		c.Assert(err, IsNil)
+		p, err := plan.Optimize(se, stmt, is)
+		c.Assert(err, IsNil)
+		c.Assert(plan.ToString(p), Equals, tt.best, Commentf("for %s", tt.sql))
+	}
+}
+
+func (s *testPlanSuite) TestDAGPlanBuilderBasePhysicalPlan(c *C) {
+	store, err := newStoreWithBootstrap()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	se, err := tidb.CreateSession(store)
+	c.Assert(err, IsNil)
+
+	defer func() {
+		testleak.AfterTest(c)()
+	}()
+	tests := []struct {
+		sql  string
+		best string
+	}{
+		// Test for update.
+		{
+			sql: "select * from t order by b limit 1 for update",
+			// TODO: This is not reasonable. Mysql do like this because the limit of InnoDB, should TiDB keep consistency with MySQL?
+			best: "TableReader(Table(t))->Lock->TopN([test.t.b],0,1)",
+		},
+		// Test complex update.
+		{
+			sql:  "update t set a = 5 where b < 1 order by d limit 1",
+			best: "TableReader(Table(t)->Sel([lt(test.t.b, 1)])->TopN([test.t.d],0,1))->TopN([test.t.d],0,1)->*plan.Update",
+		},
+		// Test simple update.
+		{
+			sql:  "update t set a = 5",
+			best: "TableReader(Table(t))->*plan.Update",
+		},
+		// TODO: Test delete/update with join.
+		// Test complex delete.
+		{
+			sql:  "delete from t where b < 1 order by d limit 1",
+			best: "TableReader(Table(t)->Sel([lt(test.t.b, 1)])->TopN([test.t.d],0,1))->TopN([test.t.d],0,1)->*plan.Delete",
+		},
+		// Test simple delete.
+		{
+			sql:  "delete from t",
+			best: "TableReader(Table(t))->*plan.Delete",
+		},
+		// Test complex insert.
+		{
+			sql:  "insert into t select * from t where b < 1 order by d limit 1",
+			best: "TableReader(Table(t)->Sel([lt(test.t.b, 1)])->TopN([test.t.d],0,1))->TopN([test.t.d],0,1)->*plan.Insert",
+		},
+		// Test simple insert.
+		{
+			sql:  "insert into t values(0,0,0,0,0,0,0)",
+			best: "*plan.Insert",
+		},
+		// Test dual.
+		{
+			sql:  "select 1",
+			best: "Dual->Projection",
+		},
+		{
+			sql:  "select * from t where false",
+			best: "Dual",
+		},
+		// Test show.
+		{
+			sql:  "show tables",
+			best: "*plan.Show",
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := plan.MockResolve(stmt)
+		c.Assert(err, IsNil)
+		p, err := plan.Optimize(se, stmt, is)
+		c.Assert(err, IsNil)
+		c.Assert(plan.ToString(p), Equals, tt.best, Commentf("for %s", tt.sql))
+	}
+}
+
+func (s *testPlanSuite) TestDAGPlanBuilderUnion(c *C) {
+	store, err := newStoreWithBootstrap()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	se, err := tidb.CreateSession(store)
+	c.Assert(err, IsNil)
+
+	defer func() {
+		testleak.AfterTest(c)()
+	}()
+	tests := []struct {
+		sql  string
+		best string
+	}{
+		// Test simple union.
+		{
+			sql:  "select * from t union all select * from t",
+			best: "UnionAll{TableReader(Table(t))->TableReader(Table(t))}",
+		},
+		// Test Order by + Union.
+		{
+			sql:  "select * from t union all (select * from t) order by a ",
+			best: "UnionAll{TableReader(Table(t))->TableReader(Table(t))}->Sort",
+		},
+		// Test Limit + Union.
+		{
+			sql:  "select * from t union all (select * from t) limit 1",
+			best: "UnionAll{TableReader(Table(t)->Limit)->TableReader(Table(t)->Limit)}->Limit",
+		},
+		// Test TopN + Union.
+		{
+			sql:  "select a from t union all (select c from t) order by a limit 1",
+			best: "UnionAll{TableReader(Table(t)->Limit)->IndexReader(Index(t.c_d_e)[[<nil>,+inf]]->Limit)}->TopN([a],0,1)",
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := plan.MockResolve(stmt)
+		c.Assert(err, IsNil)
+		p, err := plan.Optimize(se, stmt, is)
+		c.Assert(err, IsNil)
+		c.Assert(plan.ToString(p), Equals, tt.best, Commentf("for %s", tt.sql))
+	}
+}
+
+func (s *testPlanSuite) TestDAGPlanBuilderUnionScan(c *C) {
+	store, err := newStoreWithBootstrap()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	se, err := tidb.CreateSession(store)
+	c.Assert(err, IsNil)
+
+	defer func() {
+		testleak.AfterTest(c)()
+	}()
+	tests := []struct {
+		sql  string
+		best string
+	}{
+		// Read table.
+		{
+			sql:  "select * from t",
+			best: "TableReader(Table(t))->UnionScan([])",
+		},
+		{
+			sql:  "select * from t where b = 1",
+			best: "TableReader(Table(t)->Sel([eq(test.t.b, 1)]))->UnionScan([eq(test.t.b, 1)])",
+		},
+		{
+			sql:  "select * from t where a = 1",
+			best: "TableReader(Table(t))->UnionScan([eq(test.t.a, 1)])",
+		},
+		{
+			sql:  "select * from t where a = 1 order by a",
+			best: "TableReader(Table(t))->UnionScan([eq(test.t.a, 1)])",
+		},
+		{
+			sql:  "select * from t where a = 1 order by b",
+			best: "TableReader(Table(t))->UnionScan([eq(test.t.a, 1)])->Sort",
+		},
+		{
+			sql:  "select * from t where a = 1 limit 1",
+			best: "TableReader(Table(t))->UnionScan([eq(test.t.a, 1)])->Limit",
+		},
+		{
+			sql:  "select * from t where c = 1",
+			best: "IndexLookUp(Index(t.c_d_e)[[1,1]], Table(t))->UnionScan([eq(test.t.c, 1)])",
+		},
+		{
+			sql:  "select c from t where c = 1",
+			best: "IndexReader(Index(t.c_d_e)[[1,1]])->UnionScan([eq(test.t.c, 1)])",
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := plan.MockResolve(stmt)
+		c.Assert(err, IsNil)
+
+		err = se.NewTxn()
+		c.Assert(err, IsNil)
+		// Make txn not read only.
+		se.Txn().Set(nil, nil)
+		p, err := plan.Optimize(se, stmt, is)
+		c.Assert(err, IsNil)
+		c.Assert(plan.ToString(p), Equals, tt.best, Commentf("for %s", tt.sql))
+	}
+}
+
+func (s *testPlanSuite) TestDAGPlanBuilderSkipRowHandle(c *C) {
+	store, err := newStoreWithBootstrap()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	se, err := tidb.CreateSession(store)
+	c.Assert(err, IsNil)
+
+	defer func() {
+		testleak.AfterTest(c)()
+	}()
+	se.GetSessionVars().AllowSkipRowHandle = true
+	tests := []struct {
+		sql      string
+		modified bool
+		best     string
+	}{
+		{
+			sql:  "select * from t",
+			best: "TableReader(Table(t))",
+		},
+		{
+			sql:  "select c from t where c = 1",
+			best: "IndexReader(Index(t.c_d_e)[[1,1]])",
+		},
+		{
+			sql:      "select * from t",
+			modified: true,
+			best:     "TableReader(Table(t))->UnionScan([])",
+		},
+		{
+			sql:  "select * from t for update",
+			best: "TableReader(Table(t))->UnionScan([])->Lock",
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := plan.MockResolve(stmt)
+		c.Assert(err, IsNil)
+
+		err = se.NewTxn()
+		c.Assert(err, IsNil)
+		// Make txn not read only.
+		se.Txn().Set(nil, nil)
+		if tt.modified {
+			tbl, err := is.TableByName(model.NewCIStr("test"), model.NewCIStr("t"))
+			c.Assert(err, IsNil)
+			se.GetSessionVars().TxnCtx.UpdateDeltaForTable(tbl.Meta().ID, 1, 1)
+		}
+		p, err := plan.Optimize(se, stmt, is)
+		c.Assert(err, IsNil)
+		c.Assert(plan.ToString(p), Equals, tt.best, comment)
+	}
+}
+
+func (s *testPlanSuite) TestDAGPlanBuilderColumnAccess(c *C) {
+	store, err := newStoreWithBootstrap()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	se, err := tidb.CreateSession(store)
+	c.Assert(err, IsNil)
+
+	defer func() {
+		testleak.AfterTest(c)()
+	}()
+	tests := []struct {
+		sql  string
+		cols []string
+	}{
+		{
+			sql:  "select a, b from t",
+			cols: []string{"a", "b"},
+		},
+		{
+			sql:  "select a from (select a, b, c from t) x where x.c > 1",
+			cols: []string{"a", "c"},
+		},
+		{
+			sql:  "select t1.a from t t1 join t t2 on t1.b = t2.c where t2.d > 0",
+			cols: []string{"a", "b", "c", "d"},
+		},
+		{
+			sql:  "select count(*) from t group by e order by sum(f)",
+			cols: []string{"e", "f"},
+		},
+		{
+			sql:  "select a from t where b in (select c from t where d = 1)",
+			cols: []string{"a", "b", "c", "d"},
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := plan.MockResolve(stmt)
+		c.Assert(err, IsNil)
+		tbl, err := is.TableByName(model.NewCIStr("test"), model.NewCIStr("t"))
+		c.Assert(err, IsNil)
+
+		_, access, err := plan.OptimizeWithColumnAccess(se, stmt, is)
+		c.Assert(err, IsNil)
+		c.Assert(access, HasLen, 1, comment)
+		cols := make([]string, 0, len(access[tbl.Meta().ID]))
+		for _, col := range tbl.Meta().Columns {
+			for _, id := range access[tbl.Meta().ID] {
+				if col.ID == id {
+					cols = append(cols, col.Name.L)
+				}
+			}
+		}
+		c.Assert(cols, DeepEquals, tt.cols, comment)
 	}
 }
 
@@ -98,6 +98,8 @@ func (s *decorrelateSolver) optimize(p LogicalPlan, _ context.Context, _ *idAllo
 			outerPlan.SetParents(join)
 			join.self = join
 			p = join
+		} else if apply.noDecorrelate {
+			// The NO_DECORRELATE hint keeps the correlated apply, but its children can still be decorrelated.
 		} else if sel, ok := innerPlan.(*Selection); ok {
 			// If the inner plan is a selection, we add this condition to join predicates.
 			// Notice that no matter what kind of join is, it's always right.
@@ -182,7 +184,7 @@ func (p *Selection) checkScanController() int {
 		pkCol       *expression.Column
 	)
 	ds := p.children[0].(*DataSource)
-	indices, includeTableScan := availableIndices(ds.indexHints, ds.tableInfo)
+	indices, includeTableScan := availableIndices(ds.indexHintsFor(ast.HintForScan), ds.tableInfo)
 	for _, expr := range p.Conditions {
 		if !expr.IsCorrelated() {
 			continue
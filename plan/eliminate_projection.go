@@ -223,3 +223,9 @@ func (p *TopN) replaceExprColumns(replace map[string]*expression.Column) {
 		resolveExprAndReplace(byItem.Expr, replace)
 	}
 }
+
+func (p *Limit) replaceExprColumns(replace map[string]*expression.Column) {
+	for _, byItem := range p.ByItems {
+		resolveExprAndReplace(byItem.Expr, replace)
+	}
+}
@@ -146,6 +146,14 @@ func (p *Selection) ExplainInfo() string {
 	return string(expression.ExplainExpressionList(p.Conditions))
 }
 
+// ExplainInfo implements PhysicalPlan interface.
+func (p *MaxOneRow) ExplainInfo() string {
+	if p.Once {
+		return "once"
+	}
+	return ""
+}
+
 // ExplainInfo implements PhysicalPlan interface.
 func (p *Projection) ExplainInfo() string {
 	return string(expression.ExplainExpressionList(p.Exprs))
@@ -174,6 +182,9 @@ func (p *Sort) ExplainInfo() string {
 
 // ExplainInfo implements PhysicalPlan interface.
 func (p *Limit) ExplainInfo() string {
+	if p.WithTies {
+		return fmt.Sprintf("offset:%v, count:%v, with ties", p.Offset, p.Count)
+	}
 	return fmt.Sprintf("offset:%v, count:%v", p.Offset, p.Count)
 }
 
@@ -48,10 +48,10 @@ func (s *testExplainSuite) TestExplain(c *C) {
 				"TableScan_15   cop table:t3, range:(-inf,+inf), keep order:false 8000",
 				"TableReader_16 Projection_12  root data:TableScan_15 8000",
 				"Projection_12 HashSemiJoin_14 TableReader_16 root test.t3.a, test.t3.b, test.t3.c, test.t3.d, cast(test.t3.a) 8000",
-				"TableScan_18 HashAgg_17  cop table:s, range:(-inf,+inf), keep order:false 8000",
-				"HashAgg_17  TableScan_18 cop type:complete, funcs:sum(s.a) 1",
-				"TableReader_20 HashAgg_19  root data:HashAgg_17 1",
-				"HashAgg_19 HashSemiJoin_14 TableReader_20 root type:final, funcs:sum(col_0) 1",
+				"TableScan_18 HashAgg_17  cop table:s, range:(-inf,+inf), keep order:false, query block:sel_2 8000",
+				"HashAgg_17  TableScan_18 cop type:complete, funcs:sum(s.a), query block:sel_2 1",
+				"TableReader_20 HashAgg_19  root data:HashAgg_17, query block:sel_2 1",
+				"HashAgg_19 HashSemiJoin_14 TableReader_20 root type:final, funcs:sum(col_0), query block:sel_2 1",
 				"HashSemiJoin_14 Projection_11 Projection_12,HashAgg_19 root right:HashAgg_19, equal:[eq(cast(test.t3.a), sel_agg_1)] 6400",
 				"Projection_11  HashSemiJoin_14 root test.t3.a, test.t3.b, test.t3.c, test.t3.d 6400",
 			},
@@ -181,11 +181,11 @@ func (s *testExplainSuite) TestExplain(c *C) {
 			[]string{
 				"TableScan_13   cop table:t1, range:(-inf,+inf), keep order:false 8000",
 				"TableReader_14 Apply_12  root data:TableScan_13 8000",
-				"TableScan_16   cop table:s, range:(-inf,+inf), keep order:false 8000",
-				"TableReader_17 Selection_4  root data:TableScan_16 8000",
-				"Selection_4 HashAgg_15 TableReader_17 root eq(s.c1, test.t1.c1) 6400",
-				"HashAgg_15 Selection_10 Selection_4 root type:complete, funcs:count(1) 1",
-				"Selection_10 Apply_12 HashAgg_15 root ne(k, 0) 0.8",
+				"TableScan_16   cop table:s, range:(-inf,+inf), keep order:false, query block:sel_2 8000",
+				"TableReader_17 Selection_4  root data:TableScan_16, query block:sel_2 8000",
+				"Selection_4 HashAgg_15 TableReader_17 root eq(s.c1, test.t1.c1), query block:sel_2 6400",
+				"HashAgg_15 Selection_10 Selection_4 root type:complete, funcs:count(1), query block:sel_2 1",
+				"Selection_10 Apply_12 HashAgg_15 root ne(k, 0), query block:sel_2 0.8",
 				"Apply_12 Projection_2 TableReader_14,Selection_10 root left outer join, small:Selection_10, right:Selection_10 8000",
 				"Projection_2  Apply_12 root k 8000",
 			},
@@ -201,11 +201,11 @@ func (s *testExplainSuite) TestExplain(c *C) {
 			[]string{
 				"TableScan_14   cop table:t1, range:(-inf,+inf), keep order:false 8000",
 				"TableReader_15 Apply_13  root data:TableScan_14 8000",
-				"IndexScan_23   cop table:t2, index:c1, range:[<nil>,+inf], out of order:false 1.25",
-				"TableScan_24   cop table:t2, keep order:false 1.25",
-				"IndexLookUp_25 Selection_4  root index:IndexScan_23, table:TableScan_24 1.25",
-				"Selection_4 Limit_16 IndexLookUp_25 root eq(test.t1.c1, test.t2.c1) 6400",
-				"Limit_16 MaxOneRow_9 Selection_4 root offset:0, count:1 1",
+				"IndexScan_23   cop table:t2, index:c1, range:[<nil>,+inf], out of order:false, query block:sel_2 1.25",
+				"TableScan_24   cop table:t2, keep order:false, query block:sel_2 1.25",
+				"IndexLookUp_25 Selection_4  root index:IndexScan_23, table:TableScan_24, query block:sel_2 1.25",
+				"Selection_4 Limit_16 IndexLookUp_25 root eq(test.t1.c1, test.t2.c1), query block:sel_2 6400",
+				"Limit_16 MaxOneRow_9 Selection_4 root offset:0, count:1, query block:sel_2 1",
 				"MaxOneRow_9 Apply_13 Limit_16 root  1",
 				"Apply_13 Projection_2 TableReader_15,MaxOneRow_9 root left outer join, small:MaxOneRow_9, right:MaxOneRow_9 8000",
 				"Projection_2  Apply_13 root eq(test.t1.c2, test.t2.c2) 8000",
@@ -236,8 +236,8 @@ func (s *testExplainSuite) TestExplain(c *C) {
 			[]string{
 				"TableScan_10   cop table:t1, range:(-inf,+inf), keep order:false 8000",
 				"TableReader_11 HashSemiJoin_9  root data:TableScan_10 8000",
-				"TableScan_12   cop table:t2, range:(-inf,+inf), keep order:false 8000",
-				"TableReader_13 HashSemiJoin_9  root data:TableScan_12 8000",
+				"TableScan_12   cop table:t2, range:(-inf,+inf), keep order:false, query block:sel_2 8000",
+				"TableReader_13 HashSemiJoin_9  root data:TableScan_12, query block:sel_2 8000",
 				"HashSemiJoin_9 HashAgg_8 TableReader_11,TableReader_13 root right:TableReader_13, aux, equal:[eq(test.t1.c1, test.t2.c1)] 8000",
 				"HashAgg_8  HashSemiJoin_9 root type:complete, funcs:sum(join_5_aux_0) 1",
 			},
@@ -247,9 +247,9 @@ func (s *testExplainSuite) TestExplain(c *C) {
 			[]string{
 				"TableScan_8   cop table:t1, range:(-inf,+inf), keep order:false 8000",
 				"TableReader_9 HashSemiJoin_7  root data:TableScan_8 8000",
-				"TableScan_10 Selection_11  cop table:t2, range:(-inf,+inf), keep order:false 10",
-				"Selection_11  TableScan_10 cop eq(1, test.t2.c2) 10",
-				"TableReader_12 HashSemiJoin_7  root data:Selection_11 10",
+				"TableScan_10 Selection_11  cop table:t2, range:(-inf,+inf), keep order:false, query block:sel_2 10",
+				"Selection_11  TableScan_10 cop eq(1, test.t2.c2), query block:sel_2 10",
+				"TableReader_12 HashSemiJoin_7  root data:Selection_11, query block:sel_2 10",
 				"HashSemiJoin_7  TableReader_9,TableReader_12 root right:TableReader_12, aux 8000",
 			},
 		},
@@ -258,9 +258,9 @@ func (s *testExplainSuite) TestExplain(c *C) {
 			[]string{
 				"TableScan_10   cop table:t1, range:(-inf,+inf), keep order:false 8000",
 				"TableReader_11 HashSemiJoin_9  root data:TableScan_10 8000",
-				"TableScan_12 Selection_13  cop table:t2, range:(-inf,+inf), keep order:false 10",
-				"Selection_13  TableScan_12 cop eq(6, test.t2.c2) 10",
-				"TableReader_14 HashSemiJoin_9  root data:Selection_13 10",
+				"TableScan_12 Selection_13  cop table:t2, range:(-inf,+inf), keep order:false, query block:sel_2 10",
+				"Selection_13  TableScan_12 cop eq(6, test.t2.c2), query block:sel_2 10",
+				"TableReader_14 HashSemiJoin_9  root data:Selection_13, query block:sel_2 10",
 				"HashSemiJoin_9 HashAgg_8 TableReader_11,TableReader_14 root right:TableReader_14, aux 8000",
 				"HashAgg_8  HashSemiJoin_9 root type:complete, funcs:sum(join_5_aux_0) 1",
 			},
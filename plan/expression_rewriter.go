@@ -165,6 +165,11 @@ func popRowArg(ctx context.Context, e expression.Expression) (ret expression.Exp
 func (er *expressionRewriter) constructBinaryOpFunction(l expression.Expression, r expression.Expression, op string) (expression.Expression, error) {
 	lLen, rLen := getRowLen(l), getRowLen(r)
 	if lLen == 1 && rLen == 1 {
+		if er.b.inCondition {
+			if err := er.b.checkImplicitConversion(op, l, r); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
 		return expression.NewFunction(er.ctx, op, types.NewFieldType(mysql.TypeTiny), l, r)
 	} else if rLen != lLen {
 		return nil, ErrOperandColumns.GenByArgs(lLen)
@@ -182,6 +187,11 @@ func (er *expressionRewriter) constructBinaryOpFunction(l expression.Expression,
 		return expression.ComposeCNFCondition(er.ctx, funcs...), nil
 	default:
 		larg0, rarg0 := getRowArg(l, 0), getRowArg(r, 0)
+		if er.b.inCondition {
+			if err := er.b.checkImplicitConversion(op, larg0, rarg0); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
 		var expr1, expr2, expr3 expression.Expression
 		if op == ast.LE || op == ast.GE {
 			expr1, _ = expression.NewFunction(er.ctx, op, types.NewFieldType(mysql.TypeTiny), larg0, rarg0)
@@ -211,7 +221,10 @@ func (er *expressionRewriter) constructBinaryOpFunction(l expression.Expression,
 func (er *expressionRewriter) buildSubquery(subq *ast.SubqueryExpr) LogicalPlan {
 	outerSchema := er.schema.Clone()
 	er.b.outerSchemas = append(er.b.outerSchemas, outerSchema)
+	inSelectFields, inCondition := er.b.inSelectFields, er.b.inCondition
+	er.b.inSelectFields, er.b.inCondition = false, false
 	np := er.b.buildResultSetNode(subq.Query)
+	er.b.inSelectFields, er.b.inCondition = inSelectFields, inCondition
 	er.b.outerSchemas = er.b.outerSchemas[0 : len(er.b.outerSchemas)-1]
 	if er.b.err != nil {
 		er.err = errors.Trace(er.b.err)
@@ -506,6 +519,7 @@ func (er *expressionRewriter) handleExistSubquery(v *ast.ExistsSubqueryExpr) (as
 	if er.err != nil {
 		return v, true
 	}
+	markSubqueryPredicateLimit(np)
 	np = er.b.buildExists(np)
 	if len(np.extractCorrelatedCols()) > 0 {
 		er.p = er.b.buildSemiApply(er.p, np.Children()[0].(LogicalPlan), nil, er.asScalar, false)
@@ -514,7 +528,7 @@ func (er *expressionRewriter) handleExistSubquery(v *ast.ExistsSubqueryExpr) (as
 		}
 		er.ctxStack = append(er.ctxStack, er.p.Schema().Columns[er.p.Schema().Len()-1])
 	} else {
-		physicalPlan, err := doOptimize(er.b.optFlag, np, er.b.ctx, er.b.allocator)
+		physicalPlan, err := doOptimizeWithColumnAccess(er.b.optFlag, np, er.b.ctx, er.b.allocator, er.b.columnAccess)
 		rows, err := EvalSubquery(physicalPlan, er.b.is, er.b.ctx)
 		if err != nil {
 			er.err = errors.Trace(err)
@@ -544,6 +558,7 @@ func (er *expressionRewriter) handleInSubquery(v *ast.PatternInExpr) (ast.Node,
 	if er.err != nil {
 		return v, true
 	}
+	markSubqueryPredicateLimit(np)
 	lLen := getRowLen(lexpr)
 	if lLen != np.Schema().Len() {
 		er.err = ErrOperandColumns.GenByArgs(lLen)
@@ -553,7 +568,7 @@ func (er *expressionRewriter) handleInSubquery(v *ast.PatternInExpr) (ast.Node,
 	// TODO: Now we cannot add it to CBO framework. Instead, user can set a session variable to open this optimization.
 	// We will improve our CBO framework in future.
 	if lLen == 1 && er.ctx.GetSessionVars().AllowInSubqueryUnFolding && len(np.extractCorrelatedCols()) == 0 {
-		physicalPlan, err := doOptimize(er.b.optFlag, np, er.b.ctx, er.b.allocator)
+		physicalPlan, err := doOptimizeWithColumnAccess(er.b.optFlag, np, er.b.ctx, er.b.allocator, er.b.columnAccess)
 		if err != nil {
 			er.err = errors.Trace(err)
 			return v, true
@@ -617,8 +632,19 @@ func (er *expressionRewriter) handleScalarSubquery(v *ast.SubqueryExpr) (ast.Nod
 	if er.err != nil {
 		return v, true
 	}
-	np = er.b.buildMaxOneRow(np)
-	if len(np.extractCorrelatedCols()) > 0 {
+	correlated := len(np.extractCorrelatedCols()) > 0
+	materialized := !correlated && er.b.inSelectFields && er.ctx.GetSessionVars().MaterializeScalarSubquery
+	// The subquery evaluated now skips the MaxOneRow if it returns at most one row already,
+	// and its empty result is treated as NULL below.
+	if correlated || materialized || !returnsMaxOneRow(np) {
+		np = er.b.buildMaxOneRow(np)
+	}
+	if materialized {
+		// The uncorrelated subquery is kept in the plan rather than evaluated now, the MaxOneRow evaluates it once
+		// and its row is reused for all the outer rows.
+		np = er.b.buildOnceMaxOneRow(np)
+	}
+	if correlated || materialized {
 		er.p = er.b.buildApplyWithJoinType(er.p, np, LeftOuterJoin)
 		if np.Schema().Len() > 1 {
 			newCols := make([]expression.Expression, 0, np.Schema().Len())
@@ -636,7 +662,7 @@ func (er *expressionRewriter) handleScalarSubquery(v *ast.SubqueryExpr) (ast.Nod
 		}
 		return v, true
 	}
-	physicalPlan, err := doOptimize(er.b.optFlag, np, er.b.ctx, er.b.allocator)
+	physicalPlan, err := doOptimizeWithColumnAccess(er.b.optFlag, np, er.b.ctx, er.b.allocator, er.b.columnAccess)
 	if err != nil {
 		er.err = errors.Trace(err)
 		return v, true
@@ -646,6 +672,10 @@ func (er *expressionRewriter) handleScalarSubquery(v *ast.SubqueryExpr) (ast.Nod
 		er.err = errors.Trace(err)
 		return v, true
 	}
+	if len(rows) == 0 {
+		// Like MaxOneRow, the empty result is a row of NULLs.
+		rows = append(rows, make([]types.Datum, np.Schema().Len()))
+	}
 	if np.Schema().Len() > 1 {
 		newCols := make([]expression.Expression, 0, np.Schema().Len())
 		for i, data := range rows[0] {
@@ -717,6 +747,12 @@ func (er *expressionRewriter) Leave(originInNode ast.Node) (retNode ast.Node, ok
 		er.rowToScalarFunc(v)
 	case *ast.PatternInExpr:
 		if v.Sel == nil {
+			if er.b.inCondition {
+				er.checkInListConversion(v)
+				if er.err != nil {
+					return retNode, false
+				}
+			}
 			er.inToExpression(len(v.List), v.Not, &v.Type)
 		}
 	case *ast.PositionExpr:
@@ -928,6 +964,27 @@ func (er *expressionRewriter) inToExpression(lLen int, not bool, tp *types.Field
 	er.ctxStack = append(er.ctxStack, function)
 }
 
+// checkInListConversion checks the constants of the IN list v by checkImplicitConversion. The type inferrer converts
+// them to the type of the column on the left if it loses nothing, e.g. `b IN (1, 2)` of a string column b has '1' and
+// '2' in the list then, so they're checked by their types in the AST. The other items are checked by inToExpression.
+func (er *expressionRewriter) checkInListConversion(v *ast.PatternInExpr) {
+	stkLen := len(er.ctxStack)
+	lexpr := er.ctxStack[stkLen-len(v.List)-1]
+	if getRowLen(lexpr) != 1 {
+		return
+	}
+	for _, item := range v.List {
+		value, ok := item.(*ast.ValueExpr)
+		if !ok {
+			continue
+		}
+		arg := &expression.Constant{Value: value.Datum, RetType: &value.Type}
+		if er.err = er.b.checkImplicitConversion("IN", lexpr, arg); er.err != nil {
+			return
+		}
+	}
+}
+
 func (er *expressionRewriter) caseToExpression(v *ast.CaseExpr) {
 	stkLen := len(er.ctxStack)
 	argsLen := 2 * len(v.WhenClauses)
@@ -1026,6 +1083,13 @@ func (er *expressionRewriter) betweenToExpression(v *ast.BetweenExpr) {
 	if er.err != nil {
 		return
 	}
+	if er.b.inCondition {
+		for _, arg := range er.ctxStack[stkLen-2:] {
+			if er.err = er.b.checkImplicitConversion("BETWEEN", er.ctxStack[stkLen-3], arg); er.err != nil {
+				return
+			}
+		}
+	}
 	var op string
 	var l, r expression.Expression
 	l, er.err = expression.NewFunction(er.ctx, ast.GE, &v.Type, er.ctxStack[stkLen-3], er.ctxStack[stkLen-2])
@@ -1121,6 +1185,19 @@ func (er *expressionRewriter) toColumn(v *ast.ColumnName) {
 		er.ctxStack = append(er.ctxStack, column.Clone())
 		return
 	}
+	// The common column eliminated by USING or NATURAL join is still a local column,
+	// so it must be resolved before any outer scope.
+	if redundantSchema := getRedundantSchema(er.p); redundantSchema != nil {
+		column, err := redundantSchema.FindColumn(v)
+		if err != nil {
+			er.err = errors.Trace(err)
+			return
+		}
+		if column != nil {
+			er.ctxStack = append(er.ctxStack, column.Clone())
+			return
+		}
+	}
 	for i := len(er.b.outerSchemas) - 1; i >= 0; i-- {
 		outerSchema := er.b.outerSchemas[i]
 		column, err = outerSchema.FindColumn(v)
@@ -1133,16 +1210,5 @@ func (er *expressionRewriter) toColumn(v *ast.ColumnName) {
 			return
 		}
 	}
-	if join, ok := er.p.(*LogicalJoin); ok && join.redundantSchema != nil {
-		column, err := join.redundantSchema.FindColumn(v)
-		if err != nil {
-			er.err = errors.Trace(err)
-			return
-		}
-		if column != nil {
-			er.ctxStack = append(er.ctxStack, column.Clone())
-			return
-		}
-	}
 	er.err = ErrUnknownColumn.GenByArgs(v.Text(), "field list")
 }
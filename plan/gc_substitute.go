@@ -0,0 +1,162 @@
+// Copyright 2017 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"github.com/juju/errors"
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/context"
+	"github.com/pingcap/tidb/expression"
+	"github.com/pingcap/tidb/model"
+	"github.com/pingcap/tidb/mysql"
+	"github.com/pingcap/tidb/table"
+	"github.com/pingcap/tidb/util/types"
+)
+
+// gcSubstituter substitutes the expressions in the filters that are the same as the generation expression of an
+// indexed stored generated column by the column, e.g. `a + b = 5` becomes `g = 5` if g is defined as `a + b` and indexed,
+// so the index on g can be used. It runs before column pruning, so the substituted columns are read.
+type gcSubstituter struct {
+}
+
+func (s *gcSubstituter) optimize(p LogicalPlan, ctx context.Context, alloc *idAllocator) (LogicalPlan, error) {
+	substitutePlanGenCols(p, ctx)
+	return p, nil
+}
+
+// substitutePlanGenCols substitutes the filters of p and its children, and returns the indexed generated columns
+// visible in the output of p.
+func substitutePlanGenCols(p LogicalPlan, ctx context.Context) []*expression.Assignment {
+	if ds, ok := p.(*DataSource); ok {
+		return ds.indexedGenCols
+	}
+	var genCols []*expression.Assignment
+	for _, child := range p.Children() {
+		for _, gc := range substitutePlanGenCols(child.(LogicalPlan), ctx) {
+			if p.Schema().Contains(gc.Col) {
+				genCols = append(genCols, gc)
+			}
+		}
+	}
+	if sel, ok := p.(*Selection); ok && len(genCols) > 0 {
+		for i, cond := range sel.Conditions {
+			sel.Conditions[i] = substituteGenCols(cond, genCols, ctx)
+		}
+	}
+	return genCols
+}
+
+func substituteGenCols(expr expression.Expression, genCols []*expression.Assignment, ctx context.Context) expression.Expression {
+	sf, ok := expr.(*expression.ScalarFunction)
+	if !ok {
+		return expr
+	}
+	for _, gc := range genCols {
+		if sf.Equal(gc.Expr, ctx) {
+			return gc.Col
+		}
+	}
+	changed := false
+	newArgs := make([]expression.Expression, 0, len(sf.GetArgs()))
+	for _, arg := range sf.GetArgs() {
+		newArg := substituteGenCols(arg, genCols, ctx)
+		changed = changed || newArg != arg
+		newArgs = append(newArgs, newArg)
+	}
+	if !changed {
+		return expr
+	}
+	if sf.FuncName.L == ast.Cast {
+		newFunc := sf.Clone().(*expression.ScalarFunction)
+		newFunc.GetArgs()[0] = newArgs[0]
+		return newFunc
+	}
+	newFunc, err := expression.NewFunction(ctx, sf.FuncName.L, sf.RetType, newArgs...)
+	if err != nil {
+		return expr
+	}
+	return newFunc
+}
+
+// buildIndexedGenCols rewrites the generation expressions of the stored generated columns used by the public indices
+// of the table. schema is the schema of the DataSource built from columns.
+func (b *planBuilder) buildIndexedGenCols(tableInfo *model.TableInfo, columns []*table.Column, schema *expression.Schema) []*expression.Assignment {
+	indexed := make(map[int]bool)
+	for _, idx := range tableInfo.Indices {
+		if idx.State != model.StatePublic {
+			continue
+		}
+		for _, idxCol := range idx.Columns {
+			indexed[idxCol.Offset] = true
+		}
+	}
+	var genCols []*expression.Assignment
+	var mockTablePlan LogicalPlan
+	for i, col := range columns {
+		// Only the stored generated columns keep the values of the expressions, the virtual ones are not computed
+		// when they are read.
+		if col.GeneratedExpr == nil || !col.GeneratedStored || col.State != model.StatePublic || !indexed[col.Offset] {
+			continue
+		}
+		if mockTablePlan == nil {
+			mockTablePlan = TableDual{}.init(b.allocator, b.ctx)
+			mockTablePlan.SetSchema(schema)
+		}
+		expr, _, err := b.rewrite(col.GeneratedExpr, mockTablePlan, nil, true)
+		if err != nil {
+			b.err = errors.Trace(err)
+			return nil
+		}
+		// The column stores the value of the expression converted to the column type, it's only the same as the
+		// expression if the conversion doesn't change the value.
+		if !genColKeepsExprValue(&col.FieldType, expr.GetType()) {
+			continue
+		}
+		if genColMayBeStale(expr) {
+			continue
+		}
+		genCols = append(genCols, &expression.Assignment{Col: schema.Columns[i], Expr: expr})
+	}
+	return genCols
+}
+
+// genColMayBeStale checks whether the stored values of a generated column of expr can differ from expr. UPDATE and
+// INSERT ... ON DUPLICATE KEY UPDATE don't compute the stored generated columns again, so the values are stale once
+// any column read by expr is changed, either directly or through the columns it's generated from.
+func genColMayBeStale(expr expression.Expression) bool {
+	return len(expression.ExtractColumns(expr)) > 0
+}
+
+// genColKeepsExprValue checks whether the values of an expression of exprType are stored in a generated column of
+// colType without any change. It's conservative, e.g. an INT column may overflow for the BIGINT results of `a + b`,
+// and a CHAR column strips the trailing spaces.
+func genColKeepsExprValue(colType, exprType *types.FieldType) bool {
+	if mysql.HasUnsignedFlag(colType.Flag) != mysql.HasUnsignedFlag(exprType.Flag) {
+		return false
+	}
+	switch colType.Tp {
+	case mysql.TypeLonglong:
+		return exprType.ToClass() == types.ClassInt && exprType.Tp != mysql.TypeBit && exprType.Tp != mysql.TypeYear
+	case mysql.TypeDouble:
+		return exprType.ToClass() == types.ClassReal && colType.Decimal == types.UnspecifiedLength
+	case mysql.TypeNewDecimal:
+		return exprType.Tp == mysql.TypeNewDecimal && exprType.Decimal == colType.Decimal &&
+			exprType.Flen != types.UnspecifiedLength && exprType.Flen <= colType.Flen
+	case mysql.TypeVarchar, mysql.TypeVarString, mysql.TypeTinyBlob, mysql.TypeBlob, mysql.TypeMediumBlob,
+		mysql.TypeLongBlob:
+		return (types.IsTypeVarchar(exprType.Tp) || types.IsTypeBlob(exprType.Tp)) &&
+			exprType.Flen != types.UnspecifiedLength && exprType.Flen <= colType.Flen && exprType.Charset == colType.Charset
+	}
+	return false
+}
@@ -23,22 +23,23 @@ import (
 )
 
 // tryToGetJoinGroup tries to fetch a whole join group, which all joins is cartesian join.
-func tryToGetJoinGroup(j *LogicalJoin) ([]LogicalPlan, bool) {
+// crosses[i] is whether the i-th plan of the group is joined with the plans before it by an explicit CROSS JOIN.
+func tryToGetJoinGroup(j *LogicalJoin) (group []LogicalPlan, crosses []bool, valid bool) {
 	// Ignore reorder if:
 	// 1. already reordered
 	// 2. not inner join
 	// 3. forced merge join
 	// 4. forced index nested loop join
 	if j.reordered || !j.cartesianJoin || j.preferMergeJoin || j.preferINLJ > 0 {
-		return nil, false
+		return nil, nil, false
 	}
 	lChild := j.children[0].(LogicalPlan)
 	rChild := j.children[1].(LogicalPlan)
 	if nj, ok := lChild.(*LogicalJoin); ok {
-		plans, valid := tryToGetJoinGroup(nj)
-		return append(plans, rChild), valid
+		plans, crosses, valid := tryToGetJoinGroup(nj)
+		return append(plans, rChild), append(crosses, j.explicitCross), valid
 	}
-	return []LogicalPlan{lChild, rChild}, true
+	return []LogicalPlan{lChild, rChild}, []bool{false, j.explicitCross}, true
 }
 
 func findColumnIndexByGroup(groups []LogicalPlan, col *expression.Column) int {
@@ -57,8 +58,12 @@ type joinReOrderSolver struct {
 	visited    []bool
 	resultJoin LogicalPlan
 	groupRank  []*rankInfo
-	allocator  *idAllocator
-	ctx        context.Context
+	// crosses is the explicit CROSS JOINs of the group, see tryToGetJoinGroup.
+	crosses []bool
+	// walked is the indices of the plans in the group visited by walkGraphAndComposeJoin.
+	walked    []int
+	allocator *idAllocator
+	ctx       context.Context
 }
 
 type edgeList []*rankInfo
@@ -95,9 +100,10 @@ func (e *joinReOrderSolver) Len() int {
 // reorderJoin implements a simple join reorder algorithm. It will extract all the equal conditions and compose them to a graph.
 // Then walk through the graph and pick the nodes connected by some edges to compose a join tree.
 // We will pick the node with least result set as early as possible.
-func (e *joinReOrderSolver) reorderJoin(group []LogicalPlan, conds []expression.Expression) {
+func (e *joinReOrderSolver) reorderJoin(group []LogicalPlan, crosses []bool, conds []expression.Expression) {
 	e.graph = make([]edgeList, len(group))
 	e.group = group
+	e.crosses = crosses
 	e.visited = make([]bool, len(group))
 	e.resultJoin = nil
 	e.groupRank = make([]*rankInfo, len(group))
@@ -158,33 +164,56 @@ func (e *joinReOrderSolver) reorderJoin(group []LogicalPlan, conds []expression.
 		sort.Sort(edge)
 	}
 	var cartesianJoinGroup []LogicalPlan
+	// groupIndices[i] is the indices in the group of the plans composing cartesianJoinGroup[i].
+	var groupIndices [][]int
 	for j := 0; j < len(e.groupRank); j++ {
 		i := e.groupRank[j].nodeID
 		if !e.visited[i] {
 			e.resultJoin = e.group[i]
+			e.walked = nil
 			e.walkGraphAndComposeJoin(i)
 			cartesianJoinGroup = append(cartesianJoinGroup, e.resultJoin)
+			groupIndices = append(groupIndices, e.walked)
 		}
 	}
-	e.makeBushyJoin(cartesianJoinGroup)
+	e.makeBushyJoin(cartesianJoinGroup, groupIndices)
 }
 
 // Make cartesian join as bushy tree.
-func (e *joinReOrderSolver) makeBushyJoin(cartesianJoinGroup []LogicalPlan) {
+func (e *joinReOrderSolver) makeBushyJoin(cartesianJoinGroup []LogicalPlan, groupIndices [][]int) {
 	for len(cartesianJoinGroup) > 1 {
 		resultJoinGroup := make([]LogicalPlan, 0, len(cartesianJoinGroup))
+		resultIndices := make([][]int, 0, len(cartesianJoinGroup))
 		for i := 0; i < len(cartesianJoinGroup); i += 2 {
 			if i+1 == len(cartesianJoinGroup) {
 				resultJoinGroup = append(resultJoinGroup, cartesianJoinGroup[i])
+				resultIndices = append(resultIndices, groupIndices[i])
 				break
 			}
-			resultJoinGroup = append(resultJoinGroup, e.newJoin(cartesianJoinGroup[i], cartesianJoinGroup[i+1]))
+			join := e.newJoin(cartesianJoinGroup[i], cartesianJoinGroup[i+1])
+			join.explicitCross = e.isExplicitCross(groupIndices[i], groupIndices[i+1])
+			resultJoinGroup = append(resultJoinGroup, join)
+			resultIndices = append(resultIndices, append(groupIndices[i], groupIndices[i+1]...))
 		}
 		cartesianJoinGroup = resultJoinGroup
+		groupIndices = resultIndices
 	}
 	e.resultJoin = cartesianJoinGroup[0]
 }
 
+// isExplicitCross checks whether the cartesian join of the plans of the two index sets is written as CROSS JOIN,
+// i.e. one side has a plan that is cross joined with the plans before it, which include a plan of the other side.
+func (e *joinReOrderSolver) isExplicitCross(lIndices, rIndices []int) bool {
+	for _, l := range lIndices {
+		for _, r := range rIndices {
+			if (l > r && e.crosses[l]) || (r > l && e.crosses[r]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (e *joinReOrderSolver) newJoin(lChild, rChild LogicalPlan) *LogicalJoin {
 	join := LogicalJoin{
 		JoinType:  InnerJoin,
@@ -200,6 +229,7 @@ func (e *joinReOrderSolver) newJoin(lChild, rChild LogicalPlan) *LogicalJoin {
 // walkGraph implements a dfs algorithm. Each time it picks a edge with lowest rate, which has been sorted before.
 func (e *joinReOrderSolver) walkGraphAndComposeJoin(u int) {
 	e.visited[u] = true
+	e.walked = append(e.walked, u)
 	for _, edge := range e.graph[u] {
 		v := edge.nodeID
 		if !e.visited[v] {
@@ -21,14 +21,18 @@ import (
 	"github.com/cznic/mathutil"
 	"github.com/juju/errors"
 	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/context"
 	"github.com/pingcap/tidb/expression"
+	"github.com/pingcap/tidb/infoschema"
 	"github.com/pingcap/tidb/model"
 	"github.com/pingcap/tidb/mysql"
 	"github.com/pingcap/tidb/parser"
 	"github.com/pingcap/tidb/sessionctx"
 	"github.com/pingcap/tidb/sessionctx/variable"
+	"github.com/pingcap/tidb/sessionctx/varsutil"
 	"github.com/pingcap/tidb/statistics"
 	"github.com/pingcap/tidb/table"
+	"github.com/pingcap/tidb/terror"
 	"github.com/pingcap/tidb/util/types"
 )
 
@@ -37,6 +41,14 @@ const (
 	TiDBMergeJoin = "tidb_smj"
 	// TiDBIndexNestedLoopJoin is hint enforce index nested loop join.
 	TiDBIndexNestedLoopJoin = "tidb_inlj"
+	// TiDBNoDecorrelate is hint keep the correlated subquery of the query block as apply.
+	TiDBNoDecorrelate = "no_decorrelate"
+	// QBName is hint names the query block, so the block prefixed hints can be specified for it.
+	QBName = "qb_name"
+	// TiDBIndexOnly is hint enforce reading the tables by covering index scans only.
+	TiDBIndexOnly = "use_index_only"
+	// TiDBCardinality is hint gives the row count of a table or the NDV of its column, overriding the statistics.
+	TiDBCardinality = "tidb_card"
 )
 
 type idAllocator struct {
@@ -106,9 +118,37 @@ func (b *planBuilder) buildAggregation(p LogicalPlan, aggFuncList []*ast.Aggrega
 	agg.GroupByItems = gbyItems
 	agg.SetSchema(schema)
 	agg.collectGroupByColumns()
+	if len(gbyItems) > 0 {
+		b.checkGroupByIndexHints(p, childDataSource(p), gbyItems)
+	}
 	return agg, aggIndexMap
 }
 
+// checkGroupByIndexHints warns about the indices forced FOR GROUP BY in p which can't satisfy the group by items, they
+// are ignored. Only the indices of grouped, the table read directly by the aggregation, may satisfy them.
+func (b *planBuilder) checkGroupByIndexHints(p LogicalPlan, grouped *DataSource, gbyItems []expression.Expression) {
+	if ds, ok := p.(*DataSource); ok {
+		for _, hint := range ds.groupIndexHints {
+			if hint.HintType != ast.HintForce {
+				continue
+			}
+			for _, name := range hint.IndexNames {
+				idx := findIndexByName(ds.tableInfo.Indices, name)
+				if idx == nil || idx.State != model.StatePublic {
+					continue
+				}
+				if ds != grouped || indexGroupByCols(ds.schema.Columns, idx, gbyItems) == nil {
+					b.ctx.GetSessionVars().StmtCtx.AppendWarning(ErrGroupByIndexHint.GenByArgs(idx.Name.O, ds.tableInfo.Name.O))
+				}
+			}
+		}
+		return
+	}
+	for _, child := range p.Children() {
+		b.checkGroupByIndexHints(child.(LogicalPlan), grouped, gbyItems)
+	}
+}
+
 func (b *planBuilder) buildResultSetNode(node ast.ResultSetNode) LogicalPlan {
 	switch x := node.(type) {
 	case *ast.Join:
@@ -131,6 +171,10 @@ func (b *planBuilder) buildResultSetNode(node ast.ResultSetNode) LogicalPlan {
 		}
 		if v, ok := p.(*DataSource); ok {
 			v.TableAsName = &x.AsName
+			if b.TableHints() != nil {
+				v.preferIndexOnly = b.TableHints().ifPreferIndexOnly(extractTableAlias(v))
+				b.applyCardinalityHints(v, b.TableHints().cardinalityHints)
+			}
 		}
 		if x.AsName.L != "" {
 			for _, col := range p.Schema().Columns {
@@ -138,6 +182,9 @@ func (b *planBuilder) buildResultSetNode(node ast.ResultSetNode) LogicalPlan {
 				col.DBName = model.NewCIStr("")
 			}
 		}
+		if v, ok := p.(*DataSource); ok {
+			return b.buildRowFilter(v)
+		}
 		return p
 	case *ast.SelectStmt:
 		return b.buildSelect(x)
@@ -220,9 +267,27 @@ func (b *planBuilder) buildJoin(join *ast.Join) LogicalPlan {
 	if join.Right == nil {
 		return b.buildResultSetNode(join.Left)
 	}
+	if err := checkJoinCondition(join); err != nil {
+		b.err = errors.Trace(err)
+		return nil
+	}
 	b.optFlag = b.optFlag | flagPredicatePushDown
+	// The parenthesized join on the right is nested, e.g. `t1 JOIN (t2 JOIN (t3 JOIN t4))`, so the depth of building it
+	// recursively is bounded. The left-deep joins like `t1, t2, t3` aren't nested, they're only bounded by the stack.
+	if right, ok := join.Right.(*ast.Join); ok && right.Right != nil {
+		if !b.enterNesting() {
+			return nil
+		}
+		defer func() { b.nestingDepth-- }()
+	}
 	leftPlan := b.buildResultSetNode(join.Left)
+	if b.err != nil {
+		return nil
+	}
 	rightPlan := b.buildResultSetNode(join.Right)
+	if b.err != nil {
+		return nil
+	}
 	leftAlias := extractTableAlias(leftPlan)
 	rightAlias := extractTableAlias(rightPlan)
 
@@ -269,7 +334,10 @@ func (b *planBuilder) buildJoin(join *ast.Join) LogicalPlan {
 			return nil
 		}
 	} else if join.On != nil {
+		inCondition := b.inCondition
+		b.inCondition = true
 		onExpr, _, err := b.rewrite(join.On.Expr, joinPlan, nil, false)
+		b.inCondition = inCondition
 		if err != nil {
 			b.err = err
 			return nil
@@ -282,6 +350,7 @@ func (b *planBuilder) buildJoin(join *ast.Join) LogicalPlan {
 		joinPlan.attachOnConds(onCondition)
 	} else if joinPlan.JoinType == InnerJoin {
 		joinPlan.cartesianJoin = true
+		joinPlan.explicitCross = join.ExplicitCross
 	}
 	if join.Tp == ast.LeftJoin {
 		joinPlan.JoinType = LeftOuterJoin
@@ -295,6 +364,26 @@ func (b *planBuilder) buildJoin(join *ast.Join) LogicalPlan {
 	return joinPlan
 }
 
+// checkJoinCondition rejects the join conditions that can't be combined. The parser never produces them, but the AST
+// may be built or rewritten by others, and buildJoin only uses the first one of them.
+func checkJoinCondition(join *ast.Join) error {
+	if join.NaturalJoin {
+		if join.On != nil {
+			return ErrIllegalJoinCondition.GenByArgs("NATURAL", "ON")
+		}
+		if join.Using != nil {
+			return ErrIllegalJoinCondition.GenByArgs("NATURAL", "USING")
+		}
+	}
+	if join.On != nil && join.Using != nil {
+		return ErrIllegalJoinCondition.GenByArgs("ON", "USING")
+	}
+	if join.ExplicitCross && (join.NaturalJoin || join.On != nil || join.Using != nil) {
+		return ErrIllegalJoinCondition.GenByArgs("CROSS JOIN without condition", "join condition")
+	}
+	return nil
+}
+
 // buildUsingClause do redundant column elimination and column ordering based on using clause.
 // According to standard SQL, producing this display order:
 // First, coalesced common columns of the two joined tables, in the order in which they occur in the first table.
@@ -308,6 +397,38 @@ func (b *planBuilder) buildUsingClause(p *LogicalJoin, leftPlan, rightPlan Logic
 	return b.coalesceCommonColumns(p, leftPlan, rightPlan, join.Tp == ast.RightJoin, filter)
 }
 
+// getRedundantSchema returns the redundantSchema of the join under p, the selections on the join are skipped.
+func getRedundantSchema(p LogicalPlan) *expression.Schema {
+	for {
+		switch x := p.(type) {
+		case *LogicalJoin:
+			return x.redundantSchema
+		case *Selection:
+			p = x.children[0].(LogicalPlan)
+		default:
+			return nil
+		}
+	}
+}
+
+// usingColumnClause returns the clause used in the unknown column error of USING column name,
+// which tells the side of the join that lacks the column.
+func usingColumnClause(name string, leftPlan, rightPlan LogicalPlan) string {
+	inLeft, inRight := false, false
+	for _, col := range leftPlan.Schema().Columns {
+		inLeft = inLeft || col.ColName.L == name
+	}
+	for _, col := range rightPlan.Schema().Columns {
+		inRight = inRight || col.ColName.L == name
+	}
+	if inLeft && !inRight {
+		return "right side of the USING clause"
+	} else if !inLeft && inRight {
+		return "left side of the USING clause"
+	}
+	return "from clause"
+}
+
 // buildNaturalJoin build natural join output schema. It find out all the common columns
 // then using the same mechanism as buildUsingClause to eliminate redundant columns and build join conditions.
 // According to standard SQL, producing this display order:
@@ -343,14 +464,14 @@ func (b *planBuilder) coalesceCommonColumns(p *LogicalJoin, leftPlan, rightPlan
 				filter[lCol.ColName.L] = false
 			}
 
-			col := rColumns[i]
-			copy(rColumns[commonLen+1:i+1], rColumns[commonLen:i])
-			rColumns[commonLen] = col
-
-			col = lColumns[j]
-			copy(lColumns[commonLen+1:j+1], lColumns[commonLen:j])
+			col := lColumns[i]
+			copy(lColumns[commonLen+1:i+1], lColumns[commonLen:i])
 			lColumns[commonLen] = col
 
+			col = rColumns[j]
+			copy(rColumns[commonLen+1:j+1], rColumns[commonLen:j])
+			rColumns[commonLen] = col
+
 			commonLen++
 			break
 		}
@@ -359,7 +480,7 @@ func (b *planBuilder) coalesceCommonColumns(p *LogicalJoin, leftPlan, rightPlan
 	if len(filter) > 0 && len(filter) != commonLen {
 		for col, notExist := range filter {
 			if notExist {
-				return ErrUnknownColumn.GenByArgs(col, "from clause")
+				return ErrUnknownColumn.GenByArgs(col, usingColumnClause(col, leftPlan, rightPlan))
 			}
 		}
 	}
@@ -371,6 +492,9 @@ func (b *planBuilder) coalesceCommonColumns(p *LogicalJoin, leftPlan, rightPlan
 	conds := make([]*expression.ScalarFunction, 0, commonLen)
 	for i := 0; i < commonLen; i++ {
 		lc, rc := lsc.Columns[i], rsc.Columns[i]
+		if err := b.checkImplicitConversion(ast.EQ, lc, rc); err != nil {
+			return errors.Trace(err)
+		}
 		cond, err := expression.NewFunction(b.ctx, ast.EQ, types.NewFieldType(mysql.TypeTiny), lc, rc)
 		if err != nil {
 			return errors.Trace(err)
@@ -387,6 +511,12 @@ func (b *planBuilder) coalesceCommonColumns(p *LogicalJoin, leftPlan, rightPlan
 
 func (b *planBuilder) buildSelection(p LogicalPlan, where ast.ExprNode, AggMapper map[*ast.AggregateFuncExpr]int) LogicalPlan {
 	b.optFlag = b.optFlag | flagPredicatePushDown
+	if b.ctx.GetSessionVars().AllowPropagateConstant {
+		b.optFlag = b.optFlag | flagPropagateConstant
+	}
+	inCondition := b.inCondition
+	b.inCondition = true
+	defer func() { b.inCondition = inCondition }()
 	conditions := splitWhere(where)
 	expressions := make([]expression.Expression, 0, len(conditions))
 	selection := Selection{}.init(b.allocator, b.ctx)
@@ -411,6 +541,65 @@ func (b *planBuilder) buildSelection(p LogicalPlan, where ast.ExprNode, AggMappe
 	return selection
 }
 
+// checkImplicitConversion rejects the comparison op of l and r between a string and a number if the session sets
+// RejectImplicitConversion, MySQL compares them as floating point numbers, which can't use the index on the string.
+// It must be called on the arguments before the comparison is built, the built comparison casts them to the same type.
+func (b *planBuilder) checkImplicitConversion(op string, l, r expression.Expression) error {
+	if !b.ctx.GetSessionVars().RejectImplicitConversion {
+		return nil
+	}
+	lType, rType := l.GetType(), r.GetType()
+	if (isStringType(lType) && isNumberType(rType)) || (isNumberType(lType) && isStringType(rType)) {
+		return ErrImplicitConversion.GenByArgs(fmt.Sprintf("%s %s %s", l, op, r), types.TypeStr(lType.Tp), types.TypeStr(rType.Tp))
+	}
+	return nil
+}
+
+func isStringType(ft *types.FieldType) bool {
+	return types.IsTypeChar(ft.Tp) || types.IsTypeVarchar(ft.Tp) || types.IsTypeBlob(ft.Tp)
+}
+
+func isNumberType(ft *types.FieldType) bool {
+	switch ft.ToClass() {
+	case types.ClassInt, types.ClassReal, types.ClassDecimal:
+		return !types.IsTypeTemporal(ft.Tp) && ft.Tp != mysql.TypeBit && ft.Tp != mysql.TypeYear
+	}
+	return false
+}
+
+// foldConstantSelection removes the constant true conditions of the selection p. If any condition is constant false,
+// the selection can't output any row, so it is replaced by a TableDual without rows but with the same schema.
+func (b *planBuilder) foldConstantSelection(p LogicalPlan) LogicalPlan {
+	sel, ok := p.(*Selection)
+	if !ok {
+		return p
+	}
+	conds := sel.Conditions[:0]
+	for _, cond := range sel.Conditions {
+		if _, ok := cond.(*expression.Constant); !ok {
+			conds = append(conds, cond)
+			continue
+		}
+		isTrue, err := expression.EvalBool([]expression.Expression{cond}, nil, b.ctx)
+		if err != nil {
+			b.err = errors.Trace(err)
+			return nil
+		}
+		if !isTrue {
+			dual := TableDual{}.init(b.allocator, b.ctx)
+			dual.SetSchema(sel.Schema())
+			return dual
+		}
+	}
+	sel.Conditions = conds
+	if len(conds) == 0 {
+		child := sel.children[0].(LogicalPlan)
+		child.SetParents()
+		return child
+	}
+	return sel
+}
+
 // buildProjectionFieldNameFromColumns builds the field name and the table name when field expression is a column reference.
 func (b *planBuilder) buildProjectionFieldNameFromColumns(field *ast.SelectField, c *expression.Column) (model.CIStr, model.CIStr) {
 	if astCol, ok := getInnerFromParentheses(field.Expr).(*ast.ColumnNameExpr); ok {
@@ -483,6 +672,9 @@ func (b *planBuilder) buildProjection(p LogicalPlan, fields []*ast.SelectField,
 	proj := Projection{Exprs: make([]expression.Expression, 0, len(fields))}.init(b.allocator, b.ctx)
 	schema := expression.NewSchema(make([]*expression.Column, 0, len(fields))...)
 	oldLen := 0
+	inSelectFields, inCondition := b.inSelectFields, b.inCondition
+	b.inSelectFields, b.inCondition = true, false
+	defer func() { b.inSelectFields, b.inCondition = inSelectFields, inCondition }()
 	for _, field := range fields {
 		newExpr, np, err := b.rewrite(field.Expr, p, mapper, true)
 		if err != nil {
@@ -580,10 +772,13 @@ func (b *planBuilder) buildUnion(union *ast.UnionStmt) LogicalPlan {
 		p = b.buildDistinct(u, u.Schema().Len())
 	}
 	if union.OrderBy != nil {
+		if b.ctx.GetSessionVars().StableSort {
+			b.ctx.GetSessionVars().StmtCtx.AppendWarning(ErrNoStableSortHandle.GenByArgs("UNION"))
+		}
 		p = b.buildSort(p, union.OrderBy.Items, nil)
 	}
 	if union.Limit != nil {
-		p = b.buildLimit(p, union.Limit)
+		p = b.buildLimit(p, union.Limit, union.OrderBy, b.topNode == union && calcFoundRows(union.SelectList.Selects[0]))
 	}
 	return p
 }
@@ -620,9 +815,150 @@ func (b *planBuilder) buildSort(p LogicalPlan, byItems []*ast.ByItem, aggMapper
 	return sort
 }
 
-// getUintForLimitOffset gets uint64 value for limit/offset.
-// For ordinary statement, limit/offset should be uint64 constant value.
-// For prepared statement, limit/offset is string. We should convert it to uint64.
+// reuseProjectedByItems replaces the items of sort that are the same as the expressions of the projection below it by
+// the projected columns, so they aren't computed again, e.g. `SELECT a+b AS s FROM t ORDER BY a+b` is sorted by s. The
+// auxiliary fields of the columns only used by the replaced items are pruned then. A non-deterministic expression, like
+// rand(), is never the same as another one.
+func reuseProjectedByItems(sort *Sort, ctx context.Context) {
+	var proj *Projection
+	for p := sort.children[0]; proj == nil; {
+		switch x := p.(type) {
+		case *Projection:
+			proj = x
+		case *Selection:
+			p = x.children[0]
+		default:
+			return
+		}
+	}
+	for _, item := range sort.ByItems {
+		if _, ok := item.Expr.(*expression.ScalarFunction); !ok {
+			continue
+		}
+		expr := expression.ColumnSubstitute(item.Expr, proj.Schema(), proj.Exprs)
+		for i, projExpr := range proj.Exprs {
+			if projExpr.Equal(expr, ctx) {
+				item.Expr = proj.Schema().Columns[i].Clone()
+				break
+			}
+		}
+	}
+}
+
+// markStableSortTables marks the tables of sel to read their handles, which are appended to ORDER BY to make the sort
+// stable, see variable.StableSort. The rows must be the rows of the base tables to be identified by the handles, it
+// returns false with a warning if they aren't, e.g. they are aggregated or read from a derived table.
+func (b *planBuilder) markStableSortTables(sel *ast.SelectStmt, hasAgg bool) bool {
+	var reason string
+	var tables []*ast.TableName
+	switch {
+	case hasAgg:
+		reason = "aggregation"
+	case sel.Distinct:
+		reason = "DISTINCT"
+	case sel.From == nil:
+		reason = "SELECT without tables"
+	default:
+		var ok bool
+		if tables, ok = appendBaseTables(tables, sel.From.TableRefs); !ok {
+			reason = "derived table"
+		}
+	}
+	if reason != "" {
+		b.ctx.GetSessionVars().StmtCtx.AppendWarning(ErrNoStableSortHandle.GenByArgs(reason))
+		return false
+	}
+	for _, tn := range tables {
+		b.handleTables[tn] = true
+	}
+	return true
+}
+
+// appendBaseTables appends the tables read by node to tables, it returns false if node reads a derived table.
+func appendBaseTables(tables []*ast.TableName, node ast.ResultSetNode) ([]*ast.TableName, bool) {
+	ok := true
+	switch x := node.(type) {
+	case *ast.Join:
+		tables, ok = appendBaseTables(tables, x.Left)
+		if ok && x.Right != nil {
+			tables, ok = appendBaseTables(tables, x.Right)
+		}
+	case *ast.TableSource:
+		tables, ok = appendBaseTables(tables, x.Source)
+	case *ast.TableName:
+		tables = append(tables, x)
+	default:
+		ok = false
+	}
+	return tables, ok
+}
+
+// handleColumns returns the handle columns of the tables in schema, in the order of the columns.
+func handleColumns(schema *expression.Schema) []*expression.Column {
+	isHandle := make([]bool, schema.Len())
+	for _, cols := range schema.TblID2Handle {
+		for _, col := range cols {
+			if idx := schema.ColumnIndex(col); idx != -1 {
+				isHandle[idx] = true
+			}
+		}
+	}
+	var handles []*expression.Column
+	for i, col := range schema.Columns {
+		if isHandle[i] {
+			handles = append(handles, col)
+		}
+	}
+	return handles
+}
+
+// projectColumns returns the output columns of the projection for cols. The columns not projected yet are appended to
+// the projection as auxiliary columns, which are pruned after they are used by the plans above the projection.
+func projectColumns(proj *Projection, cols []*expression.Column) []*expression.Column {
+	projCols := make([]*expression.Column, 0, len(cols))
+	for _, col := range cols {
+		idx := -1
+		for i, expr := range proj.Exprs {
+			if c, ok := expr.(*expression.Column); ok && c.Equal(col, nil) {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			idx = len(proj.Exprs)
+			proj.Exprs = append(proj.Exprs, col)
+			proj.schema.Append(&expression.Column{
+				FromID:   proj.id,
+				Position: proj.schema.Len() + 1,
+				TblName:  col.TblName,
+				ColName:  col.ColName,
+				RetType:  col.GetType(),
+			})
+		}
+		projCols = append(projCols, proj.schema.Columns[idx])
+	}
+	return projCols
+}
+
+// appendByItemColumns appends the columns which aren't sorted by yet to the items of sort.
+func appendByItemColumns(sort *Sort, cols []*expression.Column) {
+	for _, col := range cols {
+		sorted := false
+		for _, item := range sort.ByItems {
+			if c, ok := item.Expr.(*expression.Column); ok && c.Equal(col, nil) {
+				sorted = true
+				break
+			}
+		}
+		if !sorted {
+			sort.ByItems = append(sort.ByItems, &ByItems{Expr: col})
+		}
+	}
+}
+
+// getUintForLimitOffset gets the value of the LIMIT count or offset, which may be bound to a placeholder of a prepared
+// statement as a string or a number. Unlike ORDER BY and GROUP BY, where a placeholder is a constant expression, the
+// value must be a non-negative integer.
 func getUintForLimitOffset(sc *variable.StatementContext, val interface{}) (uint64, error) {
 	switch v := val.(type) {
 	case uint64:
@@ -638,10 +974,38 @@ func getUintForLimitOffset(sc *variable.StatementContext, val interface{}) (uint
 	return 0, errors.Errorf("Invalid type %T for Limit/Offset", val)
 }
 
-func (b *planBuilder) buildLimit(src LogicalPlan, limit *ast.Limit) LogicalPlan {
-	if UseDAGPlanBuilder(b.ctx) {
+// calcFoundRows checks whether the SELECT has the SQL_CALC_FOUND_ROWS modifier.
+func calcFoundRows(sel *ast.SelectStmt) bool {
+	return sel.SelectStmtOpts != nil && sel.CalcFoundRows
+}
+
+// checkCalcFoundRows checks whether SQL_CALC_FOUND_ROWS is used on the top level SELECT,
+// or the first SELECT of the top level UNION, which are the only placements allowed by MySQL.
+func (b *planBuilder) checkCalcFoundRows(sel *ast.SelectStmt) error {
+	if b.topNode == sel {
+		return nil
+	}
+	if union, ok := b.topNode.(*ast.UnionStmt); ok && union.SelectList.Selects[0] == sel {
+		return nil
+	}
+	return ErrCantUseOptionHere.GenByArgs("SQL_CALC_FOUND_ROWS")
+}
+
+// buildLimit builds the Limit plan, if calcFoundRows is true, the rows beyond the limit
+// still need to be counted for FOUND_ROWS(), so the limit can not be pushed down. orderBy is
+// the ORDER BY that src is sorted by, a WITH TIES limit also returns the rows tying with its
+// last row on it, so it can not be pushed down either.
+func (b *planBuilder) buildLimit(src LogicalPlan, limit *ast.Limit, orderBy *ast.OrderByClause, calcFoundRows bool) LogicalPlan {
+	if limit.WithTies && orderBy == nil {
+		b.err = ErrWithTiesNoOrderBy
+		return nil
+	}
+	if UseDAGPlanBuilder(b.ctx) && !calcFoundRows && !limit.WithTies {
 		b.optFlag = b.optFlag | flagPushDownTopN
 	}
+	if b.ctx.GetSessionVars().WarnNondeterministicLimit {
+		b.optFlag = b.optFlag | flagBuildKeyInfo
+	}
 	var (
 		offset, count uint64
 		err           error
@@ -663,9 +1027,17 @@ func (b *planBuilder) buildLimit(src LogicalPlan, limit *ast.Limit) LogicalPlan
 	}
 
 	li := Limit{
-		Offset: offset,
-		Count:  count,
+		Offset:        offset,
+		Count:         count,
+		CalcFoundRows: calcFoundRows,
+		WithTies:      limit.WithTies,
 	}.init(b.allocator, b.ctx)
+	if limit.WithTies {
+		// The handle columns appended to the sort items for a stable sort are not compared, they never tie.
+		for _, item := range src.(*Sort).ByItems[:len(orderBy.Items)] {
+			li.ByItems = append(li.ByItems, &ByItems{Expr: item.Expr.Clone(), Desc: item.Desc})
+		}
+	}
 	addChild(li, src)
 	li.SetSchema(src.Schema().Clone())
 	return li
@@ -697,6 +1069,9 @@ func matchField(f *ast.SelectField, col *ast.ColumnNameExpr, ignoreAsName bool)
 	return false
 }
 
+// resolveFromSelectFields finds the select field v refers to, it's ambiguous if v matches the fields of different
+// columns. The auxiliary fields appended by havingAndOrderbyExprResolver are never matched, their synthesized names,
+// e.g. `sel_agg_2`, may be the same as a user alias by coincidence, but they are invisible to the statement.
 func resolveFromSelectFields(v *ast.ColumnNameExpr, fields []*ast.SelectField, ignoreAsName bool) (index int, err error) {
 	var matchedExpr ast.ExprNode
 	index = -1
@@ -757,6 +1132,16 @@ func (a *havingAndOrderbyExprResolver) resolveFromSchema(v *ast.ColumnNameExpr,
 	if err != nil {
 		return -1, errors.Trace(err)
 	}
+	if col == nil {
+		// The common column eliminated by USING or NATURAL join can still be referred by its qualified name,
+		// e.g. select * from t1 join t2 using (a) order by t2.a.
+		if redundantSchema := getRedundantSchema(a.p); redundantSchema != nil {
+			col, err = redundantSchema.FindColumn(v.Name)
+			if err != nil {
+				return -1, errors.Trace(err)
+			}
+		}
+	}
 	if col == nil {
 		return -1, nil
 	}
@@ -830,10 +1215,18 @@ func (a *havingAndOrderbyExprResolver) Leave(n ast.Node) (node ast.Node, ok bool
 		}
 		if index == -1 {
 			// If we can't find it any where, it may be a correlated columns.
-			for _, schema := range a.outerSchemas {
-				if col, _ := schema.FindColumn(v.Name); col != nil {
+			// The nearest outer scope wins, which is the same as the expression rewriter.
+			// We leave the column as it is, the expression rewriter builds it as a correlated column when the
+			// HAVING condition is built, so the apply of the subquery collects it like the other correlated columns.
+			for i := len(a.outerSchemas) - 1; i >= 0; i-- {
+				col, err := a.outerSchemas[i].FindColumn(v.Name)
+				if col != nil {
 					return n, true
 				}
+				if err != nil {
+					a.err = ErrAmbiguous.GenByArgs(v.Name.Name.L)
+					return node, false
+				}
 			}
 			a.err = errors.Errorf("Unknown Column %s", v.Name.Name.L)
 			return node, false
@@ -904,8 +1297,16 @@ func (b *planBuilder) extractAggFuncs(fields []*ast.SelectField) ([]*ast.Aggrega
 	return aggList, totalAggMapper
 }
 
-// gbyResolver resolves group by items from select fields.
+// gbyResolver resolves group by items from select fields. Like MySQL, a column name of a group by item is resolved as
+// follows:
+// 1. Inside an expression, e.g. `a` of `GROUP BY a + 1`, it refers to the base column if there is one.
+// 2. Otherwise it's looked up in the select fields first, it's an error if more than one select field matches it.
+// 3. If the base column exists, it refers to the base column, even if a select field aliased with the same name matches
+//    it, e.g. `SELECT YEAR(d) AS d FROM t GROUP BY d` groups by the column `d`, a warning is appended for the shadowed
+//    select field.
+// 4. Otherwise it refers to the matched select field, e.g. `SELECT YEAR(d) AS y FROM t GROUP BY y` groups by `YEAR(d)`.
 type gbyResolver struct {
+	ctx    context.Context
 	fields []*ast.SelectField
 	schema *expression.Schema
 	err    error
@@ -934,6 +1335,9 @@ func (g *gbyResolver) Leave(inNode ast.Node) (ast.Node, bool) {
 				return inNode, false
 			}
 			if col != nil {
+				if index != -1 && !g.isBaseColumn(g.fields[index].Expr, col) {
+					g.ctx.GetSessionVars().StmtCtx.AppendWarning(ErrNonUniq.GenByArgs(v.Name.Name.O, "group statement"))
+				}
 				return inNode, true
 			}
 			if index != -1 {
@@ -952,9 +1356,19 @@ func (g *gbyResolver) Leave(inNode ast.Node) (ast.Node, bool) {
 	return inNode, true
 }
 
+// isBaseColumn checks whether the select field expression is the base column col itself.
+func (g *gbyResolver) isBaseColumn(expr ast.ExprNode, col *expression.Column) bool {
+	colExpr, ok := expr.(*ast.ColumnNameExpr)
+	if !ok {
+		return false
+	}
+	fieldCol, err := g.schema.FindColumn(colExpr.Name)
+	return err == nil && fieldCol == col
+}
+
 func (b *planBuilder) resolveGbyExprs(p LogicalPlan, gby *ast.GroupByClause, fields []*ast.SelectField) (LogicalPlan, []expression.Expression) {
 	exprs := make([]expression.Expression, 0, len(gby.Items))
-	resolver := &gbyResolver{fields: fields, schema: p.Schema()}
+	resolver := &gbyResolver{ctx: b.ctx, fields: fields, schema: p.Schema()}
 	for _, item := range gby.Items {
 		resolver.inExpr = false
 		retExpr, _ := item.Expr.Accept(resolver)
@@ -986,10 +1400,13 @@ func (b *planBuilder) unfoldWildStar(p LogicalPlan, selectFields []*ast.SelectFi
 		}
 		dbName := field.WildCard.Schema
 		tblName := field.WildCard.Table
+		if dbName.L == "" && tblName.L != "" {
+			dbName = b.wildCardSchema(p, tblName)
+		}
 		for _, col := range p.Schema().Columns {
 			if (dbName.L == "" || dbName.L == col.DBName.L) &&
 				(tblName.L == "" || tblName.L == col.TblName.L) &&
-				col.ID != model.ExtraHandleID {
+				(col.ID != model.ExtraHandleID || b.ctx.GetSessionVars().WildcardRowID) {
 				colName := &ast.ColumnNameExpr{
 					Name: &ast.ColumnName{
 						Schema: col.DBName,
@@ -1006,22 +1423,59 @@ func (b *planBuilder) unfoldWildStar(p LogicalPlan, selectFields []*ast.SelectFi
 	return
 }
 
+// wildCardSchema returns the schema qualifying the wildcard `tblName.*` without schema. The table of the default
+// schema is chosen if tables of the same name in other schemas are joined too, e.g. `SELECT t.* FROM t JOIN db2.t`
+// only expands to the columns of the t in the default schema, the name resolver has checked the wildcard matches a
+// single table otherwise.
+func (b *planBuilder) wildCardSchema(p LogicalPlan, tblName model.CIStr) model.CIStr {
+	var dbName model.CIStr
+	currentDB := b.ctx.GetSessionVars().CurrentDB
+	for _, col := range p.Schema().Columns {
+		if col.TblName.L != tblName.L {
+			continue
+		}
+		// The aliased tables and the derived tables have no schema, they're only visible by their alias.
+		if col.DBName.L == "" {
+			return model.CIStr{}
+		}
+		if col.DBName.L == strings.ToLower(currentDB) {
+			dbName = col.DBName
+		}
+	}
+	return dbName
+}
+
 func (b *planBuilder) pushTableHints(hints []*ast.TableOptimizerHint) bool {
-	var sortMergeTables, INLJTables []model.CIStr
+	var sortMergeTables, INLJTables, indexOnlyTables []model.CIStr
+	var cardinalityHints []*ast.TableOptimizerHint
+	noDecorrelate := false
 	for _, hint := range hints {
 		switch hint.HintName.L {
 		case TiDBMergeJoin:
 			sortMergeTables = append(sortMergeTables, hint.Tables...)
 		case TiDBIndexNestedLoopJoin:
 			INLJTables = append(INLJTables, hint.Tables...)
+		case TiDBNoDecorrelate:
+			noDecorrelate = true
+		case TiDBIndexOnly:
+			indexOnlyTables = append(indexOnlyTables, hint.Tables...)
+		case TiDBCardinality:
+			if hint.Cardinality == 0 {
+				b.ctx.GetSessionVars().StmtCtx.AppendWarning(ErrInvalidCardinalityHint.GenByArgs(hint.HintName.O))
+				continue
+			}
+			cardinalityHints = append(cardinalityHints, hint)
 		default:
 			// ignore hints that not implemented
 		}
 	}
-	if len(sortMergeTables) != 0 || len(INLJTables) != 0 {
+	if len(sortMergeTables) != 0 || len(INLJTables) != 0 || len(indexOnlyTables) != 0 || len(cardinalityHints) != 0 || noDecorrelate {
 		b.tableHintInfo = append(b.tableHintInfo, tableHintInfo{
 			sortMergeJoinTables:       sortMergeTables,
 			indexNestedLoopJoinTables: INLJTables,
+			indexOnlyTables:           indexOnlyTables,
+			cardinalityHints:          cardinalityHints,
+			noDecorrelate:             noDecorrelate,
 		})
 		return true
 	}
@@ -1040,25 +1494,192 @@ func (b *planBuilder) TableHints() *tableHintInfo {
 	return &(b.tableHintInfo[len(b.tableHintInfo)-1])
 }
 
+// isTopQueryBlock checks whether the SELECT is the top level SELECT or a SELECT of the top level UNION.
+func isTopQueryBlock(top ast.Node, sel *ast.SelectStmt) bool {
+	if top == sel {
+		return true
+	}
+	if union, ok := top.(*ast.UnionStmt); ok {
+		for _, s := range union.SelectList.Selects {
+			if s == sel {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type blockHint struct {
+	offset int
+	hint   *ast.TableOptimizerHint
+}
+
+// queryBlockCollector numbers the SELECTs in the order they appear in the statement and collects their hints.
+type queryBlockCollector struct {
+	b *planBuilder
+	// top is the top level SELECT or UNION of the statement.
+	top    ast.Node
+	offset int
+	// names maps the names given by QB_NAME to the query block offsets.
+	names map[string]int
+	hints []blockHint
+}
+
+func (c *queryBlockCollector) Enter(in ast.Node) (ast.Node, bool) {
+	switch x := in.(type) {
+	case *ast.ExplainStmt:
+		// The explained statement is planned by another builder.
+		return in, true
+	case *ast.SelectStmt:
+		c.offset++
+		c.b.selectOffsets[x] = c.offset
+		hints := x.TableHints
+		if c.b.overrideHints && isTopQueryBlock(c.top, x) {
+			hints = c.b.hintOverride
+		}
+		for _, hint := range hints {
+			if hint.HintName.L == QBName {
+				if _, ok := c.names[hint.QBName.L]; !ok {
+					c.names[hint.QBName.L] = c.offset
+				}
+				continue
+			}
+			c.hints = append(c.hints, blockHint{offset: c.offset, hint: hint})
+		}
+	}
+	return in, false
+}
+
+func (c *queryBlockCollector) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
+
+// blockOffset returns the offset of the query block named name, which is given by QB_NAME or the default name like
+// sel_2, 0 means there is no such query block.
+func (c *queryBlockCollector) blockOffset(name string) int {
+	if offset, ok := c.names[name]; ok {
+		return offset
+	}
+	for offset := 1; offset <= c.offset; offset++ {
+		if QueryBlockName(offset) == name {
+			return offset
+		}
+	}
+	return 0
+}
+
+// collectQueryBlocks numbers the SELECTs of the statement, the top level one is sel_1, and binds the hints to the
+// query blocks they are specified for. The hints for unknown query blocks are ignored with a warning.
+func (b *planBuilder) collectQueryBlocks(node ast.Node) {
+	b.selectOffsets = make(map[*ast.SelectStmt]int)
+	b.blockHints = make(map[int][]*ast.TableOptimizerHint)
+	top := node
+	if insert, ok := node.(*ast.InsertStmt); ok && insert.Select != nil {
+		top = insert.Select
+	}
+	c := &queryBlockCollector{b: b, top: top, names: make(map[string]int)}
+	node.Accept(c)
+	b.selectOffset = c.offset
+	for _, h := range c.hints {
+		offset := h.offset
+		if h.hint.QBName.L != "" {
+			offset = c.blockOffset(h.hint.QBName.L)
+			if offset == 0 {
+				b.ctx.GetSessionVars().StmtCtx.AppendWarning(ErrUnknownQueryBlock.GenByArgs(h.hint.QBName.O, h.hint.HintName.O))
+				continue
+			}
+		}
+		b.blockHints[offset] = append(b.blockHints[offset], h.hint)
+	}
+}
+
+// QueryBlockName returns the name of the query block at the offset, which is the name used by block prefixed hints,
+// e.g. the first subquery of the statement is named sel_2.
+func QueryBlockName(offset int) string {
+	return fmt.Sprintf("sel_%d", offset)
+}
+
+// tagQueryBlock tags the untagged plans of the tree with the query block offset.
+// The subtree of a tagged plan belongs to an inner query block, so it has been tagged already.
+func tagQueryBlock(p Plan, offset int) {
+	if p.queryBlockOffset() != 0 {
+		return
+	}
+	p.setQueryBlockOffset(offset)
+	for _, child := range p.Children() {
+		tagQueryBlock(child, offset)
+	}
+}
+
+// enterNesting increases the nesting depth before a nested SELECT or parenthesized join is built, it fails if the depth exceeds the
+// MaxSubqueryDepth session variable. The caller decreases the depth after the building.
+func (b *planBuilder) enterNesting() bool {
+	if b.nestingDepth >= b.ctx.GetSessionVars().MaxSubqueryDepth {
+		b.err = ErrTooHighLevelOfNesting
+		return false
+	}
+	b.nestingDepth++
+	return true
+}
+
 func (b *planBuilder) buildSelect(sel *ast.SelectStmt) LogicalPlan {
-	if sel.TableHints != nil {
-		// table hints without query block support only visible in current SELECT
-		if b.pushTableHints(sel.TableHints) {
-			defer b.popTableHints()
+	// The subqueries and the derived tables are built by the recursive calls of buildSelect, so the depth is bounded here.
+	if !b.enterNesting() {
+		return nil
+	}
+	defer func() { b.nestingDepth-- }()
+	blockOffset, ok := b.selectOffsets[sel]
+	if !ok {
+		b.selectOffset++
+		blockOffset = b.selectOffset
+	}
+	// The hints bound to the query block override the ones of the outer block, the block without hints inherits them.
+	noDecorrelate := false
+	if b.pushTableHints(b.blockHints[blockOffset]) {
+		defer b.popTableHints()
+		noDecorrelate = b.TableHints().noDecorrelate
+	}
+	// The apply built on this query block as a subquery is decided after it is built,
+	// so pass the hint to the outer block through the builder.
+	defer func() { b.noDecorrelate = noDecorrelate }()
+
+	if calcFoundRows(sel) {
+		if err := b.checkCalcFoundRows(sel); err != nil {
+			b.err = errors.Trace(err)
+			return nil
 		}
+		b.ctx.GetSessionVars().StmtCtx.AppendWarning(ErrWarnDeprecatedSyntax.GenByArgs("SQL_CALC_FOUND_ROWS", "SELECT COUNT(*)"))
 	}
 
 	if sel.LockTp == ast.SelectLockForUpdate {
 		b.needColHandle++
 	}
 
+	if sel.IsTable {
+		p := b.buildTableStmt(sel)
+		if b.err != nil {
+			return nil
+		}
+		tagQueryBlock(p, blockOffset)
+		return p
+	}
 	hasAgg := b.detectSelectAgg(sel)
+	if !hasAgg && isDualSelect(sel) {
+		p := b.buildDualProjection(sel.Fields.Fields)
+		if b.err != nil {
+			return nil
+		}
+		tagQueryBlock(p, blockOffset)
+		return p
+	}
 	var (
 		p                             LogicalPlan
 		aggFuncs                      []*ast.AggregateFuncExpr
 		havingMap, orderMap, totalMap map[*ast.AggregateFuncExpr]int
 		gbyCols                       []expression.Expression
+		sortHandles                   []*expression.Column
 	)
+	stableSort := sel.OrderBy != nil && b.ctx.GetSessionVars().StableSort && b.markStableSortTables(sel, hasAgg)
 	if sel.From != nil {
 		p = b.buildResultSetNode(sel.From.TableRefs)
 	} else {
@@ -1087,10 +1708,17 @@ func (b *planBuilder) buildSelect(sel *ast.SelectStmt) LogicalPlan {
 		if b.err != nil {
 			return nil
 		}
+		p = b.foldConstantSelection(p)
+		if b.err != nil {
+			return nil
+		}
 	}
 	if sel.LockTp != ast.SelectLockNone {
 		p = b.buildSelectLock(p, sel.LockTp)
 	}
+	if stableSort {
+		sortHandles = handleColumns(p.Schema())
+	}
 	if hasAgg {
 		aggFuncs, totalMap = b.extractAggFuncs(sel.Fields.Fields)
 		if b.err != nil {
@@ -1110,6 +1738,9 @@ func (b *planBuilder) buildSelect(sel *ast.SelectStmt) LogicalPlan {
 	if b.err != nil {
 		return nil
 	}
+	if len(sortHandles) > 0 {
+		sortHandles = projectColumns(p.(*Projection), sortHandles)
+	}
 	if sel.Having != nil {
 		p = b.buildSelection(p, sel.Having.Expr, havingMap)
 		if b.err != nil {
@@ -1127,9 +1758,12 @@ func (b *planBuilder) buildSelect(sel *ast.SelectStmt) LogicalPlan {
 		if b.err != nil {
 			return nil
 		}
+		sort := p.(*Sort)
+		appendByItemColumns(sort, sortHandles)
+		reuseProjectedByItems(sort, b.ctx)
 	}
 	if sel.Limit != nil {
-		p = b.buildLimit(p, sel.Limit)
+		p = b.buildLimit(p, sel.Limit, sel.OrderBy, b.topNode == sel && calcFoundRows(sel))
 		if b.err != nil {
 			return nil
 		}
@@ -1146,12 +1780,124 @@ func (b *planBuilder) buildSelect(sel *ast.SelectStmt) LogicalPlan {
 			col.FromID = proj.ID()
 		}
 		proj.SetSchema(schema)
-		return proj
+		p = proj
 	}
+	tagQueryBlock(p, blockOffset)
+	return p
+}
 
+// buildTableStmt builds the `TABLE t [ORDER BY ...] [LIMIT ...]` statement, which is the same as
+// `SELECT * FROM t [ORDER BY ...] [LIMIT ...]`.
+func (b *planBuilder) buildTableStmt(sel *ast.SelectStmt) LogicalPlan {
+	if err := checkTableStmt(sel); err != nil {
+		b.err = errors.Trace(err)
+		return nil
+	}
+	p := b.buildResultSetNode(sel.From.TableRefs)
+	if b.err != nil {
+		return nil
+	}
+	fields := b.unfoldWildStar(p, sel.Fields.Fields)
+	if b.err != nil {
+		return nil
+	}
+	// All the columns of the table are selected, so the ORDER BY items are resolved by the projection.
+	p, _ = b.buildProjection(p, fields, nil)
+	if b.err != nil {
+		return nil
+	}
+	if sel.OrderBy != nil {
+		p = b.buildSort(p, sel.OrderBy.Items, nil)
+		if b.err != nil {
+			return nil
+		}
+	}
+	if sel.Limit != nil {
+		p = b.buildLimit(p, sel.Limit, sel.OrderBy, false)
+		if b.err != nil {
+			return nil
+		}
+	}
+	return p
+}
+
+// checkTableStmt checks that the TABLE statement sel only has the ORDER BY and LIMIT clauses, the others can't be
+// written in the syntax but may be set by the statements rewriting it.
+func checkTableStmt(sel *ast.SelectStmt) error {
+	switch {
+	case sel.Where != nil:
+		return ErrTableStmtClause.GenByArgs("WHERE")
+	case sel.GroupBy != nil:
+		return ErrTableStmtClause.GenByArgs("GROUP BY")
+	case sel.Having != nil:
+		return ErrTableStmtClause.GenByArgs("HAVING")
+	case sel.Distinct:
+		return ErrTableStmtClause.GenByArgs("DISTINCT")
+	case sel.LockTp != ast.SelectLockNone:
+		return ErrTableStmtClause.GenByArgs("locking read")
+	}
+	return nil
+}
+
+// isDualSelect checks whether sel only computes its fields without any table, e.g. `select 1` or `select now()`.
+func isDualSelect(sel *ast.SelectStmt) bool {
+	return sel.From == nil && sel.Where == nil && sel.GroupBy == nil && sel.Having == nil && sel.OrderBy == nil &&
+		sel.Limit == nil && !sel.Distinct && sel.LockTp == ast.SelectLockNone
+}
+
+// buildDualProjection builds the fields of a select without any table as a projection over a single row TableDual.
+// The projection can't be optimized, so the optimizing rules set by building it are cleared, unless the fields have
+// subqueries, which are built into the plan.
+func (b *planBuilder) buildDualProjection(fields []*ast.SelectField) LogicalPlan {
+	optFlag := b.optFlag
+	dual := b.buildTableDual()
+	unfoldedFields := b.unfoldWildStar(dual, fields)
+	if b.err != nil {
+		return nil
+	}
+	p, _ := b.buildProjection(dual, unfoldedFields, nil)
+	if b.err != nil {
+		return nil
+	}
+	if p.Children()[0] == dual {
+		b.optFlag = optFlag
+	}
 	return p
 }
 
+// applyCardinalityHints overrides the statistics of the DataSource p by the TIDB_CARD hints for its table. The
+// histograms collected for another row count can't be scaled to the given one, so the estimates of a table with the
+// row count given are the pseudo ones based on it. A column NDV given only replaces the NDV of the column.
+func (b *planBuilder) applyCardinalityHints(p *DataSource, hints []*ast.TableOptimizerHint) {
+	alias := extractTableAlias(p)
+	for _, hint := range hints {
+		if alias == nil || hint.Tables[0].L != alias.L {
+			continue
+		}
+		if hint.Column.L == "" {
+			statisticTable := statistics.PseudoTable(p.tableInfo.ID)
+			statisticTable.Count = int64(hint.Cardinality)
+			p.statisticTable = statisticTable
+			continue
+		}
+		var col *model.ColumnInfo
+		for _, c := range p.tableInfo.Columns {
+			if c.Name.L == hint.Column.L {
+				col = c
+				break
+			}
+		}
+		if col == nil {
+			b.ctx.GetSessionVars().StmtCtx.AppendWarning(ErrUnknownColumn.GenByArgs(hint.Column.O, hint.HintName.O+" hint"))
+			continue
+		}
+		if p.ndvHints == nil {
+			p.ndvHints = make(map[int64]float64)
+		}
+		p.ndvHints[col.ID] = float64(hint.Cardinality)
+	}
+}
+
 func (b *planBuilder) buildTableDual() LogicalPlan {
 	dual := TableDual{RowCount: 1}.init(b.allocator, b.ctx)
 	dual.SetSchema(expression.NewSchema())
@@ -1159,33 +1905,70 @@ func (b *planBuilder) buildTableDual() LogicalPlan {
 }
 
 func (b *planBuilder) buildDataSource(tn *ast.TableName) LogicalPlan {
-	handle := sessionctx.GetDomain(b.ctx).StatsHandle()
-	var statisticTable *statistics.Table
-	if handle == nil {
-		// When the first session is created, the handle hasn't been initialized.
-		statisticTable = statistics.PseudoTable(tn.TableInfo.ID)
-	} else {
-		statisticTable = handle.GetTableStats(tn.TableInfo.ID)
-	}
-
 	schemaName := tn.Schema
 	if schemaName.L == "" {
 		schemaName = model.NewCIStr(b.ctx.GetSessionVars().CurrentDB)
 	}
-	tbl, err := b.is.TableByName(schemaName, tn.Name)
+	is, asOfTS := b.is, uint64(0)
+	if b.tableIS != nil {
+		is = b.tableIS
+	}
+	if tn.AsOf != nil {
+		var err error
+		asOfTS, is, err = getAsOfInfoSchema(b.ctx, tn.AsOf)
+		if err != nil {
+			b.err = errors.Trace(err)
+			return nil
+		}
+		if b.readCurrent || (b.asOfTS != 0 && b.asOfTS != asOfTS) {
+			b.err = ErrAsOfTimestampMixed
+			return nil
+		}
+		if b.needColHandle > 0 || b.inUpdateStmt {
+			b.err = ErrAsOfTimestampWrite.GenByArgs(tn.Name.O)
+			return nil
+		}
+		b.asOfTS = asOfTS
+	} else if b.asOfTS != 0 {
+		b.err = ErrAsOfTimestampMixed
+		return nil
+	} else {
+		b.readCurrent = true
+	}
+	tbl, err := is.TableByName(schemaName, tn.Name)
 	if err != nil {
+		if asOfTS != 0 && terror.ErrorEqual(err, infoschema.ErrTableNotExists) {
+			err = ErrAsOfTableNotExists.GenByArgs(schemaName.O, tn.Name.O, tn.AsOf.TsExpr.GetValue())
+		}
 		b.err = errors.Trace(err)
 		return nil
 	}
 	tableInfo := tbl.Meta()
+	// The statistics are looked up by the resolved table, which may be a hypothetical one not in the session's schema.
+	handle := sessionctx.GetDomain(b.ctx).StatsHandle()
+	var statisticTable *statistics.Table
+	if handle == nil {
+		// When the first session is created, the handle hasn't been initialized.
+		statisticTable = statistics.PseudoTable(tableInfo.ID)
+	} else {
+		statisticTable = handle.GetTableStats(tableInfo.ID)
+	}
+	// The handle column is read if the hidden `_rowid` is explicitly referenced, or for `*` if the rows are identified
+	// by the hidden `_rowid`, it's pruned if `*` isn't selected.
+	needColHandle := b.needColHandle > 0 || b.handleTables[tn] || (b.ctx.GetSessionVars().WildcardRowID && !tableInfo.PKIsHandle)
+	b.checkIndexHintConflicts(tn.IndexHints, tableInfo)
 
 	p := DataSource{
-		indexHints:     tn.IndexHints,
-		tableInfo:      tableInfo,
-		statisticTable: statisticTable,
-		DBName:         schemaName,
-		Columns:        make([]*model.ColumnInfo, 0, len(tableInfo.Columns)),
-		NeedColHandle:  b.needColHandle > 0,
+		indexHints:      indexHintsOfScope(tn.IndexHints, ast.HintForScan),
+		joinIndexHints:  indexHintsOfScope(tn.IndexHints, ast.HintForJoin),
+		orderIndexHints: indexHintsOfScope(tn.IndexHints, ast.HintForOrderBy),
+		groupIndexHints: indexHintsOfScope(tn.IndexHints, ast.HintForGroupBy),
+		tableInfo:       tableInfo,
+		statisticTable:  statisticTable,
+		DBName:          schemaName,
+		Columns:         make([]*model.ColumnInfo, 0, len(tableInfo.Columns)),
+		NeedColHandle:   needColHandle,
+		AsOfTS:          asOfTS,
 	}.init(b.allocator, b.ctx)
 	b.visitInfo = appendVisitInfo(b.visitInfo, mysql.SelectPriv, schemaName.L, tableInfo.Name.L, "")
 
@@ -1212,8 +1995,15 @@ func (b *planBuilder) buildDataSource(tn *ast.TableName) LogicalPlan {
 			pkCol = schema.Columns[schema.Len()-1]
 		}
 	}
-	needUnionScan := b.ctx.Txn() != nil && !b.ctx.Txn().IsReadOnly()
-	if b.needColHandle == 0 && !needUnionScan {
+	p.indexedGenCols = b.buildIndexedGenCols(tableInfo, columns, schema)
+	if b.err != nil {
+		return nil
+	}
+	if len(p.indexedGenCols) > 0 {
+		b.optFlag = b.optFlag | flagGcSubstitute
+	}
+	needUnionScan := asOfTS == 0 && b.needUnionScan(tableInfo.ID)
+	if !needColHandle && !needUnionScan {
 		p.SetSchema(schema)
 		return p
 	}
@@ -1234,7 +2024,7 @@ func (b *planBuilder) buildDataSource(tn *ast.TableName) LogicalPlan {
 			Index:    schema.Len(),
 			ID:       model.ExtraHandleID,
 		}
-		if needUnionScan && b.needColHandle > 0 {
+		if needUnionScan && needColHandle {
 			p.unionScanSchema.Columns = append(p.unionScanSchema.Columns, idCol)
 			p.unionScanSchema.TblID2Handle[tableInfo.ID] = []*expression.Column{idCol}
 		}
@@ -1245,7 +2035,7 @@ func (b *planBuilder) buildDataSource(tn *ast.TableName) LogicalPlan {
 		schema.Append(idCol)
 		schema.TblID2Handle[tableInfo.ID] = []*expression.Column{idCol}
 	} else {
-		if needUnionScan && b.needColHandle > 0 {
+		if needUnionScan && needColHandle {
 			p.unionScanSchema.TblID2Handle[tableInfo.ID] = []*expression.Column{pkCol}
 		}
 		schema.TblID2Handle[tableInfo.ID] = []*expression.Column{pkCol}
@@ -1254,6 +2044,66 @@ func (b *planBuilder) buildDataSource(tn *ast.TableName) LogicalPlan {
 	return p
 }
 
+// getAsOfInfoSchema returns the read timestamp of the AS OF TIMESTAMP clause and the information schema as of it.
+func getAsOfInfoSchema(ctx context.Context, asOf *ast.AsOfClause) (uint64, infoschema.InfoSchema, error) {
+	v, ok := asOf.TsExpr.(*ast.ValueExpr)
+	if !ok || v.Kind() != types.KindString {
+		return 0, nil, ErrAsOfTimestamp
+	}
+	t, err := types.ParseTime(v.GetString(), mysql.TypeTimestamp, types.MaxFsp)
+	if err != nil {
+		return 0, nil, errors.Trace(err)
+	}
+	goTime, err := t.Time.GoTime(ctx.GetSessionVars().GetTimeZone())
+	if err != nil {
+		return 0, nil, errors.Trace(err)
+	}
+	ts := varsutil.GoTimeToTS(goTime)
+	is, err := sessionctx.GetDomain(ctx).GetSnapshotInfoSchema(ts)
+	if err != nil {
+		return 0, nil, errors.Trace(err)
+	}
+	return ts, is, nil
+}
+
+// needUnionScan checks whether the table scan should be merged with the dirty data of the current transaction.
+// If tidb_opt_skip_row_handle is on, the table that is not modified in the transaction is read without union scan when
+// the statement never locks or modifies rows, so its handle column doesn't need to be materialized.
+func (b *planBuilder) needUnionScan(tableID int64) bool {
+	if b.ctx.Txn() == nil || b.ctx.Txn().IsReadOnly() {
+		return false
+	}
+	vars := b.ctx.GetSessionVars()
+	if !vars.AllowSkipRowHandle || b.needColHandle > 0 || b.inUpdateStmt {
+		return true
+	}
+	_, modified := vars.TxnCtx.TableDeltaMap[tableID]
+	return modified
+}
+
+// rowIDCollector collects the tables whose hidden `_rowid` is explicitly referenced by the statement, the handle
+// column of these tables must be read.
+type rowIDCollector struct {
+	tables map[*ast.TableName]bool
+}
+
+func (c *rowIDCollector) Enter(in ast.Node) (ast.Node, bool) {
+	if cn, ok := in.(*ast.ColumnNameExpr); ok && cn.Refer != nil && cn.Refer.Column.ID == model.ExtraHandleID {
+		c.tables[cn.Refer.TableName] = true
+	}
+	return in, false
+}
+
+func (c *rowIDCollector) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
+
+func collectRowIDTables(node ast.Node) map[*ast.TableName]bool {
+	c := &rowIDCollector{tables: make(map[*ast.TableName]bool)}
+	node.Accept(c)
+	return c.tables
+}
+
 // buildApplyWithJoinType builds apply plan with outerPlan and innerPlan, which apply join with particular join type for
 // every row from outerPlan and the whole innerPlan.
 func (b *planBuilder) buildApplyWithJoinType(outerPlan, innerPlan LogicalPlan, tp JoinType) LogicalPlan {
@@ -1270,6 +2120,7 @@ func (b *planBuilder) buildApplyWithJoinType(outerPlan, innerPlan LogicalPlan, t
 	for i := outerPlan.Schema().Len(); i < ap.Schema().Len(); i++ {
 		ap.schema.Columns[i].IsAggOrSubq = true
 	}
+	b.setApplyNoDecorrelate(ap)
 	return ap
 }
 
@@ -1285,9 +2136,27 @@ func (b *planBuilder) buildSemiApply(outerPlan, innerPlan LogicalPlan, condition
 	ap.self = ap
 	ap.children[0].SetParents(ap)
 	ap.children[1].SetParents(ap)
+	b.setApplyNoDecorrelate(ap)
 	return ap
 }
 
+// setApplyNoDecorrelate keeps the built apply correlated if it's hinted by NO_DECORRELATE or it's the apply kept by
+// OptimizeSubqueryAlternatives, and records it for OptimizeSubqueryAlternatives if it's correlated.
+func (b *planBuilder) setApplyNoDecorrelate(ap *LogicalApply) {
+	ap.noDecorrelate, b.noDecorrelate = b.noDecorrelate, false
+	b.applyCount++
+	if b.applyCount == b.keptApply {
+		ap.noDecorrelate = true
+	}
+	if b.subqueryAlternatives && !ap.noDecorrelate {
+		// An apply without correlated columns is always simplified to a join, it has only one form.
+		ap.extractCorColumnsBySchema()
+		if len(ap.corCols) > 0 {
+			b.correlatedApplies = append(b.correlatedApplies, b.applyCount)
+		}
+	}
+}
+
 func (b *planBuilder) buildExists(p LogicalPlan) LogicalPlan {
 out:
 	for {
@@ -1318,6 +2187,8 @@ out:
 	return exists
 }
 
+// buildMaxOneRow makes p return at most one row, or an error if it returns more.
+// If p returns no row, the MaxOneRow returns a row of NULLs.
 func (b *planBuilder) buildMaxOneRow(p LogicalPlan) LogicalPlan {
 	maxOneRow := MaxOneRow{}.init(b.allocator, b.ctx)
 	addChild(maxOneRow, p)
@@ -1325,6 +2196,39 @@ func (b *planBuilder) buildMaxOneRow(p LogicalPlan) LogicalPlan {
 	return maxOneRow
 }
 
+// buildOnceMaxOneRow is like buildMaxOneRow, but the MaxOneRow is always built and marked as Once, i.e. p returns the
+// same row for every outer row, so it's executed only once.
+func (b *planBuilder) buildOnceMaxOneRow(p LogicalPlan) LogicalPlan {
+	maxOneRow, ok := p.(*MaxOneRow)
+	if !ok {
+		maxOneRow = MaxOneRow{}.init(b.allocator, b.ctx)
+		addChild(maxOneRow, p)
+		maxOneRow.SetSchema(p.Schema().Clone())
+	}
+	maxOneRow.Once = true
+	return maxOneRow
+}
+
+// returnsMaxOneRow checks whether the built plan returns at most one row whatever the data is.
+func returnsMaxOneRow(p LogicalPlan) bool {
+	switch x := p.(type) {
+	case *MaxOneRow:
+		return true
+	case *LogicalAggregation:
+		return len(x.GroupByItems) == 0
+	case *TableDual:
+		return x.RowCount <= 1
+	case *Limit:
+		if x.Count <= 1 {
+			return true
+		}
+	case *Projection, *Selection, *Sort:
+	default:
+		return false
+	}
+	return returnsMaxOneRow(p.Children()[0].(LogicalPlan))
+}
+
 func (b *planBuilder) buildSemiJoin(outerPlan, innerPlan LogicalPlan, onCondition []expression.Expression, asScalar bool, not bool) *LogicalJoin {
 	joinPlan := LogicalJoin{}.init(b.allocator, b.ctx)
 	for i, expr := range onCondition {
@@ -1384,7 +2288,7 @@ func (b *planBuilder) buildUpdate(update *ast.UpdateStmt) LogicalPlan {
 		}
 	}
 	if sel.Limit != nil {
-		p = b.buildLimit(p, sel.Limit)
+		p = b.buildLimit(p, sel.Limit, sel.OrderBy, false)
 		if b.err != nil {
 			return nil
 		}
@@ -1408,6 +2312,17 @@ func (b *planBuilder) buildUpdateLists(tableList []*ast.TableName, list []*ast.A
 			b.err = errors.Trace(err)
 			return nil, nil
 		}
+		// The hidden `_rowid` column is only readable, the row handle can't be assigned.
+		if col.ID == model.ExtraHandleID {
+			b.err = ErrUnknownColumn.GenByArgs(assign.Column.Name.O, "field list")
+			return nil, nil
+		}
+		// The columns of a derived table are computed by its query, they can't be mapped back to the rows of the
+		// base tables.
+		if !isBaseTableColumn(p, col) {
+			b.err = ErrNonUpdatableTable.GenByArgs(col.TblName.O, "UPDATE")
+			return nil, nil
+		}
 		columnFullName := fmt.Sprintf("%s.%s.%s", col.DBName.L, col.TblName.L, col.ColName)
 		modifyColumns[columnFullName] = struct{}{}
 	}
@@ -1446,6 +2361,22 @@ func (b *planBuilder) buildUpdateLists(tableList []*ast.TableName, list []*ast.A
 	return newList, p
 }
 
+// isBaseTableColumn checks whether col is read from a table of the FROM clause built into p, rather than output by
+// a derived table.
+func isBaseTableColumn(p LogicalPlan, col *expression.Column) bool {
+	switch x := p.(type) {
+	case *DataSource:
+		return x.Schema().Contains(col)
+	case *LogicalJoin, *LogicalApply, *Selection, *Sort, *Limit:
+		for _, child := range p.Children() {
+			if isBaseTableColumn(child.(LogicalPlan), col) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (b *planBuilder) buildDelete(delete *ast.DeleteStmt) LogicalPlan {
 	b.needColHandle++
 	sel := &ast.SelectStmt{Fields: &ast.FieldList{}, From: delete.TableRefs, Where: delete.Where, OrderBy: delete.Order, Limit: delete.Limit}
@@ -1467,7 +2398,7 @@ func (b *planBuilder) buildDelete(delete *ast.DeleteStmt) LogicalPlan {
 		}
 	}
 	if sel.Limit != nil {
-		p = b.buildLimit(p, sel.Limit)
+		p = b.buildLimit(p, sel.Limit, sel.OrderBy, false)
 		if b.err != nil {
 			return nil
 		}
@@ -14,6 +14,7 @@
 package plan
 
 import (
+	"fmt"
 	"sort"
 	"testing"
 
@@ -510,6 +511,14 @@ func (s *testPlanSuite) TestPredicatePushDown(c *C) {
 			sql:  "select (select count(*) from t where t.a = k.a) from t k",
 			best: "Apply{DataScan(k)->DataScan(t)->Selection->Aggr(count(1))->Projection->MaxOneRow}->Projection",
 		},
+		{
+			sql:  "select a, (select sum(t.b + s.b) from t s) from t",
+			best: "Apply{DataScan(t)->DataScan(s)->Aggr(sum(plus(test.t.b, s.b)))->Projection->MaxOneRow}->Projection",
+		},
+		{
+			sql:  "select a, (select max(t.b) from t s) from t",
+			best: "Apply{DataScan(t)->DataScan(s)->Aggr(max(test.t.b))->Projection->MaxOneRow}->Projection",
+		},
 		{
 			sql:  "select a from t where exists(select 1 from t as x where x.a < t.a)",
 			best: "Join{DataScan(t)->DataScan(x)}->Projection",
@@ -622,6 +631,21 @@ func (s *testPlanSuite) TestPlanBuilder(c *C) {
 			sql:  "select (select count(1) k from t s where s.a = t.a having k != 0) from t",
 			plan: "Apply{DataScan(t)->DataScan(s)->Selection->Aggr(count(1))}->Projection->Projection",
 		},
+		{
+			// The hint keeps the correlated sub query as apply.
+			sql:  "select count(c) ,(select /*+ NO_DECORRELATE() */ b from t s where s.a = t.a) from t",
+			plan: "Apply{DataScan(t)->Aggr(count(test.t.c),firstrow(test.t.a))->DataScan(s)->Selection->Projection->MaxOneRow}->Projection",
+		},
+		{
+			// The hint only takes effect on the query block it is written in.
+			sql:  "select /*+ NO_DECORRELATE() */ * from t where 10 in (select b from t s where s.a = t.a)",
+			plan: "Join{DataScan(t)->DataScan(s)}(test.t.a,s.a)->Projection",
+		},
+		{
+			// The hint has no effect on the non-correlated sub query.
+			sql:  "select * from t where a in (select /*+ NO_DECORRELATE() */ s.b from t s where s.a = 1)",
+			plan: "Join{DataScan(t)->DataScan(s)->Selection->Projection}(test.t.a,s.b)->Projection",
+		},
 		{
 			sql:  "select a from t where a in (select a from t s group by t.b)",
 			plan: "Join{DataScan(t)->DataScan(s)->Aggr(firstrow(s.a))->Projection}(test.t.a,a)->Projection",
@@ -640,6 +664,15 @@ func (s *testPlanSuite) TestPlanBuilder(c *C) {
 			sql:  "select * from t where exists (select s.a from t s having sum(s.a) = t.a )",
 			plan: "Join{DataScan(t)->DataScan(s)->Aggr(sum(s.a))->Projection}->Projection",
 		},
+		{
+			// The correlated columns may only appear in the HAVING clause of the subquery.
+			sql:  "select * from t where exists (select s.a from t s group by s.b having count(*) > t.b and sum(s.c) = t.c)",
+			plan: "Join{DataScan(t)->DataScan(s)->Aggr(count(1),sum(s.c))->Projection}->Projection",
+		},
+		{
+			sql:  "select t.a, (select sum(s.c) from t s where s.b = 1 having sum(s.c) > t.b) from t",
+			plan: "Join{DataScan(t)->DataScan(s)->Selection->Aggr(sum(s.c))->Projection}->Projection->Projection",
+		},
 		{
 			// Test Nested sub query.
 			sql:  "select * from t where exists (select s.a from t s where s.c in (select c from t as k where k.d = s.d) having sum(s.a) = t.a )",
@@ -673,10 +706,32 @@ func (s *testPlanSuite) TestPlanBuilder(c *C) {
 			sql:  "do sleep(5)",
 			plan: "Dual->Projection",
 		},
+		{
+			sql:  "do 1 in (select a from t)",
+			plan: "Join{Dual->DataScan(t)->Projection}->Projection",
+		},
 		{
 			sql:  "select substr(\"abc\", 1)",
 			plan: "Dual->Projection",
 		},
+		{
+			// Constant false where is replaced by an empty dual.
+			sql:  "select a, b from t where 1 = 0 order by a limit 1",
+			plan: "Dual->Projection->Sort->Limit",
+		},
+		{
+			sql:  "select count(*) from t where a > 1 and 1 = 0",
+			plan: "Dual->Aggr(count(1))->Projection",
+		},
+		{
+			// Constant true where is eliminated.
+			sql:  "select * from t where 1 = 1",
+			plan: "DataScan(t)->Projection",
+		},
+		{
+			sql:  "select * from t where 1 = 1 and a > 1",
+			plan: "DataScan(t)->Selection->Projection",
+		},
 		{
 			sql:  "analyze table t, t",
 			plan: "*plan.Analyze",
@@ -705,6 +760,209 @@ func (s *testPlanSuite) TestPlanBuilder(c *C) {
 	}
 }
 
+func (s *testPlanSuite) TestResolveAuxiliaryFieldAlias(c *C) {
+	defer testleak.AfterTest(c)()
+	stmt, err := s.ParseOneStmt("select a as sel_agg_1, b from t group by a, b having sum(b) > 0 order by sel_agg_1", "", "")
+	c.Assert(err, IsNil)
+	sel := stmt.(*ast.SelectStmt)
+	// The auxiliary field of sum(b) is named sel_agg_2, append one named sel_agg_1 to collide with the alias of a.
+	fields := append(sel.Fields.Fields, &ast.SelectField{
+		Auxiliary: true,
+		Expr:      sel.Having.Expr.(*ast.BinaryOperationExpr).L,
+		AsName:    model.NewCIStr("sel_agg_1"),
+	}, &ast.SelectField{
+		Auxiliary: true,
+		Expr:      &ast.ColumnNameExpr{Name: &ast.ColumnName{Name: model.NewCIStr("c")}},
+		AsName:    model.NewCIStr("sel_agg_1"),
+	})
+	col := sel.OrderBy.Items[0].Expr.(*ast.ColumnNameExpr)
+	index, err := resolveFromSelectFields(col, fields, false)
+	c.Assert(err, IsNil)
+	c.Assert(index, Equals, 0)
+	index, err = resolveFromSelectFields(col, fields, true)
+	c.Assert(err, IsNil)
+	c.Assert(index, Equals, -1)
+}
+
+func (s *testPlanSuite) TestAuxiliaryAggTrimmed(c *C) {
+	defer testleak.AfterTest(c)()
+	tests := []struct {
+		sql  string
+		cols []string
+	}{
+		{
+			sql:  "select a from t group by a order by count(*)",
+			cols: []string{"a"},
+		},
+		{
+			sql:  "select a from t group by a having sum(b) > 0 order by count(*), max(c)",
+			cols: []string{"a"},
+		},
+		{
+			sql:  "select a, count(*) from t group by a order by count(*), sum(b) desc",
+			cols: []string{"a", "count(*)"},
+		},
+		{
+			sql:  "select * from t group by a order by count(*) limit 1",
+			cols: []string{"a", "b", "c", "d", "e", "c_str", "d_str", "e_str", "f", "g"},
+		},
+		{
+			sql:  "select a from t group by a order by count(*) union all select b from t",
+			cols: []string{"a"},
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := MockResolve(stmt)
+		c.Assert(err, IsNil)
+
+		builder := &planBuilder{
+			allocator: new(idAllocator),
+			ctx:       mockContext(),
+			colMapper: make(map[*ast.ColumnNameExpr]int),
+			is:        is,
+		}
+		p := builder.build(stmt)
+		c.Assert(builder.err, IsNil, comment)
+		cols := make([]string, 0, p.Schema().Len())
+		for _, col := range p.Schema().Columns {
+			cols = append(cols, col.ColName.L)
+		}
+		c.Assert(cols, DeepEquals, tt.cols, comment)
+	}
+}
+
+func (s *testPlanSuite) TestHavingAndOrderBySameAgg(c *C) {
+	defer testleak.AfterTest(c)()
+	tests := []struct {
+		sql     string
+		plan    string
+		aggCols string
+	}{
+		{
+			sql:     "select a from t group by a having sum(b) > 0 order by sum(b)",
+			plan:    "DataScan(t)->Aggr(sum(test.t.b),firstrow(test.t.a),firstrow(test.t.b),firstrow(test.t.c),firstrow(test.t.d),firstrow(test.t.e),firstrow(test.t.c_str),firstrow(test.t.d_str),firstrow(test.t.e_str),firstrow(test.t.f),firstrow(test.t.g))->Projection->Selection->Sort->Projection",
+			aggCols: "[aggregation_2_col_0]",
+		},
+		{
+			sql:     "select a from t group by a having sum(b) > 0 and count(*) > 1 order by count(*), sum(b) desc",
+			plan:    "DataScan(t)->Aggr(sum(test.t.b),count(1),firstrow(test.t.a),firstrow(test.t.b),firstrow(test.t.c),firstrow(test.t.d),firstrow(test.t.e),firstrow(test.t.c_str),firstrow(test.t.d_str),firstrow(test.t.e_str),firstrow(test.t.f),firstrow(test.t.g))->Projection->Selection->Sort->Projection",
+			aggCols: "[aggregation_2_col_0 aggregation_2_col_1]",
+		},
+		{
+			sql:     "select a, sum(b) from t group by a having sum(b) > 0 order by sum(b)",
+			plan:    "DataScan(t)->Aggr(sum(test.t.b),firstrow(test.t.a),firstrow(test.t.b),firstrow(test.t.c),firstrow(test.t.d),firstrow(test.t.e),firstrow(test.t.c_str),firstrow(test.t.d_str),firstrow(test.t.e_str),firstrow(test.t.f),firstrow(test.t.g))->Projection->Selection->Sort->Projection",
+			aggCols: "[aggregation_2_col_0]",
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := MockResolve(stmt)
+		c.Assert(err, IsNil)
+
+		builder := &planBuilder{
+			allocator: new(idAllocator),
+			ctx:       mockContext(),
+			colMapper: make(map[*ast.ColumnNameExpr]int),
+			is:        is,
+		}
+		p := builder.build(stmt).(LogicalPlan)
+		c.Assert(builder.err, IsNil, comment)
+		c.Assert(ToString(p), Equals, tt.plan, comment)
+		// The HAVING and ORDER BY items are built on the projection of the auxiliary fields, they must be
+		// projected from the same aggregation output.
+		srt := p.Children()[0].(*Sort)
+		sel := srt.Children()[0].(*Selection)
+		proj := sel.Children()[0].(*Projection)
+		aggCols := func(exprs []expression.Expression) string {
+			var cols []string
+			for _, expr := range exprs {
+				for _, col := range expression.ExtractColumns(expr) {
+					cols = append(cols, proj.Exprs[proj.Schema().ColumnIndex(col)].String())
+				}
+			}
+			sort.Strings(cols)
+			return fmt.Sprint(cols)
+		}
+		byItems := make([]expression.Expression, 0, len(srt.ByItems))
+		for _, item := range srt.ByItems {
+			byItems = append(byItems, item.Expr)
+		}
+		c.Assert(aggCols(sel.Conditions), Equals, tt.aggCols, comment)
+		c.Assert(aggCols(byItems), Equals, tt.aggCols, comment)
+	}
+}
+
+// TestGroupByAndOrderByAliases checks that the aliases of the same column in GROUP BY and ORDER BY are resolved to
+// the same grouping column.
+func (s *testPlanSuite) TestGroupByAndOrderByAliases(c *C) {
+	defer testleak.AfterTest(c)()
+	tests := []struct {
+		sql  string
+		plan string
+	}{
+		{
+			sql:  "select a as x, a as y from t group by x order by y",
+			plan: "DataScan(t)->Aggr(firstrow(test.t.a),firstrow(test.t.b),firstrow(test.t.c),firstrow(test.t.d),firstrow(test.t.e),firstrow(test.t.c_str),firstrow(test.t.d_str),firstrow(test.t.e_str),firstrow(test.t.f),firstrow(test.t.g))->Projection->Sort",
+		},
+		{
+			sql:  "select a as x, a as y from t group by y order by x desc",
+			plan: "DataScan(t)->Aggr(firstrow(test.t.a),firstrow(test.t.b),firstrow(test.t.c),firstrow(test.t.d),firstrow(test.t.e),firstrow(test.t.c_str),firstrow(test.t.d_str),firstrow(test.t.e_str),firstrow(test.t.f),firstrow(test.t.g))->Projection->Sort",
+		},
+		{
+			sql:  "select a as x, a as y, count(*) from t group by x order by y desc, x",
+			plan: "DataScan(t)->Aggr(count(1),firstrow(test.t.a),firstrow(test.t.b),firstrow(test.t.c),firstrow(test.t.d),firstrow(test.t.e),firstrow(test.t.c_str),firstrow(test.t.d_str),firstrow(test.t.e_str),firstrow(test.t.f),firstrow(test.t.g))->Projection->Sort",
+		},
+		{
+			sql:  "select a as x, a as y from t group by x having y > 1 order by y",
+			plan: "DataScan(t)->Aggr(firstrow(test.t.a),firstrow(test.t.b),firstrow(test.t.c),firstrow(test.t.d),firstrow(test.t.e),firstrow(test.t.c_str),firstrow(test.t.d_str),firstrow(test.t.e_str),firstrow(test.t.f),firstrow(test.t.g))->Projection->Selection->Sort",
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := MockResolve(stmt)
+		c.Assert(err, IsNil)
+
+		builder := &planBuilder{
+			allocator: new(idAllocator),
+			ctx:       mockContext(),
+			colMapper: make(map[*ast.ColumnNameExpr]int),
+			is:        is,
+		}
+		p := builder.build(stmt).(LogicalPlan)
+		c.Assert(builder.err, IsNil, comment)
+		c.Assert(ToString(p), Equals, tt.plan, comment)
+
+		sort := p.(*Sort)
+		var proj *Projection
+		for child := sort.Children()[0]; proj == nil; child = child.Children()[0] {
+			proj, _ = child.(*Projection)
+		}
+		agg := proj.Children()[0].(*LogicalAggregation)
+		// Both aliases are grouped by the only grouping column test.t.a, and every ORDER BY item refers to its value.
+		c.Assert(agg.GroupByItems, HasLen, 1, comment)
+		c.Assert(agg.GroupByItems[0].String(), Equals, "test.t.a", comment)
+		for _, item := range sort.ByItems {
+			col, ok := item.Expr.(*expression.Column)
+			c.Assert(ok, IsTrue, comment)
+			projCol, ok := proj.Exprs[proj.Schema().ColumnIndex(col)].(*expression.Column)
+			c.Assert(ok, IsTrue, comment)
+			aggFunc := agg.AggFuncs[agg.Schema().ColumnIndex(projCol)]
+			c.Assert(aggFunc.GetName(), Equals, ast.AggFuncFirstRow, comment)
+			c.Assert(aggFunc.GetArgs()[0].Equal(agg.GroupByItems[0], builder.ctx), IsTrue, comment)
+		}
+	}
+}
+
 func (s *testPlanSuite) TestJoinReOrder(c *C) {
 	defer testleak.AfterTest(c)()
 	tests := []struct {
@@ -759,6 +1017,64 @@ func (s *testPlanSuite) TestJoinReOrder(c *C) {
 	}
 }
 
+func (s *testPlanSuite) TestReorderExplicitCrossJoin(c *C) {
+	defer testleak.AfterTest(c)()
+	tests := []struct {
+		sql      string
+		implicit bool
+	}{
+		{
+			sql:      "select * from t cross join t t2",
+			implicit: false,
+		},
+		{
+			sql:      "select t.a from t cross join t t2",
+			implicit: false,
+		},
+		{
+			sql:      "select * from t cross join t t2 cross join t t3",
+			implicit: false,
+		},
+		{
+			sql:      "select * from t, t t2 cross join t t3 where t.a = t2.a",
+			implicit: false,
+		},
+		{
+			sql:      "select * from t, t t2",
+			implicit: true,
+		},
+		{
+			sql:      "select * from t cross join t t2, t t3",
+			implicit: true,
+		},
+		{
+			sql:      "select * from t cross join t t2, t t3 where t2.a = t3.a",
+			implicit: false,
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := MockResolve(stmt)
+		c.Assert(err, IsNil)
+
+		builder := &planBuilder{
+			allocator: new(idAllocator),
+			ctx:       mockContext(),
+			colMapper: make(map[*ast.ColumnNameExpr]int),
+			is:        is,
+		}
+		p := builder.build(stmt)
+		c.Assert(builder.err, IsNil)
+		// The mock client doesn't support DAG requests, so the joins are reordered.
+		lp, err := logicalOptimize(flagPredicatePushDown|flagPrunColumns, p.(LogicalPlan), builder.ctx, builder.allocator)
+		c.Assert(err, IsNil)
+		c.Assert(existsImplicitCartesianJoin(lp), Equals, tt.implicit, comment)
+	}
+}
+
 func (s *testPlanSuite) TestAggPushDown(c *C) {
 	defer testleak.AfterTest(c)()
 	tests := []struct {
@@ -1244,6 +1560,57 @@ func checkDataSourceCols(p Plan, c *C, ans map[string][]string, comment CommentI
 	}
 }
 
+func (s *testPlanSuite) TestSubqueryDepth(c *C) {
+	defer testleak.AfterTest(c)()
+	tests := []struct {
+		sql string
+		err bool
+	}{
+		{"select a from (select a from (select a from t) s1) s2", false},
+		{"select a from (select a from (select a from (select a from t) s1) s2) s3", true},
+		{"select a from t where a in (select a from t s1 where b > (select max(b) from t s2 where s2.a = s1.a))", false},
+		{"select a from t where a in (select a from t s1 where b > (select max(b) from t s2 where s2.a = s1.a and c in (select c from t)))", true},
+		{"select (select a from (select a from t) s1 where s1.a = t.a) from t", false},
+		{"select (select a from (select a from (select a from t) s1) s2 where s2.a = t.a) from t", true},
+		// The SELECTs of a UNION are at the same level.
+		{"select a from (select a from t union select a from t union select b from t) s1", false},
+		{"select a from (select a from (select a from t union select a from t) s1 union select b from t) s2", false},
+		{"select a from (select a from t union select a from (select a from (select a from t) s1) s2) s3", true},
+		// The parenthesized joins on the right are nesting levels, the left-deep joins aren't.
+		{"select t.a from t, t s1, t s2, t s3, t s4", false},
+		{"select t.a from t join t s1 on t.a = s1.a join t s2 on s1.a = s2.a join t s3 on s2.a = s3.a", false},
+		{"select t.a from t join (t s1 join t s2 on s1.a = s2.a) on t.a = s1.a", false},
+		{"select t.a from t join (t s1 join (t s2 join t s3 on s2.a = s3.a) on s1.a = s2.a) on t.a = s1.a", false},
+		{"select t.a from t join (t s1 join (t s2 join (t s3 join t s4 on s3.a = s4.a) on s2.a = s3.a) on s1.a = s2.a) on t.a = s1.a", true},
+		{"select a from (select t.a from t join (t s1 join t s2 on s1.a = s2.a) on t.a = s1.a) s3", false},
+		{"select a from (select t.a from t join (t s1 join (t s2 join t s3 on s2.a = s3.a) on s1.a = s2.a) on t.a = s1.a) s4", true},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := MockResolve(stmt)
+		c.Assert(err, IsNil, comment)
+
+		ctx := mockContext()
+		ctx.GetSessionVars().MaxSubqueryDepth = 3
+		builder := &planBuilder{
+			allocator: new(idAllocator),
+			ctx:       ctx,
+			colMapper: make(map[*ast.ColumnNameExpr]int),
+			is:        is,
+		}
+		builder.build(stmt)
+		if tt.err {
+			c.Assert(ErrTooHighLevelOfNesting.Equal(builder.err), IsTrue, comment)
+		} else {
+			c.Assert(builder.err, IsNil, comment)
+			c.Assert(builder.nestingDepth, Equals, 0, comment)
+		}
+	}
+}
+
 func (s *testPlanSuite) TestValidate(c *C) {
 	defer testleak.AfterTest(c)()
 	tests := []struct {
@@ -1342,6 +1709,22 @@ func (s *testPlanSuite) TestValidate(c *C) {
 			sql: "insert into t set a = 1, b = values(a) + 1",
 			err: nil,
 		},
+		{
+			sql: "insert into t (a, b) values (1, 2), (3, 4)",
+			err: nil,
+		},
+		{
+			sql: "insert into t (a, b) values (1, 2), (3)",
+			err: ErrWrongValueCountOnRow,
+		},
+		{
+			sql: "insert into t (a) values ()",
+			err: ErrWrongValueCountOnRow,
+		},
+		{
+			sql: "insert into t (a) values (1, 2)",
+			err: ErrWrongValueCountOnRow,
+		},
 	}
 	for _, tt := range tests {
 		sql := tt.sql
@@ -1365,6 +1748,65 @@ func (s *testPlanSuite) TestValidate(c *C) {
 	}
 }
 
+func (s *testPlanSuite) TestIllegalJoinCondition(c *C) {
+	defer testleak.AfterTest(c)()
+	using := []*ast.ColumnName{{Name: model.NewCIStr("a")}}
+	tests := []struct {
+		sql    string
+		modify func(join *ast.Join)
+		err    *terror.Error
+	}{
+		{
+			sql:    "select * from t t1 join t t2 on t1.a = t2.a",
+			modify: func(join *ast.Join) { join.Using = using },
+			err:    ErrIllegalJoinCondition,
+		},
+		{
+			sql:    "select * from t t1 natural join t t2",
+			modify: func(join *ast.Join) { join.Using = using },
+			err:    ErrIllegalJoinCondition,
+		},
+		{
+			sql:    "select * from t t1 join t t2 on t1.a = t2.a",
+			modify: func(join *ast.Join) { join.NaturalJoin = true },
+			err:    ErrIllegalJoinCondition,
+		},
+		{
+			sql:    "select * from t t1 cross join t t2 using (a)",
+			modify: func(join *ast.Join) { join.ExplicitCross = true },
+			err:    ErrIllegalJoinCondition,
+		},
+		{
+			sql:    "select * from t t1 cross join t t2 using (a)",
+			modify: func(join *ast.Join) {},
+		},
+		{
+			sql:    "select * from t t1 cross join t t2 on t1.a = t2.a",
+			modify: func(join *ast.Join) {},
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+		is, err := MockResolve(stmt)
+		c.Assert(err, IsNil)
+		tt.modify(stmt.(*ast.SelectStmt).From.TableRefs)
+		builder := &planBuilder{
+			allocator: new(idAllocator),
+			ctx:       mockContext(),
+			colMapper: make(map[*ast.ColumnNameExpr]int),
+			is:        is,
+		}
+		builder.build(stmt)
+		if tt.err == nil {
+			c.Assert(builder.err, IsNil, comment)
+		} else {
+			c.Assert(tt.err.Equal(builder.err), IsTrue, comment)
+		}
+	}
+}
+
 func checkUniqueKeys(p Plan, c *C, ans map[string][][]string, sql string) {
 	keyList, ok := ans[p.ID()]
 	c.Assert(ok, IsTrue, Commentf("for %s, %v not found", sql, p.ID()))
@@ -1446,6 +1888,18 @@ func (s *testPlanSuite) TestUniqueKeyInfo(c *C) {
 				"Projection_4": {{"t1.f"}, {"t1.g"}, {"t1.f", "t1.g"}, {"t1.a"}},
 			},
 		},
+		{
+			// The distinct output of a set operation is unique on all its columns.
+			sql: "select b, c from t union select b, c from t",
+			ans: map[string][][]string{
+				"TableScan_2":   {},
+				"Projection_3":  {},
+				"TableScan_4":   {},
+				"Projection_5":  {},
+				"Union_1":       {},
+				"Aggregation_6": {{"b", "c"}},
+			},
+		},
 	}
 	for _, tt := range tests {
 		comment := Commentf("for %s", tt.sql)
@@ -1529,6 +1983,21 @@ func (s *testPlanSuite) TestVisitInfo(c *C) {
 				{mysql.InsertPriv, "test", "t", ""},
 			},
 		},
+		{
+			sql: "replace into t (a) values (1)",
+			ans: []visitInfo{
+				{mysql.InsertPriv, "test", "t", ""},
+				{mysql.DeletePriv, "test", "t", ""},
+			},
+		},
+		{
+			sql: "replace into t select * from t",
+			ans: []visitInfo{
+				{mysql.InsertPriv, "test", "t", ""},
+				{mysql.DeletePriv, "test", "t", ""},
+				{mysql.SelectPriv, "test", "t", ""},
+			},
+		},
 		{
 			sql: "delete from t where a = 1",
 			ans: []visitInfo{
@@ -1853,3 +2322,303 @@ func (s *testPlanSuite) TestTopNPushDown(c *C) {
 		c.Assert(ToString(p), Equals, tt.best, comment)
 	}
 }
+
+func (s *testPlanSuite) TestConstantFold(c *C) {
+	defer testleak.AfterTest(c)()
+	tests := []struct {
+		sql   string
+		conds string
+	}{
+		{
+			sql:   "select * from t where a = 2+3",
+			conds: "[eq(test.t.a, 5)]",
+		},
+		{
+			sql:   "select * from t where a > 1 * (4 - 2) and b < abs(-3)",
+			conds: "[gt(test.t.a, 2) lt(test.t.b, 3)]",
+		},
+		{
+			// NOW() is fixed for the statement, so it's folded.
+			sql:   "select * from t where a < year(now()) - year(now())",
+			conds: "[lt(test.t.a, 0)]",
+		},
+		{
+			sql:   "select * from t where a < unix_timestamp(sysdate())",
+			conds: "[lt(test.t.a, unix_timestamp(sysdate()))]",
+		},
+		{
+			sql:   "select * from t where a > rand() + 1",
+			conds: "[gt(cast(test.t.a), plus(rand(), 1))]",
+		},
+		{
+			sql:   "select * from t where a = @a + 1",
+			conds: "[eq(cast(test.t.a), plus(cast(getvar(a)), 1))]",
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := MockResolve(stmt)
+		c.Assert(err, IsNil)
+
+		builder := &planBuilder{
+			allocator: new(idAllocator),
+			ctx:       mockContext(),
+			is:        is,
+			colMapper: make(map[*ast.ColumnNameExpr]int),
+		}
+		p := builder.build(stmt).(LogicalPlan)
+		c.Assert(builder.err, IsNil)
+		sel, ok := p.Children()[0].(*Selection)
+		c.Assert(ok, IsTrue, comment)
+		c.Assert(fmt.Sprintf("%s", sel.Conditions), Equals, tt.conds, comment)
+	}
+}
+
+func (s *testPlanSuite) TestDistinctElimination(c *C) {
+	defer testleak.AfterTest(c)()
+	tests := []struct {
+		sql  string
+		best string
+	}{
+		{
+			sql:  "select distinctrow b from t",
+			best: "DataScan(t)->Projection->Aggr(firstrow(b))",
+		},
+		{
+			sql:  "select distinct b from t",
+			best: "DataScan(t)->Projection->Aggr(firstrow(b))",
+		},
+		{
+			sql:  "select distinctrow a, b from t",
+			best: "DataScan(t)->Projection->Projection",
+		},
+		{
+			sql:  "select distinct b, count(*) from t group by b",
+			best: "DataScan(t)->Aggr(count(1),firstrow(test.t.b))->Projection->Projection",
+		},
+		{
+			sql:  "select distinct count(*) from t group by b",
+			best: "DataScan(t)->Aggr(count(1))->Projection->Aggr(firstrow(count(*)))",
+		},
+		// The aggregation that isn't a DISTINCT is kept without the aggregation push down.
+		{
+			sql:  "select a, count(b) from t group by a",
+			best: "DataScan(t)->Aggr(count(test.t.b),firstrow(test.t.a))->Projection",
+		},
+		{
+			sql:  "select group_concat(b, c) from t group by a",
+			best: "DataScan(t)->Aggr(group_concat(test.t.b, test.t.c))->Projection",
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := MockResolve(stmt)
+		c.Assert(err, IsNil)
+
+		ctx := mockContext()
+		ctx.GetSessionVars().AllowAggPushDown = false
+		builder := &planBuilder{
+			allocator: new(idAllocator),
+			ctx:       ctx,
+			is:        is,
+		}
+		p := builder.build(stmt).(LogicalPlan)
+		c.Assert(builder.err, IsNil)
+		p, err = logicalOptimize(builder.optFlag, p.(LogicalPlan), builder.ctx, builder.allocator)
+		c.Assert(err, IsNil)
+		c.Assert(ToString(p), Equals, tt.best, comment)
+	}
+}
+
+func (s *testPlanSuite) TestDualSelect(c *C) {
+	defer testleak.AfterTest(c)()
+	tests := []struct {
+		sql   string
+		plan  string
+		names string
+		// noOpt means no optimizing rule is needed other than column pruning.
+		noOpt bool
+	}{
+		{
+			sql:   "select 1",
+			plan:  "Dual->Projection",
+			names: "[1]",
+			noOpt: true,
+		},
+		{
+			sql:   "select 1+1, now(), @a",
+			plan:  "Dual->Projection",
+			names: "[1+1 now() @a]",
+			noOpt: true,
+		},
+		{
+			sql:   "select 1 as x, concat('a', 'b') y",
+			plan:  "Dual->Projection",
+			names: "[x y]",
+			noOpt: true,
+		},
+		{
+			sql:   "select 1 from dual where 1 = 1",
+			plan:  "Dual->Projection",
+			names: "[1]",
+			noOpt: false,
+		},
+		{
+			sql:   "select count(1)",
+			plan:  "Dual->Aggr(count(1))->Projection",
+			names: "[count(1)]",
+			noOpt: false,
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := MockResolve(stmt)
+		c.Assert(err, IsNil)
+
+		builder := &planBuilder{
+			allocator: new(idAllocator),
+			ctx:       mockContext(),
+			is:        is,
+			colMapper: make(map[*ast.ColumnNameExpr]int),
+		}
+		p := builder.build(stmt).(LogicalPlan)
+		c.Assert(builder.err, IsNil, comment)
+		names := make([]string, 0, p.Schema().Len())
+		for _, col := range p.Schema().Columns {
+			names = append(names, col.ColName.O)
+		}
+		c.Check(ToString(p), Equals, tt.plan, comment)
+		c.Check(fmt.Sprint(names), Equals, tt.names, comment)
+		c.Check(builder.optFlag == flagPrunColumns, Equals, tt.noOpt, comment)
+	}
+}
+
+func (s *testPlanSuite) TestTableStmt(c *C) {
+	defer testleak.AfterTest(c)()
+	tests := []struct {
+		modify func(sel *ast.SelectStmt)
+		plan   string
+		err    string
+	}{
+		{
+			modify: func(sel *ast.SelectStmt) {},
+			plan:   "DataScan(t)->Projection->Sort->Limit",
+		},
+		{
+			modify: func(sel *ast.SelectStmt) { sel.Where = ast.NewValueExpr(1) },
+			err:    "[plan:14]TABLE statement doesn't support WHERE, use SELECT * FROM instead",
+		},
+		{
+			modify: func(sel *ast.SelectStmt) { sel.GroupBy = &ast.GroupByClause{} },
+			err:    "[plan:14]TABLE statement doesn't support GROUP BY, use SELECT * FROM instead",
+		},
+		{
+			modify: func(sel *ast.SelectStmt) { sel.Distinct = true },
+			err:    "[plan:14]TABLE statement doesn't support DISTINCT, use SELECT * FROM instead",
+		},
+		{
+			modify: func(sel *ast.SelectStmt) { sel.LockTp = ast.SelectLockForUpdate },
+			err:    "[plan:14]TABLE statement doesn't support locking read, use SELECT * FROM instead",
+		},
+	}
+	for i, tt := range tests {
+		comment := Commentf("for %d", i)
+		stmt, err := s.ParseOneStmt("table t order by b limit 2", "", "")
+		c.Assert(err, IsNil, comment)
+		sel := stmt.(*ast.SelectStmt)
+		c.Assert(sel.IsTable, IsTrue, comment)
+		tt.modify(sel)
+
+		is, err := MockResolve(stmt)
+		c.Assert(err, IsNil)
+
+		builder := &planBuilder{
+			allocator: new(idAllocator),
+			ctx:       mockContext(),
+			is:        is,
+			colMapper: make(map[*ast.ColumnNameExpr]int),
+		}
+		p := builder.build(stmt)
+		if tt.err != "" {
+			c.Assert(ErrTableStmtClause.Equal(builder.err), IsTrue, comment)
+			c.Assert(builder.err.Error(), Equals, tt.err, comment)
+			continue
+		}
+		c.Assert(builder.err, IsNil, comment)
+		c.Assert(ToString(p), Equals, tt.plan, comment)
+	}
+}
+
+func (s *testPlanSuite) TestReuseProjectedByItems(c *C) {
+	defer testleak.AfterTest(c)()
+	tests := []struct {
+		sql     string
+		byItems string
+		exprs   string
+	}{
+		{
+			sql:     "select a+b as s from t order by a+b",
+			byItems: "[s]",
+			exprs:   "[plus(test.t.a, test.t.b)]",
+		},
+		{
+			sql:     "select a+b from t order by a+b desc, c",
+			byItems: "[a+b true t.c]",
+			exprs:   "[plus(test.t.a, test.t.b) test.t.c]",
+		},
+		{
+			sql:     "select a+b as s, count(*) from t group by a+b having count(*) > 1 order by a+b",
+			byItems: "[s]",
+			exprs:   "[plus(test.t.a, test.t.b) aggregation_2_col_0 aggregation_2_col_0]",
+		},
+		{
+			sql:     "select a+b from t order by b+a",
+			byItems: "[plus(t.b, t.a)]",
+			exprs:   "[plus(test.t.a, test.t.b) test.t.b test.t.a]",
+		},
+		{
+			sql:     "select a+rand() from t order by a+rand()",
+			byItems: "[plus(cast(t.a), rand())]",
+			exprs:   "[plus(cast(test.t.a), rand()) test.t.a]",
+		},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.sql)
+		stmt, err := s.ParseOneStmt(tt.sql, "", "")
+		c.Assert(err, IsNil, comment)
+
+		is, err := MockResolve(stmt)
+		c.Assert(err, IsNil)
+
+		builder := &planBuilder{
+			allocator: new(idAllocator),
+			ctx:       mockContext(),
+			is:        is,
+			colMapper: make(map[*ast.ColumnNameExpr]int),
+		}
+		p := builder.build(stmt).(LogicalPlan)
+		c.Assert(builder.err, IsNil, comment)
+		p, err = logicalOptimize(builder.optFlag, p, builder.ctx, builder.allocator)
+		c.Assert(err, IsNil, comment)
+		var sort *Sort
+		for sort == nil {
+			sort, _ = p.(*Sort)
+			p = p.Children()[0].(LogicalPlan)
+		}
+		for _, ok := p.(*Projection); !ok; _, ok = p.(*Projection) {
+			p = p.Children()[0].(LogicalPlan)
+		}
+		// The columns only used by the replaced items are pruned from the projection.
+		c.Assert(fmt.Sprintf("%s", sort.ByItems), Equals, tt.byItems, comment)
+		c.Assert(fmt.Sprintf("%s", p.(*Projection).Exprs), Equals, tt.exprs, comment)
+	}
+}
@@ -89,6 +89,7 @@ type LogicalJoin struct {
 	anti            bool
 	reordered       bool
 	cartesianJoin   bool
+	explicitCross   bool
 	preferINLJ      int
 	preferMergeJoin bool
 
@@ -231,6 +232,8 @@ type LogicalApply struct {
 	LogicalJoin
 
 	corCols []*expression.CorrelatedColumn
+	// noDecorrelate means the inner plan is hinted to be kept as a correlated nested loop.
+	noDecorrelate bool
 }
 
 func (p *LogicalApply) extractCorrelatedCols() []*expression.CorrelatedColumn {
@@ -255,6 +258,10 @@ type MaxOneRow struct {
 	*basePlan
 	baseLogicalPlan
 	basePhysicalPlan
+
+	// Once means the row is the same for every execution, e.g. the row of an uncorrelated scalar subquery, so the
+	// child is executed only once and the row is reused.
+	Once bool
 }
 
 // TableDual represents a dual table plan.
@@ -271,10 +278,16 @@ type DataSource struct {
 	*basePlan
 	baseLogicalPlan
 
+	// indexHints are the index hints without a scope, they apply to all the purposes of reading the table.
 	indexHints []*ast.IndexHint
-	tableInfo  *model.TableInfo
-	Columns    []*model.ColumnInfo
-	DBName     model.CIStr
+	// joinIndexHints, orderIndexHints and groupIndexHints are the index hints FOR JOIN, FOR ORDER BY and FOR GROUP BY,
+	// see indexHintsFor.
+	joinIndexHints  []*ast.IndexHint
+	orderIndexHints []*ast.IndexHint
+	groupIndexHints []*ast.IndexHint
+	tableInfo       *model.TableInfo
+	Columns         []*model.ColumnInfo
+	DBName          model.CIStr
 
 	TableAsName *model.CIStr
 
@@ -287,6 +300,15 @@ type DataSource struct {
 
 	// NeedColHandle is used in execution phase.
 	NeedColHandle bool
+	// AsOfTS is the read timestamp of the AS OF TIMESTAMP clause, 0 if the table is read at the current time.
+	AsOfTS uint64
+	// preferIndexOnly means the table is read by covering index scans only, it's set by the USE_INDEX_ONLY hint.
+	preferIndexOnly bool
+	// ndvHints is the column NDVs given by the TIDB_CARD hints, which override the ones of statisticTable.
+	ndvHints map[int64]float64
+	// indexedGenCols are the indexed stored generated columns and their generation expressions, which can substitute the
+	// expressions in the filters, see gcSubstituter.
+	indexedGenCols []*expression.Assignment
 
 	// This is schema the PhysicalUnionScan should be.
 	unionScanSchema *expression.Schema
@@ -304,6 +326,105 @@ func (p *DataSource) getPKIsHandleCol() *expression.Column {
 	return nil
 }
 
+// indexHintsFor returns the index hints applied when the DataSource is read for the purpose of scope, e.g. the order
+// required by ORDER BY is for HintForOrderBy. Like MySQL, the FOR JOIN hints also apply to finding the rows.
+func (p *DataSource) indexHintsFor(scope ast.IndexHintScope) []*ast.IndexHint {
+	var scopeHints []*ast.IndexHint
+	switch scope {
+	case ast.HintForScan, ast.HintForJoin:
+		scopeHints = p.joinIndexHints
+	case ast.HintForOrderBy:
+		scopeHints = p.orderIndexHints
+	case ast.HintForGroupBy:
+		scopeHints = p.groupIndexHints
+	}
+	if len(scopeHints) == 0 {
+		return p.indexHints
+	}
+	hints := make([]*ast.IndexHint, 0, len(p.indexHints)+len(scopeHints))
+	hints = append(hints, p.indexHints...)
+	return append(hints, scopeHints...)
+}
+
+// availableIndices returns the indices can be used to read the DataSource for the purpose of scope and whether the
+// table scan can be used. If the DataSource prefers index only, they are limited to the indices covering all the
+// columns of it.
+func (p *DataSource) availableIndices(scope ast.IndexHintScope) ([]*model.IndexInfo, bool, error) {
+	indices, includeTableScan := availableIndices(p.indexHintsFor(scope), p.tableInfo)
+	if !p.preferIndexOnly {
+		return indices, includeTableScan, nil
+	}
+	coveringIndices := make([]*model.IndexInfo, 0, len(indices))
+	for _, idx := range indices {
+		if isCoveringIndex(p.Columns, idx.Columns, p.tableInfo.PKIsHandle) {
+			coveringIndices = append(coveringIndices, idx)
+		}
+	}
+	if len(coveringIndices) == 0 {
+		return nil, false, ErrNoCoveringIndex.GenByArgs(p.tableInfo.Name.O)
+	}
+	return coveringIndices, false, nil
+}
+
+// groupByIndexCols returns the leading columns of the first index hinted FOR GROUP BY which are the same as the group
+// by items in the order of the index, and whether the index is forced.
+func (p *DataSource) groupByIndexCols(items []expression.Expression) ([]*expression.Column, bool) {
+	hinted, forced := false, false
+	for _, hint := range p.groupIndexHints {
+		hinted = hinted || hint.HintType != ast.HintIgnore
+		forced = forced || hint.HintType == ast.HintForce
+	}
+	if !hinted {
+		return nil, false
+	}
+	indices, _ := availableIndices(p.indexHintsFor(ast.HintForGroupBy), p.tableInfo)
+	for _, idx := range indices {
+		if cols := indexGroupByCols(p.schema.Columns, idx, items); cols != nil {
+			return cols, forced
+		}
+	}
+	return nil, forced
+}
+
+// indexGroupByCols returns the leading columns of idx if they are the same as the group by items, nil otherwise.
+func indexGroupByCols(schemaCols []*expression.Column, idx *model.IndexInfo, items []expression.Expression) []*expression.Column {
+	idxCols, lengths := expression.IndexInfo2Cols(schemaCols, idx)
+	if len(items) == 0 || len(idxCols) < len(items) {
+		return nil
+	}
+	for i, idxCol := range idxCols[:len(items)] {
+		// The prefix of a column doesn't decide the group.
+		if lengths[i] != types.UnspecifiedLength {
+			return nil
+		}
+		found := false
+		for _, item := range items {
+			if idxCol.Equal(item, nil) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return idxCols[:len(items)]
+}
+
+// childDataSource returns the DataSource p reads directly, the Selections above it are skipped.
+func childDataSource(p LogicalPlan) *DataSource {
+	for {
+		switch x := p.(type) {
+		case *DataSource:
+			return x
+		case *Selection:
+			p = x.children[0].(LogicalPlan)
+		default:
+			return nil
+		}
+	}
+}
+
 // TableInfo returns the *TableInfo of data source.
 func (p *DataSource) TableInfo() *model.TableInfo {
 	return p.tableInfo
@@ -369,9 +490,19 @@ type Limit struct {
 
 	Offset uint64
 	Count  uint64
+	// CalcFoundRows is true if the SELECT has SQL_CALC_FOUND_ROWS, the rows beyond the limit
+	// are still read and counted for FOUND_ROWS().
+	CalcFoundRows bool
+	// WithTies is true for a LIMIT ... WITH TIES, the rows beyond the limit that are equal to
+	// the last row on ByItems, the ORDER BY items of the sorted child, are returned too.
+	WithTies bool
+	ByItems  []*ByItems
 
 	// partial is true if this topn is generated by push-down optimization.
 	partial bool
+	// inSubqueryPredicate is true for the top level LIMIT of an EXISTS or IN subquery, it isn't warned by
+	// checkLimitDeterminism.
+	inSubqueryPredicate bool
 
 	expectedProp *requiredProp
 }
@@ -45,8 +45,8 @@ func (p *requiredProp) enforceProperty(task task, ctx context.Context, allocator
 	}
 	task = finishCopTask(task, ctx, allocator)
 	sort := Sort{ByItems: make([]*ByItems, 0, len(p.cols))}.init(allocator, ctx)
-	for _, col := range p.cols {
-		sort.ByItems = append(sort.ByItems, &ByItems{col, p.desc})
+	for i, col := range p.cols {
+		sort.ByItems = append(sort.ByItems, &ByItems{col, p.isDesc(i)})
 	}
 	sort.SetSchema(task.plan().Schema())
 	sort.profile = task.plan().statsProfile()
@@ -58,9 +58,10 @@ func (p *requiredProp) enforceProperty(task task, ctx context.Context, allocator
 // When a sort column will be replaced by a constant, we just remove it.
 func (p *Projection) getChildrenPossibleProps(prop *requiredProp) [][]*requiredProp {
 	p.expectedCnt = prop.expectedCnt
-	newProp := &requiredProp{taskTp: rootTaskType, expectedCnt: prop.expectedCnt}
+	newProp := &requiredProp{taskTp: rootTaskType, expectedCnt: prop.expectedCnt, hintScope: prop.hintScope}
 	newCols := make([]*expression.Column, 0, len(prop.cols))
-	for _, col := range prop.cols {
+	var newDescs []bool
+	for i, col := range prop.cols {
 		idx := p.schema.ColumnIndex(col)
 		if idx == -1 {
 			return nil
@@ -68,12 +69,16 @@ func (p *Projection) getChildrenPossibleProps(prop *requiredProp) [][]*requiredP
 		switch expr := p.Exprs[idx].(type) {
 		case *expression.Column:
 			newCols = append(newCols, expr)
+			if prop.descs != nil {
+				newDescs = append(newDescs, prop.descs[i])
+			}
 		case *expression.ScalarFunction:
 			return nil
 		}
 	}
 	newProp.cols = newCols
 	newProp.desc = prop.desc
+	newProp.descs = newDescs
 	return [][]*requiredProp{{newProp}}
 }
 
@@ -154,7 +159,11 @@ func (p *LogicalJoin) getIndexJoinByOuterIdx(outerIdx int) []PhysicalPlan {
 	if !ok {
 		return nil
 	}
-	indices, includeTableScan := availableIndices(x.indexHints, x.tableInfo)
+	indices, includeTableScan, err := x.availableIndices(ast.HintForJoin)
+	if err != nil {
+		// The inner child can't be read by the USE_INDEX_ONLY hint, it's reported when the child is built.
+		return nil
+	}
 	if includeTableScan && len(innerJoinKeys) == 1 {
 		pkCol := x.getPKIsHandleCol()
 		if pkCol != nil && innerJoinKeys[0].Equal(pkCol, nil) {
@@ -197,18 +206,18 @@ func (p *PhysicalIndexJoin) getChildrenPossibleProps(prop *requiredProp) [][]*re
 		}
 	}
 	requiredProps1 := make([]*requiredProp, 2)
-	requiredProps1[p.outerIndex] = &requiredProp{taskTp: rootTaskType, expectedCnt: prop.expectedCnt, cols: prop.cols, desc: prop.desc}
-	requiredProps1[1-p.outerIndex] = &requiredProp{taskTp: copSingleReadTaskType, cols: p.InnerJoinKeys, expectedCnt: math.MaxFloat64}
+	requiredProps1[p.outerIndex] = &requiredProp{taskTp: rootTaskType, expectedCnt: prop.expectedCnt, cols: prop.cols, desc: prop.desc, descs: prop.descs, hintScope: prop.hintScope}
+	requiredProps1[1-p.outerIndex] = &requiredProp{taskTp: copSingleReadTaskType, cols: p.InnerJoinKeys, expectedCnt: math.MaxFloat64, hintScope: ast.HintForJoin}
 	requiredProps2 := make([]*requiredProp, 2)
-	requiredProps2[p.outerIndex] = &requiredProp{taskTp: rootTaskType, expectedCnt: prop.expectedCnt, cols: prop.cols, desc: prop.desc}
-	requiredProps2[1-p.outerIndex] = &requiredProp{taskTp: copDoubleReadTaskType, cols: p.InnerJoinKeys, expectedCnt: math.MaxFloat64}
+	requiredProps2[p.outerIndex] = &requiredProp{taskTp: rootTaskType, expectedCnt: prop.expectedCnt, cols: prop.cols, desc: prop.desc, descs: prop.descs, hintScope: prop.hintScope}
+	requiredProps2[1-p.outerIndex] = &requiredProp{taskTp: copDoubleReadTaskType, cols: p.InnerJoinKeys, expectedCnt: math.MaxFloat64, hintScope: ast.HintForJoin}
 	return [][]*requiredProp{requiredProps1, requiredProps2}
 }
 
 func (p *PhysicalMergeJoin) getChildrenPossibleProps(prop *requiredProp) [][]*requiredProp {
 	p.expectedCnt = prop.expectedCnt
-	lProp := &requiredProp{taskTp: rootTaskType, cols: p.leftKeys, expectedCnt: math.MaxFloat64}
-	rProp := &requiredProp{taskTp: rootTaskType, cols: p.rightKeys, expectedCnt: math.MaxFloat64}
+	lProp := &requiredProp{taskTp: rootTaskType, cols: p.leftKeys, expectedCnt: math.MaxFloat64, hintScope: ast.HintForJoin}
+	rProp := &requiredProp{taskTp: rootTaskType, cols: p.rightKeys, expectedCnt: math.MaxFloat64, hintScope: ast.HintForJoin}
 	if !prop.isEmpty() {
 		if prop.desc {
 			return nil
@@ -414,22 +423,27 @@ func (p *LogicalJoin) getHashJoin(smallTable int) PhysicalPlan {
 }
 
 // getPropByOrderByItems will check if this sort property can be pushed or not. In order to simplify the problem, we only
-// consider the case that all expression are columns and all of them are asc or desc.
+// consider the case that all expression are columns. If the items are in mixed directions, the direction of each column
+// is kept in descs, so the index scans can check whether the order is satisfied.
 func getPropByOrderByItems(items []*ByItems) (*requiredProp, bool) {
-	desc := false
+	mixed := false
 	cols := make([]*expression.Column, 0, len(items))
+	descs := make([]bool, 0, len(items))
 	for i, item := range items {
 		col, ok := item.Expr.(*expression.Column)
 		if !ok {
 			return nil, false
 		}
 		cols = append(cols, col)
-		desc = item.Desc
+		descs = append(descs, item.Desc)
 		if i > 0 && item.Desc != items[i-1].Desc {
-			return nil, false
+			mixed = true
 		}
 	}
-	return &requiredProp{cols: cols, desc: desc}, true
+	if mixed {
+		return &requiredProp{cols: cols, descs: descs, hintScope: ast.HintForOrderBy}, true
+	}
+	return &requiredProp{cols: cols, desc: len(descs) > 0 && descs[0], hintScope: ast.HintForOrderBy}, true
 }
 
 func (p *TopN) generatePhysicalPlans() []PhysicalPlan {
@@ -511,6 +525,9 @@ func (p *baseLogicalPlan) convert2NewPhysicalPlan(prop *requiredProp) (task, err
 	}
 	// Else we suppose it only has one child.
 	for _, pp := range p.basePlan.self.(LogicalPlan).generatePhysicalPlans() {
+		if p.basePlan.blockOffset != 0 {
+			pp.setQueryBlockOffset(p.basePlan.blockOffset)
+		}
 		// We consider to add enforcer firstly.
 		t, err = p.getBestTask(t, prop, pp, true)
 		if err != nil {
@@ -643,7 +660,14 @@ func (p *DataSource) convert2NewPhysicalPlan(prop *requiredProp) (task, error) {
 		return t, p.storeTask(prop, t)
 	}
 	// TODO: We have not checked if this table has a predicate. If not, we can only consider table scan.
-	indices, includeTableScan := availableIndices(p.indexHints, p.tableInfo)
+	scope := ast.HintForScan
+	if !prop.isEmpty() && prop.hintScope != 0 {
+		scope = prop.hintScope
+	}
+	indices, includeTableScan, err := p.availableIndices(scope)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 	t = invalidTask
 	if includeTableScan {
 		t, err = p.convertToTableScan(prop)
@@ -662,9 +686,33 @@ func (p *DataSource) convert2NewPhysicalPlan(prop *requiredProp) (task, error) {
 			}
 		}
 	}
+	p.tagTaskQueryBlock(t)
 	return t, p.storeTask(prop, t)
 }
 
+// tagTaskQueryBlock tags the scans of the task with the query block of the DataSource.
+func (p *DataSource) tagTaskQueryBlock(t task) {
+	switch x := t.(type) {
+	case *rootTask:
+		if x.p == nil {
+			return
+		}
+		tagQueryBlock(x.p, p.blockOffset)
+		for _, copPlan := range readerCopPlans(x.p) {
+			if copPlan.queryBlockOffset() == 0 {
+				copPlan.setQueryBlockOffset(p.blockOffset)
+			}
+		}
+	case *copTask:
+		if x.indexPlan != nil {
+			tagQueryBlock(x.indexPlan, p.blockOffset)
+		}
+		if x.tablePlan != nil {
+			tagQueryBlock(x.tablePlan, p.blockOffset)
+		}
+	}
+}
+
 // convertToIndexScan converts the DataSource to index scan with idx.
 func (p *DataSource) convertToIndexScan(prop *requiredProp, idx *model.IndexInfo) (task task, err error) {
 	is := PhysicalIndexScan{
@@ -674,7 +722,7 @@ func (p *DataSource) convertToIndexScan(prop *requiredProp, idx *model.IndexInfo
 		Columns:             p.Columns,
 		Index:               idx,
 		dataSourceSchema:    p.schema,
-		physicalTableSource: physicalTableSource{NeedColHandle: p.NeedColHandle || p.unionScanSchema != nil},
+		physicalTableSource: physicalTableSource{NeedColHandle: p.NeedColHandle || p.unionScanSchema != nil, AsOfTS: p.AsOfTS},
 	}.init(p.allocator, p.ctx)
 	statsTbl := p.statisticTable
 	rowCount := float64(statsTbl.Count)
@@ -707,7 +755,9 @@ func (p *DataSource) convertToIndexScan(prop *requiredProp, idx *model.IndexInfo
 	}
 	if !isCoveringIndex(is.Columns, is.Index.Columns, is.Table.PKIsHandle) {
 		// On this way, it's double read case.
-		cop.tablePlan = PhysicalTableScan{Columns: p.Columns, Table: is.Table}.init(p.allocator, p.ctx)
+		ts := PhysicalTableScan{Columns: p.Columns, Table: is.Table}.init(p.allocator, p.ctx)
+		ts.AsOfTS = p.AsOfTS
+		cop.tablePlan = ts
 		cop.tablePlan.SetSchema(is.dataSourceSchema)
 		// If it's parent requires single read task, return max cost.
 		if prop.taskTp == copSingleReadTaskType {
@@ -731,12 +781,15 @@ func (p *DataSource) convertToIndexScan(prop *requiredProp, idx *model.IndexInfo
 	}
 	is.SetSchema(expression.NewSchema(indexCols...))
 	// Check if this plan matches the property.
-	matchProperty := false
+	matchProperty, scanDesc := false, false
 	if !prop.isEmpty() {
 		for i, col := range idx.Columns {
 			// not matched
 			if col.Name.L == prop.cols[0].ColName.L {
 				matchProperty = matchIndicesProp(idx.Columns[i:], prop.cols)
+				if matchProperty {
+					scanDesc, matchProperty = indexScanDirection(prop, i, is.AccessCondition)
+				}
 				break
 			} else if i >= len(is.AccessCondition) {
 				break
@@ -757,7 +810,7 @@ func (p *DataSource) convertToIndexScan(prop *requiredProp, idx *model.IndexInfo
 	cop.cst = rowCount * scanFactor
 	task = cop
 	if matchProperty {
-		if prop.desc {
+		if scanDesc {
 			is.Desc = true
 			cop.cst = rowCount * descScanFactor
 		}
@@ -829,6 +882,35 @@ func matchIndicesProp(idxCols []*model.IndexColumn, propCols []*expression.Colum
 	return true
 }
 
+// indexScanDirection returns whether the index scan should be backward to satisfy the order of prop, whose columns match
+// the index columns from offset. The index columns are stored in ascending order, so a forward or backward scan only
+// satisfies the columns in one direction, except that the columns fixed by the leading equal access conditions, e.g. a
+// of `WHERE a = 1 ORDER BY a ASC, b DESC`, can be in any direction. The second return value is false if no scan
+// satisfies the order.
+func indexScanDirection(prop *requiredProp, offset int, accessConds []expression.Expression) (desc bool, ok bool) {
+	if prop.descs == nil {
+		return prop.desc, true
+	}
+	eqCount := 0
+	for _, cond := range accessConds {
+		if sf, ok := cond.(*expression.ScalarFunction); !ok || sf.FuncName.L != ast.EQ {
+			break
+		}
+		eqCount++
+	}
+	decided := false
+	for i, colDesc := range prop.descs {
+		if offset+i < eqCount {
+			continue
+		}
+		if decided && colDesc != desc {
+			return false, false
+		}
+		desc, decided = colDesc, true
+	}
+	return desc, true
+}
+
 // convertToTableScan converts the DataSource to table scan.
 func (p *DataSource) convertToTableScan(prop *requiredProp) (task task, err error) {
 	if prop.taskTp == copDoubleReadTaskType {
@@ -839,7 +921,7 @@ func (p *DataSource) convertToTableScan(prop *requiredProp) (task task, err erro
 		Columns:             p.Columns,
 		TableAsName:         p.TableAsName,
 		DBName:              p.DBName,
-		physicalTableSource: physicalTableSource{NeedColHandle: p.NeedColHandle || p.unionScanSchema != nil},
+		physicalTableSource: physicalTableSource{NeedColHandle: p.NeedColHandle || p.unionScanSchema != nil, AsOfTS: p.AsOfTS},
 	}.init(p.allocator, p.ctx)
 	ts.SetSchema(p.schema)
 	sc := p.ctx.GetSessionVars().StmtCtx
@@ -976,7 +1058,7 @@ func (p *PhysicalHashJoin) getChildrenPossibleProps(prop *requiredProp) [][]*req
 
 func (p *PhysicalHashSemiJoin) getChildrenPossibleProps(prop *requiredProp) [][]*requiredProp {
 	p.expectedCnt = prop.expectedCnt
-	lProp := &requiredProp{taskTp: rootTaskType, cols: prop.cols, expectedCnt: prop.expectedCnt, desc: prop.desc}
+	lProp := &requiredProp{taskTp: rootTaskType, cols: prop.cols, expectedCnt: prop.expectedCnt, desc: prop.desc, descs: prop.descs, hintScope: prop.hintScope}
 	for _, col := range lProp.cols {
 		idx := p.Schema().ColumnIndex(col)
 		if idx == -1 || idx >= p.rightChOffset {
@@ -988,7 +1070,7 @@ func (p *PhysicalHashSemiJoin) getChildrenPossibleProps(prop *requiredProp) [][]
 
 func (p *PhysicalApply) getChildrenPossibleProps(prop *requiredProp) [][]*requiredProp {
 	p.expectedCnt = prop.expectedCnt
-	lProp := &requiredProp{taskTp: rootTaskType, cols: prop.cols, expectedCnt: prop.expectedCnt, desc: prop.desc}
+	lProp := &requiredProp{taskTp: rootTaskType, cols: prop.cols, expectedCnt: prop.expectedCnt, desc: prop.desc, descs: prop.descs, hintScope: prop.hintScope}
 	for _, col := range lProp.cols {
 		idx := p.Schema().ColumnIndex(col)
 		if idx == -1 || idx >= p.rightChOffset {
@@ -1003,12 +1085,18 @@ func (p *Limit) getChildrenPossibleProps(prop *requiredProp) [][]*requiredProp {
 	if !prop.isEmpty() {
 		return nil
 	}
+	expectedCnt := float64(p.Count + p.Offset)
+	if p.CalcFoundRows {
+		expectedCnt = math.MaxFloat64
+	}
 	props := make([][]*requiredProp, 0, len(wholeTaskTypes))
 	for _, tp := range wholeTaskTypes {
-		newProp := &requiredProp{taskTp: tp, expectedCnt: float64(p.Count + p.Offset)}
+		newProp := &requiredProp{taskTp: tp, expectedCnt: expectedCnt}
 		if p.expectedProp != nil {
 			newProp.cols = p.expectedProp.cols
 			newProp.desc = p.expectedProp.desc
+			newProp.descs = p.expectedProp.descs
+			newProp.hintScope = p.expectedProp.hintScope
 		}
 		props = append(props, []*requiredProp{newProp})
 	}
@@ -1036,7 +1124,40 @@ func (p *LogicalAggregation) generatePhysicalPlans() []PhysicalPlan {
 	}.init(p.allocator, p.ctx)
 	ha.SetSchema(p.schema)
 	ha.profile = p.profile
-	return []PhysicalPlan{ha}
+	cols, forced := p.groupByIndexCols()
+	if cols == nil {
+		return []PhysicalPlan{ha}
+	}
+	// The child ordered by the index hinted FOR GROUP BY can be grouped by a stream aggregation, the hash aggregation
+	// isn't considered if the index is forced.
+	sa := PhysicalAggregation{
+		GroupByItems: p.GroupByItems,
+		AggFuncs:     p.AggFuncs,
+		HasGby:       true,
+		AggType:      StreamedAgg,
+		propCols:     cols,
+	}.init(p.allocator, p.ctx)
+	sa.SetSchema(p.schema)
+	sa.profile = p.profile
+	if forced {
+		return []PhysicalPlan{sa}
+	}
+	return []PhysicalPlan{ha, sa}
+}
+
+// groupByIndexCols returns the columns of the index hinted FOR GROUP BY which the child should be ordered by for a
+// stream aggregation, and whether the index is forced. The child must read the table directly.
+func (p *LogicalAggregation) groupByIndexCols() ([]*expression.Column, bool) {
+	for _, aggFunc := range p.AggFuncs {
+		if aggFunc.GetMode() == expression.FinalMode {
+			return nil, false
+		}
+	}
+	ds := childDataSource(p.children[0].(LogicalPlan))
+	if ds == nil {
+		return nil, false
+	}
+	return ds.groupByIndexCols(p.GroupByItems)
 }
 
 func (p *PhysicalAggregation) getChildrenPossibleProps(prop *requiredProp) [][]*requiredProp {
@@ -1044,6 +1165,9 @@ func (p *PhysicalAggregation) getChildrenPossibleProps(prop *requiredProp) [][]*
 	if !prop.isEmpty() {
 		return nil
 	}
+	if p.AggType == StreamedAgg {
+		return [][]*requiredProp{{{taskTp: rootTaskType, cols: p.propCols, expectedCnt: math.MaxFloat64, hintScope: ast.HintForGroupBy}}}
+	}
 	props := make([][]*requiredProp, 0, len(wholeTaskTypes))
 	for _, tp := range wholeTaskTypes {
 		props = append(props, []*requiredProp{{taskTp: tp, expectedCnt: math.MaxFloat64}})
@@ -21,6 +21,7 @@ import (
 	"github.com/pingcap/tidb/context"
 	"github.com/pingcap/tidb/expression"
 	"github.com/pingcap/tidb/infoschema"
+	"github.com/pingcap/tidb/model"
 	"github.com/pingcap/tidb/mysql"
 	"github.com/pingcap/tidb/privilege"
 	"github.com/pingcap/tidb/terror"
@@ -30,20 +31,24 @@ import (
 var AllowCartesianProduct = true
 
 const (
-	flagPrunColumns uint64 = 1 << iota
+	flagGcSubstitute uint64 = 1 << iota
+	flagPrunColumns
 	flagEliminateProjection
 	flagBuildKeyInfo
 	flagDecorrelate
+	flagPropagateConstant
 	flagPredicatePushDown
 	flagAggregationOptimize
 	flagPushDownTopN
 )
 
 var optRuleList = []logicalOptRule{
+	&gcSubstituter{},
 	&columnPruner{},
 	&projectionEliminater{},
 	&buildKeySolver{},
 	&decorrelateSolver{},
+	&constantPropagator{},
 	&ppdSolver{},
 	&aggregationOptimizer{},
 	&pushDownTopNOptimizer{},
@@ -57,16 +62,108 @@ type logicalOptRule interface {
 // Optimize does optimization and creates a Plan.
 // The node must be prepared first.
 func Optimize(ctx context.Context, node ast.Node, is infoschema.InfoSchema) (Plan, error) {
-	// We have to infer type again because after parameter is set, the expression type may change.
-	if err := expression.InferType(ctx.GetSessionVars().StmtCtx, node); err != nil {
-		return nil, errors.Trace(err)
+	builder := &planBuilder{
+		ctx:       ctx,
+		is:        is,
+		colMapper: make(map[*ast.ColumnNameExpr]int),
+		allocator: new(idAllocator),
 	}
-	allocator := new(idAllocator)
+	return optimize(builder, node)
+}
+
+// OptimizeWithTableHints is like Optimize, but the table hints of the top level query blocks are replaced by hints,
+// the hints of the other query blocks are kept. It is used to plan a statement with different hints without parsing it again.
+func OptimizeWithTableHints(ctx context.Context, node ast.Node, is infoschema.InfoSchema, hints []*ast.TableOptimizerHint) (Plan, error) {
+	builder := &planBuilder{
+		ctx:           ctx,
+		is:            is,
+		colMapper:     make(map[*ast.ColumnNameExpr]int),
+		allocator:     new(idAllocator),
+		overrideHints: true,
+		hintOverride:  hints,
+	}
+	return optimize(builder, node)
+}
+
+// OptimizeWithTableSchema is like Optimize, but the tables read by the statement are resolved in tableIS instead of is.
+// It is used to check the plan of a statement against a hypothetical schema, e.g. whether a proposed index would be
+// used, without applying the DDL. The tables without statistics, like the hypothetical ones, use the pseudo statistics.
+func OptimizeWithTableSchema(ctx context.Context, node ast.Node, is, tableIS infoschema.InfoSchema) (Plan, error) {
 	builder := &planBuilder{
 		ctx:       ctx,
 		is:        is,
+		tableIS:   tableIS,
 		colMapper: make(map[*ast.ColumnNameExpr]int),
-		allocator: allocator,
+		allocator: new(idAllocator),
+	}
+	return optimize(builder, node)
+}
+
+// OptimizeWithColumnAccess is like Optimize, but it also returns the columns of every table referenced by the
+// statement, including those only used in the filters and the join conditions. It serves the tools auditing the
+// column access.
+func OptimizeWithColumnAccess(ctx context.Context, node ast.Node, is infoschema.InfoSchema) (Plan, ColumnAccess, error) {
+	builder := &planBuilder{
+		ctx:          ctx,
+		is:           is,
+		colMapper:    make(map[*ast.ColumnNameExpr]int),
+		allocator:    new(idAllocator),
+		columnAccess: make(ColumnAccess),
+	}
+	p, err := optimize(builder, node)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	return p, builder.columnAccess, nil
+}
+
+// SubqueryAlternative is a statement planned with one of its correlated subqueries in both forms, a cost based
+// decision can compare them to choose whether to decorrelate the subquery.
+type SubqueryAlternative struct {
+	// Apply is the plan where the subquery is kept as a correlated apply, which executes it for every outer row.
+	Apply Plan
+	// Join is the plan where the subquery is decorrelated into a join as far as possible.
+	Join Plan
+}
+
+// OptimizeSubqueryAlternatives is like Optimize, but it returns the alternatives of every correlated subquery of the
+// statement, in the order the subqueries are built. The other subqueries are decorrelated as usual in both plans, and
+// the Join plan is shared by all the alternatives. The statement is built once more for the Apply plan of every
+// subquery, Optimize keeps building the single decorrelated form.
+func OptimizeSubqueryAlternatives(ctx context.Context, node ast.Node, is infoschema.InfoSchema) ([]*SubqueryAlternative, error) {
+	builder := &planBuilder{
+		ctx:                  ctx,
+		is:                   is,
+		colMapper:            make(map[*ast.ColumnNameExpr]int),
+		allocator:            new(idAllocator),
+		subqueryAlternatives: true,
+	}
+	join, err := optimize(builder, node)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	alternatives := make([]*SubqueryAlternative, 0, len(builder.correlatedApplies))
+	for _, offset := range builder.correlatedApplies {
+		apply, err := optimize(&planBuilder{
+			ctx:       ctx,
+			is:        is,
+			colMapper: make(map[*ast.ColumnNameExpr]int),
+			allocator: new(idAllocator),
+			keptApply: offset,
+		}, node)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		alternatives = append(alternatives, &SubqueryAlternative{Apply: apply, Join: join})
+	}
+	return alternatives, nil
+}
+
+func optimize(builder *planBuilder, node ast.Node) (Plan, error) {
+	ctx := builder.ctx
+	// We have to infer type again because after parameter is set, the expression type may change.
+	if err := expression.InferType(ctx.GetSessionVars().StmtCtx, node); err != nil {
+		return nil, errors.Trace(err)
 	}
 	p := builder.build(node)
 	if builder.err != nil {
@@ -82,7 +179,7 @@ func Optimize(ctx context.Context, node ast.Node, is infoschema.InfoSchema) (Pla
 	}
 
 	if logic, ok := p.(LogicalPlan); ok {
-		return doOptimize(builder.optFlag, logic, ctx, allocator)
+		return doOptimizeWithColumnAccess(builder.optFlag, logic, ctx, builder.allocator, builder.columnAccess)
 	}
 	return p, nil
 }
@@ -116,6 +213,12 @@ func checkPrivilege(pm privilege.Manager, vs []visitInfo) bool {
 }
 
 func doOptimize(flag uint64, logic LogicalPlan, ctx context.Context, allocator *idAllocator) (PhysicalPlan, error) {
+	return doOptimizeWithColumnAccess(flag, logic, ctx, allocator, nil)
+}
+
+// doOptimizeWithColumnAccess is like doOptimize, but it also collects the referenced columns into access if it isn't nil.
+func doOptimizeWithColumnAccess(flag uint64, logic LogicalPlan, ctx context.Context, allocator *idAllocator,
+	access ColumnAccess) (PhysicalPlan, error) {
 	logic, err := logicalOptimize(flag, logic, ctx, allocator)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -123,6 +226,12 @@ func doOptimize(flag uint64, logic LogicalPlan, ctx context.Context, allocator *
 	if !AllowCartesianProduct && existsCartesianProduct(logic) {
 		return nil, errors.Trace(ErrCartesianProductUnsupported)
 	}
+	if ctx.GetSessionVars().NoCartesianJoin && existsImplicitCartesianJoin(logic) {
+		return nil, errors.Trace(ErrImplicitCartesianJoin)
+	}
+	if access != nil {
+		access.collect(logic)
+	}
 	var physical PhysicalPlan
 	if UseDAGPlanBuilder(ctx) {
 		physical, err = dagPhysicalOptimize(logic)
@@ -191,6 +300,60 @@ func existsCartesianProduct(p LogicalPlan) bool {
 	return false
 }
 
+// existsImplicitCartesianJoin checks whether there is an inner join between two tables without any join condition,
+// which is not written as CROSS JOIN. It is usually caused by a dropped join predicate.
+func existsImplicitCartesianJoin(p LogicalPlan) bool {
+	if join, ok := p.(*LogicalJoin); ok && join.JoinType == InnerJoin && !join.explicitCross &&
+		len(join.EqualConditions) == 0 && len(join.OtherConditions) == 0 &&
+		isTableSource(join.children[0].(LogicalPlan)) && isTableSource(join.children[1].(LogicalPlan)) {
+		return true
+	}
+	for _, child := range p.Children() {
+		if existsImplicitCartesianJoin(child.(LogicalPlan)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTableSource checks whether the rows of p come from real tables, rather than a dual table or an aggregation.
+func isTableSource(p LogicalPlan) bool {
+	switch x := p.(type) {
+	case *DataSource, *LogicalJoin:
+		return true
+	case *Selection, *Projection, *Sort, *Limit, *TopN:
+		return isTableSource(x.Children()[0].(LogicalPlan))
+	}
+	return false
+}
+
+// ColumnAccess maps the table ID to the IDs of its columns referenced by a statement, see OptimizeWithColumnAccess.
+type ColumnAccess map[int64][]int64
+
+// collect records the columns of the data sources after column pruning, they are exactly the columns
+// referenced by the statement, including those only used in the filters and the join conditions.
+func (ca ColumnAccess) collect(p LogicalPlan) {
+	if ds, ok := p.(*DataSource); ok {
+		for _, col := range ds.Columns {
+			if col.ID != model.ExtraHandleID {
+				ca.add(ds.tableInfo.ID, col.ID)
+			}
+		}
+	}
+	for _, child := range p.Children() {
+		ca.collect(child.(LogicalPlan))
+	}
+}
+
+func (ca ColumnAccess) add(tableID, colID int64) {
+	for _, id := range ca[tableID] {
+		if id == colID {
+			return
+		}
+	}
+	ca[tableID] = append(ca[tableID], colID)
+}
+
 // PrepareStmt prepares a raw statement parsed from parser.
 // The statement must be prepared before it can be passed to optimize function.
 // We pass InfoSchema instead of getting from Context in case it is changed after resolving name.
@@ -211,6 +374,8 @@ const (
 	CodeUnsupported         terror.ErrCode = 4
 	CodeInvalidGroupFuncUse terror.ErrCode = 5
 	CodeIllegalReference    terror.ErrCode = 6
+	CodeImplicitCartesian   terror.ErrCode = 7
+	CodeImplicitConversion  terror.ErrCode = 8
 
 	// MySQL error code.
 	CodeNoDB terror.ErrCode = mysql.ErrNoDB
@@ -224,6 +389,8 @@ var (
 	ErrInvalidGroupFuncUse         = terror.ClassOptimizer.New(CodeInvalidGroupFuncUse, "Invalid use of group function")
 	ErrIllegalReference            = terror.ClassOptimizer.New(CodeIllegalReference, "Illegal reference")
 	ErrNoDB                        = terror.ClassOptimizer.New(CodeNoDB, "No database selected")
+	ErrImplicitCartesianJoin       = terror.ClassOptimizer.New(CodeImplicitCartesian, "Join without join condition is disallowed by tidb_opt_no_cartesian_join, use CROSS JOIN if the cartesian product is intended")
+	ErrImplicitConversion          = terror.ClassOptimizer.New(CodeImplicitConversion, "Comparison %s between %s and %s is disallowed by tidb_opt_reject_implicit_conversion, use CAST if the conversion is intended")
 )
 
 func init() {
@@ -18,6 +18,7 @@ import (
 
 	"github.com/juju/errors"
 	"github.com/ngaut/log"
+	"github.com/pingcap/tidb/ast"
 	"github.com/pingcap/tidb/context"
 	"github.com/pingcap/tidb/expression"
 	"github.com/pingcap/tidb/infoschema"
@@ -54,10 +55,11 @@ func (p *DataSource) convert2TableScan(prop *requiredProperty) (*physicalPlanInf
 			client:          client,
 			NeedColHandle:   p.NeedColHandle,
 			unionScanSchema: p.unionScanSchema,
+			AsOfTS:          p.AsOfTS,
 		},
 	}.init(p.allocator, p.ctx)
 	ts.SetSchema(p.schema)
-	if p.ctx.Txn() != nil {
+	if p.ctx.Txn() != nil && p.AsOfTS == 0 {
 		ts.readOnly = p.ctx.Txn().IsReadOnly()
 	} else {
 		ts.readOnly = true
@@ -123,10 +125,11 @@ func (p *DataSource) convert2IndexScan(prop *requiredProperty, index *model.Inde
 			client:          client,
 			NeedColHandle:   p.NeedColHandle,
 			unionScanSchema: p.unionScanSchema,
+			AsOfTS:          p.AsOfTS,
 		},
 	}.init(p.allocator, p.ctx)
 	is.SetSchema(p.schema)
-	if p.ctx.Txn() != nil {
+	if p.ctx.Txn() != nil && p.AsOfTS == 0 {
 		is.readOnly = p.ctx.Txn().IsReadOnly()
 	} else {
 		is.readOnly = true
@@ -242,7 +245,10 @@ func (p *DataSource) convert2PhysicalPlan(prop *requiredProperty) (*physicalPlan
 		p.storePlanInfo(prop, info)
 		return info, nil
 	}
-	indices, includeTableScan := availableIndices(p.indexHints, p.tableInfo)
+	indices, includeTableScan, err := p.availableIndices(ast.HintForScan)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 	if includeTableScan {
 		info, err = p.convert2TableScan(prop)
 		if err != nil {
@@ -391,10 +397,18 @@ func (p *Limit) convert2PhysicalPlan(prop *requiredProperty) (*physicalPlanInfo,
 	if info != nil {
 		return info, nil
 	}
-	info, err = p.children[0].(LogicalPlan).convert2PhysicalPlan(limitProperty(&Limit{Offset: p.Offset, Count: p.Count}))
+	childProp := limitProperty(&Limit{Offset: p.Offset, Count: p.Count})
+	if p.CalcFoundRows || p.WithTies {
+		// The child should produce all the rows for FOUND_ROWS() or the ties, so the limit is enforced here.
+		childProp = &requiredProperty{}
+	}
+	info, err = p.children[0].(LogicalPlan).convert2PhysicalPlan(childProp)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	if p.CalcFoundRows || p.WithTies {
+		info = addPlanToResponse(p, info)
+	}
 	info = enforceProperty(prop, info)
 	p.storePlanInfo(prop, info)
 	return info, nil
@@ -1242,7 +1256,7 @@ func (p *Selection) makeScanController() *physicalPlanInfo {
 		corColConds []expression.Expression
 	)
 	ds := p.children[0].(*DataSource)
-	indices, _ := availableIndices(ds.indexHints, ds.tableInfo)
+	indices, _ := availableIndices(ds.indexHintsFor(ast.HintForScan), ds.tableInfo)
 	for _, expr := range p.Conditions {
 		if !expr.IsCorrelated() {
 			continue
@@ -1260,10 +1274,10 @@ func (p *Selection) makeScanController() *physicalPlanInfo {
 			Table:               ds.tableInfo,
 			Columns:             ds.Columns,
 			DBName:              ds.DBName,
-			physicalTableSource: physicalTableSource{client: ds.ctx.GetClient()},
+			physicalTableSource: physicalTableSource{client: ds.ctx.GetClient(), AsOfTS: ds.AsOfTS},
 		}.init(p.allocator, p.ctx)
 		ts.SetSchema(ds.schema)
-		if ds.ctx.Txn() != nil {
+		if ds.ctx.Txn() != nil && ds.AsOfTS == 0 {
 			ts.readOnly = p.ctx.Txn().IsReadOnly()
 		} else {
 			ts.readOnly = true
@@ -1287,10 +1301,10 @@ func (p *Selection) makeScanController() *physicalPlanInfo {
 					Columns:             ds.Columns,
 					OutOfOrder:          true,
 					DBName:              ds.DBName,
-					physicalTableSource: physicalTableSource{client: ds.ctx.GetClient()},
+					physicalTableSource: physicalTableSource{client: ds.ctx.GetClient(), AsOfTS: ds.AsOfTS},
 				}.init(p.allocator, p.ctx)
 				is.SetSchema(ds.schema)
-				if is.ctx.Txn() != nil {
+				if is.ctx.Txn() != nil && ds.AsOfTS == 0 {
 					is.readOnly = p.ctx.Txn().IsReadOnly()
 				} else {
 					is.readOnly = true
@@ -245,6 +245,9 @@ type physicalTableSource struct {
 	// AccessCondition is used to calculate range.
 	AccessCondition []expression.Expression
 
+	// AsOfTS is the read timestamp of the AS OF TIMESTAMP clause, 0 if the table is read at the current time.
+	AsOfTS uint64
+
 	LimitCount  *int64
 	SortItemsPB []*tipb.ByItem
 
@@ -578,6 +581,9 @@ type PhysicalAggregation struct {
 	AggType      AggregationType
 	AggFuncs     []expression.AggregationFunction
 	GroupByItems []expression.Expression
+
+	// propCols are the columns the child of a StreamedAgg is ordered by.
+	propCols []*expression.Column
 }
 
 // PhysicalUnionScan represents a union scan operator.
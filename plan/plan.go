@@ -74,6 +74,11 @@ type Plan interface {
 	// findColumn finds the column in basePlan's schema.
 	// If the column is not in the schema, returns error.
 	findColumn(*ast.ColumnName) (*expression.Column, int, error)
+
+	// queryBlockOffset returns the offset of the query block the plan is built for, 0 means it isn't tagged.
+	queryBlockOffset() int
+	// setQueryBlockOffset tags the plan with the offset of the query block.
+	setQueryBlockOffset(offset int)
 }
 
 type columnProp struct {
@@ -112,6 +117,9 @@ func (t taskType) String() string {
 type requiredProp struct {
 	cols []*expression.Column
 	desc bool
+	// descs is the direction of each column in cols. It's only set when the directions are mixed, e.g. for
+	// `ORDER BY a ASC, b DESC`, otherwise all the columns are in the direction of desc.
+	descs []bool
 	// taskTp means the type of task that an operator requires.
 	// It needs to be specified because two different tasks can't be compared with cost directly.
 	// e.g. If a copTask takes less cost than a rootTask, we can't sure that we must choose the former one. Because the copTask
@@ -120,12 +128,20 @@ type requiredProp struct {
 	taskTp taskType
 	// expectedCnt means this operator may be closed after fetching expectedCnt records.
 	expectedCnt float64
+	// hintScope is the purpose of the order of cols, e.g. HintForOrderBy for the order required by ORDER BY. Only the
+	// index hints of the scope are applied to the indices providing the order, see DataSource.indexHintsFor.
+	hintScope ast.IndexHintScope
 }
 
 func (p *requiredProp) equal(prop *requiredProp) bool {
-	if len(p.cols) != len(prop.cols) || p.desc != prop.desc {
+	if len(p.cols) != len(prop.cols) || p.desc != prop.desc || len(p.descs) != len(prop.descs) {
 		return false
 	}
+	for i := range p.descs {
+		if p.descs[i] != prop.descs[i] {
+			return false
+		}
+	}
 	if p.taskTp != prop.taskTp {
 		return false
 	}
@@ -141,15 +157,27 @@ func (p *requiredProp) isEmpty() bool {
 	return len(p.cols) == 0
 }
 
+// isDesc returns whether the i-th column of prop is in descending order.
+func (p *requiredProp) isDesc(i int) bool {
+	if p.descs != nil {
+		return p.descs[i]
+	}
+	return p.desc
+}
+
 // getHashKey encodes prop to a unique key. The key will be stored in the memory table.
 func (p *requiredProp) getHashKey() ([]byte, error) {
-	datums := make([]types.Datum, 0, len(p.cols)*2+3)
+	datums := make([]types.Datum, 0, len(p.cols)*2+len(p.descs)+4)
 	datums = append(datums, types.NewDatum(p.desc))
+	for _, desc := range p.descs {
+		datums = append(datums, types.NewDatum(desc))
+	}
 	for _, c := range p.cols {
 		datums = append(datums, types.NewDatum(c.FromID), types.NewDatum(c.Position))
 	}
 	datums = append(datums, types.NewDatum(int(p.taskTp)))
 	datums = append(datums, types.NewDatum(p.expectedCnt))
+	datums = append(datums, types.NewDatum(int(p.hintScope)))
 	bytes, err := codec.EncodeValue(nil, datums...)
 	return bytes, errors.Trace(err)
 }
@@ -449,6 +477,8 @@ type basePlan struct {
 	ctx       context.Context
 	self      Plan
 	profile   *statsProfile
+	// blockOffset is the offset of the query block the plan is built for, the top level SELECT is 1.
+	blockOffset int
 }
 
 func (p *basePlan) copy() *basePlan {
@@ -481,6 +511,14 @@ func (p *basePlan) ID() string {
 	return p.id
 }
 
+func (p *basePlan) queryBlockOffset() int {
+	return p.blockOffset
+}
+
+func (p *basePlan) setQueryBlockOffset(offset int) {
+	p.blockOffset = offset
+}
+
 // SetSchema implements Plan SetSchema interface.
 func (p *basePlan) SetSchema(schema *expression.Schema) {
 	p.schema = schema
@@ -31,37 +31,87 @@ import (
 
 // Error instances.
 var (
-	ErrUnsupportedType      = terror.ClassOptimizerPlan.New(CodeUnsupportedType, "Unsupported type")
-	SystemInternalErrorType = terror.ClassOptimizerPlan.New(SystemInternalError, "System internal error")
-	ErrUnknownColumn        = terror.ClassOptimizerPlan.New(CodeUnknownColumn, mysql.MySQLErrName[mysql.ErrBadField])
-	ErrUnknownTable         = terror.ClassOptimizerPlan.New(CodeUnknownColumn, mysql.MySQLErrName[mysql.ErrBadTable])
-	ErrWrongArguments       = terror.ClassOptimizerPlan.New(CodeWrongArguments, "Incorrect arguments to EXECUTE")
-	ErrAmbiguous            = terror.ClassOptimizerPlan.New(CodeAmbiguous, "Column '%s' in field list is ambiguous")
-	ErrAnalyzeMissIndex     = terror.ClassOptimizerPlan.New(CodeAnalyzeMissIndex, "Index '%s' in field list does not exist in table '%s'")
-	ErrAlterAutoID          = terror.ClassAutoid.New(CodeAlterAutoID, "No support for setting auto_increment using alter_table")
-	ErrBadGeneratedColumn   = terror.ClassOptimizerPlan.New(CodeBadGeneratedColumn, mysql.MySQLErrName[mysql.ErrBadGeneratedColumn])
+	ErrUnsupportedType        = terror.ClassOptimizerPlan.New(CodeUnsupportedType, "Unsupported type")
+	SystemInternalErrorType   = terror.ClassOptimizerPlan.New(SystemInternalError, "System internal error")
+	ErrUnknownColumn          = terror.ClassOptimizerPlan.New(CodeUnknownColumn, mysql.MySQLErrName[mysql.ErrBadField])
+	ErrUnknownTable           = terror.ClassOptimizerPlan.New(CodeUnknownColumn, mysql.MySQLErrName[mysql.ErrBadTable])
+	ErrWrongArguments         = terror.ClassOptimizerPlan.New(CodeWrongArguments, "Incorrect arguments to EXECUTE")
+	ErrAmbiguous              = terror.ClassOptimizerPlan.New(CodeAmbiguous, "Column '%s' in field list is ambiguous")
+	ErrNonUniq                = terror.ClassOptimizerPlan.New(CodeAmbiguous, mysql.MySQLErrName[mysql.ErrNonUniq])
+	ErrNonUniqTable           = terror.ClassOptimizerPlan.New(CodeNonUniqTable, mysql.MySQLErrName[mysql.ErrNonuniqTable])
+	ErrAnalyzeMissIndex       = terror.ClassOptimizerPlan.New(CodeAnalyzeMissIndex, "Index '%s' in field list does not exist in table '%s'")
+	ErrAlterAutoID            = terror.ClassAutoid.New(CodeAlterAutoID, "No support for setting auto_increment using alter_table")
+	ErrBadGeneratedColumn     = terror.ClassOptimizerPlan.New(CodeBadGeneratedColumn, mysql.MySQLErrName[mysql.ErrBadGeneratedColumn])
+	ErrWrongValueCountOnRow   = terror.ClassOptimizerPlan.New(CodeWrongValueCountOnRow, "Column count doesn't match value count at row %d")
+	ErrAsOfTimestamp          = terror.ClassOptimizerPlan.New(CodeAsOfTimestamp, "AS OF TIMESTAMP must be a constant timestamp")
+	ErrAsOfTimestampMixed     = terror.ClassOptimizerPlan.New(CodeAsOfTimestampMixed, "Can not read tables AS OF different timestamps or at the current time in one statement")
+	ErrAsOfTimestampWrite     = terror.ClassOptimizerPlan.New(CodeAsOfTimestampWrite, "Can not lock or modify table '%s' read AS OF TIMESTAMP")
+	ErrAsOfTableNotExists     = terror.ClassOptimizerPlan.New(CodeAsOfTableNotExists, "Table '%s.%s' doesn't exist AS OF TIMESTAMP '%s'")
+	ErrCantUseOptionHere      = terror.ClassOptimizerPlan.New(CodeCantUseOptionHere, mysql.MySQLErrName[mysql.ErrCantUseOptionHere])
+	ErrWarnDeprecatedSyntax   = terror.ClassOptimizerPlan.New(CodeWarnDeprecatedSyntax, mysql.MySQLErrName[mysql.ErrWarnDeprecatedSyntax])
+	ErrNoDefaultForField      = terror.ClassOptimizerPlan.New(CodeNoDefaultForField, mysql.MySQLErrName[mysql.ErrNoDefaultForField])
+	ErrUnknownQueryBlock      = terror.ClassOptimizerPlan.New(CodeUnknownQueryBlock, "Query block name %s is not found for %s hint")
+	ErrIllegalJoinCondition   = terror.ClassOptimizerPlan.New(CodeIllegalJoinCondition, "Join can not have %s together with %s")
+	ErrNoCoveringIndex        = terror.ClassOptimizerPlan.New(CodeNoCoveringIndex, "No covering index available for table '%s'")
+	ErrNondeterministicLimit  = terror.ClassOptimizerPlan.New(CodeNondeterministicLimit, "LIMIT is not applied over an ORDER BY of unique key, the returned rows are non-deterministic")
+	ErrInvalidCardinalityHint = terror.ClassOptimizerPlan.New(CodeInvalidCardinalityHint, "Cardinality of %s hint must be positive")
+	ErrGroupByIndexHint       = terror.ClassOptimizerPlan.New(CodeGroupByIndexHint, "Index '%s' of table '%s' can't satisfy the GROUP BY, it's ignored by FORCE INDEX FOR GROUP BY")
+	ErrNonUpdatableTable      = terror.ClassOptimizerPlan.New(CodeNonUpdatableTable, mysql.MySQLErrName[mysql.ErrNonUpdatableTable])
+	ErrTableStmtClause        = terror.ClassOptimizerPlan.New(CodeTableStmtClause, "TABLE statement doesn't support %s, use SELECT * FROM instead")
+	ErrIndexHintConflict      = terror.ClassOptimizerPlan.New(CodeIndexHintConflict, "Index '%s' of table '%s' is both in %s INDEX and IGNORE INDEX, IGNORE INDEX takes precedence")
+	ErrNoStableSortHandle     = terror.ClassOptimizerPlan.New(CodeNoStableSortHandle, "ORDER BY isn't made stable, the row handles aren't available for %s")
+	ErrWithTiesNoOrderBy      = terror.ClassOptimizerPlan.New(CodeWithTiesNoOrderBy, "WITH TIES can't be used without ORDER BY")
+	ErrTooHighLevelOfNesting  = terror.ClassOptimizerPlan.New(CodeTooDeepNesting, mysql.MySQLErrName[mysql.ErrTooHighLevelOfNestingForSelect])
 )
 
 // Error codes.
 const (
-	CodeUnsupportedType    terror.ErrCode = 1
-	SystemInternalError                   = 2
-	CodeAlterAutoID                       = 3
-	CodeAnalyzeMissIndex                  = 4
-	CodeAmbiguous                         = 1052
-	CodeUnknownColumn                     = mysql.ErrBadField
-	CodeUnknownTable                      = mysql.ErrBadTable
-	CodeWrongArguments                    = 1210
-	CodeBadGeneratedColumn                = mysql.ErrBadGeneratedColumn
+	CodeUnsupportedType        terror.ErrCode = 1
+	SystemInternalError                       = 2
+	CodeAlterAutoID                           = 3
+	CodeAnalyzeMissIndex                      = 4
+	CodeAsOfTimestamp                         = 5
+	CodeAsOfTimestampMixed                    = 6
+	CodeAsOfTimestampWrite                    = 7
+	CodeUnknownQueryBlock                     = 8
+	CodeIllegalJoinCondition                  = 9
+	CodeNoCoveringIndex                       = 10
+	CodeNondeterministicLimit                 = 11
+	CodeInvalidCardinalityHint                = 12
+	CodeGroupByIndexHint                      = 13
+	CodeTableStmtClause                       = 14
+	CodeIndexHintConflict                     = 15
+	CodeNoStableSortHandle                    = 16
+	CodeWithTiesNoOrderBy                     = 17
+	CodeAmbiguous                             = 1052
+	CodeNonUniqTable                          = mysql.ErrNonuniqTable
+	CodeUnknownColumn                         = mysql.ErrBadField
+	CodeUnknownTable                          = mysql.ErrBadTable
+	CodeWrongArguments                        = 1210
+	CodeBadGeneratedColumn                    = mysql.ErrBadGeneratedColumn
+	CodeWrongValueCountOnRow                  = mysql.ErrWrongValueCountOnRow
+	CodeAsOfTableNotExists                    = mysql.ErrNoSuchTable
+	CodeCantUseOptionHere                     = mysql.ErrCantUseOptionHere
+	CodeWarnDeprecatedSyntax                  = mysql.ErrWarnDeprecatedSyntax
+	CodeNoDefaultForField                     = mysql.ErrNoDefaultForField
+	CodeNonUpdatableTable                     = mysql.ErrNonUpdatableTable
+	CodeTooDeepNesting                        = mysql.ErrTooHighLevelOfNestingForSelect
 )
 
 func init() {
 	tableMySQLErrCodes := map[terror.ErrCode]uint16{
-		CodeUnknownColumn:      mysql.ErrBadField,
-		CodeUnknownTable:       mysql.ErrBadTable,
-		CodeAmbiguous:          mysql.ErrNonUniq,
-		CodeWrongArguments:     mysql.ErrWrongArguments,
-		CodeBadGeneratedColumn: mysql.ErrBadGeneratedColumn,
+		CodeUnknownColumn:        mysql.ErrBadField,
+		CodeUnknownTable:         mysql.ErrBadTable,
+		CodeAmbiguous:            mysql.ErrNonUniq,
+		CodeWrongArguments:       mysql.ErrWrongArguments,
+		CodeBadGeneratedColumn:   mysql.ErrBadGeneratedColumn,
+		CodeWrongValueCountOnRow: mysql.ErrWrongValueCountOnRow,
+		CodeAsOfTableNotExists:   mysql.ErrNoSuchTable,
+		CodeCantUseOptionHere:    mysql.ErrCantUseOptionHere,
+		CodeWarnDeprecatedSyntax: mysql.ErrWarnDeprecatedSyntax,
+		CodeNoDefaultForField:    mysql.ErrNoDefaultForField,
+		CodeNonUpdatableTable:    mysql.ErrNonUpdatableTable,
+		CodeTooDeepNesting:       mysql.ErrTooHighLevelOfNestingForSelect,
 	}
 	terror.ErrClassToMySQLCodes[terror.ClassOptimizerPlan] = tableMySQLErrCodes
 }
@@ -76,6 +126,9 @@ type visitInfo struct {
 type tableHintInfo struct {
 	indexNestedLoopJoinTables []model.CIStr
 	sortMergeJoinTables       []model.CIStr
+	indexOnlyTables           []model.CIStr
+	cardinalityHints          []*ast.TableOptimizerHint
+	noDecorrelate             bool
 }
 
 func (info *tableHintInfo) ifPreferMergeJoin(tableNames ...*model.CIStr) bool {
@@ -113,6 +166,18 @@ func (info *tableHintInfo) ifPreferINLJ(tableNames ...*model.CIStr) bool {
 	return false
 }
 
+func (info *tableHintInfo) ifPreferIndexOnly(tableName *model.CIStr) bool {
+	if tableName == nil {
+		return false
+	}
+	for _, curEntry := range info.indexOnlyTables {
+		if curEntry.L == tableName.L {
+			return true
+		}
+	}
+	return false
+}
+
 // planBuilder builds Plan from an ast.Node.
 // It just builds the ast node straightforwardly.
 type planBuilder struct {
@@ -131,10 +196,67 @@ type planBuilder struct {
 	visitInfo     []visitInfo
 	tableHintInfo []tableHintInfo
 	optFlag       uint64
+	// asOfTS is the AS OF TIMESTAMP read timestamp of the tables in the statement, 0 if they are read at the current time.
+	asOfTS uint64
+	// readCurrent is true if any table in the statement is read at the current time.
+	readCurrent bool
+	// tableIS is the InfoSchema the tables read by the statement are resolved in if it's set, e.g. a hypothetical
+	// schema with a proposed index, see OptimizeWithTableSchema.
+	tableIS infoschema.InfoSchema
+	// inSelectFields means the select fields are being rewritten, the new plan of the rewriting is always kept, so an
+	// uncorrelated scalar subquery can be built into the plan instead of being evaluated, see handleScalarSubquery.
+	inSelectFields bool
+	// inCondition means the conditions of WHERE, HAVING or ON are being rewritten, their comparisons are checked by
+	// checkImplicitConversion.
+	inCondition bool
+	// noDecorrelate is set by the last built query block with the NO_DECORRELATE hint and
+	// consumed by the apply built on it.
+	noDecorrelate bool
+	// applyCount is the number of the applies built, keptApply is the 1-based offset of the apply kept correlated, 0
+	// means none, see OptimizeSubqueryAlternatives.
+	applyCount int
+	keptApply  int
+	// subqueryAlternatives means the offsets of the correlated applies are recorded in correlatedApplies, see
+	// OptimizeSubqueryAlternatives.
+	subqueryAlternatives bool
+	correlatedApplies    []int
+	// topNode is the top level SELECT or UNION of the statement, SQL_CALC_FOUND_ROWS can only be used on it.
+	topNode ast.ResultSetNode
+	// overrideHints means the table hints of the top level query blocks are replaced by hintOverride.
+	overrideHints bool
+	hintOverride  []*ast.TableOptimizerHint
+	// selectOffset is the offset of the last query block started to build.
+	selectOffset int
+	// selectOffsets stores the query block offsets of the SELECTs of the statement, see collectQueryBlocks.
+	selectOffsets map[*ast.SelectStmt]int
+	// blockHints stores the hints bound to every query block, including the block prefixed hints specified in the
+	// other query blocks.
+	blockHints map[int][]*ast.TableOptimizerHint
+	// rowFilters stores the row filter builders of the tables, see RegisterRowFilter.
+	rowFilters map[int64]RowFilterBuilder
+	// handleTables stores the tables whose handle column must be read, because the hidden `_rowid` is explicitly
+	// referenced, see rowIDResultField, or the handles break the ties of ORDER BY, see markStableSortTables.
+	handleTables map[*ast.TableName]bool
+	// nestingDepth is the nesting level of the SELECTs and the parenthesized joins on the right of a join being built,
+	// it's checked against the MaxSubqueryDepth session variable, so a deeply nested statement fails instead of
+	// overflowing the stack by the recursive building.
+	nestingDepth int
+	// columnAccess collects the columns of every table referenced by the statement if it isn't nil, see
+	// OptimizeWithColumnAccess.
+	columnAccess ColumnAccess
 }
 
 func (b *planBuilder) build(node ast.Node) Plan {
 	b.optFlag = flagPrunColumns
+	if b.selectOffsets == nil {
+		b.collectQueryBlocks(node)
+	}
+	if b.rowFilters == nil {
+		b.rowFilters = registeredRowFilters()
+	}
+	if b.handleTables == nil {
+		b.handleTables = collectRowIDTables(node)
+	}
 	switch x := node.(type) {
 	case *ast.AdminStmt:
 		return b.buildAdmin(x)
@@ -153,8 +275,10 @@ func (b *planBuilder) build(node ast.Node) Plan {
 	case *ast.PrepareStmt:
 		return b.buildPrepare(x)
 	case *ast.SelectStmt:
+		b.topNode = x
 		return b.buildSelect(x)
 	case *ast.UnionStmt:
+		b.topNode = x
 		return b.buildUnion(x)
 	case *ast.UpdateStmt:
 		return b.buildUpdate(x)
@@ -191,27 +315,34 @@ func (b *planBuilder) buildExecute(v *ast.ExecuteStmt) Plan {
 	return exe
 }
 
+// buildDo builds a projection of the expressions over a dual of one row, it evaluates the expressions but returns no
+// columns. The subqueries in the expressions are built above the dual like those of a SELECT without FROM.
 func (b *planBuilder) buildDo(v *ast.DoStmt) Plan {
 	exprs := make([]expression.Expression, 0, len(v.Exprs))
-	dual := TableDual{RowCount: 1}.init(b.allocator, b.ctx)
+	var p LogicalPlan = TableDual{RowCount: 1}.init(b.allocator, b.ctx)
+	p.SetSchema(expression.NewSchema())
 	for _, astExpr := range v.Exprs {
-		expr, _, err := b.rewrite(astExpr, dual, nil, true)
+		expr, np, err := b.rewrite(astExpr, p, nil, true)
 		if err != nil {
 			b.err = errors.Trace(err)
 			return nil
 		}
+		p = np
 		exprs = append(exprs, expr)
 	}
-	dual.SetSchema(expression.NewSchema())
-	p := Projection{Exprs: exprs}.init(b.allocator, b.ctx)
-	addChild(p, dual)
-	p.self = p
-	p.SetSchema(expression.NewSchema())
-	return p
+	proj := Projection{Exprs: exprs}.init(b.allocator, b.ctx)
+	addChild(proj, p)
+	proj.self = proj
+	proj.SetSchema(expression.NewSchema())
+	return proj
 }
 
 func (b *planBuilder) buildSet(v *ast.SetStmt) Plan {
 	p := &Set{}
+	// The value may be a scalar subquery, e.g. set @x = (select max(a) from t), which is built against an empty row.
+	// The subquery isn't correlated, so it's evaluated here and must return at most one row of one column.
+	mockTablePlan := TableDual{}.init(b.allocator, b.ctx)
+	mockTablePlan.SetSchema(expression.NewSchema())
 	for _, vars := range v.Variables {
 		assign := &expression.VarAssignment{
 			Name:     vars.Name,
@@ -219,7 +350,7 @@ func (b *planBuilder) buildSet(v *ast.SetStmt) Plan {
 			IsSystem: vars.IsSystem,
 		}
 		if _, ok := vars.Value.(*ast.DefaultExpr); !ok {
-			assign.Expr, _, b.err = b.rewrite(vars.Value, nil, nil, true)
+			assign.Expr, _, b.err = b.rewrite(vars.Value, mockTablePlan, nil, true)
 			if b.err != nil {
 				return nil
 			}
@@ -263,25 +394,23 @@ func (b *planBuilder) detectSelectAgg(sel *ast.SelectStmt) bool {
 	return false
 }
 
+// availableIndices returns the indices allowed by the index hints and whether the table scan is allowed.
+// IGNORE INDEX takes precedence over USE INDEX and FORCE INDEX like MySQL, an index both used and ignored is removed
+// from the used indices, and if none of them remains, it's the same as an empty USE INDEX, i.e. only the table scan
+// is allowed.
 func availableIndices(hints []*ast.IndexHint, tableInfo *model.TableInfo) (indices []*model.IndexInfo, includeTableScan bool) {
-	var usableHints []*ast.IndexHint
-	for _, hint := range hints {
-		if hint.HintScope == ast.HintForScan {
-			usableHints = append(usableHints, hint)
-		}
-	}
 	publicIndices := make([]*model.IndexInfo, 0, len(tableInfo.Indices))
 	for _, index := range tableInfo.Indices {
 		if index.State == model.StatePublic {
 			publicIndices = append(publicIndices, index)
 		}
 	}
-	if len(usableHints) == 0 {
+	if len(hints) == 0 {
 		return publicIndices, true
 	}
 	var hasUse bool
 	var ignores []*model.IndexInfo
-	for _, hint := range usableHints {
+	for _, hint := range hints {
 		switch hint.HintType {
 		case ast.HintUse, ast.HintForce:
 			// Currently we don't distinguish between Force and Use because our cost estimation is not reliable.
@@ -314,6 +443,59 @@ func availableIndices(hints []*ast.IndexHint, tableInfo *model.TableInfo) (indic
 	return removeIgnores(publicIndices, ignores), true
 }
 
+// indexHintsOfScope returns the hints of the scope in hints.
+func indexHintsOfScope(hints []*ast.IndexHint, scope ast.IndexHintScope) []*ast.IndexHint {
+	var scopeHints []*ast.IndexHint
+	for _, hint := range hints {
+		if hint.HintScope == scope {
+			scopeHints = append(scopeHints, hint)
+		}
+	}
+	return scopeHints
+}
+
+// checkIndexHintConflicts appends a warning for each index both in a USE or FORCE INDEX hint and in an IGNORE INDEX
+// hint of the same table, if the scopes of the two hints overlap. The conflicts are resolved by availableIndices.
+func (b *planBuilder) checkIndexHintConflicts(hints []*ast.IndexHint, tableInfo *model.TableInfo) {
+	warned := make(map[string]bool)
+	for _, use := range hints {
+		if use.HintType == ast.HintIgnore {
+			continue
+		}
+		for _, ignore := range hints {
+			if ignore.HintType != ast.HintIgnore || !indexHintScopesOverlap(use.HintScope, ignore.HintScope) {
+				continue
+			}
+			for _, name := range use.IndexNames {
+				if warned[name.L] || !containsIndexName(ignore.IndexNames, name) {
+					continue
+				}
+				warned[name.L] = true
+				hintType := "USE"
+				if use.HintType == ast.HintForce {
+					hintType = "FORCE"
+				}
+				b.ctx.GetSessionVars().StmtCtx.AppendWarning(ErrIndexHintConflict.GenByArgs(name.O, tableInfo.Name.O, hintType))
+			}
+		}
+	}
+}
+
+// indexHintScopesOverlap checks whether the hints of scope a and b are applied together, the hints without a scope
+// are applied with the hints of any scope.
+func indexHintScopesOverlap(a, b ast.IndexHintScope) bool {
+	return a == b || a == ast.HintForScan || b == ast.HintForScan
+}
+
+func containsIndexName(names []model.CIStr, name model.CIStr) bool {
+	for _, n := range names {
+		if n.L == name.L {
+			return true
+		}
+	}
+	return false
+}
+
 func removeIgnores(indices, ignores []*model.IndexInfo) []*model.IndexInfo {
 	if len(ignores) == 0 {
 		return indices
@@ -386,7 +568,7 @@ func getColsInfo(tn *ast.TableName) (indicesInfo []*model.IndexInfo, colsInfo []
 			}
 		}
 	}
-	indices, _ := availableIndices(tn.IndexHints, tn.TableInfo)
+	indices, _ := availableIndices(indexHintsOfScope(tn.IndexHints, ast.HintForScan), tn.TableInfo)
 	for _, index := range indices {
 		for _, idx := range tn.TableInfo.Indices {
 			if index.Name.L == idx.Name.L {
@@ -657,6 +839,11 @@ func (b *planBuilder) buildInsert(insert *ast.InsertStmt) Plan {
 		db:        tn.DBInfo.Name.L,
 		table:     tableInfo.Name.L,
 	})
+	if insert.IsReplace {
+		// REPLACE deletes the rows conflicting with the new rows before inserting them, like MySQL, it requires the
+		// DELETE privilege too. The rest is built the same as INSERT.
+		b.visitInfo = appendVisitInfo(b.visitInfo, mysql.DeletePriv, tn.DBInfo.Name.L, tableInfo.Name.L, "")
+	}
 
 	columnByName := make(map[string]*table.Column, len(insertPlan.Table.Cols()))
 	for _, col := range insertPlan.Table.Cols() {
@@ -676,36 +863,9 @@ func (b *planBuilder) buildInsert(insert *ast.InsertStmt) Plan {
 		}
 	}
 
-	cols := insertPlan.Table.Cols()
-	maxValuesItemLength := 0 // the max length of items in VALUES list.
-	for _, valuesItem := range insert.Lists {
-		exprList := make([]expression.Expression, 0, len(valuesItem))
-		for i, valueItem := range valuesItem {
-			var expr expression.Expression
-			var err error
-			if dft, ok := valueItem.(*ast.DefaultExpr); ok {
-				if dft.Name != nil {
-					expr, err = b.findDefaultValue(cols, dft.Name)
-				} else {
-					expr, err = b.getDefaultValue(cols[i])
-				}
-			} else if val, ok := valueItem.(*ast.ValueExpr); ok {
-				expr = &expression.Constant{
-					Value:   val.Datum,
-					RetType: &val.Type,
-				}
-			} else {
-				expr, _, err = b.rewrite(valueItem, nil, nil, true)
-			}
-			if err != nil {
-				b.err = errors.Trace(err)
-			}
-			exprList = append(exprList, expr)
-		}
-		if len(valuesItem) > maxValuesItemLength {
-			maxValuesItemLength = len(valuesItem)
-		}
-		insertPlan.Lists = append(insertPlan.Lists, exprList)
+	maxValuesItemLength := b.buildValuesLists(insert, insertPlan)
+	if b.err != nil {
+		return nil
 	}
 
 	// It's for INSERT INTO t VALUES (...)
@@ -783,6 +943,10 @@ func (b *planBuilder) buildInsert(insert *ast.InsertStmt) Plan {
 			Expr: expr,
 		})
 	}
+	b.buildInsertFills(insert, insertPlan, mockTablePlan, maxValuesItemLength)
+	if b.err != nil {
+		return nil
+	}
 	if insert.Select != nil {
 		selectPlan := b.build(insert.Select)
 		if b.err != nil {
@@ -808,6 +972,162 @@ func (b *planBuilder) buildInsert(insert *ast.InsertStmt) Plan {
 	return insertPlan
 }
 
+// omittedInsertColumns returns the public columns of the table that the INSERT statement doesn't give a value.
+func omittedInsertColumns(insert *ast.InsertStmt, tbl table.Table, maxValuesItemLength int) []*table.Column {
+	named := make(map[string]struct{}, len(insert.Columns)+len(insert.Setlist))
+	switch {
+	case len(insert.Columns) > 0:
+		for _, col := range insert.Columns {
+			named[col.Name.L] = struct{}{}
+		}
+	case len(insert.Setlist) > 0:
+		for _, assign := range insert.Setlist {
+			named[assign.Column.Name.L] = struct{}{}
+		}
+	case len(insert.Lists) > 0 && maxValuesItemLength == 0:
+		// "insert into t values ()" omits all the columns.
+	default:
+		return nil
+	}
+	var omitted []*table.Column
+	for _, col := range tbl.Cols() {
+		if _, ok := named[col.Name.L]; !ok {
+			omitted = append(omitted, col)
+		}
+	}
+	return omitted
+}
+
+// buildInsertFills resolves how the columns omitted by the INSERT statement are filled, and builds the
+// expressions of the generated columns. An omitted column takes its explicit DEFAULT, or the implicit
+// default of its type. AUTO_INCREMENT columns are left to the executor, which allocates the next id,
+// and generated columns are always computed rather than defaulted. A NOT NULL column without a default
+// is also left to the executor in strict sql mode, which raises the error when a row is inserted, unless
+// the statement is INSERT IGNORE, then the column takes the zero value of its type with a warning.
+func (b *planBuilder) buildInsertFills(insert *ast.InsertStmt, insertPlan *Insert, mockTablePlan LogicalPlan, maxValuesItemLength int) {
+	sc := b.ctx.GetSessionVars().StmtCtx
+	for _, col := range omittedInsertColumns(insert, insertPlan.Table, maxValuesItemLength) {
+		if len(col.GeneratedExprString) != 0 || mysql.HasAutoIncrementFlag(col.Flag) {
+			continue
+		}
+		var value types.Datum
+		if col.DefaultValue == nil && mysql.HasNotNullFlag(col.Flag) && col.Tp != mysql.TypeEnum &&
+			b.ctx.GetSessionVars().StrictSQLMode {
+			if !insert.Ignore {
+				continue
+			}
+			sc.AppendWarning(ErrNoDefaultForField.GenByArgs(col.Name.O))
+			value = table.GetZeroValue(col.ToInfo())
+		} else {
+			var err error
+			value, err = table.GetColDefaultValue(b.ctx, col.ToInfo())
+			if err != nil {
+				b.err = errors.Trace(err)
+				return
+			}
+		}
+		// The implicit default of ENUM is the raw first element, cast it to the column type.
+		value, err := table.CastValue(b.ctx, value, col.ToInfo())
+		if err != nil {
+			b.err = errors.Trace(err)
+			return
+		}
+		insertPlan.DefaultFills = append(insertPlan.DefaultFills, &expression.Assignment{
+			Col:  insertPlan.tableSchema.Columns[col.Offset],
+			Expr: &expression.Constant{Value: value, RetType: &col.FieldType},
+		})
+	}
+	for _, col := range insertPlan.Table.Cols() {
+		if col.GeneratedExpr == nil {
+			continue
+		}
+		expr, _, err := b.rewrite(col.GeneratedExpr, mockTablePlan, nil, true)
+		if err != nil {
+			b.err = errors.Trace(err)
+			return
+		}
+		insertPlan.GenCols = append(insertPlan.GenCols, &expression.Assignment{
+			Col:  insertPlan.tableSchema.Columns[col.Offset],
+			Expr: expr,
+		})
+	}
+}
+
+// checkValuesItemCount checks the arity of the num-th row of a VALUES list against the first row and the column list.
+// "insert into t values (), ()" is valid, while "insert into t values (), (1)", "insert into t values (1,2), (1)",
+// "insert into t (c1) values ()" and "insert into t (c1) values (1,2)" are not.
+// Without the column list, the rows are checked against the table width by the executor, because the table may
+// change after the plan is built.
+func checkValuesItemCount(firstLen, valuesLen, num int, insert *ast.InsertStmt) error {
+	if firstLen != valuesLen {
+		return ErrWrongValueCountOnRow.GenByArgs(num + 1)
+	}
+	if len(insert.Columns) > 0 && valuesLen != len(insert.Columns) {
+		return ErrWrongValueCountOnRow.GenByArgs(num + 1)
+	}
+	return nil
+}
+
+// getValuesItemDefault returns the default value of the i-th item of a VALUES row.
+// The item is inserted into the i-th column of the column list, or the i-th column of the table without it.
+func (b *planBuilder) getValuesItemDefault(insert *ast.InsertStmt, cols []*table.Column, i, num int) (*expression.Constant, error) {
+	if len(insert.Columns) > 0 {
+		return b.findDefaultValue(cols, insert.Columns[i])
+	}
+	if i >= len(cols) {
+		return nil, ErrWrongValueCountOnRow.GenByArgs(num + 1)
+	}
+	return b.getDefaultValue(cols[i])
+}
+
+// buildValuesLists rewrites every row of the VALUES list of an INSERT statement and stores them into the insert plan.
+// Each row is checked by checkValuesItemCount, and the DEFAULT of a row is resolved by the column it is inserted into.
+// The values are cast to the column types by the executor, which handles the truncation according to the sql mode.
+// It returns the max length of the rows.
+func (b *planBuilder) buildValuesLists(insert *ast.InsertStmt, insertPlan *Insert) int {
+	if len(insert.Lists) == 0 {
+		return 0
+	}
+	cols := insertPlan.Table.Cols()
+	firstLen := len(insert.Lists[0])
+	maxValuesItemLength := 0 // the max length of items in VALUES list.
+	for num, valuesItem := range insert.Lists {
+		if err := checkValuesItemCount(firstLen, len(valuesItem), num, insert); err != nil {
+			b.err = errors.Trace(err)
+			return 0
+		}
+		exprList := make([]expression.Expression, 0, len(valuesItem))
+		for i, valueItem := range valuesItem {
+			var expr expression.Expression
+			var err error
+			if dft, ok := valueItem.(*ast.DefaultExpr); ok {
+				if dft.Name != nil {
+					expr, err = b.findDefaultValue(cols, dft.Name)
+				} else {
+					expr, err = b.getValuesItemDefault(insert, cols, i, num)
+				}
+			} else if val, ok := valueItem.(*ast.ValueExpr); ok {
+				expr = &expression.Constant{
+					Value:   val.Datum,
+					RetType: &val.Type,
+				}
+			} else {
+				expr, _, err = b.rewrite(valueItem, nil, nil, true)
+			}
+			if err != nil {
+				b.err = errors.Trace(err)
+				return 0
+			}
+			exprList = append(exprList, expr)
+		}
+		if len(valuesItem) > maxValuesItemLength {
+			maxValuesItemLength = len(valuesItem)
+		}
+		insertPlan.Lists = append(insertPlan.Lists, exprList)
+	}
+	return maxValuesItemLength
+}
+
 func (b *planBuilder) buildLoadData(ld *ast.LoadDataStmt) Plan {
 	p := &LoadData{
 		IsLocal:    ld.IsLocal,
@@ -916,6 +1236,8 @@ func (b *planBuilder) buildExplain(explain *ast.ExplainStmt) Plan {
 		schema.Append(buildColumn("", "count", mysql.TypeDouble, mysql.MaxRealWidth))
 		p.SetSchema(schema)
 		p.explainedPlans = map[string]bool{}
+		p.QueryBlocks = make(map[string]string)
+		p.prepareQueryBlocks(p.StmtPlan.(PhysicalPlan))
 		p.prepareRootTaskInfo(p.StmtPlan.(PhysicalPlan))
 	} else {
 		schema := expression.NewSchema(make([]*expression.Column, 0, 3)...)
@@ -16,6 +16,7 @@ package plan
 import (
 	. "github.com/pingcap/check"
 	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/model"
 )
 
 var _ = Suite(&testPlanBuilderSuite{})
@@ -56,3 +57,59 @@ func (s *testPlanBuilderSuite) TestShow(c *C) {
 		}
 	}
 }
+
+func (s *testPlanBuilderSuite) TestUnresolvedColumnsAfterAlter(c *C) {
+	tblInfo := &model.TableInfo{
+		Name: model.NewCIStr("t"),
+		Columns: []*model.ColumnInfo{
+			{Name: model.NewCIStr("a"), State: model.StatePublic},
+			{Name: model.NewCIStr("b"), State: model.StatePublic},
+			{Name: model.NewCIStr("c"), State: model.StatePublic},
+			{Name: model.NewCIStr("d"), State: model.StateWriteOnly},
+		},
+	}
+	tests := []struct {
+		expr       string
+		dropped    []string
+		renamed    map[string]string
+		unresolved []string
+	}{
+		{"a + b", nil, nil, nil},
+		{"a + test.t.b * t.c", []string{"c"}, nil, []string{"t.c"}},
+		{"a + d", nil, nil, []string{"d"}},
+		{"concat(a, b) + c", nil, map[string]string{"a": "x", "c": "y"}, []string{"a", "c"}},
+		{"x + b", []string{"b"}, map[string]string{"a": "x"}, []string{"b"}},
+		{"b", nil, map[string]string{"a": "b"}, []string{"b"}},
+		{"other.t.a + t2.b", nil, nil, []string{"other.t.a", "t2.b"}},
+	}
+	for _, tt := range tests {
+		comment := Commentf("for %s", tt.expr)
+		var dropped []model.CIStr
+		for _, name := range tt.dropped {
+			dropped = append(dropped, model.NewCIStr(name))
+		}
+		renamed := make(map[string]model.CIStr, len(tt.renamed))
+		for oldName, newName := range tt.renamed {
+			renamed[oldName] = model.NewCIStr(newName)
+		}
+		cols, err := UnresolvedColumnsAfterAlter(model.NewCIStr("test"), tblInfo, tt.expr, dropped, renamed)
+		c.Assert(err, IsNil, comment)
+		var unresolved []string
+		for _, col := range cols {
+			name := col.Name.L
+			if col.Table.L != "" {
+				name = col.Table.L + "." + name
+			}
+			if col.Schema.L != "" {
+				name = col.Schema.L + "." + name
+			}
+			unresolved = append(unresolved, name)
+		}
+		c.Assert(unresolved, DeepEquals, tt.unresolved, comment)
+	}
+
+	_, err := UnresolvedColumnsAfterAlter(model.NewCIStr("test"), tblInfo, "a from t", nil, nil)
+	c.Assert(err, NotNil)
+	_, err = UnresolvedColumnsAfterAlter(model.NewCIStr("test"), tblInfo, "a +", nil, nil)
+	c.Assert(err, NotNil)
+}
@@ -116,10 +116,16 @@ type Insert struct {
 	Lists       [][]expression.Expression
 	Setlist     []*expression.Assignment
 	OnDuplicate []*expression.Assignment
+	// DefaultFills are the values of the columns omitted by the statement.
+	DefaultFills []*expression.Assignment
+	// GenCols are the generated columns, computed from the other columns of the row.
+	GenCols []*expression.Assignment
 
 	IsReplace bool
 	Priority  mysql.PriorityEnum
-	Ignore    bool
+	// Ignore is set by INSERT IGNORE. The duplicate-key and NOT NULL errors of a row become warnings, and the row
+	// is skipped or gets the implicit default value. A duplicate key is still handled by OnDuplicate if there is one, like MySQL.
+	Ignore bool
 }
 
 // AnalyzeColumnsTask is used for analyze columns.
@@ -169,6 +175,53 @@ type Explain struct {
 	StmtPlan       Plan
 	Rows           [][]types.Datum
 	explainedPlans map[string]bool
+	// QueryBlocks maps the ID of every explained plan to the name of the query block it belongs to, so the
+	// sub-plans of the subqueries can be presented with the block names used by block prefixed hints.
+	// The query block of the plans out of the top level block is rendered in their operator info.
+	QueryBlocks map[string]string
+}
+
+// prepareQueryBlocks records the query block of every plan of the tree in QueryBlocks and returns the query block of p.
+// The operators introduced by the optimizer aren't tagged, they work on the output of their first child, so they
+// belong to the query block of it. For a reader, the children are its cop plans.
+func (e *Explain) prepareQueryBlocks(p PhysicalPlan) int {
+	childOffset := 0
+	for _, child := range p.Children() {
+		offset := e.prepareQueryBlocks(child.(PhysicalPlan))
+		if childOffset == 0 {
+			childOffset = offset
+		}
+	}
+	// The cop plans are listed from the bottom up.
+	for _, copPlan := range readerCopPlans(p) {
+		if offset := copPlan.queryBlockOffset(); offset != 0 {
+			childOffset = offset
+		}
+		if childOffset != 0 {
+			e.QueryBlocks[copPlan.ID()] = QueryBlockName(childOffset)
+		}
+	}
+	offset := p.queryBlockOffset()
+	if offset == 0 {
+		offset = childOffset
+	}
+	if offset != 0 {
+		e.QueryBlocks[p.ID()] = QueryBlockName(offset)
+	}
+	return offset
+}
+
+// readerCopPlans returns the cop plans of p if it's a reader, they aren't children of the reader.
+func readerCopPlans(p PhysicalPlan) []PhysicalPlan {
+	switch x := p.(type) {
+	case *PhysicalTableReader:
+		return x.TablePlans
+	case *PhysicalIndexReader:
+		return x.IndexPlans
+	case *PhysicalIndexLookUpReader:
+		return append(append([]PhysicalPlan(nil), x.IndexPlans...), x.TablePlans...)
+	}
+	return nil
 }
 
 func (e *Explain) prepareExplainInfo(p Plan, parent Plan) error {
@@ -206,6 +259,12 @@ func (e *Explain) prepareExplainInfo4DAGTask(p PhysicalPlan, taskType string) {
 	parentInfo := strings.Join(parentIDs, ",")
 	childrenInfo := strings.Join(childrenIDs, ",")
 	operatorInfo := p.ExplainInfo()
+	if block, ok := e.QueryBlocks[p.ID()]; ok && block != QueryBlockName(1) {
+		if operatorInfo != "" {
+			operatorInfo += ", "
+		}
+		operatorInfo += "query block:" + block
+	}
 	count := p.statsProfile().count
 	row := types.MakeDatums(p.ID(), parentInfo, childrenInfo, taskType, operatorInfo, count)
 	e.Rows = append(e.Rows, row)
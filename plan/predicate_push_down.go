@@ -30,7 +30,7 @@ func (s *ppdSolver) optimize(lp LogicalPlan, _ context.Context, _ *idAllocator)
 }
 
 func addSelection(p Plan, child LogicalPlan, conditions []expression.Expression, allocator *idAllocator) error {
-	conditions = expression.PropagateConstant(p.context(), conditions)
+	conditions = propagateConstant(p.context(), conditions)
 	selection := Selection{Conditions: conditions}.init(allocator, p.context())
 	selection.SetSchema(child.Schema().Clone())
 	return InsertPlan(p, child, selection)
@@ -43,7 +43,7 @@ func (p *Selection) PredicatePushDown(predicates []expression.Expression) ([]exp
 		return nil, nil, errors.Trace(err)
 	}
 	if len(retConditions) > 0 {
-		p.Conditions = expression.PropagateConstant(p.ctx, retConditions)
+		p.Conditions = propagateConstant(p.ctx, retConditions)
 		return nil, p, nil
 	}
 	err = RemovePlan(p)
@@ -56,11 +56,34 @@ func (p *Selection) PredicatePushDown(predicates []expression.Expression) ([]exp
 // PredicatePushDown implements LogicalPlan PredicatePushDown interface.
 func (p *DataSource) PredicatePushDown(predicates []expression.Expression) ([]expression.Expression, LogicalPlan, error) {
 	if UseDAGPlanBuilder(p.ctx) {
+		var rowIDConds []expression.Expression
+		predicates, rowIDConds = splitRowIDConds(predicates)
 		_, p.pushedDownConds, predicates = expression.ExpressionsToPB(p.ctx.GetSessionVars().StmtCtx, predicates, p.ctx.GetClient())
+		predicates = append(predicates, rowIDConds...)
 	}
 	return predicates, p, nil
 }
 
+// splitRowIDConds splits the conditions on the hidden `_rowid` from conds. The `_rowid` is decoded from the row keys
+// after the rows are read, so these conditions can't be evaluated by the storage.
+func splitRowIDConds(conds []expression.Expression) (others, rowIDConds []expression.Expression) {
+	for _, cond := range conds {
+		onRowID := false
+		for _, col := range expression.ExtractColumns(cond) {
+			if col.ID == model.ExtraHandleID {
+				onRowID = true
+				break
+			}
+		}
+		if onRowID {
+			rowIDConds = append(rowIDConds, cond)
+		} else {
+			others = append(others, cond)
+		}
+	}
+	return others, rowIDConds
+}
+
 // PredicatePushDown implements LogicalPlan PredicatePushDown interface.
 func (p *TableDual) PredicatePushDown(predicates []expression.Expression) ([]expression.Expression, LogicalPlan, error) {
 	return predicates, p, nil
@@ -73,10 +96,10 @@ func (p *LogicalJoin) PredicatePushDown(predicates []expression.Expression) (ret
 		return nil, nil, errors.Trace(err)
 	}
 	if !UseDAGPlanBuilder(p.ctx) { // close join reorder for new plan.
-		groups, valid := tryToGetJoinGroup(p)
+		groups, crosses, valid := tryToGetJoinGroup(p)
 		if valid {
 			e := joinReOrderSolver{allocator: p.allocator, ctx: p.ctx}
-			e.reorderJoin(groups, predicates)
+			e.reorderJoin(groups, crosses, predicates)
 			newJoin := e.resultJoin
 			if len(p.parents) > 0 {
 				parent := p.parents[0]
@@ -103,7 +126,7 @@ func (p *LogicalJoin) PredicatePushDown(predicates []expression.Expression) (ret
 		tempCond = append(tempCond, expression.ScalarFuncs2Exprs(p.EqualConditions)...)
 		tempCond = append(tempCond, p.OtherConditions...)
 		tempCond = append(tempCond, predicates...)
-		equalCond, leftPushCond, rightPushCond, otherCond = extractOnCondition(expression.PropagateConstant(p.ctx, tempCond), leftPlan, rightPlan)
+		equalCond, leftPushCond, rightPushCond, otherCond = extractOnCondition(propagateConstant(p.ctx, tempCond), leftPlan, rightPlan)
 	}
 	switch p.JoinType {
 	case LeftOuterJoin, LeftOuterSemiJoin:
@@ -0,0 +1,49 @@
+// Copyright 2017 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"github.com/pingcap/tidb/context"
+	"github.com/pingcap/tidb/expression"
+)
+
+// constantPropagator derives the predicates implied by the `col = const` conditions of each Selection, e.g. `a = 5 AND
+// b = a` gets `b = 5`, so the predicate push down and the range builder can use them for the index of b. Only the
+// constants are substituted, the non-deterministic functions like RAND() are never constants after constant folding,
+// so the derived predicates always hold when the original ones hold.
+type constantPropagator struct {
+}
+
+func (s *constantPropagator) optimize(p LogicalPlan, ctx context.Context, alloc *idAllocator) (LogicalPlan, error) {
+	propagatePlanConstant(p, ctx)
+	return p, nil
+}
+
+func propagatePlanConstant(p LogicalPlan, ctx context.Context) {
+	if sel, ok := p.(*Selection); ok {
+		sel.Conditions = expression.PropagateConstant(ctx, sel.Conditions)
+	}
+	for _, child := range p.Children() {
+		propagatePlanConstant(child.(LogicalPlan), ctx)
+	}
+}
+
+// propagateConstant propagates the constants of the conditions moved by predicate push down, unless constant
+// propagation is disabled by tidb_opt_propagate_constant.
+func propagateConstant(ctx context.Context, conditions []expression.Expression) []expression.Expression {
+	if !ctx.GetSessionVars().AllowPropagateConstant {
+		return conditions
+	}
+	return expression.PropagateConstant(ctx, conditions)
+}
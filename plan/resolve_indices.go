@@ -201,6 +201,14 @@ func (p *Sort) ResolveIndices() {
 	}
 }
 
+// ResolveIndices implements Plan interface.
+func (p *Limit) ResolveIndices() {
+	p.basePlan.ResolveIndices()
+	for _, item := range p.ByItems {
+		item.Expr.ResolveIndices(p.children[0].Schema())
+	}
+}
+
 // ResolveIndices implements Plan interface.
 func (p *TopN) ResolveIndices() {
 	p.basePlan.ResolveIndices()
@@ -246,6 +254,13 @@ func (p *Insert) ResolveIndices() {
 		set.Col.ResolveIndices(p.tableSchema)
 		set.Expr.ResolveIndices(p.tableSchema)
 	}
+	for _, fill := range p.DefaultFills {
+		fill.Col.ResolveIndices(p.tableSchema)
+	}
+	for _, gen := range p.GenCols {
+		gen.Col.ResolveIndices(p.tableSchema)
+		gen.Expr.ResolveIndices(p.tableSchema)
+	}
 }
 
 // ResolveIndices implements Plan interface.
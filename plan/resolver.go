@@ -23,6 +23,7 @@ import (
 	"github.com/pingcap/tidb/model"
 	"github.com/pingcap/tidb/mysql"
 	"github.com/pingcap/tidb/table"
+	"github.com/pingcap/tidb/terror"
 	"github.com/pingcap/tidb/util/types"
 )
 
@@ -388,13 +389,23 @@ func (nr *nameResolver) handleTableName(tn *ast.TableName) {
 		tn.SetResultFields(tableName.GetResultFields())
 		return
 	}
-	table, err := nr.Info.TableByName(tn.Schema, tn.Name)
+	is := nr.Info
+	if tn.AsOf != nil {
+		_, is, nr.Err = getAsOfInfoSchema(nr.Ctx, tn.AsOf)
+		if nr.Err != nil {
+			return
+		}
+	}
+	table, err := is.TableByName(tn.Schema, tn.Name)
 	if err != nil {
+		if tn.AsOf != nil && terror.ErrorEqual(err, infoschema.ErrTableNotExists) {
+			err = ErrAsOfTableNotExists.GenByArgs(tn.Schema.O, tn.Name.O, tn.AsOf.TsExpr.GetValue())
+		}
 		nr.Err = errors.Trace(err)
 		return
 	}
 	tn.TableInfo = table.Meta()
-	dbInfo, _ := nr.Info.SchemaByName(tn.Schema)
+	dbInfo, _ := is.SchemaByName(tn.Schema)
 	tn.DBInfo = dbInfo
 
 	rfs := make([]*ast.ResultField, 0, len(tn.TableInfo.Columns))
@@ -644,7 +655,7 @@ func (nr *nameResolver) resolveColumnInTableSources(cn *ast.ColumnNameExpr, tabl
 	tableNameL := cn.Name.Table.L
 	columnNameL := cn.Name.Name.L
 	if tableNameL != "" {
-		var matchedTable ast.ResultSetNode
+		var matchedTable *ast.TableSource
 		for _, ts := range tableSources {
 			if tableNameL == ts.AsName.L {
 				// different table name.
@@ -672,6 +683,9 @@ func (nr *nameResolver) resolveColumnInTableSources(cn *ast.ColumnNameExpr, tabl
 					break
 				}
 			}
+			if matchedResultField == nil && columnNameL == "_rowid" {
+				matchedResultField = rowIDResultField(matchedTable)
+			}
 		}
 	} else {
 		for _, ts := range tableSources {
@@ -684,6 +698,19 @@ func (nr *nameResolver) resolveColumnInTableSources(cn *ast.ColumnNameExpr, tabl
 				}
 			}
 		}
+		if matchedResultField == nil && columnNameL == "_rowid" {
+			for _, ts := range tableSources {
+				rf := rowIDResultField(ts)
+				if rf == nil {
+					continue
+				}
+				if matchedResultField != nil {
+					nr.Err = ErrAmbiguous.GenByArgs(cn.Name.Name.O)
+					return true
+				}
+				matchedResultField = rf
+			}
+		}
 	}
 	if matchedResultField != nil {
 		// Bind column.
@@ -694,6 +721,31 @@ func (nr *nameResolver) resolveColumnInTableSources(cn *ast.ColumnNameExpr, tabl
 	return false
 }
 
+// rowIDResultField returns the result field of the hidden `_rowid` column of the table source, which is the handle of
+// a table without an integer primary key, nil if the table doesn't have it. The column isn't in the result fields of
+// the table, so it's only resolved when it's explicitly named and never expanded from `*`.
+func rowIDResultField(ts *ast.TableSource) *ast.ResultField {
+	tn, ok := ts.Source.(*ast.TableName)
+	if !ok || tn.TableInfo == nil || tn.TableInfo.PKIsHandle {
+		return nil
+	}
+	col := &model.ColumnInfo{
+		ID:        model.ExtraHandleID,
+		Name:      model.NewCIStr("_rowid"),
+		FieldType: *types.NewFieldType(mysql.TypeLonglong),
+		State:     model.StatePublic,
+	}
+	expr := &ast.ValueExpr{}
+	expr.SetType(&col.FieldType)
+	return &ast.ResultField{
+		Column:    col,
+		Table:     tn.TableInfo,
+		DBName:    tn.Schema,
+		Expr:      expr,
+		TableName: tn,
+	}
+}
+
 func (nr *nameResolver) resolveColumnInResultFields(ctx *resolverContext, cn *ast.ColumnNameExpr, rfs []*ast.ResultField) bool {
 	var matched *ast.ResultField
 	for _, rf := range rfs {
@@ -778,8 +830,24 @@ func (nr *nameResolver) createResultFields(field *ast.SelectField) (rfs []*ast.R
 			name := nr.tableUniqueName(field.WildCard.Schema, field.WildCard.Table)
 			tableIdx, ok1 := ctx.tableMap[name]
 			derivedTableIdx, ok2 := ctx.derivedTableMap[name]
+			if field.WildCard.Schema.L != "" {
+				// The aliased tables and the derived tables are only visible by their alias, e.g. `db.t.*` doesn't
+				// match `db.t AS x` and `db.x.*` doesn't match it either.
+				ok1 = ok1 && ctx.tables[tableIdx].AsName.L == ""
+				ok2 = false
+			}
+			if !ok1 && !ok2 && field.WildCard.Schema.L == "" {
+				tableIdx, ok1 = nr.tableIndexByName(field.WildCard.Table)
+				if nr.Err != nil {
+					return
+				}
+			}
 			if !ok1 && !ok2 {
-				nr.Err = ErrUnknownTable.GenByArgs(field.WildCard.Table.String())
+				tableName := field.WildCard.Table.String()
+				if field.WildCard.Schema.L != "" {
+					tableName = field.WildCard.Schema.String() + "." + tableName
+				}
+				nr.Err = ErrUnknownTable.GenByArgs(tableName)
 			}
 			if ok1 {
 				tableRfs = ctx.tables[tableIdx].GetResultFields()
@@ -855,6 +923,24 @@ func appendTableSources(in []*ast.TableSource, resultSetNode ast.ResultSetNode)
 	return
 }
 
+// tableIndexByName finds the table named table without alias in any schema, e.g. the table of `t.*` in
+// `SELECT t.* FROM db2.t` when the default schema is not db2. It's an error if more than one table matches.
+func (nr *nameResolver) tableIndexByName(table model.CIStr) (int, bool) {
+	idx, found := -1, false
+	for i, ts := range nr.currentContext().tables {
+		tn, ok := ts.Source.(*ast.TableName)
+		if !ok || ts.AsName.L != "" || tn.Name.L != table.L {
+			continue
+		}
+		if found {
+			nr.Err = ErrNonUniqTable.GenByArgs(table.O)
+			return -1, false
+		}
+		idx, found = i, true
+	}
+	return idx, found
+}
+
 func (nr *nameResolver) tableUniqueName(schema, table model.CIStr) string {
 	if schema.L != "" && schema.L != nr.DefaultSchema.L {
 		return schema.L + "." + table.L
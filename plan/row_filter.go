@@ -0,0 +1,86 @@
+// Copyright 2017 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"sync"
+
+	"github.com/juju/errors"
+	"github.com/pingcap/tidb/context"
+	"github.com/pingcap/tidb/expression"
+)
+
+// RowFilterBuilder builds the filter of the table rows visible to the session ctx, e.g. the rows owned by the current
+// user. The filter can only refer to the columns of schema, which is the schema of the DataSource reading the table.
+// A nil filter means all the rows are visible.
+type RowFilterBuilder func(ctx context.Context, schema *expression.Schema) (expression.Expression, error)
+
+var rowFilters = struct {
+	sync.RWMutex
+	builders map[int64]RowFilterBuilder
+}{builders: make(map[int64]RowFilterBuilder)}
+
+// RegisterRowFilter registers the row filter builder of a table. The filter is applied to every read of the table,
+// before any condition of the statement.
+func RegisterRowFilter(tableID int64, builder RowFilterBuilder) {
+	rowFilters.Lock()
+	rowFilters.builders[tableID] = builder
+	rowFilters.Unlock()
+}
+
+// UnregisterRowFilter removes the row filter builder of a table.
+func UnregisterRowFilter(tableID int64) {
+	rowFilters.Lock()
+	delete(rowFilters.builders, tableID)
+	rowFilters.Unlock()
+}
+
+// registeredRowFilters returns a copy of the registered row filter builders, so a statement is built with the same
+// filters even if they are changed concurrently.
+func registeredRowFilters() map[int64]RowFilterBuilder {
+	rowFilters.RLock()
+	defer rowFilters.RUnlock()
+	builders := make(map[int64]RowFilterBuilder, len(rowFilters.builders))
+	for id, builder := range rowFilters.builders {
+		builders[id] = builder
+	}
+	return builders
+}
+
+// buildRowFilter puts a Selection of the row filter of the table above the DataSource p, if the table has one.
+func (b *planBuilder) buildRowFilter(p *DataSource) LogicalPlan {
+	builder, ok := b.rowFilters[p.tableInfo.ID]
+	if !ok {
+		return p
+	}
+	cond, err := builder(b.ctx, p.Schema())
+	if err != nil {
+		b.err = errors.Trace(err)
+		return nil
+	}
+	if cond == nil {
+		return p
+	}
+	for _, col := range expression.ExtractColumns(cond) {
+		if !p.Schema().Contains(col) {
+			b.err = ErrUnknownColumn.GenByArgs(col.ColName.O, "row filter")
+			return nil
+		}
+	}
+	b.optFlag = b.optFlag | flagPredicatePushDown
+	selection := Selection{Conditions: expression.SplitCNFItems(cond)}.init(b.allocator, b.ctx)
+	selection.SetSchema(p.Schema().Clone())
+	addChild(selection, p)
+	return selection
+}
@@ -73,7 +73,9 @@ func (p *DataSource) getStatsProfileByFilter(conds expression.CNFExprs) *statsPr
 	}
 	for i, col := range p.Columns {
 		hist, ok := p.statisticTable.Columns[col.ID]
-		if ok {
+		if ndv, hinted := p.ndvHints[col.ID]; hinted {
+			profile.cardinality[i] = ndv
+		} else if ok {
 			profile.cardinality[i] = float64(hist.NDV)
 		} else {
 			profile.cardinality[i] = profile.count * distinctFactor
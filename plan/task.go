@@ -280,7 +280,10 @@ func (p *Limit) attach2Task(tasks ...task) task {
 		return tasks[0]
 	}
 	t := tasks[0].copy()
-	if cop, ok := t.(*copTask); ok {
+	if cop, ok := t.(*copTask); ok && (p.CalcFoundRows || p.WithTies) {
+		// All the rows are needed to calculate FOUND_ROWS() or to find the ties, so the Limit can not be pushed down.
+		t = finishCopTask(cop, p.ctx, p.allocator)
+	} else if ok {
 		// If the task is copTask, the Limit can always be pushed down.
 		// When limit be pushed down, it should remove its offset.
 		pushedDownLimit := Limit{Count: p.Offset + p.Count}.init(p.allocator, p.ctx)
@@ -78,6 +78,10 @@ func (p *Limit) convertToTopN() *TopN {
 }
 
 func (p *Limit) pushDownTopN(topN *TopN) LogicalPlan {
+	if p.CalcFoundRows || p.WithTies {
+		// The child should produce all the rows for FOUND_ROWS() or the ties, so keep the limit here.
+		return p.baseLogicalPlan.pushDownTopN(topN)
+	}
 	child := p.children[0].(LogicalPlan).pushDownTopN(p.convertToTopN())
 	if topN != nil {
 		return topN.setChild(child, false)
@@ -14,7 +14,13 @@
 package plan
 
 import (
+	"fmt"
+
+	"github.com/juju/errors"
 	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/expression"
+	"github.com/pingcap/tidb/model"
+	"github.com/pingcap/tidb/parser"
 )
 
 // AggregateFuncExtractor visits Expr tree.
@@ -45,3 +51,74 @@ func (a *AggregateFuncExtractor) Leave(n ast.Node) (ast.Node, bool) {
 	}
 	return n, true
 }
+
+// columnNameExtractor collects the column names in an expression.
+type columnNameExtractor struct {
+	cols []*ast.ColumnName
+}
+
+// Enter implements Visitor interface.
+func (e *columnNameExtractor) Enter(n ast.Node) (ast.Node, bool) {
+	return n, false
+}
+
+// Leave implements Visitor interface.
+func (e *columnNameExtractor) Leave(n ast.Node) (ast.Node, bool) {
+	if v, ok := n.(*ast.ColumnNameExpr); ok {
+		e.cols = append(e.cols, v.Name)
+	}
+	return n, true
+}
+
+// UnresolvedColumnsAfterAlter resolves the column references of exprStr, e.g. a generated column expression,
+// against the columns of the table as they would be after an ALTER TABLE, which drops the columns in dropped
+// and renames the columns in renamed from the key to the value. It returns the references that would break.
+func UnresolvedColumnsAfterAlter(dbName model.CIStr, tblInfo *model.TableInfo, exprStr string,
+	dropped []model.CIStr, renamed map[string]model.CIStr) ([]*ast.ColumnName, error) {
+	stmts, err := parser.New().Parse(fmt.Sprintf("select %s", exprStr), "", "")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var sel *ast.SelectStmt
+	if len(stmts) == 1 {
+		sel, _ = stmts[0].(*ast.SelectStmt)
+	}
+	if sel == nil || sel.From != nil || len(sel.Fields.Fields) != 1 || sel.Fields.Fields[0].Expr == nil {
+		return nil, errors.Errorf("%s is not an expression", exprStr)
+	}
+
+	droppedCols := make(map[string]struct{}, len(dropped))
+	for _, name := range dropped {
+		droppedCols[name.L] = struct{}{}
+	}
+	schema := expression.NewSchema()
+	for _, colInfo := range tblInfo.Columns {
+		if colInfo.State != model.StatePublic {
+			continue
+		}
+		if _, ok := droppedCols[colInfo.Name.L]; ok {
+			continue
+		}
+		name := colInfo.Name
+		if newName, ok := renamed[name.L]; ok {
+			name = newName
+		}
+		schema.Append(&expression.Column{
+			DBName:  dbName,
+			TblName: tblInfo.Name,
+			ColName: name,
+			RetType: &colInfo.FieldType,
+		})
+	}
+
+	extractor := &columnNameExtractor{}
+	sel.Fields.Fields[0].Expr.Accept(extractor)
+	var unresolved []*ast.ColumnName
+	for _, name := range extractor.cols {
+		// A column that is ambiguous after renaming can't be resolved either.
+		if col, err := schema.FindColumn(name); col == nil || err != nil {
+			unresolved = append(unresolved, name)
+		}
+	}
+	return unresolved, nil
+}
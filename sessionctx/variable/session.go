@@ -168,9 +168,37 @@ type SessionVars struct {
 	// AllowAggPushDown can be set to false to forbid aggregation push down.
 	AllowAggPushDown bool
 
+	// AllowPropagateConstant can be set to false to forbid deriving the predicates by constant propagation.
+	AllowPropagateConstant bool
+
 	// AllowInSubqueryUnFolding can be set to true to fold in subquery
 	AllowInSubqueryUnFolding bool
 
+	// AllowSkipRowHandle can be set to true to read the tables that are not modified in the transaction without handle.
+	AllowSkipRowHandle bool
+
+	// NoCartesianJoin can be set to true to reject the join without join condition unless it is written as CROSS JOIN.
+	NoCartesianJoin bool
+
+	// RejectImplicitConversion can be set to true to reject the comparisons between a string and a number in the
+	// conditions, which are compared as floating point numbers.
+	RejectImplicitConversion bool
+
+	// WarnNondeterministicLimit can be set to true to warn the LIMIT which isn't applied over an ORDER BY of unique key.
+	WarnNondeterministicLimit bool
+
+	// MaterializeScalarSubquery can be set to true to keep the uncorrelated scalar subqueries of the select fields in
+	// the plan, which are evaluated once at execution time, instead of evaluating them when the plan is built.
+	MaterializeScalarSubquery bool
+
+	// WildcardRowID can be set to true to expand `*` with the hidden `_rowid` column of the tables without an integer
+	// primary key, so the rows can be identified by it.
+	WildcardRowID bool
+
+	// StableSort can be set to true to break the ties of ORDER BY by the row handles, so the rows with equal keys are
+	// returned in the same order.
+	StableSort bool
+
 	// CurrInsertValues is used to record current ValuesExpr's values.
 	// See http://dev.mysql.com/doc/refman/5.7/en/miscellaneous-functions.html#function_values
 	CurrInsertValues interface{}
@@ -213,6 +241,10 @@ type SessionVars struct {
 	// MaxRowCountForINLJ defines max row count that the outer table of index nested loop join could be without force hint.
 	MaxRowCountForINLJ int
 
+	// MaxSubqueryDepth is the max nesting level of the SELECTs in a statement, including the subqueries and the derived
+	// tables.
+	MaxSubqueryDepth int
+
 	// CBO indicates if we use new planner with cbo.
 	CBO bool
 }
@@ -230,6 +262,7 @@ func NewSessionVars() *SessionVars {
 		Status:                     mysql.ServerStatusAutocommit,
 		StmtCtx:                    new(StatementContext),
 		AllowAggPushDown:           true,
+		AllowPropagateConstant:     DefOptPropagateConstant,
 		BuildStatsConcurrencyVar:   DefBuildStatsConcurrency,
 		IndexJoinBatchSize:         DefIndexJoinBatchSize,
 		IndexLookupSize:            DefIndexLookupSize,
@@ -237,6 +270,7 @@ func NewSessionVars() *SessionVars {
 		IndexSerialScanConcurrency: DefIndexSerialScanConcurrency,
 		DistSQLScanConcurrency:     DefDistSQLScanConcurrency,
 		MaxRowCountForINLJ:         DefMaxRowCountForINLJ,
+		MaxSubqueryDepth:           DefMaxSubqueryDepth,
 		CBO:                        true,
 	}
 }
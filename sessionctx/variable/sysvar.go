@@ -601,6 +601,14 @@ var defaultSysVars = []*SysVar{
 	{ScopeSession, TiDBSkipConstraintCheck, "0"},
 	{ScopeSession, TiDBOptAggPushDown, boolToIntStr(DefOptAggPushDown)},
 	{ScopeSession, TiDBOptInSubqUnFolding, boolToIntStr(DefOptInSubqUnfolding)},
+	{ScopeSession, TiDBOptSkipRowHandle, boolToIntStr(DefOptSkipRowHandle)},
+	{ScopeSession, TiDBOptNoCartesianJoin, boolToIntStr(DefOptNoCartesianJoin)},
+	{ScopeSession, TiDBOptRejectImplicitConversion, boolToIntStr(DefOptRejectImplicitConversion)},
+	{ScopeSession, TiDBOptWarnNondeterministicLimit, boolToIntStr(DefOptWarnNondeterministicLimit)},
+	{ScopeSession, TiDBOptMaterializeScalarSubquery, boolToIntStr(DefOptMaterializeScalarSubquery)},
+	{ScopeSession, TiDBOptWildcardRowID, boolToIntStr(DefOptWildcardRowID)},
+	{ScopeSession, TiDBOptStableSort, boolToIntStr(DefOptStableSort)},
+	{ScopeSession, TiDBOptPropagateConstant, boolToIntStr(DefOptPropagateConstant)},
 	{ScopeSession, TiDBBuildStatsConcurrency, strconv.Itoa(DefBuildStatsConcurrency)},
 	{ScopeGlobal | ScopeSession, TiDBDistSQLScanConcurrency, strconv.Itoa(DefDistSQLScanConcurrency)},
 	{ScopeGlobal | ScopeSession, TiDBIndexJoinBatchSize, strconv.Itoa(DefIndexJoinBatchSize)},
@@ -608,6 +616,7 @@ var defaultSysVars = []*SysVar{
 	{ScopeGlobal | ScopeSession, TiDBIndexLookupConcurrency, strconv.Itoa(DefIndexLookupConcurrency)},
 	{ScopeGlobal | ScopeSession, TiDBIndexSerialScanConcurrency, strconv.Itoa(DefIndexSerialScanConcurrency)},
 	{ScopeGlobal | ScopeSession, TiDBMaxRowCountForINLJ, strconv.Itoa(DefMaxRowCountForINLJ)},
+	{ScopeSession, TiDBMaxSubqueryDepth, strconv.Itoa(DefMaxSubqueryDepth)},
 	{ScopeGlobal | ScopeSession, TiDBCBO, "ON"},
 	{ScopeGlobal | ScopeSession, TiDBSkipUTF8Check, boolToIntStr(DefSkipUTF8Check)},
 	{ScopeSession, TiDBBatchInsert, boolToIntStr(DefBatchInsert)},
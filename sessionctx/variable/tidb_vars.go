@@ -44,6 +44,42 @@ const (
 	// tidb_opt_insubquery_unfold is used to enable/disable the optimizer rule of in subquery unfold.
 	TiDBOptInSubqUnFolding = "tidb_opt_insubquery_unfold"
 
+	// tidb_opt_skip_row_handle is used to enable/disable reading the tables that are not modified in the current
+	// transaction without the row handle. If it is on, a statement that never locks or modifies rows doesn't
+	// materialize the handle column and the union scan for such tables, which narrows the width of the scan.
+	TiDBOptSkipRowHandle = "tidb_opt_skip_row_handle"
+
+	// tidb_opt_no_cartesian_join is used to reject the statement that joins two tables without any join condition.
+	// It catches the dropped join predicate at plan time, an explicit CROSS JOIN is still allowed.
+	TiDBOptNoCartesianJoin = "tidb_opt_no_cartesian_join"
+
+	// tidb_opt_reject_implicit_conversion is used to reject the statement that compares a string with a number in its
+	// WHERE, HAVING or ON conditions. Such a comparison converts both sides to floating point numbers implicitly, so
+	// it can't use the index of the string column and may match unexpected strings, e.g. '1abc' = 1.
+	TiDBOptRejectImplicitConversion = "tidb_opt_reject_implicit_conversion"
+
+	// tidb_opt_warn_nondeterministic_limit is used to warn the LIMIT whose ties are broken arbitrarily, i.e. there is no
+	// ORDER BY or the ORDER BY doesn't include a unique key, so the returned rows may differ between executions.
+	TiDBOptWarnNondeterministicLimit = "tidb_opt_warn_nondeterministic_limit"
+
+	// tidb_opt_materialize_scalar_subquery is used to evaluate the uncorrelated scalar subqueries of the select fields
+	// at execution time, once per statement, rather than when the plan is built.
+	TiDBOptMaterializeScalarSubquery = "tidb_opt_materialize_scalar_subquery"
+
+	// tidb_opt_wildcard_rowid is used to include the hidden `_rowid` column in the expansion of `*`, for the tables
+	// whose row handle isn't an integer primary key column. The handle identifies the row stably, which serves the
+	// ETL tools copying the rows incrementally. It's not MySQL compatible, so it's off by default.
+	TiDBOptWildcardRowID = "tidb_opt_wildcard_rowid"
+
+	// tidb_opt_stable_sort is used to append the row handles of the tables to ORDER BY as the final sort keys, which
+	// makes the order of the rows with equal keys deterministic for the applications relying on it. The sort isn't
+	// changed if the handles aren't available, e.g. for an aggregation, and a warning is returned.
+	TiDBOptStableSort = "tidb_opt_stable_sort"
+
+	// tidb_opt_propagate_constant is used to enable/disable deriving the predicates by the constants of the equal
+	// conditions, e.g. `b = 5` from `a = 5 AND b = a`.
+	TiDBOptPropagateConstant = "tidb_opt_propagate_constant"
+
 	// tidb_build_stats_concurrency is used to speed up the ANALYZE statement, when a table has multiple indices,
 	// those indices can be scanned concurrently, with the cost of higher system performance impact.
 	TiDBBuildStatsConcurrency = "tidb_build_stats_concurrency"
@@ -97,22 +133,35 @@ const (
 	// After the row count of the inner table is accurate, this variable will be removed.
 	TiDBMaxRowCountForINLJ = "tidb_max_row_count_for_inlj"
 
+	// tidb_max_subquery_depth is the max nesting level of the SELECTs in a statement, a statement nesting deeper is
+	// rejected when its plan is built, before the recursive building runs out of the stack.
+	TiDBMaxSubqueryDepth = "tidb_max_subquery_depth"
+
 	// tidb_cbo uses new planner with cost based optimizer.
 	TiDBCBO = "tidb_cbo"
 )
 
 // Default TiDB system variable values.
 const (
-	DefIndexLookupConcurrency     = 4
-	DefIndexSerialScanConcurrency = 1
-	DefIndexJoinBatchSize         = 25000
-	DefIndexLookupSize            = 20000
-	DefDistSQLScanConcurrency     = 10
-	DefBuildStatsConcurrency      = 4
-	DefMaxRowCountForINLJ         = 128
-	DefSkipUTF8Check              = false
-	DefOptAggPushDown             = true
-	DefOptInSubqUnfolding         = false
-	DefBatchInsert                = false
-	DefCurretTS                   = 0
+	DefIndexLookupConcurrency       = 4
+	DefIndexSerialScanConcurrency   = 1
+	DefIndexJoinBatchSize           = 25000
+	DefIndexLookupSize              = 20000
+	DefDistSQLScanConcurrency       = 10
+	DefBuildStatsConcurrency        = 4
+	DefMaxRowCountForINLJ           = 128
+	DefMaxSubqueryDepth             = 63
+	DefSkipUTF8Check                = false
+	DefOptAggPushDown               = true
+	DefOptInSubqUnfolding           = false
+	DefOptSkipRowHandle             = false
+	DefOptNoCartesianJoin           = false
+	DefOptRejectImplicitConversion  = false
+	DefOptWarnNondeterministicLimit = false
+	DefOptMaterializeScalarSubquery = false
+	DefOptWildcardRowID             = false
+	DefOptStableSort                = false
+	DefOptPropagateConstant         = true
+	DefBatchInsert                  = false
+	DefCurretTS                     = 0
 )
@@ -130,8 +130,24 @@ func SetSessionSystemVar(vars *variable.SessionVars, name string, value types.Da
 		vars.SkipUTF8Check = tidbOptOn(sVal)
 	case variable.TiDBOptAggPushDown:
 		vars.AllowAggPushDown = tidbOptOn(sVal)
+	case variable.TiDBOptPropagateConstant:
+		vars.AllowPropagateConstant = tidbOptOn(sVal)
 	case variable.TiDBOptInSubqUnFolding:
 		vars.AllowInSubqueryUnFolding = tidbOptOn(sVal)
+	case variable.TiDBOptSkipRowHandle:
+		vars.AllowSkipRowHandle = tidbOptOn(sVal)
+	case variable.TiDBOptNoCartesianJoin:
+		vars.NoCartesianJoin = tidbOptOn(sVal)
+	case variable.TiDBOptRejectImplicitConversion:
+		vars.RejectImplicitConversion = tidbOptOn(sVal)
+	case variable.TiDBOptWarnNondeterministicLimit:
+		vars.WarnNondeterministicLimit = tidbOptOn(sVal)
+	case variable.TiDBOptMaterializeScalarSubquery:
+		vars.MaterializeScalarSubquery = tidbOptOn(sVal)
+	case variable.TiDBOptWildcardRowID:
+		vars.WildcardRowID = tidbOptOn(sVal)
+	case variable.TiDBOptStableSort:
+		vars.StableSort = tidbOptOn(sVal)
 	case variable.TiDBIndexLookupConcurrency:
 		vars.IndexLookupConcurrency = tidbOptPositiveInt(sVal, variable.DefIndexLookupConcurrency)
 	case variable.TiDBIndexJoinBatchSize:
@@ -146,6 +162,8 @@ func SetSessionSystemVar(vars *variable.SessionVars, name string, value types.Da
 		vars.BatchInsert = tidbOptOn(sVal)
 	case variable.TiDBMaxRowCountForINLJ:
 		vars.MaxRowCountForINLJ = tidbOptPositiveInt(sVal, variable.DefMaxRowCountForINLJ)
+	case variable.TiDBMaxSubqueryDepth:
+		vars.MaxSubqueryDepth = tidbOptPositiveInt(sVal, variable.DefMaxSubqueryDepth)
 	case variable.TiDBCBO:
 		vars.CBO = tidbOptOn(sVal)
 	case variable.TiDBCurrentTS:
@@ -156,6 +156,13 @@ func (s *testVarsutilSuite) TestVarsutil(c *C) {
 	c.Assert(v.MaxRowCountForINLJ, Equals, 128)
 	SetSessionSystemVar(v, variable.TiDBMaxRowCountForINLJ, types.NewStringDatum("127"))
 	c.Assert(v.MaxRowCountForINLJ, Equals, 127)
+
+	// Test case for tidb_max_subquery_depth.
+	c.Assert(v.MaxSubqueryDepth, Equals, 63)
+	SetSessionSystemVar(v, variable.TiDBMaxSubqueryDepth, types.NewStringDatum("8"))
+	c.Assert(v.MaxSubqueryDepth, Equals, 8)
+	SetSessionSystemVar(v, variable.TiDBMaxSubqueryDepth, types.NewStringDatum("0"))
+	c.Assert(v.MaxSubqueryDepth, Equals, 63)
 }
 
 type mockGlobalAccessor struct {